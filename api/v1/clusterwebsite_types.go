@@ -0,0 +1,95 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterWebsiteLabel is set on the Websites generated for a ClusterWebsite to the name
+// of that ClusterWebsite.
+const ClusterWebsiteLabel = "dev.mvasilenko.me/cluster-website"
+
+// ClusterWebsiteSpec defines the desired state of ClusterWebsite
+type ClusterWebsiteSpec struct {
+	// NamespaceSelector selects the namespaces the website is deployed into. An empty
+	// selector selects every namespace. Ignored when TargetNamespace is set.
+	// +optional
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+
+	// TargetNamespace deploys the website into this namespace only, such as a namespace
+	// of its own per customer
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+
+	// CreateNamespace has the operator create the target namespace when it does not exist.
+	// The namespaces the operator created are deleted, with everything in them, along with
+	// the ClusterWebsite or once it targets another namespace.
+	// +optional
+	CreateNamespace bool `json:"createNamespace,omitempty"`
+
+	// NamespaceLabels are set on the namespace the operator creates, such as the labels
+	// of the Pod Security Standards
+	// +optional
+	NamespaceLabels map[string]string `json:"namespaceLabels,omitempty"`
+
+	// Template is the spec of the Website created in each selected namespace
+	Template WebsiteSpec `json:"template"`
+}
+
+// ClusterWebsiteStatus defines the observed state of ClusterWebsite
+type ClusterWebsiteStatus struct {
+	// Namespaces lists the namespaces a Website has been created in
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// NamespaceCount is the number of namespaces a Website has been created in
+	// +optional
+	NamespaceCount int32 `json:"namespaceCount,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Image Tag",type=string,JSONPath=`.spec.template.imageTag`
+//+kubebuilder:printcolumn:name="Namespaces",type=integer,JSONPath=`.status.namespaceCount`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ClusterWebsite is the Schema for the clusterwebsites API. It deploys the same website
+// into every namespace matching its selector.
+type ClusterWebsite struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterWebsiteSpec   `json:"spec,omitempty"`
+	Status ClusterWebsiteStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterWebsiteList contains a list of ClusterWebsite
+type ClusterWebsiteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterWebsite `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterWebsite{}, &ClusterWebsiteList{})
+}
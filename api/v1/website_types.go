@@ -17,9 +17,120 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+const (
+	// PausedAnnotation, when set to "true" on a Website, stops the operator from
+	// reconciling it until the annotation is removed or set to any other value.
+	PausedAnnotation = "dev.mvasilenko.me/paused"
+
+	// ContentChecksumAnnotation holds a checksum of the Website content on the pod template,
+	// so that content changes roll the pods.
+	ContentChecksumAnnotation = "dev.mvasilenko.me/content-checksum"
+
+	// RedeployAnnotation holds an opaque value (usually a timestamp) that is copied onto
+	// the pod template, so changing it rolls every pod of the website.
+	RedeployAnnotation = "dev.mvasilenko.me/redeploy-at"
+
+	// ApprovedImageAnnotation approves the rollout of the image it holds, for Websites
+	// whose rollouts require approval.
+	ApprovedImageAnnotation = "dev.mvasilenko.me/approved-image"
+
+	// PromoteAnnotation, set to <from>=<to> such as staging=production, copies the image
+	// tag of an environment of a Website to another. The operator removes it once done.
+	PromoteAnnotation = "dev.mvasilenko.me/promote"
+)
+
+const (
+	// ConditionFailed reports that reconciliation of the website keeps failing. Its reason
+	// is the reason of the underlying error, or ReasonReconcileError when it has none.
+	ConditionFailed      = "Failed"
+	ReasonReconcileError = "ReconcileError"
+
+	// ConditionAvailable and ConditionProgressing mirror the conditions of the same types
+	// of the website workload
+	ConditionAvailable   = "Available"
+	ConditionProgressing = "Progressing"
+
+	// ConditionImageVerified reports whether the signature of the website image is valid
+	ConditionImageVerified = "ImageVerified"
+
+	// ReasonVerified and ReasonVerificationFailed are the reasons of the ImageVerified condition
+	ReasonVerified           = "Verified"
+	ReasonVerificationFailed = "VerificationFailed"
+
+	// ConditionImageScanned reports whether the website image passed its vulnerability scan
+	ConditionImageScanned = "ImageScanned"
+
+	// ReasonScanning, ReasonScanPassed, ReasonVulnerabilitiesFound and ReasonScanFailed are
+	// the reasons of the ImageScanned condition
+	ReasonScanning             = "Scanning"
+	ReasonScanPassed           = "Passed"
+	ReasonVulnerabilitiesFound = "VulnerabilitiesFound"
+	ReasonScanFailed           = "ScanFailed"
+
+	// ConditionImagePlatforms reports whether the website image is available for every
+	// platform of the website
+	ConditionImagePlatforms = "ImagePlatforms"
+
+	// ReasonPlatformsAvailable, ReasonPlatformsMissing and ReasonPlatformCheckFailed are
+	// the reasons of the ImagePlatforms condition
+	ReasonPlatformsAvailable  = "PlatformsAvailable"
+	ReasonPlatformsMissing    = "PlatformsMissing"
+	ReasonPlatformCheckFailed = "PlatformCheckFailed"
+
+	// ConditionPendingRollout reports that a new image waits for the rollout window of the
+	// website to open. ReasonOutsideRolloutWindow is its reason.
+	ConditionPendingRollout    = "PendingRollout"
+	ReasonOutsideRolloutWindow = "OutsideRolloutWindow"
+	// ReasonAwaitingApproval reports that a new image waits for its approval
+	ReasonAwaitingApproval = "AwaitingApproval"
+
+	// ConditionAvailabilityTargetMet reports whether the website meets its availability
+	// target over every window
+	ConditionAvailabilityTargetMet = "AvailabilityTargetMet"
+
+	// ReasonTargetMet and ReasonTargetMissed are the reasons of the AvailabilityTargetMet
+	// condition
+	ReasonTargetMet    = "TargetMet"
+	ReasonTargetMissed = "TargetMissed"
+
+	// ConditionCertificateExpiring reports whether the certificate the website is served
+	// with expires soon
+	ConditionCertificateExpiring = "CertificateExpiring"
+
+	// ReasonCertificateValid, ReasonExpiringSoon and ReasonExpired are the reasons of the
+	// CertificateExpiring condition
+	ReasonCertificateValid = "Valid"
+	ReasonExpiringSoon     = "ExpiringSoon"
+	ReasonExpired          = "Expired"
+
+	// ConditionDNSMisconfigured reports whether the host of the website resolves to
+	// addresses other than the ones of its load balancer
+	ConditionDNSMisconfigured = "DNSMisconfigured"
+
+	// ReasonRecordsMatch, ReasonRecordsMismatch and ReasonLookupFailed are the reasons of
+	// the DNSMisconfigured condition
+	ReasonRecordsMatch    = "RecordsMatch"
+	ReasonRecordsMismatch = "RecordsMismatch"
+	ReasonLookupFailed    = "LookupFailed"
+)
+
+// BackendTLSPortName and DefaultBackendTLSPort are the name and default container port of
+// the port nginx serves TLS on with BackendTLS.
+const (
+	BackendTLSPortName          = "https"
+	DefaultBackendTLSPort int32 = 8443
+)
+
+// DefaultReplicas is the number of pods run for a website that does not set its replicas.
+const DefaultReplicas int32 = 2
+
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
@@ -28,19 +139,1944 @@ type WebsiteSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
+	// ClassName names the WebsiteClass the website takes its defaults from. Defaults to the
+	// class marked as default, if any.
+	// +optional
+	ClassName string `json:"className,omitempty"`
+
+	// DeploymentName overrides the name of the Deployment, or Argo Rollout, running the
+	// website pods, e.g. to take over an existing workload. Defaults to the website name.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	DeploymentName string `json:"deploymentName,omitempty"`
+
+	// ServiceName overrides the name of the Service of the website, and of its headless
+	// Service, which is named after it. Defaults to the website name.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=54
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
+
+	// ImageRepository is the repository of the website image. Defaults to the one of the
+	// website class, or abangser/todo-local-storage.
+	// +optional
+	ImageRepository string `json:"imageRepository,omitempty"`
+
 	// ImageTag will be used to set the container image for the website to deploy
 	//+kubebuilder:validation:Pattern=`^[-a-z0-9]*$`
 	ImageTag string `json:"imageTag"`
+
+	// ImageDigest pins the website image to a digest, taking precedence over ImageTag
+	// +kubebuilder:validation:Pattern=`^sha256:[a-f0-9]{64}$`
+	// +optional
+	ImageDigest string `json:"imageDigest,omitempty"`
+
+	// ImageTagConstraint has the operator deploy the newest tag of the image repository
+	// satisfying a semantic version constraint, such as ~1.4 or ^2, in place of ImageTag.
+	// The registry is checked for new tags every 15 minutes, and the tag is deployed by
+	// its digest. ImageTag is deployed until a tag satisfies the constraint.
+	// +optional
+	ImageTagConstraint string `json:"imageTagConstraint,omitempty"`
+
+	// ImagePullPolicy of the website image. Defaults to Always for the latest tag and
+	// IfNotPresent otherwise, like the API server does.
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// ImageVerification requires the website image to carry a valid cosign signature.
+	// Images failing verification are not rolled out.
+	// +optional
+	ImageVerification *ImageVerificationSpec `json:"imageVerification,omitempty"`
+
+	// VulnerabilityScan scans new images before they are rolled out. Images with
+	// vulnerabilities at or above the severity threshold are not rolled out.
+	// +optional
+	VulnerabilityScan *VulnerabilityScanSpec `json:"vulnerabilityScan,omitempty"`
+
+	// FromSnapshot restores the website from a WebsiteSnapshot in its namespace: while
+	// set, the website runs with the spec, image digest and content captured by the
+	// snapshot instead of the rest of this spec
+	// +optional
+	FromSnapshot string `json:"fromSnapshot,omitempty"`
+
+	// Resources of the website container. Defaults to the ones of the website class.
+	// Huge pages and extended resources, such as GPUs, must be set in the limits and are
+	// checked against what the nodes the website may run on can allocate.
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Replicas is the number of website pods. Defaults to 2, which is only set when the
+	// workload is created so that it can be scaled by hand, while an explicit number is
+	// kept in sync.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Command overrides the entrypoint of the website image
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// Args overrides the arguments passed to the entrypoint of the website image, such as
+	// flags setting a custom document root or port
+	// +optional
+	Args []string `json:"args,omitempty"`
+
+	// SecurityContext holds the pod-level security settings of the website pods. Defaults
+	// to the one of the website class.
+	// +optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+
+	// ContainerSecurityContext holds the security settings of the nginx website container,
+	// overriding the pod-level ones it shares with them
+	// +optional
+	ContainerSecurityContext *corev1.SecurityContext `json:"containerSecurityContext,omitempty"`
+
+	// SeccompProfile is the seccomp profile of the pods generated for the website. Defaults
+	// to the one of SecurityContext, or to RuntimeDefault.
+	// +optional
+	SeccompProfile *corev1.SeccompProfile `json:"seccompProfile,omitempty"`
+
+	// AppArmorProfile is the AppArmor profile of the containers of the website pods.
+	// Container runtimes apply their default profile when unset. It is not set explicitly,
+	// as nodes without AppArmor refuse to run pods asking for a profile.
+	// +optional
+	AppArmorProfile *AppArmorProfile `json:"appArmorProfile,omitempty"`
+
+	// ReadOnlyRootFilesystem runs the nginx website container with a read-only root
+	// filesystem, writing only to the cache volume. Defaults to true; the
+	// readOnlyRootFilesystem of ContainerSecurityContext takes precedence when set.
+	// +optional
+	ReadOnlyRootFilesystem *bool `json:"readOnlyRootFilesystem,omitempty"`
+
+	// SidecarSecurityContexts hold the security settings of the sidecars generated by the
+	// operator. Contexts of sidecars the website does not run are ignored.
+	// +listType=map
+	// +listMapKey=container
+	// +optional
+	SidecarSecurityContexts []SidecarSecurityContext `json:"sidecarSecurityContexts,omitempty"`
+
+	// Ingress exposes the website through an Ingress
+	// +optional
+	Ingress *IngressSpec `json:"ingress,omitempty"`
+
+	// DNS registers the host of the website against the address of its load balancer
+	// +optional
+	DNS *DNSSpec `json:"dns,omitempty"`
+
+	// Ports lists the ports the website container listens on and exposes through its
+	// Service. Defaults to a single "http" port 80.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Ports []WebsitePort `json:"ports,omitempty"`
+
+	// Service configures the Services generated for the website
+	// +optional
+	Service *ServiceSpec `json:"service,omitempty"`
+
+	// HostAliases are added to the hosts file of every website pod
+	// +optional
+	HostAliases []corev1.HostAlias `json:"hostAliases,omitempty"`
+
+	// RuntimeClassName is the RuntimeClass the website pods run with, such as a gVisor or
+	// Kata Containers sandbox for untrusted websites. Defaults to the cluster default runtime.
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	RuntimeClassName *string `json:"runtimeClassName,omitempty"`
+
+	// HostNetwork runs the website pods in the network namespace of their node, so that
+	// every port is served on the node addresses. Only one website pod can then run per node.
+	// +optional
+	HostNetwork bool `json:"hostNetwork,omitempty"`
+
+	// DNSPolicy sets the DNS policy of the website pods. Defaults to ClusterFirst, or to
+	// ClusterFirstWithHostNet with hostNetwork.
+	// +kubebuilder:validation:Enum=ClusterFirstWithHostNet;ClusterFirst;Default;None
+	// +optional
+	DNSPolicy corev1.DNSPolicy `json:"dnsPolicy,omitempty"`
+
+	// DNSConfig specifies DNS parameters of the website pods in addition to those generated from DNSPolicy
+	// +optional
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	// TerminationGracePeriodSeconds is how long website pods are given to shut down
+	// before they are killed. Defaults to 30 seconds.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+
+	// PreStopSleepSeconds, when set, makes the nginx container wait this long before
+	// gracefully draining its connections on shutdown, giving load balancers time to
+	// stop sending new requests. It must be shorter than the termination grace period.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PreStopSleepSeconds *int32 `json:"preStopSleepSeconds,omitempty"`
+
+	// ReadinessGates are extra conditions that must be true before website pods are
+	// considered ready, e.g. load balancer target registration
+	// +optional
+	ReadinessGates []corev1.PodReadinessGate `json:"readinessGates,omitempty"`
+
+	// Content serves the website files from a ConfigMap or Secret instead of the files
+	// baked into the image
+	// +optional
+	Content *ContentSpec `json:"content,omitempty"`
+
+	// CacheVolume configures the volume nginx writes its cache, temporary files and pid
+	// file to. Websites with a read-only root filesystem get one by default.
+	// +optional
+	CacheVolume *CacheVolumeSpec `json:"cacheVolume,omitempty"`
+
+	// Volumes are added to the website pods, for VolumeMounts to mount. They are passed
+	// through to the pods as they are, and validated by the API server when the pods are
+	// created.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Type=array
+	// +optional
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// VolumeMounts mount Volumes into the containers of the website pods
+	// +optional
+	VolumeMounts []WebsiteVolumeMount `json:"volumeMounts,omitempty"`
+
+	// ServiceAccountToken configures the service account tokens mounted into the website
+	// container, for websites authenticating to in-cluster APIs
+	// +optional
+	ServiceAccountToken *ServiceAccountTokenSpec `json:"serviceAccountToken,omitempty"`
+
+	// BackendTLS makes nginx serve TLS itself, so that traffic stays encrypted between the
+	// Ingress and the website pods
+	// +optional
+	BackendTLS *BackendTLSSpec `json:"backendTLS,omitempty"`
+
+	// ErrorPages serves branded error pages from a ConfigMap instead of the nginx ones
+	// +optional
+	ErrorPages *ErrorPagesSpec `json:"errorPages,omitempty"`
+
+	// Compression configures the compression of the responses of nginx
+	// +optional
+	Compression *CompressionSpec `json:"compression,omitempty"`
+
+	// Caching sets the Cache-Control header of the responses of the website by path
+	// +optional
+	Caching *CachingSpec `json:"caching,omitempty"`
+
+	// RateLimit limits the rate of requests each client IP address can make
+	// +optional
+	RateLimit *RateLimitSpec `json:"rateLimit,omitempty"`
+
+	// SecurityHeaders overrides the security headers nginx adds to every response
+	// +optional
+	SecurityHeaders *SecurityHeadersSpec `json:"securityHeaders,omitempty"`
+
+	// Redirects answer the requests they match with a redirect, in order, before anything
+	// else is served
+	// +optional
+	Redirects []Redirect `json:"redirects,omitempty"`
+
+	// Rewrites change the path of the requests they match, in order, before the content
+	// is looked up
+	// +optional
+	Rewrites []Rewrite `json:"rewrites,omitempty"`
+
+	// TrailingSlash redirects paths to the form with, or without, a trailing slash. Add
+	// only applies to paths without a file extension.
+	// +kubebuilder:validation:Enum=Add;Remove
+	// +optional
+	TrailingSlash TrailingSlashPolicy `json:"trailingSlash,omitempty"`
+
+	// Mesh configures the service mesh sidecar of the website pods. The injection setting
+	// of the namespace applies when unset.
+	// +optional
+	Mesh *MeshSpec `json:"mesh,omitempty"`
+
+	// NetworkPolicy restricts the traffic of the website pods with a NetworkPolicy
+	// +optional
+	NetworkPolicy *NetworkPolicySpec `json:"networkPolicy,omitempty"`
+
+	// Logging ships the access logs of the website with a fluent-bit sidecar
+	// +optional
+	Logging *LoggingSpec `json:"logging,omitempty"`
+
+	// Build builds the website content from a source repository with a static site
+	// generator such as Hugo or Jekyll. The website is only rolled out to a new build once
+	// it succeeded.
+	// +optional
+	Build *BuildSpec `json:"build,omitempty"`
+
+	// Monitoring configures the observability resources generated for the website
+	// +optional
+	Monitoring *MonitoringSpec `json:"monitoring,omitempty"`
+
+	// ExternalSecrets generates External Secrets Operator ExternalSecrets, whose resulting
+	// Secrets are injected into the website container, keeping credentials out of Git
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	ExternalSecrets []ExternalSecretSpec `json:"externalSecrets,omitempty"`
+
+	// Vault makes secrets from HashiCorp Vault available to the website at runtime
+	// +optional
+	Vault *VaultSpec `json:"vault,omitempty"`
+
+	// Affinity sets the scheduling constraints of the website pods. Defaults to preferring
+	// to spread the replicas across nodes.
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// NodeSelector restricts the website pods to the nodes with these labels, e.g. the
+	// pool of edge nodes a DaemonSet serves the website on
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Platforms restricts the website pods to the nodes running one of these operating
+	// systems and CPU architectures
+	// +listType=atomic
+	// +optional
+	Platforms []Platform `json:"platforms,omitempty"`
+
+	// VerifyPlatforms holds back an image until its registry lists every platform of
+	// Platforms for it
+	// +optional
+	VerifyPlatforms bool `json:"verifyPlatforms,omitempty"`
+
+	// Autoscaling configures the autoscalers generated for the website
+	// +optional
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+
+	// Eviction controls whether autoscalers and node drains may evict the website pods
+	// +optional
+	Eviction *EvictionSpec `json:"eviction,omitempty"`
+
+	// ABTest routes the requests matching its rules to variants of the website running
+	// other images. It relies on ingress-nginx canary annotations and requires an Ingress.
+	// +optional
+	ABTest *ABTestSpec `json:"abTest,omitempty"`
+
+	// WorkloadType is the kind of workload running the website pods. Defaults to Deployment.
+	// +optional
+	WorkloadType WorkloadType `json:"workloadType,omitempty"`
+
+	// Rollout configures how new versions of the website are rolled out
+	// +optional
+	Rollout *RolloutSpec `json:"rollout,omitempty"`
+
+	// RolloutWindow restricts when new images are rolled out. An image changed outside of
+	// the window waits for it to open, other changes are applied right away.
+	// +optional
+	RolloutWindow *RolloutWindowSpec `json:"rolloutWindow,omitempty"`
+
+	// Clusters lists remote clusters the website is deployed to, in addition to the
+	// cluster of the Website itself
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Clusters []ClusterTarget `json:"clusters,omitempty"`
+
+	// Environments deploys the website once per environment, such as staging and
+	// production, each as a Website named <website>-<environment> copying this spec with
+	// the overrides of the environment. The Website itself then runs nothing.
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=10
+	// +optional
+	Environments []EnvironmentSpec `json:"environments,omitempty"`
+}
+
+// EnvironmentSpec overrides the spec of a Website in one of its environments
+type EnvironmentSpec struct {
+	// Name of the environment, such as staging
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=20
+	Name string `json:"name"`
+
+	// ImageTag deployed in the environment. Defaults to the one of the Website.
+	// +kubebuilder:validation:Pattern=`^[-a-z0-9]*$`
+	// +optional
+	ImageTag string `json:"imageTag,omitempty"`
+
+	// Replicas of the environment. Defaults to the ones of the Website.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Host the environment is served on. Defaults to <environment>.<host of the Website>.
+	// +optional
+	Host string `json:"host,omitempty"`
+}
+
+// WebsitePort describes a port of the website container and how its Service exposes it
+type WebsitePort struct {
+	// Name of the port, unique within the website
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=15
+	Name string `json:"name"`
+
+	// ContainerPort is the port the website container listens on
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	ContainerPort int32 `json:"containerPort"`
+
+	// ServicePort is the port exposed by the Service. Defaults to ContainerPort.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	ServicePort int32 `json:"servicePort,omitempty"`
+
+	// Protocol of the port. Defaults to TCP.
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	// +optional
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+
+	// AppProtocol is the application protocol of the port, such as http, https, grpc or
+	// kubernetes.io/h2c, used by service meshes and Gateway implementations to route it.
+	// +optional
+	AppProtocol *string `json:"appProtocol,omitempty"`
+
+	// HostPort exposes the port on the node running each website pod, for clusters
+	// without a load balancer. Only one website pod can then run per node. With
+	// hostNetwork it must be empty or equal to ContainerPort.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	HostPort int32 `json:"hostPort,omitempty"`
+}
+
+// Platform is an operating system and CPU architecture website pods may run on
+type Platform struct {
+	// OS of the nodes. Defaults to linux.
+	// +kubebuilder:validation:Enum=linux;windows
+	// +optional
+	OS string `json:"os,omitempty"`
+
+	// Architecture of the nodes
+	// +kubebuilder:validation:Enum=amd64;arm64
+	Architecture string `json:"architecture"`
+}
+
+// String returns the platform in the os/architecture form used by image indexes.
+func (p Platform) String() string {
+	os := p.OS
+	if os == "" {
+		os = "linux"
+	}
+	return os + "/" + p.Architecture
+}
+
+// HeadlessMode selects whether a headless Service is generated for a Website
+// +kubebuilder:validation:Enum=None;Alongside;Only
+type HeadlessMode string
+
+const (
+	// HeadlessNone generates only the regular Service
+	HeadlessNone HeadlessMode = "None"
+	// HeadlessAlongside generates a headless Service next to the regular Service
+	HeadlessAlongside HeadlessMode = "Alongside"
+	// HeadlessOnly generates a headless Service instead of the regular Service
+	HeadlessOnly HeadlessMode = "Only"
+)
+
+// ServiceSpec configures the Services generated for a Website
+type ServiceSpec struct {
+	// Annotations are added to the generated Services, e.g. to configure cloud load
+	// balancers. Removing an annotation here removes it from the Services too.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Labels are added to the generated Services, e.g. for service meshes, without
+	// changing the labels of the pods or the Service selectors. Removing a label here
+	// removes it from the Services too.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Type of the generated Service. Defaults to NodePort.
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +optional
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// ExternalTrafficPolicy set to Local preserves the client source IP of external
+	// traffic, at the cost of only routing it to pods on the receiving node. Only valid
+	// for NodePort and LoadBalancer Services. Defaults to Cluster.
+	// +kubebuilder:validation:Enum=Cluster;Local
+	// +optional
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicyType `json:"externalTrafficPolicy,omitempty"`
+
+	// InternalTrafficPolicy set to Local routes traffic from inside the cluster only to
+	// website pods on the same node as the client. Defaults to Cluster.
+	// +kubebuilder:validation:Enum=Cluster;Local
+	// +optional
+	InternalTrafficPolicy *corev1.ServiceInternalTrafficPolicyType `json:"internalTrafficPolicy,omitempty"`
+
+	// HealthCheckNodePort pins the node port load balancers use to health check nodes
+	// of a LoadBalancer Service with the Local external traffic policy. Allocated by the
+	// cluster when unset.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	HealthCheckNodePort int32 `json:"healthCheckNodePort,omitempty"`
+
+	// NodePort pins the node port of the first port of a NodePort or LoadBalancer Service.
+	// Allocated by the cluster when unset, and reported in the status either way.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	NodePort int32 `json:"nodePort,omitempty"`
+
+	// Headless controls generation of a headless (clusterIP: None) Service named
+	// <website>-headless, for client-side load balancing. Defaults to None.
+	// +optional
+	Headless HeadlessMode `json:"headless,omitempty"`
+
+	// IPFamilyPolicy selects single-stack or dual-stack Services. Left to the cluster
+	// default when unset.
+	// +kubebuilder:validation:Enum=SingleStack;PreferDualStack;RequireDualStack
+	// +optional
+	IPFamilyPolicy *corev1.IPFamilyPolicy `json:"ipFamilyPolicy,omitempty"`
+
+	// IPFamilies lists the IP families (IPv4, IPv6) of the Services, primary first.
+	// Left to the cluster default when unset.
+	// +kubebuilder:validation:MaxItems=2
+	// +optional
+	IPFamilies []corev1.IPFamily `json:"ipFamilies,omitempty"`
+
+	// SessionAffinity routes all requests of a client to the same pod when set to ClientIP,
+	// for websites keeping sessions in memory. Defaults to None.
+	// +kubebuilder:validation:Enum=None;ClientIP
+	// +optional
+	SessionAffinity corev1.ServiceAffinity `json:"sessionAffinity,omitempty"`
+
+	// SessionAffinityTimeoutSeconds is how long a ClientIP session sticks to its pod.
+	// Defaults to 10800 (3 hours).
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	// +optional
+	SessionAffinityTimeoutSeconds *int32 `json:"sessionAffinityTimeoutSeconds,omitempty"`
+}
+
+// IngressSpec configures the Ingress generated for a Website
+type IngressSpec struct {
+	// Host the website is served on
+	Host string `json:"host"`
+
+	// Path the website is served under. Defaults to /.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// ClassName is the IngressClass of the Ingress. Defaults to the one of the website
+	// class, or to the cluster default IngressClass.
+	// +optional
+	ClassName string `json:"className,omitempty"`
+
+	// TLS terminates TLS for the host on the Ingress
+	// +optional
+	TLS *IngressTLSSpec `json:"tls,omitempty"`
+
+	// VerifyDNS resolves the host and checks that it points at the load balancer of the
+	// Ingress. The website is Degraded rather than Ready while it does not, as reported by
+	// the DNSMisconfigured condition.
+	// +optional
+	VerifyDNS bool `json:"verifyDNS,omitempty"`
+}
+
+// DNSProvider registers the DNS records of a Website
+// +kubebuilder:validation:Enum=ExternalDNS;Cloudflare;Route53
+type DNSProvider string
+
+const (
+	// DNSProviderExternalDNS generates a DNSEndpoint for the CRD source of external-dns
+	DNSProviderExternalDNS DNSProvider = "ExternalDNS"
+	// DNSProviderCloudflare manages the records through the Cloudflare API. They are
+	// removed when the Website is deleted.
+	DNSProviderCloudflare DNSProvider = "Cloudflare"
+	// DNSProviderRoute53 manages the records through the AWS Route 53 API. They are
+	// removed when the Website is deleted.
+	DNSProviderRoute53 DNSProvider = "Route53"
+)
+
+// DNSSpec configures the DNS records of a Website. Records are registered once its
+// Ingress or LoadBalancer Service has been given an address.
+type DNSSpec struct {
+	// Provider registering the records. Defaults to ExternalDNS.
+	// +optional
+	Provider DNSProvider `json:"provider,omitempty"`
+
+	// Host the records are registered for. Defaults to the host of the Ingress, and is
+	// required without one.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// RecordType of the records. Defaults to a CNAME to a load balancer with a hostname,
+	// or to A and AAAA records for its IPs.
+	// +kubebuilder:validation:Enum=A;AAAA;CNAME
+	// +optional
+	RecordType string `json:"recordType,omitempty"`
+
+	// TTL of the records in seconds. Defaults to the one of the provider.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TTL int64 `json:"ttl,omitempty"`
+
+	// Cloudflare configures the Cloudflare provider
+	// +optional
+	Cloudflare *CloudflareSpec `json:"cloudflare,omitempty"`
+
+	// Route53 configures the Route 53 provider
+	// +optional
+	Route53 *Route53Spec `json:"route53,omitempty"`
+}
+
+// Route53Spec configures the AWS Route 53 DNS provider of a Website. The name of the
+// host is claimed with a TXT record at _owner.<host>, and records of a host claimed by
+// another Website or created by hand are not touched.
+type Route53Spec struct {
+	// HostedZoneID of the hosted zone holding the host
+	HostedZoneID string `json:"hostedZoneID"`
+
+	// LoadBalancerHostedZoneID is the canonical hosted zone of the load balancer, such as
+	// Z35SXDOTRQ7X7K for classic and application load balancers in us-east-1. When set,
+	// the host is registered as an alias of the load balancer hostname, evaluating its
+	// health, instead of a CNAME.
+	// +optional
+	LoadBalancerHostedZoneID string `json:"loadBalancerHostedZoneID,omitempty"`
+
+	// CredentialsSecretName is a Secret in the Website namespace holding the
+	// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and optionally AWS_SESSION_TOKEN keys.
+	// Defaults to the credentials of the operator, such as the IAM role of its service
+	// account.
+	// +optional
+	CredentialsSecretName string `json:"credentialsSecretName,omitempty"`
+}
+
+// CloudflareSpec configures the Cloudflare DNS provider of a Website
+type CloudflareSpec struct {
+	// APITokenSecretName is a Secret in the Website namespace holding, under the apiToken
+	// key, a Cloudflare API token allowed to edit the DNS records of the zone
+	APITokenSecretName string `json:"apiTokenSecretName"`
+
+	// ZoneID of the zone holding the host. Looked up from the host when unset, which
+	// requires the token to be allowed to read the zone.
+	// +optional
+	ZoneID string `json:"zoneID,omitempty"`
+
+	// Proxied sends the traffic of the host through the Cloudflare proxy
+	// +optional
+	Proxied bool `json:"proxied,omitempty"`
+}
+
+// IngressTLSSpec configures TLS termination on the Ingress of a Website
+type IngressTLSSpec struct {
+	// SecretName is the Secret holding the certificate. Defaults to <website>-tls.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// Issuer is the cert-manager ClusterIssuer issuing the certificate. Defaults to the
+	// one of the website class. Without an issuer the Secret must be provided.
+	// +optional
+	Issuer string `json:"issuer,omitempty"`
+
+	// ACME has the operator issue and renew the certificate itself with an ACME server,
+	// for clusters without cert-manager. The http-01 challenge is answered by the website
+	// pods through the Ingress.
+	// +optional
+	ACME *ACMESpec `json:"acme,omitempty"`
+
+	// SelfSigned has the operator generate a self-signed certificate, for local and CI
+	// clusters where no issuer is available. Browsers do not trust it.
+	// +optional
+	SelfSigned bool `json:"selfSigned,omitempty"`
+}
+
+// ACMESpec configures the issuance of the certificate of a Website by the operator
+type ACMESpec struct {
+	// Email the ACME server sends expiry notices to
+	// +optional
+	Email string `json:"email,omitempty"`
+
+	// Server is the directory URL of the ACME server. Defaults to Let's Encrypt.
+	// +kubebuilder:validation:Pattern=`^https://`
+	// +optional
+	Server string `json:"server,omitempty"`
+}
+
+// ContentSpec defines where the files of a Website come from. Exactly one of
+// ConfigMapName and SecretName must be set.
+type ContentSpec struct {
+	// ConfigMapName is the name of a ConfigMap in the Website namespace whose keys are
+	// served as files
+	// +optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// SecretName is the name of a Secret in the Website namespace whose keys are served as files
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// MountPath is the directory the content is mounted at. Defaults to /usr/share/nginx/html.
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+}
+
+// ImageVerificationSpec defines how the signature of a Website image is verified.
+// Exactly one of PublicKey and Keyless must be set.
+type ImageVerificationSpec struct {
+	// PublicKey is the PEM encoded public key the image is signed with
+	// +optional
+	PublicKey string `json:"publicKey,omitempty"`
+
+	// Keyless verifies signatures made with short-lived certificates issued to an identity
+	// by the certificate authorities the operator trusts
+	// +optional
+	Keyless *KeylessVerificationSpec `json:"keyless,omitempty"`
+}
+
+// KeylessVerificationSpec is the identity keyless image signatures must be made by
+type KeylessVerificationSpec struct {
+	// Issuer is the OIDC issuer the signer authenticated with, e.g.
+	// https://token.actions.githubusercontent.com
+	Issuer string `json:"issuer"`
+
+	// Subject is the email address or URI identifying the signer
+	Subject string `json:"subject"`
+}
+
+// Scanner is a vulnerability scanner
+// +kubebuilder:validation:Enum=Trivy;Grype
+type Scanner string
+
+const (
+	// ScannerTrivy scans images with Trivy
+	ScannerTrivy Scanner = "Trivy"
+	// ScannerGrype scans images with Grype. Grype exits the same way on scan errors as on
+	// vulnerabilities, so both block the rollout.
+	ScannerGrype Scanner = "Grype"
+)
+
+// Severity is the severity of a vulnerability
+// +kubebuilder:validation:Enum=LOW;MEDIUM;HIGH;CRITICAL
+type Severity string
+
+// VulnerabilityScanSpec defines how Website images are scanned before they are rolled out
+type VulnerabilityScanSpec struct {
+	// Scanner runs the scan. Defaults to Trivy.
+	// +optional
+	Scanner Scanner `json:"scanner,omitempty"`
+
+	// SeverityThreshold is the lowest severity blocking a rollout. Defaults to CRITICAL.
+	// +optional
+	SeverityThreshold Severity `json:"severityThreshold,omitempty"`
+}
+
+// BuildSpec defines how the content of a Website is built from source
+type BuildSpec struct {
+	// Repository is the URL of the git repository holding the site sources
+	Repository string `json:"repository"`
+
+	// Revision is the branch, tag or commit built. Defaults to the default branch.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// BuilderImage runs the static site generator in the checked out repository
+	BuilderImage string `json:"builderImage"`
+
+	// Command overrides the entrypoint of the builder image
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// OutputPath is the directory, relative to the repository, the generator writes the
+	// site to. Defaults to public.
+	// +optional
+	OutputPath string `json:"outputPath,omitempty"`
+
+	// StorageClassName of the volume the built sites are published to. The volume is
+	// mounted by the build Job and every website pod, so the class must support
+	// ReadWriteMany.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// Size of the volume the built sites are published to. Defaults to 1Gi.
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+}
+
+// LogOutput is where access logs are shipped to
+// +kubebuilder:validation:Enum=Stdout;Loki;Elasticsearch
+type LogOutput string
+
+const (
+	// LogOutputStdout writes the access logs to the sidecar output as JSON lines
+	LogOutputStdout LogOutput = "Stdout"
+	// LogOutputLoki pushes the access logs to Loki
+	LogOutputLoki LogOutput = "Loki"
+	// LogOutputElasticsearch indexes the access logs in Elasticsearch
+	LogOutputElasticsearch LogOutput = "Elasticsearch"
+)
+
+// AppArmorProfileType is the kind of AppArmor profile containers run with
+// +kubebuilder:validation:Enum=RuntimeDefault;Localhost;Unconfined
+type AppArmorProfileType string
+
+const (
+	// AppArmorProfileRuntimeDefault is the default profile of the container runtime
+	AppArmorProfileRuntimeDefault AppArmorProfileType = "RuntimeDefault"
+	// AppArmorProfileLocalhost is a profile loaded on the node
+	AppArmorProfileLocalhost AppArmorProfileType = "Localhost"
+	// AppArmorProfileUnconfined runs the containers without AppArmor confinement
+	AppArmorProfileUnconfined AppArmorProfileType = "Unconfined"
+)
+
+// AppArmorProfile selects the AppArmor profile of the containers of a Website
+type AppArmorProfile struct {
+	// Type of the profile
+	Type AppArmorProfileType `json:"type"`
+
+	// LocalhostProfile is the name of the profile loaded on the node. Required for the
+	// Localhost type only.
+	// +optional
+	LocalhostProfile string `json:"localhostProfile,omitempty"`
+}
+
+// SidecarSecurityContext holds the security settings of one of the sidecars of a Website
+type SidecarSecurityContext struct {
+	// Container is the name of the sidecar
+	// +kubebuilder:validation:Enum=log-shipper;nginx-exporter;vault-agent;tls-reloader
+	Container string `json:"container"`
+
+	// SecurityContext of the sidecar
+	SecurityContext corev1.SecurityContext `json:"securityContext"`
+}
+
+// WebsiteVolumeMount mounts one of the Volumes of a Website into one of its containers
+type WebsiteVolumeMount struct {
+	// Container the volume is mounted into: the nginx website container, or one of the
+	// sidecars. Mounts into sidecars the website does not run are ignored. Defaults to nginx.
+	// +kubebuilder:validation:Enum=nginx;log-shipper;nginx-exporter;vault-agent;tls-reloader
+	// +optional
+	Container string `json:"container,omitempty"`
+
+	corev1.VolumeMount `json:",inline"`
+}
+
+// ServiceAccountTokenSpec configures the service account tokens of the pods of a Website
+type ServiceAccountTokenSpec struct {
+	// Automount mounts the API token of the service account into every container, as
+	// Kubernetes does by default. Set it to false for websites that do not talk to the API.
+	// +optional
+	Automount *bool `json:"automount,omitempty"`
+
+	// Audience of a projected token mounted into the website container. No projected
+	// token is mounted when empty.
+	// +optional
+	Audience string `json:"audience,omitempty"`
+
+	// ExpirationSeconds is how long the projected token is valid for. The kubelet
+	// refreshes it before it expires. Defaults to one hour.
+	// +kubebuilder:validation:Minimum=600
+	// +optional
+	ExpirationSeconds *int64 `json:"expirationSeconds,omitempty"`
+
+	// MountPath is the directory the projected token is mounted into, as a file named
+	// token. Defaults to /var/run/secrets/tokens.
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+}
+
+// BackendTLSSpec configures TLS served by the pods of a Website. The https port is added
+// to the website pods and Service, and the Ingress forwards to it over HTTPS. A tls-reloader
+// sidecar reloads nginx when the certificate is rotated, so the pods are not restarted. It
+// signals nginx through the process namespace the containers of the pod then share, which
+// requires both containers to run as the same user.
+type BackendTLSSpec struct {
+	// SecretName is the kubernetes.io/tls Secret holding the serving certificate. Defaults
+	// to <website>-backend-tls.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// Issuer is the cert-manager ClusterIssuer issuing the certificate for the names of
+	// the Service. Without an issuer the Secret must be provided.
+	// +optional
+	Issuer string `json:"issuer,omitempty"`
+
+	// Port nginx serves TLS on in the pods. Defaults to 8443.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+}
+
+// CompressionAlgorithm is an encoding nginx compresses responses with
+// +kubebuilder:validation:Enum=gzip;brotli
+type CompressionAlgorithm string
+
+const (
+	CompressionGzip CompressionAlgorithm = "gzip"
+	// CompressionBrotli requires an nginx image built with the ngx_brotli module
+	CompressionBrotli CompressionAlgorithm = "brotli"
+)
+
+// CompressionSpec configures the compression of the responses of a Website
+type CompressionSpec struct {
+	// Enabled turns compression on
+	Enabled bool `json:"enabled"`
+
+	// Algorithms are the encodings offered to clients. Defaults to gzip.
+	// +listType=set
+	// +optional
+	Algorithms []CompressionAlgorithm `json:"algorithms,omitempty"`
+
+	// Types are the MIME types compressed in addition to text/html. Defaults to the usual
+	// text types of static sites: CSS, JavaScript, JSON, XML, SVG and plain text.
+	// +listType=set
+	// +optional
+	Types []string `json:"types,omitempty"`
+
+	// Level is the compression level, from 1 for the fastest to 9 for the smallest
+	// responses. Defaults to 5.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=9
+	// +optional
+	Level int32 `json:"level,omitempty"`
+}
+
+// RedirectPathType is how the path of a Redirect matches requests
+// +kubebuilder:validation:Enum=Exact;Prefix
+type RedirectPathType string
+
+const (
+	RedirectPathExact RedirectPathType = "Exact"
+	// RedirectPathPrefix redirects the paths under the prefix, appending what follows the
+	// prefix to the target
+	RedirectPathPrefix RedirectPathType = "Prefix"
+)
+
+// Redirect sends the requests for a host and path elsewhere. The query string of the
+// request is kept. Redirecting a host, such as www to the apex domain, requires its
+// requests to reach the website, through an additional Ingress for instance.
+type Redirect struct {
+	// Host of the requests redirected. Defaults to any host.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Path of the requests redirected. Defaults to any path.
+	// +kubebuilder:validation:Pattern=`^/`
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// PathType is how the path matches requests. Defaults to Prefix.
+	// +optional
+	PathType RedirectPathType `json:"pathType,omitempty"`
+
+	// To is the path or URL requests are redirected to
+	// +kubebuilder:validation:Pattern=`^(/|https?://)`
+	To string `json:"to"`
+
+	// Code is the redirect status code. Defaults to 301.
+	// +kubebuilder:validation:Enum=301;302;307;308
+	// +optional
+	Code int32 `json:"code,omitempty"`
+}
+
+// Rewrite replaces the path of the requests matching a regular expression
+type Rewrite struct {
+	// Regex the request path is matched against
+	Regex string `json:"regex"`
+
+	// Replacement of the path, which can refer to the groups of the expression as $1, $2...
+	Replacement string `json:"replacement"`
+}
+
+// TrailingSlashPolicy is how paths ending, or not, with a slash are redirected
+type TrailingSlashPolicy string
+
+const (
+	TrailingSlashAdd    TrailingSlashPolicy = "Add"
+	TrailingSlashRemove TrailingSlashPolicy = "Remove"
+)
+
+// SecurityHeadersSpec overrides the values of the security headers of a Website. A header
+// left unset keeps its default, and an empty value stops the header from being sent.
+type SecurityHeadersSpec struct {
+	// StrictTransportSecurity is the HSTS header. Defaults to max-age=31536000.
+	// +optional
+	StrictTransportSecurity *string `json:"strictTransportSecurity,omitempty"`
+
+	// ContentSecurityPolicy is the CSP header. Defaults to a policy that only restricts
+	// framing, plugins and the base URL, which does not break scripts or styles:
+	// frame-ancestors 'self'; object-src 'none'; base-uri 'self'.
+	// +optional
+	ContentSecurityPolicy *string `json:"contentSecurityPolicy,omitempty"`
+
+	// FrameOptions is the X-Frame-Options header. Defaults to SAMEORIGIN.
+	// +optional
+	FrameOptions *string `json:"frameOptions,omitempty"`
+
+	// ReferrerPolicy is the Referrer-Policy header. Defaults to
+	// strict-origin-when-cross-origin.
+	// +optional
+	ReferrerPolicy *string `json:"referrerPolicy,omitempty"`
+
+	// ContentTypeOptions is the X-Content-Type-Options header. Defaults to nosniff.
+	// +optional
+	ContentTypeOptions *string `json:"contentTypeOptions,omitempty"`
+}
+
+// RateLimitPeriod is the period a request rate is expressed over
+// +kubebuilder:validation:Enum=Second;Minute
+type RateLimitPeriod string
+
+const (
+	RateLimitPerSecond RateLimitPeriod = "Second"
+	RateLimitPerMinute RateLimitPeriod = "Minute"
+)
+
+// RateLimitSpec limits the rate of requests of the clients of a Website. With an Ingress,
+// the Ingress controller limits the requests, as it is the one seeing the client addresses.
+// Otherwise nginx limits them, by the address the requests reach the pods from.
+type RateLimitSpec struct {
+	// Requests is the number of requests a client can make per period
+	// +kubebuilder:validation:Minimum=1
+	Requests int32 `json:"requests"`
+
+	// Period the requests are counted over. Defaults to Second.
+	// +optional
+	Period RateLimitPeriod `json:"period,omitempty"`
+
+	// Burst is the number of requests a client can make above the rate before being
+	// rejected with 429 Too Many Requests. The Ingress controller only supports multiples
+	// of the rate, so it is rounded up to one there.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Burst int32 `json:"burst,omitempty"`
+}
+
+// CachingSpec configures the Cache-Control header of the responses of a Website
+type CachingSpec struct {
+	// Rules are matched against the request path in order, the first matching rule sets
+	// the header. Responses matching no rule are sent without one.
+	// +kubebuilder:validation:MinItems=1
+	Rules []CacheRule `json:"rules"`
+}
+
+// CacheRule sets the Cache-Control header of the responses for the paths it matches
+type CacheRule struct {
+	// Path prefix the rule matches. Defaults to every path.
+	// +kubebuilder:validation:Pattern=`^/`
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Extensions restrict the rule to files with one of these extensions, such as css or js
+	// +optional
+	Extensions []string `json:"extensions,omitempty"`
+
+	// MaxAge is how long clients and proxies may cache the responses. Responses are
+	// revalidated on every use without one.
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+
+	// Immutable tells clients the responses never change while they are fresh, for assets
+	// with content hashes in their names
+	// +optional
+	Immutable bool `json:"immutable,omitempty"`
+}
+
+// ErrorPagesSpec configures the pages nginx answers errors with. The operator replaces
+// the default server of the nginx image with one serving the website content and these
+// pages, so that they are also used when the website serves TLS itself.
+type ErrorPagesSpec struct {
+	// ConfigMapName is the ConfigMap in the website namespace holding the HTML pages
+	ConfigMapName string `json:"configMapName"`
+
+	// Pages maps status codes to keys of the ConfigMap. Defaults to 404.html for 404, and
+	// 50x.html for 500, 502, 503 and 504, like the nginx image.
+	// +optional
+	Pages []ErrorPage `json:"pages,omitempty"`
+}
+
+// ErrorPage is the page served for a set of status codes
+type ErrorPage struct {
+	// Codes are the HTTP status codes the page is served for
+	// +kubebuilder:validation:MinItems=1
+	Codes []ErrorPageCode `json:"codes"`
+
+	// Key of the page in the ConfigMap
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	Key string `json:"key"`
+}
+
+// ErrorPageCode is an HTTP error status code
+// +kubebuilder:validation:Minimum=400
+// +kubebuilder:validation:Maximum=599
+type ErrorPageCode int32
+
+// MeshSpec configures the service mesh sidecars of the pods of a Website
+type MeshSpec struct {
+	// Istio configures the Istio sidecar
+	// +optional
+	Istio *IstioSpec `json:"istio,omitempty"`
+
+	// Linkerd configures the Linkerd proxy
+	// +optional
+	Linkerd *LinkerdSpec `json:"linkerd,omitempty"`
+}
+
+// LinkerdInjection is the value of the Linkerd injection annotation
+// +kubebuilder:validation:Enum=enabled;disabled;ingress
+type LinkerdInjection string
+
+// LinkerdSpec configures the Linkerd proxy of the pods of a Website
+type LinkerdSpec struct {
+	// Inject selects whether the Linkerd proxy is injected into the website pods, whatever
+	// the injection setting of the namespace. ingress runs it in ingress mode.
+	// The namespace setting applies when unset.
+	// +optional
+	Inject LinkerdInjection `json:"inject,omitempty"`
+
+	// SkipInboundPorts are ports whose incoming connections bypass the proxy
+	// +optional
+	SkipInboundPorts []int32 `json:"skipInboundPorts,omitempty"`
+
+	// SkipOutboundPorts are ports whose outgoing connections bypass the proxy
+	// +optional
+	SkipOutboundPorts []int32 `json:"skipOutboundPorts,omitempty"`
+
+	// OpaquePorts are ports whose traffic the proxy forwards without detecting its protocol
+	// +optional
+	OpaquePorts []int32 `json:"opaquePorts,omitempty"`
+}
+
+// IstioSpec configures the Istio sidecar of the pods of a Website
+type IstioSpec struct {
+	// Enabled injects the Istio sidecar into the website pods when true, and keeps it out
+	// when false, whatever the injection setting of the namespace. The exporter port is
+	// left out of the mesh, so that Prometheus can scrape it without a sidecar of its own.
+	Enabled bool `json:"enabled"`
+}
+
+// NetworkPolicySpec configures the NetworkPolicy generated for a Website
+type NetworkPolicySpec struct {
+	// Egress lists the destinations the website pods may connect to, such as the backend
+	// APIs they call, or the log server and Vault their sidecars talk to. DNS lookups are
+	// always allowed. Egress is not restricted when empty.
+	// +optional
+	Egress []networkingv1.NetworkPolicyEgressRule `json:"egress,omitempty"`
+}
+
+// CacheVolumeSpec configures the emptyDir volume mounted at the paths nginx writes to at
+// run time: /var/cache/nginx, /var/run and /tmp
+type CacheVolumeSpec struct {
+	// Medium backing the volume. Memory uses a tmpfs, whose content counts against the
+	// memory of the website container. Defaults to the disk of the node.
+	// +kubebuilder:validation:Enum="";Memory
+	// +optional
+	Medium corev1.StorageMedium `json:"medium,omitempty"`
+
+	// SizeLimit of the volume. A pod whose volume outgrows it is evicted.
+	// +optional
+	SizeLimit *resource.Quantity `json:"sizeLimit,omitempty"`
+}
+
+// LoggingSpec defines how the access logs of a Website are shipped. nginx writes them to
+// a volume the sidecar reads, which is not rotated: a pod is evicted once its logs
+// outgrow BufferSize.
+type LoggingSpec struct {
+	// Output is where the logs are shipped to
+	Output LogOutput `json:"output"`
+
+	// URL of Loki or Elasticsearch, e.g. http://loki.monitoring:3100
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// Index is the Elasticsearch index the logs are written to. Defaults to websites.
+	// +optional
+	Index string `json:"index,omitempty"`
+
+	// BufferSize limits the volume holding the logs. Defaults to 500Mi.
+	// +optional
+	BufferSize *resource.Quantity `json:"bufferSize,omitempty"`
+}
+
+// MonitoringSpec defines the observability resources generated for a Website
+type MonitoringSpec struct {
+	// Enabled turns on generation of monitoring resources, such as a Grafana dashboard
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Exporter runs an nginx Prometheus exporter next to the website, serving request and
+	// connection metrics on a metrics port of the Service
+	// +optional
+	Exporter bool `json:"exporter,omitempty"`
+
+	// ServiceMonitor generates a prometheus-operator ServiceMonitor scraping the exporter
+	// +optional
+	ServiceMonitor bool `json:"serviceMonitor,omitempty"`
+
+	// Probe configures the prometheus-operator Probe checking the availability of the
+	// website URL through a blackbox exporter. The Probe is generated once the website has
+	// a URL, when a blackbox exporter is set here or on the operator.
+	// +optional
+	Probe *ProbeSpec `json:"probe,omitempty"`
+
+	// Availability configures how the probe results are tracked in the status. The
+	// availability is tracked whenever the website is probed and a Prometheus server is
+	// known.
+	// +optional
+	Availability *AvailabilitySpec `json:"availability,omitempty"`
+}
+
+// AvailabilitySpec configures the availability tracking of a Website
+type AvailabilitySpec struct {
+	// Address of the Prometheus server scraping the probe. Defaults to the one of the
+	// operator.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// Windows the availability is computed over, such as 24h or 720h. Defaults to 168h.
+	// +kubebuilder:validation:MaxItems=5
+	// +optional
+	Windows []metav1.Duration `json:"windows,omitempty"`
+
+	// Target is the availability percentage the website should meet over every window,
+	// such as 99.9. The AvailabilityTargetMet condition reports whether it does.
+	// +kubebuilder:validation:Pattern=`^(100|[0-9]{1,2}(\.[0-9]+)?)$`
+	// +optional
+	Target string `json:"target,omitempty"`
+}
+
+// ProbeSpec configures the blackbox probing of a Website
+type ProbeSpec struct {
+	// ProberURL is the host and port of the blackbox exporter, such as
+	// blackbox-exporter.monitoring.svc:9115. Defaults to the one of the operator.
+	// +optional
+	ProberURL string `json:"proberURL,omitempty"`
+
+	// Module of the blackbox exporter probing the website. Defaults to http_2xx.
+	// +optional
+	Module string `json:"module,omitempty"`
+
+	// Interval between two probes. Defaults to the scrape interval of Prometheus.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// ExternalSecretSpec describes an ExternalSecret generated for a Website. The resulting
+// Secret is named <website>-<name>.
+type ExternalSecretSpec struct {
+	// Name of the ExternalSecret, unique within the website
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=56
+	Name string `json:"name"`
+
+	// SecretStoreRef names the SecretStore or ClusterSecretStore the data is fetched from
+	SecretStoreRef SecretStoreRef `json:"secretStoreRef"`
+
+	// RefreshInterval is how often the data is fetched again. Defaults to one hour.
+	// +optional
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+
+	// Data maps keys of the resulting Secret to entries of the secret store
+	// +kubebuilder:validation:MinItems=1
+	Data []ExternalSecretData `json:"data"`
+
+	// MountPath, when set, mounts the resulting Secret as files in this directory.
+	// Otherwise its keys are injected as environment variables.
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+}
+
+// SecretStoreRef references a SecretStore or ClusterSecretStore
+type SecretStoreRef struct {
+	// Name of the store
+	Name string `json:"name"`
+
+	// Kind of the store. Defaults to SecretStore.
+	// +kubebuilder:validation:Enum=SecretStore;ClusterSecretStore
+	// +optional
+	Kind string `json:"kind,omitempty"`
+}
+
+// ExternalSecretData maps a key of the resulting Secret to an entry of the secret store
+type ExternalSecretData struct {
+	// SecretKey is the key in the resulting Secret
+	SecretKey string `json:"secretKey"`
+
+	// RemoteKey is the key of the entry in the secret store
+	RemoteKey string `json:"remoteKey"`
+
+	// Property selects a single property of a structured entry
+	// +optional
+	Property string `json:"property,omitempty"`
+}
+
+// VaultMode selects how the Vault Agent is added to the website pods
+// +kubebuilder:validation:Enum=Injector;Sidecar
+type VaultMode string
+
+const (
+	// VaultInjector annotates the pods for the Vault Agent injector, which must be installed
+	VaultInjector VaultMode = "Injector"
+	// VaultSidecar adds a Vault Agent sidecar to the pods without relying on the injector
+	VaultSidecar VaultMode = "Sidecar"
+)
+
+// VaultSpec configures the Vault Agent that renders secrets into files for a Website
+type VaultSpec struct {
+	// Mode selects between the Vault Agent injector and an explicit sidecar. Defaults to Injector.
+	// +optional
+	Mode VaultMode `json:"mode,omitempty"`
+
+	// Role is the Vault Kubernetes auth role the agent logs in with
+	Role string `json:"role"`
+
+	// Address of the Vault server. Required in Sidecar mode, defaults to the injector
+	// configuration in Injector mode.
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// AuthPath is the mount path of the Kubernetes auth method. Defaults to auth/kubernetes.
+	// +optional
+	AuthPath string `json:"authPath,omitempty"`
+
+	// Image of the Vault Agent sidecar. Only used in Sidecar mode.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// MountPath is the directory secrets are rendered into. Defaults to /vault/secrets.
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+
+	// Secrets lists the secrets to render, each into a file named after it
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=name
+	Secrets []VaultSecret `json:"secrets"`
+}
+
+// VaultSecret describes a file rendered by the Vault Agent
+type VaultSecret struct {
+	// Name of the rendered file
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9]([-_.a-zA-Z0-9]*[a-zA-Z0-9])?$`
+	Name string `json:"name"`
+
+	// Path of the secret in Vault
+	Path string `json:"path"`
+
+	// Template is a Consul Template rendering the secret. Defaults to one "key: value"
+	// line per key of the secret.
+	// +optional
+	Template string `json:"template,omitempty"`
+}
+
+// AutoscalingSpec configures the autoscalers generated for a Website
+type AutoscalingSpec struct {
+	// Vertical generates a VerticalPodAutoscaler right-sizing the website container
+	// +optional
+	Vertical *VerticalAutoscalingSpec `json:"vertical,omitempty"`
+}
+
+// VerticalAutoscalingSpec configures the VerticalPodAutoscaler of a Website
+type VerticalAutoscalingSpec struct {
+	// UpdateMode is how the autoscaler applies its recommendations. Defaults to Auto.
+	// +kubebuilder:validation:Enum=Off;Initial;Recreate;Auto
+	// +optional
+	UpdateMode string `json:"updateMode,omitempty"`
+
+	// MinAllowed is the lower bound of the resources recommended for the website container
+	// +optional
+	MinAllowed corev1.ResourceList `json:"minAllowed,omitempty"`
+
+	// MaxAllowed is the upper bound of the resources recommended for the website container
+	// +optional
+	MaxAllowed corev1.ResourceList `json:"maxAllowed,omitempty"`
+}
+
+// EvictionSpec controls whether the pods of a Website may be evicted
+type EvictionSpec struct {
+	// SafeToEvict tells the cluster autoscaler whether it may evict the website pods to
+	// scale down their node. Defaults to the autoscaler's own rules.
+	// +optional
+	SafeToEvict *bool `json:"safeToEvict,omitempty"`
+
+	// Disallow protects a critical website from voluntary evictions altogether: a
+	// PodDisruptionBudget blocks drains from evicting any of its pods, and autoscalers are
+	// told not to disrupt them.
+	// +optional
+	Disallow bool `json:"disallow,omitempty"`
+}
+
+// ABTestSpec configures an A/B test of a Website
+type ABTestSpec struct {
+	// Variants run next to the website, each receiving the requests matching its rules.
+	// ingress-nginx routes to a single canary Ingress per host and path, so only one
+	// variant is supported.
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=1
+	Variants []ABVariant `json:"variants"`
+}
+
+// ABVariant is a variant of a Website in an A/B test. Exactly one of Header and Cookie
+// must be set.
+type ABVariant struct {
+	// Name of the variant, used to name its Deployment, Service and Ingress
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=20
+	Name string `json:"name"`
+
+	// ImageTag of the website image the variant runs
+	//+kubebuilder:validation:Pattern=`^[-a-z0-9]*$`
+	ImageTag string `json:"imageTag"`
+
+	// Header routes the requests carrying a header to the variant
+	// +optional
+	Header *ABHeaderMatch `json:"header,omitempty"`
+
+	// Cookie routes the requests whose cookie of this name is "always" to the variant
+	// +optional
+	Cookie string `json:"cookie,omitempty"`
+}
+
+// ABHeaderMatch matches requests on a header
+type ABHeaderMatch struct {
+	// Name of the header
+	Name string `json:"name"`
+
+	// Value the header must have. When empty, requests whose header is "always" match.
+	// +optional
+	Value string `json:"value,omitempty"`
+}
+
+// WorkloadType is the kind of workload running the pods of a Website
+// +kubebuilder:validation:Enum=Deployment;Rollout;DaemonSet
+type WorkloadType string
+
+const (
+	// WorkloadDeployment runs the website pods with a Deployment
+	WorkloadDeployment WorkloadType = "Deployment"
+	// WorkloadRollout runs the website pods with an Argo Rollouts Rollout, which then
+	// performs the rollouts itself
+	WorkloadRollout WorkloadType = "Rollout"
+	// WorkloadDaemonSet runs a website pod on every node matching the node selector, for
+	// edge serving through host ports or a node-local load balancer
+	WorkloadDaemonSet WorkloadType = "DaemonSet"
+)
+
+// RolloutProvider selects what performs progressive rollouts
+// +kubebuilder:validation:Enum=Operator;Flagger
+type RolloutProvider string
+
+const (
+	// RolloutOperator rolls canaries out with the operator itself
+	RolloutOperator RolloutProvider = "Operator"
+	// RolloutFlagger generates a Flagger Canary and leaves the rollout to Flagger, which
+	// then owns the main Service of the website
+	RolloutFlagger RolloutProvider = "Flagger"
+)
+
+// RolloutSpec configures how new versions of a Website are rolled out
+type RolloutSpec struct {
+	// Provider performs the progressive rollout. Defaults to Operator.
+	// +optional
+	Provider RolloutProvider `json:"provider,omitempty"`
+
+	// Canary shifts traffic progressively to a new image instead of replacing every pod
+	// at once. With the Operator provider it relies on ingress-nginx canary annotations
+	// and requires an Ingress.
+	// +optional
+	Canary *CanarySpec `json:"canary,omitempty"`
+
+	// ArgoStrategy is passed through as the strategy of the Argo Rollouts Rollout, e.g. a
+	// canary with analysis steps referencing AnalysisTemplates. Defaults to a canary
+	// following the steps of Canary.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +optional
+	ArgoStrategy *runtime.RawExtension `json:"argoStrategy,omitempty"`
+
+	// RequireApproval holds new images until they are approved, by setting the
+	// dev.mvasilenko.me/approved-image annotation to the image, with "websitectl approve"
+	// for instance. The image waiting for approval is reported as the pending image of the
+	// status.
+	// +optional
+	RequireApproval bool `json:"requireApproval,omitempty"`
+
+	// Analysis watches a metric while and after a new image is rolled out, and rolls back
+	// to the previous image when it breaches its threshold
+	// +optional
+	Analysis *AnalysisSpec `json:"analysis,omitempty"`
+}
+
+// AnalysisThresholdType is the side of the threshold a metric fails on
+// +kubebuilder:validation:Enum=Above;Below
+type AnalysisThresholdType string
+
+const (
+	AnalysisFailAbove AnalysisThresholdType = "Above"
+	AnalysisFailBelow AnalysisThresholdType = "Below"
+)
+
+// AnalysisSpec configures the metric analysis of the rollouts of a Website
+type AnalysisSpec struct {
+	// Address of the Prometheus server queried. Defaults to the one the operator is
+	// configured with.
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// Query is a PromQL query returning a single value, such as the error rate of the
+	// website. {{namespace}} and {{website}} are replaced by the namespace and name of the
+	// Website.
+	Query string `json:"query"`
+
+	// Threshold the value of the query is compared to, as a decimal number
+	// +kubebuilder:validation:Pattern=`^-?[0-9]+(\.[0-9]+)?$`
+	Threshold string `json:"threshold"`
+
+	// FailWhen is the side of the threshold the rollout fails on. Defaults to Above.
+	// +optional
+	FailWhen AnalysisThresholdType `json:"failWhen,omitempty"`
+
+	// Interval between two evaluations of the query. Defaults to one minute.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// Duration the new image is analysed for after its rollout started. Defaults to ten
+	// minutes.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// RolloutWindowSpec lists the windows new images of a Website may be rolled out in
+type RolloutWindowSpec struct {
+	// Windows during which rollouts are allowed
+	// +kubebuilder:validation:MinItems=1
+	Windows []RolloutWindow `json:"windows"`
+
+	// TimeZone the schedules are evaluated in, such as Europe/Berlin. Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// RolloutWindow is a recurring window of time
+type RolloutWindow struct {
+	// Schedule is a cron schedule of the opening of the window, such as "0 22 * * 1-5"
+	Schedule string `json:"schedule"`
+
+	// Duration the window stays open for, at most seven days
+	Duration metav1.Duration `json:"duration"`
+}
+
+// CanarySpec configures the canary rollout of a Website
+type CanarySpec struct {
+	// Steps are the percentages of traffic sent to the new image, in order. Once the last
+	// step has passed, the new image is promoted. Defaults to 10, 25 and 50.
+	// +optional
+	Steps []int32 `json:"steps,omitempty"`
+
+	// StepDuration is how long each step lasts at least. A step only ends once the canary
+	// pods are ready. Defaults to five minutes.
+	// +optional
+	StepDuration *metav1.Duration `json:"stepDuration,omitempty"`
+}
+
+// ClusterTarget is a remote cluster a Website is deployed to. The Deployment and Services
+// of the website are created in the namespace of the same name there, which must exist
+// along with any ConfigMap or Secret the website content references.
+type ClusterTarget struct {
+	// Name identifies the cluster in the Website status
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// KubeconfigSecretRef references a Secret in the Website namespace holding a
+	// kubeconfig for the cluster
+	KubeconfigSecretRef SecretKeyRef `json:"kubeconfigSecretRef"`
+}
+
+// SecretKeyRef references a key of a Secret in the Website namespace
+type SecretKeyRef struct {
+	// Name of the Secret
+	Name string `json:"name"`
+
+	// Key of the Secret. Defaults to "kubeconfig".
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// ClusterStatus is the state of a Website in a remote cluster
+type ClusterStatus struct {
+	// Name of the cluster
+	Name string `json:"name"`
+
+	// KubeconfigSecretRef references the kubeconfig the cluster was last reached with,
+	// so that the website can be removed from it once dropped from the spec
+	KubeconfigSecretRef SecretKeyRef `json:"kubeconfigSecretRef"`
+
+	// Synced is true when the website resources were last reconciled successfully
+	Synced bool `json:"synced"`
+
+	// ReadyReplicas is the number of ready website pods in the cluster
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Message describes why the website could not be reconciled in the cluster
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // WebsiteStatus defines the observed state of Website
 type WebsiteStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// Phase summarises the state of the website in one word
+	// +optional
+	Phase WebsitePhase `json:"phase,omitempty"`
+
+	// Conditions represent the latest available observations of the website state
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Zones lists the zones the website pods are scheduled in, with their pod counts
+	// +optional
+	Zones []PodPlacement `json:"zones,omitempty"`
+
+	// Nodes lists the nodes the website pods are scheduled on, with their pod counts
+	// +optional
+	Nodes []PodPlacement `json:"nodes,omitempty"`
+
+	// URL the website is reachable at: its Ingress host, one of the nodes running a
+	// website pod when it is exposed on host ports, the load balancer of its Service, or
+	// its Service otherwise
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// Address is the external IP or hostname of the load balancer the website is exposed
+	// through, by its Ingress or its LoadBalancer Service
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// NodePort is the node port the first port of the website Service is exposed on, for
+	// NodePort and LoadBalancer Services
+	// +optional
+	NodePort int32 `json:"nodePort,omitempty"`
+
+	// ZoneCount is the number of zones the website pods are spread over. A website is
+	// zone-redundant when it is larger than one.
+	// +optional
+	ZoneCount int32 `json:"zoneCount,omitempty"`
+
+	// PendingImage is a new image waiting to be rolled out, for its approval or for the
+	// rollout window to open. The PendingRollout condition reports what it waits for.
+	// +optional
+	PendingImage string `json:"pendingImage,omitempty"`
+
+	// Canary reports the progress of a canary rollout
+	// +optional
+	Canary *CanaryStatus `json:"canary,omitempty"`
+
+	// Analysis reports the metric analysis of the latest rollout
+	// +optional
+	Analysis *AnalysisStatus `json:"analysis,omitempty"`
+
+	// Availability reports the share of successful probes of the website URL
+	// +optional
+	Availability *AvailabilityStatus `json:"availability,omitempty"`
+
+	// Certificate reports the certificate the website is served with, when it serves TLS
+	// +optional
+	Certificate *CertificateStatus `json:"certificate,omitempty"`
+
+	// ACME reports the certificate order in progress, when the operator issues the
+	// certificate of the website
+	// +optional
+	ACME *ACMEStatus `json:"acme,omitempty"`
+
+	// DNSRecords reports where the operator registered the DNS records of the website
+	// through a provider API, so that they are removed once the DNS settings change
+	// +optional
+	DNSRecords *DNSRecordsStatus `json:"dnsRecords,omitempty"`
+
+	// ResolvedImage reports the tag the image tag constraint was last resolved to
+	// +optional
+	ResolvedImage *ResolvedImageStatus `json:"resolvedImage,omitempty"`
+
+	// Build reports the state of the content build
+	// +optional
+	Build *BuildStatus `json:"build,omitempty"`
+
+	// LastTrigger records the last redeploy requested through the trigger endpoint of
+	// the operator
+	// +optional
+	LastTrigger *TriggerStatus `json:"lastTrigger,omitempty"`
+
+	// Clusters reports the state of the website in each of its remote clusters
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Clusters []ClusterStatus `json:"clusters,omitempty"`
+
+	// Environments reports the state of the website in each of its environments
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Environments []EnvironmentStatus `json:"environments,omitempty"`
+}
+
+// EnvironmentStatus is the state of a Website in one of its environments
+type EnvironmentStatus struct {
+	// Name of the environment
+	Name string `json:"name"`
+
+	// WebsiteName is the Website deploying the environment
+	WebsiteName string `json:"websiteName"`
+
+	// ImageTag deployed in the environment
+	// +optional
+	ImageTag string `json:"imageTag,omitempty"`
+
+	// Phase of the Website of the environment
+	// +optional
+	Phase WebsitePhase `json:"phase,omitempty"`
+
+	// URL the environment is reachable at
+	// +optional
+	URL string `json:"url,omitempty"`
+}
+
+// TriggerStatus records a redeploy requested through the trigger endpoint
+type TriggerStatus struct {
+	// By is who requested the redeploy, as reported by the caller
+	// +optional
+	By string `json:"by,omitempty"`
+
+	// At is when the redeploy was requested
+	At metav1.Time `json:"at"`
+
+	// ImageTag the website was moved to. Empty when the pods were only restarted to
+	// resync their content.
+	// +optional
+	ImageTag string `json:"imageTag,omitempty"`
+}
+
+// BuildPhase is the phase of a content build
+type BuildPhase string
+
+const (
+	// BuildRunning means the build Job has not finished yet
+	BuildRunning BuildPhase = "Running"
+	// BuildSucceeded means the build has been published and is served
+	BuildSucceeded BuildPhase = "Succeeded"
+	// BuildFailed means the build Job failed. It is not retried until the build spec changes.
+	BuildFailed BuildPhase = "Failed"
+)
+
+// BuildStatus is the state of the content build of a Website
+type BuildStatus struct {
+	// ID identifies the build of the current build spec
+	ID string `json:"id"`
+
+	// Job running the build
+	Job string `json:"job"`
+
+	// Phase of the build
+	Phase BuildPhase `json:"phase"`
+
+	// Published is the ID of the build the website serves
+	// +optional
+	Published string `json:"published,omitempty"`
+}
+
+// WebsitePhase is a one-word summary of the state of a Website
+// +kubebuilder:validation:Enum=Pending;Deploying;Ready;Degraded;Failed;Terminating
+type WebsitePhase string
+
+const (
+	// PhasePending means the website pods have not been created yet
+	PhasePending WebsitePhase = "Pending"
+	// PhaseDeploying means a new version of the website is being rolled out
+	PhaseDeploying WebsitePhase = "Deploying"
+	// PhaseReady means the website is available and fully rolled out
+	PhaseReady WebsitePhase = "Ready"
+	// PhaseDegraded means the website runs, but is unavailable, stuck rolling out, kept
+	// on an older image, or its host does not resolve to it
+	PhaseDegraded WebsitePhase = "Degraded"
+	// PhaseFailed means the website cannot be run
+	PhaseFailed WebsitePhase = "Failed"
+	// PhaseTerminating means the website is being deleted
+	PhaseTerminating WebsitePhase = "Terminating"
+)
+
+// CanaryStatus is the progress of a canary rollout
+type CanaryStatus struct {
+	// Image the canary runs
+	Image string `json:"image"`
+
+	// Step is the index of the current step
+	Step int32 `json:"step"`
+
+	// Weight is the percentage of traffic currently sent to the canary
+	Weight int32 `json:"weight"`
+
+	// StepStartedAt is when the current step started
+	StepStartedAt metav1.Time `json:"stepStartedAt"`
+}
+
+// AnalysisPhase is the outcome of the analysis of a rollout
+type AnalysisPhase string
+
+const (
+	AnalysisRunning    AnalysisPhase = "Running"
+	AnalysisSuccessful AnalysisPhase = "Successful"
+	// AnalysisFailed reports that the image was rolled back. It is not rolled out again
+	// until the image of the Website changes.
+	AnalysisFailed AnalysisPhase = "Failed"
+)
+
+// AnalysisStatus reports the metric analysis of a rollout
+type AnalysisStatus struct {
+	// Image analysed
+	Image string `json:"image"`
+
+	// PreviousImage is the image rolled back to when the analysis fails
+	PreviousImage string `json:"previousImage"`
+
+	// Phase of the analysis
+	Phase AnalysisPhase `json:"phase"`
+
+	// Value is the latest value of the query
+	// +optional
+	Value string `json:"value,omitempty"`
+
+	// Message explains the phase
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// StartedAt is when the analysis started
+	StartedAt metav1.Time `json:"startedAt"`
+
+	// LastEvaluatedAt is when the query was last evaluated
+	// +optional
+	LastEvaluatedAt *metav1.Time `json:"lastEvaluatedAt,omitempty"`
+}
+
+// AvailabilityStatus reports the availability of a website over its windows
+type AvailabilityStatus struct {
+	// Windows lists the availability over every window
+	// +optional
+	Windows []WindowAvailability `json:"windows,omitempty"`
+
+	// Message explains why the availability could not be computed
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastEvaluatedAt is when the availability was last computed
+	// +optional
+	LastEvaluatedAt *metav1.Time `json:"lastEvaluatedAt,omitempty"`
+}
+
+// WindowAvailability is the availability of a website over a rolling window
+type WindowAvailability struct {
+	// Window the availability is computed over
+	Window metav1.Duration `json:"window"`
+
+	// Percentage of successful probes over the window, such as 99.2%
+	Percentage string `json:"percentage"`
+}
+
+// CertificateStatus reports the serving certificate of a website
+type CertificateStatus struct {
+	// SecretName is the Secret holding the certificate
+	SecretName string `json:"secretName"`
+
+	// NotAfter is when the certificate expires
+	// +optional
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+
+	// Message explains why the certificate could not be read
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ACMEStatus reports the issuance of the certificate of a website by the operator
+type ACMEStatus struct {
+	// OrderURL is the order in progress
+	// +optional
+	OrderURL string `json:"orderURL,omitempty"`
+
+	// Message explains why the last order failed
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// FailedAt is when the last order failed. A new order is placed an hour later.
+	// +optional
+	FailedAt *metav1.Time `json:"failedAt,omitempty"`
+}
+
+// DNSRecordsStatus reports the provider settings the DNS records of a website were
+// registered with
+type DNSRecordsStatus struct {
+	// Provider registering the records
+	Provider DNSProvider `json:"provider"`
+
+	// Host the records are registered for
+	Host string `json:"host"`
+
+	// Cloudflare zone and API token the records were registered with
+	// +optional
+	Cloudflare *CloudflareSpec `json:"cloudflare,omitempty"`
+
+	// Route53 hosted zone and credentials the records were registered with
+	// +optional
+	Route53 *Route53Spec `json:"route53,omitempty"`
+}
+
+// ResolvedImageStatus reports the newest tag satisfying the image tag constraint
+type ResolvedImageStatus struct {
+	// Repository the tags were listed from
+	Repository string `json:"repository"`
+
+	// Constraint the tag satisfies
+	Constraint string `json:"constraint"`
+
+	// Tag deployed
+	// +optional
+	Tag string `json:"tag,omitempty"`
+
+	// Digest the tag pointed to when it was resolved
+	// +optional
+	Digest string `json:"digest,omitempty"`
+
+	// LastCheckedAt is when the registry was last checked for new tags
+	// +optional
+	LastCheckedAt *metav1.Time `json:"lastCheckedAt,omitempty"`
+
+	// Message explains why the last check failed, or that no tag satisfies the constraint
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// PodPlacement counts the website pods scheduled in a zone or on a node
+type PodPlacement struct {
+	// Name of the zone or node
+	Name string `json:"name"`
+
+	// Pods is the number of website pods scheduled there
+	Pods int32 `json:"pods"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Image Tag",type=string,JSONPath=`.spec.imageTag`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Zones",type=integer,JSONPath=`.status.zoneCount`
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.url`,priority=1
+//+kubebuilder:printcolumn:name="Address",type=string,JSONPath=`.status.address`,priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Website is the Schema for the websites API
 type Website struct {
@@ -0,0 +1,906 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/mvasilenko/helloworld-operator/internal/podsecurity"
+	"github.com/mvasilenko/helloworld-operator/internal/schedule"
+	"github.com/mvasilenko/helloworld-operator/internal/semver"
+)
+
+// log is for logging in this package.
+var websitelog = logf.Log.WithName("website-resource")
+
+// WebsiteValidator validates Websites on admission against the operator-level policy.
+// +kubebuilder:object:generate=false
+type WebsiteValidator struct {
+	Client client.Reader
+	// APIReader reads the cluster-scoped objects the manager does not watch. Reading them
+	// through the cached Client would start informers that need cluster-wide list and
+	// watch permissions, and never return the Forbidden error of a denied read.
+	APIReader client.Reader
+
+	// MaxWebsitesPerNamespace limits how many Websites a namespace may have. Zero means unlimited.
+	MaxWebsitesPerNamespace int
+	// MaxReplicasPerNamespace limits the total replicas of all Websites in a namespace.
+	// Zero means unlimited.
+	MaxReplicasPerNamespace int32
+
+	// DisallowedTags are image tags Websites may not use unless pinned to a digest, such
+	// as latest. An empty tag counts as latest.
+	DisallowedTags []string
+	// TagPolicy is what happens to Websites using a disallowed tag.
+	TagPolicy TagPolicy
+	// TagPolicyExemptNamespaces are namespaces the tag policy does not apply to.
+	TagPolicyExemptNamespaces []string
+
+	// PodTemplate returns the template of the pods generated for a Website, which is
+	// checked against the Pod Security Standards of its namespace. Nothing is checked when nil.
+	PodTemplate func(ctx context.Context, website *Website) (*corev1.PodTemplateSpec, error)
+}
+
+// TagPolicy is what happens to Websites using a disallowed image tag
+type TagPolicy string
+
+const (
+	// TagPolicyReject rejects Websites using a disallowed tag
+	TagPolicyReject TagPolicy = "Reject"
+	// TagPolicyWarn admits Websites using a disallowed tag with a warning
+	TagPolicyWarn TagPolicy = "Warn"
+)
+
+// SetupWebhookWithManager registers the validating webhook for Websites with the manager.
+// The webhook is registered by hand rather than through the webhook builder, which offers
+// no way to return admission warnings.
+func (v *WebsiteValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	hook := admission.WithCustomValidator(&Website{}, v)
+	hook.Handler = &warningHandler{Handler: hook.Handler, validator: v}
+	mgr.GetWebhookServer().Register("/validate-dev-mvasilenko-me-v1-website", hook)
+	return nil
+}
+
+// warningHandler adds the warnings of the tag policy and of the Pod Security Standards to
+// the responses admitting Websites.
+type warningHandler struct {
+	admission.Handler
+	validator *WebsiteValidator
+}
+
+// InjectDecoder passes the decoder on to the wrapped handler.
+func (h *warningHandler) InjectDecoder(d *admission.Decoder) error {
+	_, err := admission.InjectDecoderInto(d, h.Handler)
+	return err
+}
+
+func (h *warningHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	resp := h.Handler.Handle(ctx, req)
+	if !resp.Allowed || len(req.Object.Raw) == 0 {
+		return resp
+	}
+	website := &Website{}
+	if err := json.Unmarshal(req.Object.Raw, website); err != nil {
+		return resp
+	}
+	if website.Namespace == "" {
+		website.Namespace = req.Namespace
+	}
+	if h.validator.TagPolicy == TagPolicyWarn {
+		if err := h.validator.validateTag(website); err != nil {
+			resp.Warnings = append(resp.Warnings, err.Error())
+		}
+	}
+	if warnings, err := h.validator.checkPodSecurity(ctx, website, podsecurity.WarnLabel); err == nil {
+		resp.Warnings = append(resp.Warnings, warnings...)
+	}
+	return resp
+}
+
+//+kubebuilder:webhook:path=/validate-dev-mvasilenko-me-v1-website,mutating=false,failurePolicy=fail,sideEffects=None,groups=dev.mvasilenko.me,resources=websites,verbs=create;update,versions=v1,name=vwebsite.kb.io,admissionReviewVersions=v1
+
+var _ webhook.CustomValidator = &WebsiteValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *WebsiteValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	website, ok := obj.(*Website)
+	if !ok {
+		return fmt.Errorf("expected a Website but got a %T", obj)
+	}
+	websitelog.Info("validate create", "name", website.Name, "namespace", website.Namespace)
+
+	if err := website.validateSpec(); err != nil {
+		return err
+	}
+	if err := v.enforceTagPolicy(website); err != nil {
+		return err
+	}
+	if err := v.validateNodeCapacity(ctx, website); err != nil {
+		return err
+	}
+	if err := v.validateNodePort(ctx, website); err != nil {
+		return err
+	}
+	if err := v.enforcePodSecurity(ctx, website); err != nil {
+		return err
+	}
+	return v.validateQuota(ctx, website, nil)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *WebsiteValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	website, ok := newObj.(*Website)
+	if !ok {
+		return fmt.Errorf("expected a Website but got a %T", newObj)
+	}
+	websitelog.Info("validate update", "name", website.Name, "namespace", website.Namespace)
+
+	if err := website.validateSpec(); err != nil {
+		return err
+	}
+	if old, ok := oldObj.(*Website); ok {
+		if err := website.validateNames(old); err != nil {
+			return err
+		}
+	}
+	if err := v.enforceTagPolicy(website); err != nil {
+		return err
+	}
+	if err := v.validateNodeCapacity(ctx, website); err != nil {
+		return err
+	}
+	if err := v.validateNodePort(ctx, website); err != nil {
+		return err
+	}
+	if err := v.enforcePodSecurity(ctx, website); err != nil {
+		return err
+	}
+	old, _ := oldObj.(*Website)
+	return v.validateQuota(ctx, website, old)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *WebsiteValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+// validateNames rejects renaming the generated Deployment and Service, which would leave
+// the previous ones running next to the new ones.
+func (r *Website) validateNames(old *Website) error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	if r.Spec.DeploymentName != old.Spec.DeploymentName {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("deploymentName"), "cannot be changed"))
+	}
+	if r.Spec.ServiceName != old.Spec.ServiceName {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("serviceName"), "cannot be changed"))
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Website").GroupKind(), r.Name, allErrs)
+}
+
+// validateSpec checks the constraints between spec fields that the CRD schema cannot express.
+func (r *Website) validateSpec() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if r.Spec.PreStopSleepSeconds != nil {
+		gracePeriod := int64(corev1.DefaultTerminationGracePeriodSeconds)
+		if r.Spec.TerminationGracePeriodSeconds != nil {
+			gracePeriod = *r.Spec.TerminationGracePeriodSeconds
+		}
+		if int64(*r.Spec.PreStopSleepSeconds) >= gracePeriod {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("preStopSleepSeconds"), *r.Spec.PreStopSleepSeconds,
+				fmt.Sprintf("must be shorter than the termination grace period of %d seconds", gracePeriod)))
+		}
+	}
+
+	if service := r.Spec.Service; service != nil {
+		servicePath := specPath.Child("service")
+		exposed := service.Type == "" || service.Type == corev1.ServiceTypeNodePort || service.Type == corev1.ServiceTypeLoadBalancer
+		if service.ExternalTrafficPolicy != "" && !exposed {
+			allErrs = append(allErrs, field.Invalid(servicePath.Child("externalTrafficPolicy"), service.ExternalTrafficPolicy,
+				"may only be set for NodePort and LoadBalancer services"))
+		}
+		if service.HealthCheckNodePort != 0 &&
+			(service.Type != corev1.ServiceTypeLoadBalancer || service.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyTypeLocal) {
+			allErrs = append(allErrs, field.Invalid(servicePath.Child("healthCheckNodePort"), service.HealthCheckNodePort,
+				"may only be set for LoadBalancer services with the Local external traffic policy"))
+		}
+		if service.NodePort != 0 && !exposed {
+			allErrs = append(allErrs, field.Invalid(servicePath.Child("nodePort"), service.NodePort,
+				"may only be set for NodePort and LoadBalancer services"))
+		}
+		labelsPath := servicePath.Child("labels")
+		allErrs = append(allErrs, metav1validation.ValidateLabels(service.Labels, labelsPath)...)
+		for key := range service.Labels {
+			// The operator owns these, the website label in particular is what the Services select on.
+			if key == "website" || key == "type" || strings.HasPrefix(key, "app.kubernetes.io/") {
+				allErrs = append(allErrs, field.Invalid(labelsPath.Key(key), key, "is set by the operator"))
+			}
+		}
+	}
+
+	if content := r.Spec.Content; content != nil && (content.ConfigMapName == "") == (content.SecretName == "") {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("content"), content,
+			"exactly one of configMapName and secretName must be set"))
+	}
+
+	if r.Spec.Build != nil && r.Spec.Content != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("build"), r.Spec.Build.Repository,
+			"may not be combined with content, which serves files from elsewhere"))
+	}
+
+	if verification := r.Spec.ImageVerification; verification != nil && (verification.PublicKey == "") == (verification.Keyless == nil) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("imageVerification"), verification,
+			"exactly one of publicKey and keyless must be set"))
+	}
+
+	if logging := r.Spec.Logging; logging != nil && logging.Output != LogOutputStdout {
+		if u, err := url.Parse(logging.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("logging", "url"), logging.URL,
+				fmt.Sprintf("must be an http or https URL for the %s output", logging.Output)))
+		}
+	}
+
+	if vault := r.Spec.Vault; vault != nil && vault.Mode == VaultSidecar && vault.Address == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("vault", "address"), "must be set in Sidecar mode"))
+	}
+
+	if eviction := r.Spec.Eviction; eviction != nil && eviction.Disallow && eviction.SafeToEvict != nil && *eviction.SafeToEvict {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("eviction", "safeToEvict"), true,
+			"may not be true when eviction is disallowed"))
+	}
+
+	if r.Spec.Ingress != nil && r.Spec.Service != nil && r.Spec.Service.Headless == HeadlessOnly {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("ingress"), r.Spec.Ingress.Host,
+			"requires the regular Service, which headless mode Only does not create"))
+	}
+
+	for i, environment := range r.Spec.Environments {
+		if name := r.Name + "-" + environment.Name; len(name) > validation.DNS1123LabelMaxLength {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("environments").Index(i).Child("name"), environment.Name,
+				fmt.Sprintf("makes the name of its Website, %q, longer than %d characters", name, validation.DNS1123LabelMaxLength)))
+		}
+	}
+
+	if r.Spec.ImageTagConstraint != "" {
+		if _, err := semver.ParseConstraint(r.Spec.ImageTagConstraint); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("imageTagConstraint"), r.Spec.ImageTagConstraint, err.Error()))
+		}
+		if r.Spec.ImageDigest != "" {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("imageTagConstraint"), r.Spec.ImageTagConstraint,
+				"may not be combined with an image digest"))
+		}
+	}
+
+	if ingress := r.Spec.Ingress; ingress != nil && ingress.TLS != nil && ingress.TLS.ACME != nil && ingress.TLS.Issuer != "" {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("ingress", "tls", "acme"), "",
+			"may not be combined with a cert-manager issuer"))
+	}
+
+	if ingress := r.Spec.Ingress; ingress != nil && ingress.TLS != nil && ingress.TLS.SelfSigned &&
+		(ingress.TLS.Issuer != "" || ingress.TLS.ACME != nil) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("ingress", "tls", "selfSigned"), true,
+			"may not be combined with a cert-manager issuer or ACME"))
+	}
+
+	if dns := r.Spec.DNS; dns != nil {
+		if dns.Host == "" && r.Spec.Ingress == nil {
+			allErrs = append(allErrs, field.Required(specPath.Child("dns", "host"), "is required without an ingress"))
+		}
+		if dns.Provider == DNSProviderCloudflare && dns.Cloudflare == nil {
+			allErrs = append(allErrs, field.Required(specPath.Child("dns", "cloudflare"), "is required by the Cloudflare provider"))
+		}
+		if dns.Provider == DNSProviderRoute53 && dns.Route53 == nil {
+			allErrs = append(allErrs, field.Required(specPath.Child("dns", "route53"), "is required by the Route53 provider"))
+		}
+	}
+
+	if rollout := r.Spec.Rollout; rollout != nil && rollout.Canary != nil {
+		canaryPath := specPath.Child("rollout", "canary")
+		if r.Spec.Ingress == nil && rollout.Provider != RolloutFlagger {
+			allErrs = append(allErrs, field.Required(specPath.Child("ingress"), "is required by canary rollouts"))
+		}
+		previous := int32(0)
+		for i, weight := range rollout.Canary.Steps {
+			if weight <= previous || weight > 100 {
+				allErrs = append(allErrs, field.Invalid(canaryPath.Child("steps").Index(i), weight,
+					"must be increasing percentages between 1 and 100"))
+			}
+			previous = weight
+		}
+	}
+
+	if r.Spec.WorkloadType == WorkloadRollout && r.Spec.Rollout != nil && r.Spec.Rollout.Provider == RolloutFlagger {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("rollout", "provider"), r.Spec.Rollout.Provider,
+			"may not be used with the Rollout workload type, which rolls out by itself"))
+	}
+
+	if r.Spec.WorkloadType == WorkloadDaemonSet {
+		// A DaemonSet rolls out node by node by itself, and runs exactly one pod per node.
+		if r.Spec.Rollout != nil {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("rollout"), "may not be used with the DaemonSet workload type"))
+		}
+		if r.Spec.ABTest != nil {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("abTest"), "may not be used with the DaemonSet workload type"))
+		}
+		if len(r.Spec.Clusters) > 0 {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("clusters"), "may not be used with the DaemonSet workload type"))
+		}
+		if r.Spec.Eviction != nil && r.Spec.Eviction.Disallow {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("eviction", "disallow"),
+				"may not be used with the DaemonSet workload type, whose pods are not evicted by drains"))
+		}
+	}
+
+	if abTest := r.Spec.ABTest; abTest != nil {
+		abTestPath := specPath.Child("abTest")
+		if r.Spec.Ingress == nil {
+			allErrs = append(allErrs, field.Required(specPath.Child("ingress"), "is required by A/B tests"))
+		}
+		if rollout := r.Spec.Rollout; rollout != nil && rollout.Canary != nil && rollout.Provider != RolloutFlagger {
+			allErrs = append(allErrs, field.Invalid(abTestPath, abTest.Variants,
+				"may not be combined with a canary rollout run by the operator, which uses the same canary Ingress"))
+		}
+		for i, variant := range abTest.Variants {
+			if (variant.Header == nil) == (variant.Cookie == "") {
+				allErrs = append(allErrs, field.Invalid(abTestPath.Child("variants").Index(i), variant.Name,
+					"exactly one of header and cookie must be set"))
+			}
+		}
+	}
+
+	allErrs = append(allErrs, r.validateVolumes(specPath)...)
+	if mesh := r.Spec.Mesh; mesh != nil && mesh.Istio != nil && mesh.Istio.Enabled && mesh.Linkerd != nil &&
+		(mesh.Linkerd.Inject == "enabled" || mesh.Linkerd.Inject == "ingress") {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("mesh", "linkerd", "inject"),
+			"may not inject the Linkerd proxy next to the Istio sidecar"))
+	}
+	if profile := r.Spec.SeccompProfile; profile != nil && (profile.Type == corev1.SeccompProfileTypeLocalhost) != (profile.LocalhostProfile != nil) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("seccompProfile", "localhostProfile"), profile.LocalhostProfile,
+			"must be set for, and only for, the Localhost type"))
+	}
+	if profile := r.Spec.AppArmorProfile; profile != nil && (profile.Type == AppArmorProfileLocalhost) != (profile.LocalhostProfile != "") {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("appArmorProfile", "localhostProfile"), profile.LocalhostProfile,
+			"must be set for, and only for, the Localhost type"))
+	}
+	if token := r.Spec.ServiceAccountToken; token != nil && token.Audience == "" {
+		if token.ExpirationSeconds != nil {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("serviceAccountToken", "expirationSeconds"), "requires audience"))
+		}
+		if token.MountPath != "" {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("serviceAccountToken", "mountPath"), "requires audience"))
+		}
+	}
+
+	if r.Spec.VerifyPlatforms && len(r.Spec.Platforms) == 0 {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("verifyPlatforms"), "requires platforms"))
+	}
+	platforms := map[string]bool{}
+	for i, platform := range r.Spec.Platforms {
+		if platforms[platform.String()] {
+			allErrs = append(allErrs, field.Duplicate(specPath.Child("platforms").Index(i), platform.String()))
+		}
+		platforms[platform.String()] = true
+	}
+
+	if r.Spec.Resources != nil {
+		allErrs = append(allErrs, validateExtendedResources(specPath.Child("resources"), r.Spec.Resources)...)
+	}
+
+	if r.Spec.BackendTLS != nil {
+		port := r.Spec.BackendTLS.Port
+		if port == 0 {
+			port = DefaultBackendTLSPort
+		}
+		for i, p := range r.Spec.Ports {
+			servicePort := p.ServicePort
+			if servicePort == 0 {
+				servicePort = p.ContainerPort
+			}
+			if p.Name == BackendTLSPortName || p.ContainerPort == port || servicePort == 443 {
+				allErrs = append(allErrs, field.Invalid(specPath.Child("ports").Index(i), p.Name,
+					fmt.Sprintf("conflicts with the %s port of backendTLS, %d in the pods and 443 on the Service", BackendTLSPortName, port)))
+			}
+		}
+	}
+
+	if headers := r.Spec.SecurityHeaders; headers != nil {
+		headersPath := specPath.Child("securityHeaders")
+		for name, value := range map[string]*string{
+			"strictTransportSecurity": headers.StrictTransportSecurity,
+			"contentSecurityPolicy":   headers.ContentSecurityPolicy,
+			"frameOptions":            headers.FrameOptions,
+			"referrerPolicy":          headers.ReferrerPolicy,
+			"contentTypeOptions":      headers.ContentTypeOptions,
+		} {
+			if value != nil && strings.ContainsAny(*value, "\"$\r\n") {
+				allErrs = append(allErrs, field.Invalid(headersPath.Child(name), *value, "must not contain double quotes, dollar signs or line breaks"))
+			}
+		}
+	}
+
+	if rollout := r.Spec.Rollout; rollout != nil && rollout.Analysis != nil {
+		analysisPath := specPath.Child("rollout", "analysis")
+		if address := rollout.Analysis.Address; address != "" {
+			if u, err := url.Parse(address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				allErrs = append(allErrs, field.Invalid(analysisPath.Child("address"), address, "must be an http or https URL"))
+			}
+		}
+		for name, duration := range map[string]*metav1.Duration{"interval": rollout.Analysis.Interval, "duration": rollout.Analysis.Duration} {
+			if duration != nil && duration.Duration <= 0 {
+				allErrs = append(allErrs, field.Invalid(analysisPath.Child(name), duration.Duration.String(), "must be positive"))
+			}
+		}
+	}
+
+	if monitoring := r.Spec.Monitoring; monitoring != nil && monitoring.Availability != nil {
+		availabilityPath := specPath.Child("monitoring", "availability")
+		if address := monitoring.Availability.Address; address != "" {
+			if u, err := url.Parse(address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				allErrs = append(allErrs, field.Invalid(availabilityPath.Child("address"), address, "must be an http or https URL"))
+			}
+		}
+		for i, window := range monitoring.Availability.Windows {
+			if window.Duration < time.Minute {
+				allErrs = append(allErrs, field.Invalid(availabilityPath.Child("windows").Index(i), window.Duration.String(), "must be at least 1m"))
+			}
+		}
+	}
+
+	if window := r.Spec.RolloutWindow; window != nil {
+		windowPath := specPath.Child("rolloutWindow")
+		if _, err := time.LoadLocation(window.TimeZone); err != nil {
+			allErrs = append(allErrs, field.Invalid(windowPath.Child("timeZone"), window.TimeZone, err.Error()))
+		}
+		for i, w := range window.Windows {
+			if _, err := schedule.Parse(w.Schedule); err != nil {
+				allErrs = append(allErrs, field.Invalid(windowPath.Child("windows").Index(i).Child("schedule"), w.Schedule, err.Error()))
+			}
+			if w.Duration.Duration < time.Minute || w.Duration.Duration > maxRolloutWindowDuration {
+				allErrs = append(allErrs, field.Invalid(windowPath.Child("windows").Index(i).Child("duration"), w.Duration.Duration.String(),
+					fmt.Sprintf("must be between 1m and %s", maxRolloutWindowDuration)))
+			}
+		}
+	}
+
+	for i, redirect := range r.Spec.Redirects {
+		redirectPath := specPath.Child("redirects").Index(i)
+		for name, value := range map[string]string{"host": redirect.Host, "path": redirect.Path, "to": redirect.To} {
+			if strings.ContainsAny(value, " \t\r\n\"'$;{}") {
+				allErrs = append(allErrs, field.Invalid(redirectPath.Child(name), value, "must not contain whitespace, quotes, dollar signs, braces or semicolons"))
+			}
+		}
+	}
+	for i, rewrite := range r.Spec.Rewrites {
+		rewritePath := specPath.Child("rewrites").Index(i)
+		if _, err := regexp.Compile(rewrite.Regex); err != nil {
+			allErrs = append(allErrs, field.Invalid(rewritePath.Child("regex"), rewrite.Regex, err.Error()))
+		}
+		for name, value := range map[string]string{"regex": rewrite.Regex, "replacement": rewrite.Replacement} {
+			if strings.ContainsAny(value, "\"\r\n") {
+				allErrs = append(allErrs, field.Invalid(rewritePath.Child(name), value, "must not contain double quotes or line breaks"))
+			}
+		}
+	}
+
+	if caching := r.Spec.Caching; caching != nil {
+		rulesPath := specPath.Child("caching", "rules")
+		for i, rule := range caching.Rules {
+			if rule.MaxAge != nil && rule.MaxAge.Duration < 0 {
+				allErrs = append(allErrs, field.Invalid(rulesPath.Index(i).Child("maxAge"), rule.MaxAge.Duration.String(), "must not be negative"))
+			}
+			if rule.Immutable && rule.MaxAge == nil {
+				allErrs = append(allErrs, field.Required(rulesPath.Index(i).Child("maxAge"), "immutable responses need a max age"))
+			}
+			for j, extension := range rule.Extensions {
+				if !cacheExtensionPattern.MatchString(extension) {
+					allErrs = append(allErrs, field.Invalid(rulesPath.Index(i).Child("extensions").Index(j), extension, "must be a file extension without the leading dot"))
+				}
+			}
+			if strings.ContainsAny(rule.Path, " \t\n;{}\"'") {
+				allErrs = append(allErrs, field.Invalid(rulesPath.Index(i).Child("path"), rule.Path, "must not contain whitespace, quotes, braces or semicolons"))
+			}
+		}
+	}
+
+	if pages := r.Spec.ErrorPages; pages != nil {
+		codes := map[ErrorPageCode]bool{}
+		for i, page := range pages.Pages {
+			for j, code := range page.Codes {
+				if codes[code] {
+					allErrs = append(allErrs, field.Duplicate(specPath.Child("errorPages", "pages").Index(i).Child("codes").Index(j), code))
+				}
+				codes[code] = true
+			}
+		}
+	}
+
+	servicePorts, hostPorts := map[string]bool{}, map[string]bool{}
+	for i, port := range r.Spec.Ports {
+		servicePort := port.ServicePort
+		if servicePort == 0 {
+			servicePort = port.ContainerPort
+		}
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		key := fmt.Sprintf("%d/%s", servicePort, protocol)
+		if servicePorts[key] {
+			allErrs = append(allErrs, field.Duplicate(specPath.Child("ports").Index(i).Child("servicePort"), servicePort))
+		}
+		servicePorts[key] = true
+
+		hostPort := port.HostPort
+		if r.Spec.HostNetwork {
+			if hostPort != 0 && hostPort != port.ContainerPort {
+				allErrs = append(allErrs, field.Invalid(specPath.Child("ports").Index(i).Child("hostPort"), hostPort,
+					"must equal containerPort with hostNetwork"))
+			}
+			hostPort = port.ContainerPort
+		}
+		if hostPort == 0 {
+			continue
+		}
+		key = fmt.Sprintf("%d/%s", hostPort, protocol)
+		if hostPorts[key] {
+			allErrs = append(allErrs, field.Duplicate(specPath.Child("ports").Index(i).Child("hostPort"), hostPort))
+		}
+		hostPorts[key] = true
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Website").GroupKind(), r.Name, allErrs)
+}
+
+// enforceTagPolicy rejects a website using a disallowed tag when the tag policy says so.
+func (v *WebsiteValidator) enforceTagPolicy(website *Website) error {
+	if v.TagPolicy != TagPolicyReject {
+		return nil
+	}
+	return v.validateTag(website)
+}
+
+// validateTag checks the image tag of a website against the disallowed tags.
+func (v *WebsiteValidator) validateTag(website *Website) error {
+	if website.Spec.ImageDigest != "" {
+		return nil
+	}
+	for _, namespace := range v.TagPolicyExemptNamespaces {
+		if namespace == website.Namespace {
+			return nil
+		}
+	}
+	tag := website.Spec.ImageTag
+	if tag == "" {
+		tag = "latest"
+	}
+	for _, disallowed := range v.DisallowedTags {
+		if tag == disallowed {
+			return apierrors.NewInvalid(GroupVersion.WithKind("Website").GroupKind(), website.Name, field.ErrorList{
+				field.Invalid(field.NewPath("spec", "imageTag"), website.Spec.ImageTag,
+					"is a floating tag, pin the website to a fixed tag or an image digest"),
+			})
+		}
+	}
+	return nil
+}
+
+// validateQuota rejects a website bringing its namespace over its quota of Websites or
+// replicas. An update, whose old website is given, is only checked when it adds replicas,
+// so that websites of a namespace already over a lowered quota can still be changed. The
+// Websites of environments are counted through the website they belong to.
+func (v *WebsiteValidator) validateQuota(ctx context.Context, website, old *Website) error {
+	if v.MaxWebsitesPerNamespace <= 0 && v.MaxReplicasPerNamespace <= 0 {
+		return nil
+	}
+	if environmentWebsite(website) || old != nil && website.replicas() <= old.replicas() {
+		return nil
+	}
+
+	websites := WebsiteList{}
+	if err := v.Client.List(ctx, &websites, client.InNamespace(website.Namespace)); err != nil {
+		return err
+	}
+
+	count := 1
+	replicas := website.replicas()
+	for i, existing := range websites.Items {
+		if existing.Name == website.Name || environmentWebsite(&websites.Items[i]) {
+			continue
+		}
+		count++
+		replicas += existing.replicas()
+	}
+
+	groupResource := GroupVersion.WithResource("websites").GroupResource()
+	if old == nil && v.MaxWebsitesPerNamespace > 0 && count > v.MaxWebsitesPerNamespace {
+		return apierrors.NewForbidden(groupResource, website.Name, fmt.Errorf(
+			"namespace %q is limited to %d Websites and already has %d",
+			website.Namespace, v.MaxWebsitesPerNamespace, count-1))
+	}
+	if v.MaxReplicasPerNamespace > 0 && replicas > v.MaxReplicasPerNamespace {
+		return apierrors.NewForbidden(groupResource, website.Name, fmt.Errorf(
+			"namespace %q is limited to %d Website replicas, this Website would bring it to %d",
+			website.Namespace, v.MaxReplicasPerNamespace, replicas))
+	}
+	return nil
+}
+
+// replicas returns the number of pods a website runs, summed over its environments.
+func (r *Website) replicas() int32 {
+	replicas := DefaultReplicas
+	if r.Spec.Replicas != nil {
+		replicas = *r.Spec.Replicas
+	}
+	if len(r.Spec.Environments) == 0 {
+		return replicas
+	}
+	var total int32
+	for _, environment := range r.Spec.Environments {
+		if environment.Replicas != nil {
+			total += *environment.Replicas
+		} else {
+			total += replicas
+		}
+	}
+	return total
+}
+
+// environmentWebsite reports whether a website runs an environment of another Website.
+func environmentWebsite(website *Website) bool {
+	owner := metav1.GetControllerOf(website)
+	return owner != nil && owner.APIVersion == GroupVersion.String() && owner.Kind == "Website"
+}
+
+// validateNodePort rejects a website pinning the node port another website asks for or
+// was allocated. The ports of Services the operator does not manage are left to the API
+// server, which rejects them when the Service is created.
+func (v *WebsiteValidator) validateNodePort(ctx context.Context, website *Website) error {
+	if website.Spec.Service == nil || website.Spec.Service.NodePort == 0 {
+		return nil
+	}
+	nodePort := website.Spec.Service.NodePort
+
+	websites := WebsiteList{}
+	if err := v.Client.List(ctx, &websites); err != nil {
+		return err
+	}
+	for _, existing := range websites.Items {
+		if existing.Name == website.Name && existing.Namespace == website.Namespace {
+			continue
+		}
+		if existing.Status.NodePort == nodePort || (existing.Spec.Service != nil && existing.Spec.Service.NodePort == nodePort) {
+			return apierrors.NewInvalid(GroupVersion.WithKind("Website").GroupKind(), website.Name, field.ErrorList{
+				field.Invalid(field.NewPath("spec", "service", "nodePort"), nodePort,
+					fmt.Sprintf("is already used by website %s/%s", existing.Namespace, existing.Name)),
+			})
+		}
+	}
+	return nil
+}
+
+// enforcePodSecurity rejects a website whose pods would break the Pod Security Standard
+// enforced on its namespace.
+func (v *WebsiteValidator) enforcePodSecurity(ctx context.Context, website *Website) error {
+	violations, err := v.checkPodSecurity(ctx, website, podsecurity.EnforceLabel)
+	if err != nil || len(violations) == 0 {
+		return err
+	}
+	return apierrors.NewForbidden(GroupVersion.WithResource("websites").GroupResource(), website.Name, fmt.Errorf(
+		"its pods would violate the Pod Security Standard enforced on namespace %q: %s",
+		website.Namespace, strings.Join(violations, "; ")))
+}
+
+// checkPodSecurity returns the violations of the pods of a website against the Pod
+// Security Standard its namespace sets with a label. Nothing is checked when the
+// namespace cannot be read.
+func (v *WebsiteValidator) checkPodSecurity(ctx context.Context, website *Website, label string) ([]string, error) {
+	if v.PodTemplate == nil {
+		return nil, nil
+	}
+	namespace := corev1.Namespace{}
+	if err := v.APIReader.Get(ctx, client.ObjectKey{Name: website.Namespace}, &namespace); err != nil {
+		if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	level, ok := namespace.Labels[label]
+	if !ok {
+		return nil, nil
+	}
+
+	// Websites may be created before their class, which is then checked on the next update.
+	template, err := v.PodTemplate(ctx, website)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var violations []string
+	for _, violation := range podsecurity.Check(podsecurity.Level(level), template) {
+		violations = append(violations, fmt.Sprintf("%s:%s %s", label, level, violation))
+	}
+	return violations, nil
+}
+
+// maxRolloutWindowDuration is the longest a rollout window can stay open.
+const maxRolloutWindowDuration = 7 * 24 * time.Hour
+
+// cacheExtensionPattern matches the file extensions caching rules can be restricted to.
+var cacheExtensionPattern = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+// reservedVolumeNames are the names of the volumes the operator adds to website pods.
+// The volumes of external secrets are named secret-<name>.
+var reservedVolumeNames = []string{"content", "build", "cache", "logs", "stub-status", "vault-secrets", "service-account-token",
+	"backend-tls", "backend-tls-conf", "error-pages", "nginx-conf", "acme-challenges"}
+
+// validateVolumes checks that the volumes of a website do not clash with the generated
+// ones, and that its volume mounts refer to them.
+func (r *Website) validateVolumes(specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	volumes := map[string]bool{}
+	for i, volume := range r.Spec.Volumes {
+		namePath := specPath.Child("volumes").Index(i).Child("name")
+		switch {
+		case volume.Name == "":
+			allErrs = append(allErrs, field.Required(namePath, ""))
+		case volumes[volume.Name]:
+			allErrs = append(allErrs, field.Duplicate(namePath, volume.Name))
+		case strings.HasPrefix(volume.Name, "secret-"):
+			allErrs = append(allErrs, field.Invalid(namePath, volume.Name, "the secret- prefix is reserved for external secrets"))
+		}
+		for _, reserved := range reservedVolumeNames {
+			if volume.Name == reserved {
+				allErrs = append(allErrs, field.Invalid(namePath, volume.Name, "is the name of a volume generated by the operator"))
+			}
+		}
+		volumes[volume.Name] = true
+	}
+	for i, mount := range r.Spec.VolumeMounts {
+		if !volumes[mount.Name] {
+			allErrs = append(allErrs, field.NotFound(specPath.Child("volumeMounts").Index(i).Child("name"), mount.Name))
+		}
+	}
+	return allErrs
+}
+
+// nodeResource reports whether a resource is only provided by some nodes, such as huge
+// pages and extended resources like GPUs, as opposed to CPU, memory and ephemeral storage.
+func nodeResource(name corev1.ResourceName) bool {
+	if strings.HasPrefix(string(name), corev1.ResourceHugePagesPrefix) {
+		return true
+	}
+	return strings.Contains(string(name), "/") && !strings.HasPrefix(string(name), corev1.ResourceDefaultNamespacePrefix)
+}
+
+// validateExtendedResources checks the rules the API server applies to huge pages and
+// extended resources of a container, so that a website breaking them is rejected instead
+// of leaving its Deployment unable to create pods.
+func validateExtendedResources(path *field.Path, resources *corev1.ResourceRequirements) field.ErrorList {
+	var allErrs field.ErrorList
+	for name, request := range resources.Requests {
+		if !nodeResource(name) {
+			continue
+		}
+		limit, ok := resources.Limits[name]
+		if !ok {
+			allErrs = append(allErrs, field.Required(path.Child("limits").Key(string(name)),
+				"must be set when the resource is requested, as it cannot be overcommitted"))
+		} else if limit.Cmp(request) != 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("requests").Key(string(name)), request.String(),
+				"must be equal to the limit, as the resource cannot be overcommitted"))
+		}
+	}
+	for name, limit := range resources.Limits {
+		if !nodeResource(name) {
+			continue
+		}
+		if strings.HasPrefix(string(name), corev1.ResourceHugePagesPrefix) {
+			_, cpu := resources.Limits[corev1.ResourceCPU]
+			_, memory := resources.Limits[corev1.ResourceMemory]
+			if !cpu && !memory {
+				allErrs = append(allErrs, field.Forbidden(path.Child("limits").Key(string(name)),
+					"huge pages require a cpu or memory limit"))
+			}
+		} else if limit.MilliValue()%1000 != 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("limits").Key(string(name)), limit.String(),
+				"must be a whole number"))
+		}
+	}
+	return allErrs
+}
+
+// validateNodeCapacity rejects a website asking for more of a huge page size or extended
+// resource than any node its pods may run on can allocate. Nothing is checked when the
+// nodes cannot be listed, as for an operator watching a single namespace, or when there
+// are none yet.
+func (v *WebsiteValidator) validateNodeCapacity(ctx context.Context, website *Website) error {
+	if website.Spec.Resources == nil {
+		return nil
+	}
+	limits := corev1.ResourceList{}
+	for name, limit := range website.Spec.Resources.Limits {
+		if nodeResource(name) {
+			limits[name] = limit
+		}
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+
+	nodes := corev1.NodeList{}
+	if err := v.APIReader.List(ctx, &nodes, client.MatchingLabels(website.Spec.NodeSelector)); err != nil {
+		if apierrors.IsForbidden(err) {
+			return nil
+		}
+		return err
+	}
+	if len(nodes.Items) == 0 {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	limitsPath := field.NewPath("spec", "resources", "limits")
+	for name, limit := range limits {
+		var largest resource.Quantity
+		for _, node := range nodes.Items {
+			if allocatable, ok := node.Status.Allocatable[name]; ok && allocatable.Cmp(largest) > 0 {
+				largest = allocatable
+			}
+		}
+		if largest.IsZero() {
+			allErrs = append(allErrs, field.Invalid(limitsPath.Key(string(name)), limit.String(),
+				"no node the website may run on provides the resource"))
+		} else if largest.Cmp(limit) < 0 {
+			allErrs = append(allErrs, field.Invalid(limitsPath.Key(string(name)), limit.String(),
+				fmt.Sprintf("no node the website may run on can allocate more than %s", largest.String())))
+		}
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Website").GroupKind(), website.Name, allErrs)
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IsDefaultClassAnnotation marks the WebsiteClass used by Websites that do not name one.
+const IsDefaultClassAnnotation = "websiteclass.dev.mvasilenko.me/is-default-class"
+
+// WebsiteClassSpec defines the defaults a WebsiteClass provides to its Websites. A field
+// set on a Website takes precedence over the one of its class.
+type WebsiteClassSpec struct {
+	// ImageRepository is the repository of the website image
+	// +optional
+	ImageRepository string `json:"imageRepository,omitempty"`
+
+	// Resources of the website container
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// IngressClassName is the IngressClass of the website Ingresses
+	// +optional
+	IngressClassName string `json:"ingressClassName,omitempty"`
+
+	// TLSIssuer is the cert-manager ClusterIssuer issuing the certificates of the website Ingresses
+	// +optional
+	TLSIssuer string `json:"tlsIssuer,omitempty"`
+
+	// SecurityContext holds the pod-level security settings of the website pods
+	// +optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// WebsiteClass is the Schema for the websiteclasses API. Like an IngressClass or
+// StorageClass, it lets a platform team define the defaults of the Websites using it.
+type WebsiteClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec WebsiteClassSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WebsiteClassList contains a list of WebsiteClass
+type WebsiteClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WebsiteClass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WebsiteClass{}, &WebsiteClassList{})
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WebsitePreviewSpec defines the desired state of WebsitePreview
+type WebsitePreviewSpec struct {
+	// WebsiteName is the Website in the preview namespace the preview is a copy of
+	WebsiteName string `json:"websiteName"`
+
+	// ImageTag is the image tag the preview runs, usually built from the branch or pull request
+	//+kubebuilder:validation:Pattern=`^[-a-z0-9]*$`
+	// +optional
+	ImageTag string `json:"imageTag,omitempty"`
+
+	// Host the preview is served on. Defaults to <preview>.<host of the website>.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// TTL is how long after its creation the preview is deleted. Defaults to 72 hours.
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+}
+
+// WebsitePreviewStatus defines the observed state of WebsitePreview
+type WebsitePreviewStatus struct {
+	// Host the preview is served on, if the website has an Ingress
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// ExpiresAt is when the preview will be deleted
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// Message describes why the preview could not be created
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Website",type=string,JSONPath=`.spec.websiteName`
+//+kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.status.host`
+//+kubebuilder:printcolumn:name="Expires",type=date,JSONPath=`.status.expiresAt`
+
+// WebsitePreview is the Schema for the websitepreviews API. It runs a short-lived copy
+// of a Website, named after the preview, for reviewing a branch or pull request.
+type WebsitePreview struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WebsitePreviewSpec   `json:"spec,omitempty"`
+	Status WebsitePreviewStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WebsitePreviewList contains a list of WebsitePreview
+type WebsitePreviewList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WebsitePreview `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WebsitePreview{}, &WebsitePreviewList{})
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WebsiteSnapshotSpec defines the desired state of WebsiteSnapshot
+type WebsiteSnapshotSpec struct {
+	// WebsiteName is the Website in the snapshot namespace to capture
+	WebsiteName string `json:"websiteName"`
+
+	// IncludeContent also copies the ConfigMap or Secret holding the website content
+	// +optional
+	IncludeContent bool `json:"includeContent,omitempty"`
+}
+
+// WebsiteSnapshotStatus defines the observed state of WebsiteSnapshot. A snapshot is
+// captured once and never changes afterwards.
+type WebsiteSnapshotStatus struct {
+	// CapturedAt is when the snapshot was captured
+	// +optional
+	CapturedAt *metav1.Time `json:"capturedAt,omitempty"`
+
+	// WebsiteSpec is the spec of the website when it was captured
+	// +optional
+	WebsiteSpec *WebsiteSpec `json:"websiteSpec,omitempty"`
+
+	// ImageDigest is the digest of the image the website pods were running
+	// +optional
+	ImageDigest string `json:"imageDigest,omitempty"`
+
+	// Content is the copy of the website content owned by the snapshot
+	// +optional
+	Content *ContentSpec `json:"content,omitempty"`
+
+	// Message describes why the snapshot could not be captured
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Website",type=string,JSONPath=`.spec.websiteName`
+//+kubebuilder:printcolumn:name="Captured",type=date,JSONPath=`.status.capturedAt`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// WebsiteSnapshot is the Schema for the websitesnapshots API. It captures a Website so
+// that it can later be restored through the fromSnapshot field of a Website.
+type WebsiteSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WebsiteSnapshotSpec   `json:"spec,omitempty"`
+	Status WebsiteSnapshotStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WebsiteSnapshotList contains a list of WebsiteSnapshot
+type WebsiteSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WebsiteSnapshot `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WebsiteSnapshot{}, &WebsiteSnapshotList{})
+}
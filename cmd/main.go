@@ -17,22 +17,40 @@ limitations under the License.
 package main
 
 import (
+	"crypto/x509"
 	"flag"
+	"fmt"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/time/rate"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
 	"github.com/mvasilenko/helloworld-operator/internal/controller"
+	"github.com/mvasilenko/helloworld-operator/internal/diagnostics"
+	"github.com/mvasilenko/helloworld-operator/internal/health"
+	"github.com/mvasilenko/helloworld-operator/internal/sharding"
+	"github.com/mvasilenko/helloworld-operator/internal/trigger"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -44,6 +62,8 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+
 	utilruntime.Must(devv1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
@@ -52,26 +72,170 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var enablePprof bool
+	var pprofAddr string
+	var logLevel string
+	var logFormat string
+	var dryRun bool
+	var maxWebsitesPerNamespace int
+	var maxReplicasPerNamespace int
+	var enableSharding bool
+	var shardingNamespace string
+	var shardLeaseDuration time.Duration
+	var reconcileBaseDelay time.Duration
+	var reconcileMaxDelay time.Duration
+	var reconcileQPS float64
+	var reconcileBurst int
+	var kubeAPIQPS float64
+	var kubeAPIBurst int
+	var triggerAddr string
+	var triggerTokenFile string
+	var registryCredentials string
+	var registryMirrors string
+	var httpProxy, httpsProxy, noProxy string
+	var sigstoreRootsFile string
+	var prometheusURL string
+	var blackboxExporterURL string
+	var certificateExpiryWarning time.Duration
+	var disallowedTags string
+	var tagPolicy string
+	var tagPolicyExemptNamespaces string
+	var maxReconcileFailures int
+	var failedRetryInterval time.Duration
+	var watchNamespace string
+	var namePrefix string
+	var nameSuffix string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enablePprof, "enable-pprof", false,
+		"Expose net/http/pprof and runtime/metrics on the diagnostics bind address.")
+	flag.StringVar(&pprofAddr, "pprof-bind-address", ":8082", "The address the diagnostics endpoint binds to.")
+	flag.StringVar(&logLevel, "log-level", "info",
+		"Log verbosity: one of 'debug', 'info', 'error', or an integer verbosity greater than 0.")
+	flag.StringVar(&logFormat, "log-format", "console", "Log encoding: one of 'console' or 'json'.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Compute and log the changes the operator would make without persisting any of them.")
+	flag.IntVar(&maxWebsitesPerNamespace, "max-websites-per-namespace", 0,
+		"The maximum number of Websites a namespace may have. Zero means unlimited.")
+	flag.IntVar(&maxReplicasPerNamespace, "max-replicas-per-namespace", 0,
+		"The maximum number of Website replicas a namespace may have in total. Zero means unlimited.")
+	flag.BoolVar(&enableSharding, "enable-sharding", false,
+		"Split Websites between all running replicas by consistent hashing instead of electing a single leader. "+
+			"Cannot be combined with --leader-elect.")
+	flag.StringVar(&shardingNamespace, "sharding-namespace", "",
+		"The namespace holding the shard membership Leases. Defaults to the namespace the operator runs in.")
+	flag.DurationVar(&shardLeaseDuration, "shard-lease-duration", 15*time.Second,
+		"How long a replica keeps its shard after it stops renewing its membership Lease.")
+	flag.DurationVar(&reconcileBaseDelay, "reconcile-base-delay", 5*time.Millisecond,
+		"The delay before the first retry of a failed reconciliation, doubled on every further failure.")
+	flag.DurationVar(&reconcileMaxDelay, "reconcile-max-delay", 1000*time.Second,
+		"The maximum delay between retries of a failed reconciliation.")
+	flag.Float64Var(&reconcileQPS, "reconcile-qps", 10,
+		"The overall rate at which Websites are taken off the reconciliation queue.")
+	flag.IntVar(&reconcileBurst, "reconcile-burst", 100,
+		"The number of Websites that may be reconciled in a burst above --reconcile-qps.")
+	flag.Float64Var(&kubeAPIQPS, "kube-api-qps", 20, "The QPS limit of the Kubernetes API client.")
+	flag.IntVar(&kubeAPIBurst, "kube-api-burst", 30, "The burst limit of the Kubernetes API client.")
+	flag.StringVar(&registryCredentials, "registry-credentials-secret", "",
+		"The <namespace>/<name> of a docker config Secret copied into the namespace of every Website "+
+			"whose image comes from one of its registries.")
+	flag.StringVar(&registryMirrors, "registry-mirrors", "",
+		"Comma-separated <registry>=<mirror> pairs rewriting the images of Websites and of the pods the operator "+
+			"runs for them to pull from mirrors, e.g. 'docker.io=mirror.example.com/dockerhub'.")
+	flag.StringVar(&httpProxy, "http-proxy", "",
+		"The proxy set in HTTP_PROXY in every container of the pods the operator runs for Websites.")
+	flag.StringVar(&httpsProxy, "https-proxy", "",
+		"The proxy set in HTTPS_PROXY in every container of the pods the operator runs for Websites.")
+	flag.StringVar(&noProxy, "no-proxy", "",
+		"The hosts and networks set in NO_PROXY in every container of the pods the operator runs for Websites, "+
+			"such as the cluster service network.")
+	flag.StringVar(&sigstoreRootsFile, "sigstore-roots-file", "",
+		"The PEM file holding the certificate authorities trusted to issue keyless image signing certificates, "+
+			"such as the Fulcio root of the public Sigstore instance.")
+	flag.StringVar(&prometheusURL, "prometheus-url", "",
+		"The address of the Prometheus server rollout analyses query, unless they name their own.")
+	flag.StringVar(&blackboxExporterURL, "blackbox-exporter-url", "",
+		"The host and port of the blackbox exporter probing the URL of monitored Websites.")
+	flag.DurationVar(&certificateExpiryWarning, "certificate-expiry-warning", 14*24*time.Hour,
+		"How long before its expiry the serving certificate of a Website is reported as expiring.")
+	flag.IntVar(&maxReconcileFailures, "max-reconcile-failures", 5,
+		"How many reconciliations of a Website may fail in a row before it is marked Failed.")
+	flag.DurationVar(&failedRetryInterval, "failed-retry-interval", 10*time.Minute,
+		"How often a Website marked Failed is retried.")
+	flag.StringVar(&disallowedTags, "disallowed-image-tags", "latest",
+		"Comma-separated image tags Websites may not use unless pinned to a digest.")
+	flag.StringVar(&tagPolicy, "image-tag-policy", "",
+		"What happens to Websites using a disallowed image tag: 'Reject', 'Warn', or empty to allow them.")
+	flag.StringVar(&tagPolicyExemptNamespaces, "image-tag-policy-exempt-namespaces", "",
+		"Comma-separated namespaces the image tag policy does not apply to.")
+	flag.StringVar(&watchNamespace, "watch-namespace", "",
+		"Watch only the Websites of this namespace, with namespaced permissions only. "+
+			"ClusterWebsites and WebsiteClasses are not supported in this mode.")
+	flag.StringVar(&namePrefix, "name-prefix", "",
+		"A prefix added to the names of the resources generated for every Website, e.g. 'web-'. "+
+			"Changing it renames the resources of existing Websites.")
+	flag.StringVar(&nameSuffix, "name-suffix", "",
+		"A suffix added to the names of the resources generated for every Website, e.g. '-prod'. "+
+			"Changing it renames the resources of existing Websites.")
+	flag.StringVar(&triggerAddr, "trigger-bind-address", "",
+		"The address the redeploy trigger endpoint binds to. Empty disables the endpoint.")
+	flag.StringVar(&triggerTokenFile, "trigger-token-file", "",
+		"The file holding the token callers of the trigger endpoint authenticate with, "+
+			"also used as secret of GitHub webhooks. Required with --trigger-bind-address.")
 	opts := zap.Options{
 		Development: true,
 	}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	level, err := parseLogLevel(logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	encoder, err := parseLogFormat(logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// The level is atomic so that it can be changed at runtime through the metrics endpoint.
+	atomicLevel := uberzap.NewAtomicLevelAt(level)
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts), zap.Level(atomicLevel), encoder))
+
+	if tagPolicy != "" && tagPolicy != string(devv1.TagPolicyReject) && tagPolicy != string(devv1.TagPolicyWarn) {
+		fmt.Fprintf(os.Stderr, "invalid --image-tag-policy %q\n", tagPolicy)
+		os.Exit(1)
+	}
+
+	for _, affix := range []string{namePrefix, nameSuffix} {
+		if affix != "" && !validNameAffix.MatchString(affix) {
+			fmt.Fprintf(os.Stderr, "invalid name prefix or suffix %q\n", affix)
+			os.Exit(1)
+		}
+	}
+	controller.SetNameAffixes(namePrefix, nameSuffix)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	if enableSharding && enableLeaderElection {
+		setupLog.Error(nil, "--enable-sharding and --leader-elect are mutually exclusive")
+		os.Exit(1)
+	}
+
+	restConfig := ctrl.GetConfigOrDie()
+	restConfig.QPS = float32(kubeAPIQPS)
+	restConfig.Burst = kubeAPIBurst
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "2fdd5e51.mvasilenko.me",
+		Namespace:              watchNamespace,
+		NewCache:               cache.BuilderWithOptions(cache.Options{SelectorsByObject: controller.CacheSelectors()}),
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -89,15 +253,193 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = (&controller.WebsiteReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
+	var sharder *sharding.Sharder
+	if enableSharding {
+		if shardingNamespace == "" {
+			shardingNamespace = inClusterNamespace()
+		}
+		identity, err := os.Hostname()
+		if err != nil {
+			setupLog.Error(err, "unable to determine shard identity")
+			os.Exit(1)
+		}
+		sharder = &sharding.Sharder{
+			Client:        mgr.GetClient(),
+			Reader:        mgr.GetAPIReader(),
+			Namespace:     shardingNamespace,
+			Identity:      identity,
+			LeaseDuration: shardLeaseDuration,
+			RenewInterval: shardLeaseDuration / 3,
+		}
+		if err := mgr.Add(sharder); err != nil {
+			setupLog.Error(err, "unable to set up sharding")
+			os.Exit(1)
+		}
+	}
+
+	reconcilerClient := mgr.GetClient()
+	if dryRun {
+		setupLog.Info("dry-run mode enabled, no changes will be persisted")
+		reconcilerClient = controller.NewDryRunClient(reconcilerClient)
+	}
+
+	reconciler := &controller.WebsiteReconciler{
+		Client:    reconcilerClient,
+		Scheme:    mgr.GetScheme(),
+		APIReader: mgr.GetAPIReader(),
+		Recorder:  mgr.GetEventRecorderFor("website-controller"),
+
+		PrometheusURL:       prometheusURL,
+		BlackboxExporterURL: blackboxExporterURL,
+
+		CertificateExpiryWarning: certificateExpiryWarning,
+
+		HTTPProxy:  httpProxy,
+		HTTPSProxy: httpsProxy,
+		NoProxy:    noProxy,
+
+		MaxFailures:         maxReconcileFailures,
+		FailedRetryInterval: failedRetryInterval,
+		// Same shape as the controller-runtime default, a per-item exponential backoff
+		// combined with an overall token bucket, but with tunable parameters.
+		RateLimiter: workqueue.NewMaxOfRateLimiter(
+			workqueue.NewItemExponentialFailureRateLimiter(reconcileBaseDelay, reconcileMaxDelay),
+			&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(reconcileQPS), reconcileBurst)},
+		),
+	}
+	if sharder != nil {
+		reconciler.Sharder = sharder
+	}
+	if watchNamespace != "" {
+		setupLog.Info("watching a single namespace", "namespace", watchNamespace)
+		reconciler.Namespace = watchNamespace
+	}
+	if registryCredentials != "" {
+		namespace, name, ok := strings.Cut(registryCredentials, "/")
+		if !ok || namespace == "" || name == "" {
+			setupLog.Error(nil, "--registry-credentials-secret must be <namespace>/<name>")
+			os.Exit(1)
+		}
+		if watchNamespace != "" && namespace != watchNamespace {
+			setupLog.Error(nil, "--registry-credentials-secret must be in the watched namespace")
+			os.Exit(1)
+		}
+		reconciler.RegistryCredentials = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+	for _, pair := range splitList(registryMirrors) {
+		upstream, mirror, ok := strings.Cut(pair, "=")
+		if !ok || upstream == "" || mirror == "" {
+			setupLog.Error(nil, "--registry-mirrors must be a list of <registry>=<mirror>", "pair", pair)
+			os.Exit(1)
+		}
+		if reconciler.RegistryMirrors == nil {
+			reconciler.RegistryMirrors = map[string]string{}
+		}
+		reconciler.RegistryMirrors[upstream] = mirror
+	}
+	if sigstoreRootsFile != "" {
+		roots, err := os.ReadFile(sigstoreRootsFile)
+		if err != nil {
+			setupLog.Error(err, "unable to read sigstore roots")
+			os.Exit(1)
+		}
+		reconciler.SigstoreRoots = x509.NewCertPool()
+		if !reconciler.SigstoreRoots.AppendCertsFromPEM(roots) {
+			setupLog.Error(nil, "no certificates found in sigstore roots", "file", sigstoreRootsFile)
+			os.Exit(1)
+		}
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Website")
 		os.Exit(1)
 	}
+	// ClusterWebsites are cluster-scoped and create Websites in any namespace.
+	if watchNamespace == "" {
+		clusterWebsiteReconciler := &controller.ClusterWebsiteReconciler{
+			Client: reconcilerClient,
+			Scheme: mgr.GetScheme(),
+		}
+		if sharder != nil {
+			clusterWebsiteReconciler.Sharder = sharder
+		}
+		if err = clusterWebsiteReconciler.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ClusterWebsite")
+			os.Exit(1)
+		}
+	}
+	snapshotReconciler := &controller.WebsiteSnapshotReconciler{
+		Client:    reconcilerClient,
+		Scheme:    mgr.GetScheme(),
+		APIReader: mgr.GetAPIReader(),
+	}
+	if sharder != nil {
+		snapshotReconciler.Sharder = sharder
+	}
+	if err = snapshotReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "WebsiteSnapshot")
+		os.Exit(1)
+	}
+	previewReconciler := &controller.WebsitePreviewReconciler{
+		Client: reconcilerClient,
+		Scheme: mgr.GetScheme(),
+	}
+	if sharder != nil {
+		previewReconciler.Sharder = sharder
+	}
+	if err = previewReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "WebsitePreview")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&devv1.WebsiteValidator{
+			Client:                  mgr.GetClient(),
+			APIReader:               mgr.GetAPIReader(),
+			MaxWebsitesPerNamespace: maxWebsitesPerNamespace,
+			MaxReplicasPerNamespace: int32(maxReplicasPerNamespace),
+
+			DisallowedTags:            splitList(disallowedTags),
+			TagPolicy:                 devv1.TagPolicy(tagPolicy),
+			TagPolicyExemptNamespaces: splitList(tagPolicyExemptNamespaces),
+
+			PodTemplate: reconciler.PodTemplate,
+		}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Website")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
+	if enablePprof {
+		if err := mgr.Add(&diagnostics.Server{Addr: pprofAddr}); err != nil {
+			setupLog.Error(err, "unable to set up diagnostics server")
+			os.Exit(1)
+		}
+	}
+
+	if triggerAddr != "" {
+		token, err := os.ReadFile(triggerTokenFile)
+		if err != nil || len(strings.TrimSpace(string(token))) == 0 {
+			setupLog.Error(err, "unable to read trigger token", "file", triggerTokenFile)
+			os.Exit(1)
+		}
+		if err := mgr.Add(&trigger.Server{
+			Addr:   triggerAddr,
+			Client: mgr.GetClient(),
+			Token:  strings.TrimSpace(string(token)),
+		}); err != nil {
+			setupLog.Error(err, "unable to set up trigger server")
+			os.Exit(1)
+		}
+	}
+
+	if err := mgr.AddMetricsExtraHandler("/log-level", &diagnostics.LogLevelHandler{
+		Level: atomicLevel,
+		Parse: parseLogLevel,
+	}); err != nil {
+		setupLog.Error(err, "unable to set up log level endpoint")
+		os.Exit(1)
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -106,6 +448,32 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	// CRDs are cluster-scoped, an operator watching a single namespace cannot read them.
+	if watchNamespace == "" {
+		if err := mgr.AddReadyzCheck("crds", health.CRDsEstablished(mgr.GetAPIReader(),
+			"websites.dev.mvasilenko.me", "clusterwebsites.dev.mvasilenko.me",
+			"websiteclasses.dev.mvasilenko.me", "websitesnapshots.dev.mvasilenko.me", "websitepreviews.dev.mvasilenko.me")); err != nil {
+			setupLog.Error(err, "unable to set up ready check")
+			os.Exit(1)
+		}
+	}
+	// A sample of what the operator cannot work without, checked cluster-wide unless it
+	// watches a single namespace.
+	if err := mgr.AddReadyzCheck("rbac", health.Permissions(mgr.GetClient(),
+		authorizationv1.ResourceAttributes{Namespace: watchNamespace, Verb: "watch", Group: devv1.GroupVersion.Group, Resource: "websites"},
+		authorizationv1.ResourceAttributes{Namespace: watchNamespace, Verb: "patch", Group: devv1.GroupVersion.Group, Resource: "websites", Subresource: "status"},
+		authorizationv1.ResourceAttributes{Namespace: watchNamespace, Verb: "create", Group: "apps", Resource: "deployments"},
+		authorizationv1.ResourceAttributes{Namespace: watchNamespace, Verb: "create", Resource: "services"},
+	)); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err := mgr.AddReadyzCheck("webhook", mgr.GetWebhookServer().StartedChecker()); err != nil {
+			setupLog.Error(err, "unable to set up ready check")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -113,3 +481,56 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// validNameAffix matches the name prefixes and suffixes keeping generated names valid
+// DNS labels.
+var validNameAffix = regexp.MustCompile(`^[-a-z0-9]{1,20}$`)
+
+// parseLogLevel accepts the named zap levels as well as integer verbosities, where
+// a verbosity of N enables logr V(N) messages.
+func parseLogLevel(level string) (zapcore.Level, error) {
+	switch level {
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	}
+	verbosity, err := strconv.Atoi(level)
+	if err != nil || verbosity <= 0 {
+		return 0, fmt.Errorf("invalid log level %q", level)
+	}
+	return zapcore.Level(-verbosity), nil
+}
+
+func parseLogFormat(format string) (zap.Opts, error) {
+	switch format {
+	case "console":
+		return zap.ConsoleEncoder(), nil
+	case "json":
+		return zap.JSONEncoder(), nil
+	}
+	return nil, fmt.Errorf("invalid --log-format %q", format)
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// inClusterNamespace returns the namespace the operator pod runs in, falling back to
+// "default" when running outside a cluster.
+func inClusterNamespace() string {
+	data, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err != nil {
+		return "default"
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,294 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// websitectl is a small companion CLI for the website operator. Installed on the PATH
+// as kubectl-website it also works as a kubectl plugin ("kubectl website list").
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const usage = `Usage: websitectl [-n namespace] <command> [website]
+
+Commands:
+  list                 List websites and their health
+  status <website>     Follow the rollout of a website until it completes
+  open <website>       Open the website URL in a browser
+  pause <website>      Stop the operator from reconciling a website
+  resume <website>     Resume reconciliation of a paused website
+  redeploy <website>   Restart all pods of a website
+  approve <website>    Approve the rollout of the image pending for a website
+  promote <website> <from> <to>
+                       Copy the image tag of an environment of a website to another
+`
+
+func main() {
+	var namespace string
+	flag.StringVar(&namespace, "n", "default", "The namespace of the websites.")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	scheme := k8sruntime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(devv1.AddToScheme(scheme))
+
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		fatal(err)
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		fatal(err)
+	}
+	cli := &websitectl{client: c, namespace: namespace}
+
+	ctx := context.Background()
+	command, args := flag.Arg(0), flag.Args()[1:]
+	switch {
+	case command == "list":
+		err = cli.list(ctx)
+	case command == "promote":
+		if len(args) != 3 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		name := types.NamespacedName{Name: args[0], Namespace: namespace}
+		err = cli.annotate(ctx, name, devv1.PromoteAnnotation, args[1]+"="+args[2])
+	default:
+		if len(args) != 1 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		name := types.NamespacedName{Name: args[0], Namespace: namespace}
+		switch command {
+		case "status":
+			err = cli.status(ctx, name)
+		case "open":
+			err = cli.open(ctx, name)
+		case "pause":
+			err = cli.annotate(ctx, name, devv1.PausedAnnotation, "true")
+		case "resume":
+			err = cli.annotate(ctx, name, devv1.PausedAnnotation, "")
+		case "redeploy":
+			err = cli.annotate(ctx, name, devv1.RedeployAnnotation, time.Now().UTC().Format(time.RFC3339))
+		case "approve":
+			err = cli.approve(ctx, name)
+		default:
+			flag.Usage()
+			os.Exit(2)
+		}
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
+
+type websitectl struct {
+	client    client.Client
+	namespace string
+}
+
+func (w *websitectl) list(ctx context.Context) error {
+	websites := devv1.WebsiteList{}
+	if err := w.client.List(ctx, &websites, client.InNamespace(w.namespace)); err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(out, "NAME\tIMAGE TAG\tREADY\tHEALTH\tPAUSED")
+	for _, website := range websites.Items {
+		ready, health := "-", "Missing"
+		deployment := appsv1.Deployment{}
+		err := w.client.Get(ctx, types.NamespacedName{Name: website.Name, Namespace: website.Namespace}, &deployment)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if err == nil {
+			ready = fmt.Sprintf("%d/%d", deployment.Status.ReadyReplicas, desiredReplicas(&deployment))
+			health = deploymentHealth(&deployment)
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%t\n", website.Name, website.Spec.ImageTag, ready, health,
+			website.Annotations[devv1.PausedAnnotation] == "true")
+	}
+	return out.Flush()
+}
+
+func (w *websitectl) status(ctx context.Context, name types.NamespacedName) error {
+	for {
+		deployment := appsv1.Deployment{}
+		if err := w.client.Get(ctx, name, &deployment); err != nil {
+			return err
+		}
+		desired := desiredReplicas(&deployment)
+		status := deployment.Status
+		fmt.Printf("%s: %d of %d updated, %d ready, %d available\n",
+			name.Name, status.UpdatedReplicas, desired, status.ReadyReplicas, status.AvailableReplicas)
+		if deploymentHealth(&deployment) == "Healthy" {
+			fmt.Printf("website %q successfully rolled out\n", name.Name)
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func (w *websitectl) open(ctx context.Context, name types.NamespacedName) error {
+	url, err := w.url(ctx, name)
+	if err != nil {
+		return err
+	}
+	fmt.Println(url)
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
+
+// url resolves where a website is reachable from outside the cluster, preferring a
+// load balancer address over a node port.
+func (w *websitectl) url(ctx context.Context, name types.NamespacedName) (string, error) {
+	service := corev1.Service{}
+	if err := w.client.Get(ctx, name, &service); err != nil {
+		return "", err
+	}
+	if len(service.Spec.Ports) == 0 {
+		return "", fmt.Errorf("service %q exposes no ports", name.Name)
+	}
+	port := service.Spec.Ports[0]
+
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		host := ingress.Hostname
+		if host == "" {
+			host = ingress.IP
+		}
+		if host != "" {
+			return fmt.Sprintf("http://%s:%d", host, port.Port), nil
+		}
+	}
+
+	if port.NodePort == 0 {
+		return "", fmt.Errorf("website %q is not exposed outside the cluster", name.Name)
+	}
+	nodes := corev1.NodeList{}
+	if err := w.client.List(ctx, &nodes); err != nil {
+		return "", err
+	}
+	for _, node := range nodes.Items {
+		for _, addressType := range []corev1.NodeAddressType{corev1.NodeExternalIP, corev1.NodeInternalIP} {
+			for _, address := range node.Status.Addresses {
+				if address.Type == addressType {
+					return fmt.Sprintf("http://%s:%d", address.Address, port.NodePort), nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("no node address found for website %q", name.Name)
+}
+
+// approve approves the rollout of the image pending for a website.
+func (w *websitectl) approve(ctx context.Context, name types.NamespacedName) error {
+	website := devv1.Website{}
+	if err := w.client.Get(ctx, name, &website); err != nil {
+		return err
+	}
+	image := website.Status.PendingImage
+	if image == "" {
+		return fmt.Errorf("website %q has no image pending", name.Name)
+	}
+	fmt.Printf("approving %s\n", image)
+	return w.annotate(ctx, name, devv1.ApprovedImageAnnotation, image)
+}
+
+// annotate sets an annotation on a website, or removes it when value is empty.
+func (w *websitectl) annotate(ctx context.Context, name types.NamespacedName, key, value string) error {
+	website := devv1.Website{}
+	if err := w.client.Get(ctx, name, &website); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(website.DeepCopy())
+	if value == "" {
+		delete(website.Annotations, key)
+	} else {
+		if website.Annotations == nil {
+			website.Annotations = map[string]string{}
+		}
+		website.Annotations[key] = value
+	}
+	if err := w.client.Patch(ctx, &website, patch); err != nil {
+		return err
+	}
+	fmt.Printf("website %q annotated\n", name.Name)
+	return nil
+}
+
+func desiredReplicas(deployment *appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas == nil {
+		return 1
+	}
+	return *deployment.Spec.Replicas
+}
+
+func deploymentHealth(deployment *appsv1.Deployment) string {
+	desired := desiredReplicas(deployment)
+	status := deployment.Status
+	switch {
+	case status.ObservedGeneration < deployment.Generation || status.UpdatedReplicas < desired:
+		return "Progressing"
+	case status.AvailableReplicas < desired:
+		return "Degraded"
+	default:
+		return "Healthy"
+	}
+}
@@ -0,0 +1,354 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package acme is a minimal ACME (RFC 8555) client ordering certificates validated with
+// the http-01 challenge.
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LetsEncryptURL is the directory of the production Let's Encrypt ACME server.
+const LetsEncryptURL = "https://acme-v02.api.letsencrypt.org/directory"
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Client talks to an ACME server on behalf of the account of its key.
+type Client struct {
+	// DirectoryURL of the ACME server. Defaults to LetsEncryptURL.
+	DirectoryURL string
+	// Key of the account, a P-256 key.
+	Key *ecdsa.PrivateKey
+
+	mu         sync.Mutex
+	directory  *directory
+	nonces     []string
+	accountURL string
+}
+
+type directory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+// Problem is an error returned by an ACME server.
+type Problem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+func (p *Problem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Type, p.Detail)
+}
+
+// Order is a request for a certificate.
+type Order struct {
+	// URL of the order
+	URL string `json:"-"`
+	// Status is pending, ready, processing, valid or invalid
+	Status         string   `json:"status"`
+	Authorizations []string `json:"authorizations"`
+	Finalize       string   `json:"finalize"`
+	Certificate    string   `json:"certificate,omitempty"`
+	Error          *Problem `json:"error,omitempty"`
+}
+
+// Authorization proves control over an identifier of an order.
+type Authorization struct {
+	// Status is pending, valid, invalid, deactivated, expired or revoked
+	Status     string `json:"status"`
+	Identifier struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"identifier"`
+	Challenges []Challenge `json:"challenges"`
+}
+
+// Challenge is a way to prove control over an identifier.
+type Challenge struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	// Status is pending, processing, valid or invalid
+	Status string   `json:"status"`
+	Token  string   `json:"token"`
+	Error  *Problem `json:"error,omitempty"`
+}
+
+// Register creates the account of the client key, or finds it when it already exists.
+func (c *Client) Register(ctx context.Context, email string) error {
+	dir, err := c.discover(ctx)
+	if err != nil {
+		return err
+	}
+	account := map[string]interface{}{"termsOfServiceAgreed": true}
+	if email != "" {
+		account["contact"] = []string{"mailto:" + email}
+	}
+	_, header, err := c.post(ctx, dir.NewAccount, account, nil)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.accountURL = header.Get("Location")
+	c.mu.Unlock()
+	return nil
+}
+
+// NewOrder orders a certificate for the given domains.
+func (c *Client) NewOrder(ctx context.Context, domains []string) (*Order, error) {
+	dir, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	identifiers := make([]map[string]string, 0, len(domains))
+	for _, domain := range domains {
+		identifiers = append(identifiers, map[string]string{"type": "dns", "value": domain})
+	}
+	order := &Order{}
+	header, err := c.postJSON(ctx, dir.NewOrder, map[string]interface{}{"identifiers": identifiers}, order)
+	if err != nil {
+		return nil, err
+	}
+	order.URL = header.Get("Location")
+	return order, nil
+}
+
+// Order returns the current state of an order.
+func (c *Client) Order(ctx context.Context, url string) (*Order, error) {
+	order := &Order{URL: url}
+	_, err := c.postJSON(ctx, url, nil, order)
+	return order, err
+}
+
+// Authorization returns the current state of an authorization.
+func (c *Client) Authorization(ctx context.Context, url string) (*Authorization, error) {
+	authorization := &Authorization{}
+	_, err := c.postJSON(ctx, url, nil, authorization)
+	return authorization, err
+}
+
+// Accept tells the server a challenge is ready to be validated.
+func (c *Client) Accept(ctx context.Context, challengeURL string) error {
+	_, err := c.postJSON(ctx, challengeURL, struct{}{}, &Challenge{})
+	return err
+}
+
+// Finalize requests the certificate of a ready order with a DER encoded CSR.
+func (c *Client) Finalize(ctx context.Context, order *Order, csr []byte) (*Order, error) {
+	finalized := &Order{URL: order.URL}
+	_, err := c.postJSON(ctx, order.Finalize, map[string]string{"csr": base64.RawURLEncoding.EncodeToString(csr)}, finalized)
+	return finalized, err
+}
+
+// Certificate downloads the PEM encoded certificate chain of a valid order.
+func (c *Client) Certificate(ctx context.Context, url string) ([]byte, error) {
+	body, _, err := c.post(ctx, url, nil, map[string]string{"Accept": "application/pem-certificate-chain"})
+	return body, err
+}
+
+// KeyAuthorization returns the content served for the http-01 challenge of a token.
+func (c *Client) KeyAuthorization(token string) string {
+	sum := sha256.Sum256([]byte(c.jwk()))
+	return token + "." + base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// jwk returns the public key of the client as a JSON Web Key, with its members in the
+// lexicographic order its thumbprint is computed over.
+func (c *Client) jwk() string {
+	size := (c.Key.Curve.Params().BitSize + 7) / 8
+	return fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`, c.Key.Curve.Params().Name,
+		base64.RawURLEncoding.EncodeToString(c.Key.X.FillBytes(make([]byte, size))),
+		base64.RawURLEncoding.EncodeToString(c.Key.Y.FillBytes(make([]byte, size))))
+}
+
+func (c *Client) discover(ctx context.Context) (*directory, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.directory != nil {
+		return c.directory, nil
+	}
+	url := c.DirectoryURL
+	if url == "" {
+		url = LetsEncryptURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	dir := &directory{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dir); err != nil {
+		return nil, fmt.Errorf("reading ACME directory %s: %w", url, err)
+	}
+	c.directory = dir
+	return dir, nil
+}
+
+// nonce returns a nonce left by a previous response, or a new one.
+func (c *Client) nonce(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	if n := len(c.nonces); n > 0 {
+		nonce := c.nonces[n-1]
+		c.nonces = c.nonces[:n-1]
+		c.mu.Unlock()
+		return nonce, nil
+	}
+	c.mu.Unlock()
+
+	dir, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, dir.NewNonce, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if nonce := resp.Header.Get("Replay-Nonce"); nonce != "" {
+		return nonce, nil
+	}
+	return "", errors.New("ACME server returned no nonce")
+}
+
+func (c *Client) postJSON(ctx context.Context, url string, payload, result interface{}) (http.Header, error) {
+	body, header, err := c.post(ctx, url, payload, nil)
+	if err != nil {
+		return nil, err
+	}
+	return header, json.Unmarshal(body, result)
+}
+
+// post sends a JWS signed request, or a POST-as-GET one without payload. Requests are
+// retried once with a fresh nonce when the server rejects theirs.
+func (c *Client) post(ctx context.Context, url string, payload interface{}, headers map[string]string) ([]byte, http.Header, error) {
+	for attempt := 0; ; attempt++ {
+		body, header, err := c.postOnce(ctx, url, payload, headers)
+		var problem *Problem
+		if attempt == 0 && errors.As(err, &problem) && problem.Type == "urn:ietf:params:acme:error:badNonce" {
+			continue
+		}
+		return body, header, err
+	}
+}
+
+func (c *Client) postOnce(ctx context.Context, url string, payload interface{}, headers map[string]string) ([]byte, http.Header, error) {
+	nonce, err := c.nonce(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	signed, err := c.sign(url, nonce, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(signed))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/jose+json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if nonce := resp.Header.Get("Replay-Nonce"); nonce != "" {
+		c.mu.Lock()
+		c.nonces = append(c.nonces, nonce)
+		c.mu.Unlock()
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode >= 400 {
+		problem := &Problem{Status: resp.StatusCode}
+		if err := json.Unmarshal(body, problem); err != nil || problem.Type == "" {
+			return nil, nil, fmt.Errorf("POST %s: %s", url, resp.Status)
+		}
+		return nil, nil, problem
+	}
+	return body, resp.Header, nil
+}
+
+// sign wraps a payload in a flattened JWS signed with the account key. The account is
+// identified by its key until it is registered, and by its URL from then on.
+func (c *Client) sign(url, nonce string, payload interface{}) ([]byte, error) {
+	protected := map[string]interface{}{"alg": "ES256", "nonce": nonce, "url": url}
+	c.mu.Lock()
+	accountURL := c.accountURL
+	c.mu.Unlock()
+	if accountURL != "" {
+		protected["kid"] = accountURL
+	} else {
+		protected["jwk"] = json.RawMessage(c.jwk())
+	}
+	encodedProtected, err := json.Marshal(protected)
+	if err != nil {
+		return nil, err
+	}
+	encodedPayload := []byte{}
+	if payload != nil {
+		if encodedPayload, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	input := base64.RawURLEncoding.EncodeToString(encodedProtected) + "." + base64.RawURLEncoding.EncodeToString(encodedPayload)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, c.Key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	parts := strings.SplitN(input, ".", 2)
+	return json.Marshal(map[string]string{
+		"protected": parts[0],
+		"payload":   parts[1],
+		"signature": base64.RawURLEncoding.EncodeToString(signature),
+	})
+}
+
+// NewKey generates a P-256 key, for accounts and certificates alike.
+func NewKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
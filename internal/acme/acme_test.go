@@ -0,0 +1,154 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// verify checks a flattened JWS signed by a P-256 key.
+func verify(key *ecdsa.PublicKey, jws []byte) ([]byte, map[string]interface{}, error) {
+	var flattened struct{ Protected, Payload, Signature string }
+	if err := json.Unmarshal(jws, &flattened); err != nil {
+		return nil, nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(flattened.Signature)
+	if err != nil || len(signature) != 64 {
+		return nil, nil, errors.New("malformed signature")
+	}
+	digest := sha256.Sum256([]byte(flattened.Protected + "." + flattened.Payload))
+	if !ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		return nil, nil, errors.New("invalid signature")
+	}
+	protected, _ := base64.RawURLEncoding.DecodeString(flattened.Protected)
+	header := map[string]interface{}{}
+	if err := json.Unmarshal(protected, &header); err != nil {
+		return nil, nil, err
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(flattened.Payload)
+	return payload, header, nil
+}
+
+func TestClient(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var server *httptest.Server
+	nonces, rejectedNonce := 0, false
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces++
+		w.Header().Set("Replay-Nonce", fmt.Sprint("nonce-", nonces))
+		switch r.URL.Path {
+		case "/directory":
+			fmt.Fprintf(w, `{"newNonce":"%[1]s/nonce","newAccount":"%[1]s/account","newOrder":"%[1]s/order"}`, server.URL)
+			return
+		case "/nonce":
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		payload, header, err := verify(&key.PublicKey, body)
+		if err != nil {
+			t.Errorf("%s: %v", r.URL.Path, err)
+		}
+		if header["url"] != server.URL+r.URL.Path {
+			t.Errorf("%s: signed for url %v", r.URL.Path, header["url"])
+		}
+		// The first nonce used is rejected, to check requests are retried with a fresh one.
+		if !rejectedNonce {
+			rejectedNonce = true
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"type":"urn:ietf:params:acme:error:badNonce","detail":"bad nonce"}`)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/account":
+			if _, ok := header["jwk"]; !ok {
+				t.Error("account request is not signed with the key")
+			}
+			w.Header().Set("Location", server.URL+"/account/1")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"status":"valid"}`)
+		case "/order":
+			if header["kid"] != server.URL+"/account/1" {
+				t.Errorf("order request is signed by %v, want the account", header["kid"])
+			}
+			if !strings.Contains(string(payload), `"value":"www.example.com"`) {
+				t.Errorf("order payload = %s", payload)
+			}
+			w.Header().Set("Location", server.URL+"/order/1")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"status":"pending","authorizations":["%[1]s/authz/1"],"finalize":"%[1]s/order/1/finalize"}`, server.URL)
+		case "/order/1/finalize":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"type":"urn:ietf:params:acme:error:orderNotReady","detail":"order is not ready"}`)
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{DirectoryURL: server.URL + "/directory", Key: key}
+	if err := c.Register(context.Background(), "admin@example.com"); err != nil {
+		t.Fatalf("Register() = %v", err)
+	}
+	order, err := c.NewOrder(context.Background(), []string{"www.example.com"})
+	if err != nil {
+		t.Fatalf("NewOrder() = %v", err)
+	}
+	if order.URL != server.URL+"/order/1" || order.Status != "pending" || len(order.Authorizations) != 1 {
+		t.Errorf("NewOrder() = %+v", order)
+	}
+
+	_, err = c.Finalize(context.Background(), order, []byte("csr"))
+	var problem *Problem
+	if !errors.As(err, &problem) || problem.Type != "urn:ietf:params:acme:error:orderNotReady" {
+		t.Errorf("Finalize() = %v, want the orderNotReady problem", err)
+	}
+}
+
+func TestKeyAuthorization(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{Key: key}
+	jwk := map[string]string{}
+	if err := json.Unmarshal([]byte(c.jwk()), &jwk); err != nil {
+		t.Fatalf("jwk() is not JSON: %v", err)
+	}
+	x, _ := base64.RawURLEncoding.DecodeString(jwk["x"])
+	if new(big.Int).SetBytes(x).Cmp(key.X) != 0 || jwk["crv"] != "P-256" {
+		t.Errorf("jwk() = %v does not hold the public key", jwk)
+	}
+	sum := sha256.Sum256([]byte(c.jwk()))
+	if got, want := c.KeyAuthorization("token"), "token."+base64.RawURLEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("KeyAuthorization() = %q, want %q", got, want)
+	}
+}
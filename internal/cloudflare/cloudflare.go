@@ -0,0 +1,207 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cloudflare manages the DNS records of a host through the Cloudflare API.
+package cloudflare
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the address of the Cloudflare API.
+const DefaultBaseURL = "https://api.cloudflare.com/client/v4"
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Client calls the Cloudflare API with an API token.
+type Client struct {
+	// Token is an API token allowed to edit the DNS records of the zones, and to read
+	// them to look zones up by name.
+	Token string
+	// BaseURL of the API. Defaults to DefaultBaseURL.
+	BaseURL string
+}
+
+// Record is a DNS record. Records of the same name and type hold one target each.
+type Record struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int64  `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// response is the envelope of every Cloudflare API response.
+type response struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+// do sends a request to the API and decodes the result of its response into result.
+func (c *Client) do(ctx context.Context, method, path string, body, result interface{}) error {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(baseURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	var envelope response
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("%s %s: %s: %w", method, path, resp.Status, err)
+	}
+	if !envelope.Success {
+		messages := make([]string, 0, len(envelope.Errors))
+		for _, e := range envelope.Errors {
+			messages = append(messages, fmt.Sprintf("%s (%d)", e.Message, e.Code))
+		}
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.Join(messages, ", "))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(envelope.Result, result)
+}
+
+// FindZone returns the ID of the zone a host belongs to, the one with the longest name
+// the host ends with.
+func (c *Client) FindZone(ctx context.Context, host string) (string, error) {
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	for i := 0; i < len(labels)-1; i++ {
+		var zones []struct {
+			ID string `json:"id"`
+		}
+		name := strings.Join(labels[i:], ".")
+		if err := c.do(ctx, http.MethodGet, "/zones?"+url.Values{"name": {name}}.Encode(), nil, &zones); err != nil {
+			return "", err
+		}
+		if len(zones) > 0 {
+			return zones[0].ID, nil
+		}
+	}
+	return "", fmt.Errorf("no zone found for %s", host)
+}
+
+// Records lists the records of a name in a zone.
+func (c *Client) Records(ctx context.Context, zoneID, name string) ([]Record, error) {
+	var records []Record
+	path := fmt.Sprintf("/zones/%s/dns_records?%s", url.PathEscape(zoneID), url.Values{"name": {name}, "per_page": {"100"}}.Encode())
+	return records, c.do(ctx, http.MethodGet, path, nil, &records)
+}
+
+// Change is a write to a record of a name: a create, update or delete.
+type Change struct {
+	Action string
+	Record Record
+}
+
+// Plan returns the changes making the records of a name holding the given comment match
+// the desired records, reading the records but writing nothing.
+func (c *Client) Plan(ctx context.Context, zoneID, name, comment string, desired []Record) ([]Change, error) {
+	existing, err := c.Records(ctx, zoneID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	managed := map[string]Record{}
+	for _, record := range existing {
+		if record.Comment == comment {
+			managed[record.Type+" "+record.Content] = record
+		}
+	}
+	var changes []Change
+	for _, record := range desired {
+		record.Name, record.Comment = name, comment
+		key := record.Type + " " + record.Content
+		current, ok := managed[key]
+		delete(managed, key)
+		switch {
+		case !ok:
+			changes = append(changes, Change{Action: "create", Record: record})
+		case current.TTL != record.TTL || current.Proxied != record.Proxied:
+			record.ID = current.ID
+			changes = append(changes, Change{Action: "update", Record: record})
+		}
+	}
+	for _, record := range managed {
+		changes = append(changes, Change{Action: "delete", Record: record})
+	}
+	return changes, nil
+}
+
+// Sync makes the records of a name holding the given comment match the desired records.
+// The comment tells the records managed by the caller apart from the others of the name,
+// which are left alone.
+func (c *Client) Sync(ctx context.Context, zoneID, name, comment string, desired []Record) error {
+	changes, err := c.Plan(ctx, zoneID, name, comment, desired)
+	if err != nil {
+		return err
+	}
+	records := fmt.Sprintf("/zones/%s/dns_records", url.PathEscape(zoneID))
+	for _, change := range changes {
+		record := change.Record
+		switch change.Action {
+		case "create":
+			err = c.do(ctx, http.MethodPost, records, record, nil)
+		case "update":
+			id := record.ID
+			record.ID = ""
+			err = c.do(ctx, http.MethodPut, records+"/"+url.PathEscape(id), record, nil)
+		case "delete":
+			err = c.do(ctx, http.MethodDelete, records+"/"+url.PathEscape(record.ID), nil, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
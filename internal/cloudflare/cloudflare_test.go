@@ -0,0 +1,170 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fakeAPI serves the zones and DNS records endpoints from memory.
+type fakeAPI struct {
+	zones   map[string]string
+	records map[string]Record
+	nextID  int
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"success":false,"errors":[{"code":9109,"message":"Invalid access token"}]}`)
+		return
+	}
+
+	var result interface{}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "zones":
+		zones := []map[string]string{}
+		if id, ok := f.zones[r.URL.Query().Get("name")]; ok {
+			zones = append(zones, map[string]string{"id": id})
+		}
+		result = zones
+	case len(parts) == 3 && r.Method == http.MethodGet:
+		records := []Record{}
+		for _, record := range f.records {
+			if record.Name == r.URL.Query().Get("name") {
+				records = append(records, record)
+			}
+		}
+		result = records
+	case len(parts) == 3 && r.Method == http.MethodPost:
+		var record Record
+		_ = json.NewDecoder(r.Body).Decode(&record)
+		f.nextID++
+		record.ID = fmt.Sprint(f.nextID)
+		f.records[record.ID] = record
+		result = record
+	case len(parts) == 4 && r.Method == http.MethodPut:
+		var record Record
+		_ = json.NewDecoder(r.Body).Decode(&record)
+		record.ID = parts[3]
+		f.records[record.ID] = record
+		result = record
+	case len(parts) == 4 && r.Method == http.MethodDelete:
+		delete(f.records, parts[3])
+		result = map[string]string{"id": parts[3]}
+	}
+	encoded, _ := json.Marshal(result)
+	fmt.Fprintf(w, `{"success":true,"errors":[],"result":%s}`, encoded)
+}
+
+func (f *fakeAPI) list() []string {
+	var list []string
+	for _, record := range f.records {
+		list = append(list, fmt.Sprintf("%s %s %s %d %t %s", record.Name, record.Type, record.Content, record.TTL, record.Proxied, record.Comment))
+	}
+	sort.Strings(list)
+	return list
+}
+
+func TestFindZone(t *testing.T) {
+	server := httptest.NewServer(&fakeAPI{zones: map[string]string{"example.com": "zone1"}})
+	defer server.Close()
+	c := &Client{Token: "token", BaseURL: server.URL}
+
+	if id, err := c.FindZone(context.Background(), "www.shop.example.com"); err != nil || id != "zone1" {
+		t.Errorf("FindZone() = %q, %v, want zone1", id, err)
+	}
+	if _, err := c.FindZone(context.Background(), "www.example.org"); err == nil {
+		t.Error("FindZone() of a host outside the zones succeeded, expected an error")
+	}
+	if _, err := (&Client{Token: "wrong", BaseURL: server.URL}).FindZone(context.Background(), "example.com"); err == nil ||
+		!strings.Contains(err.Error(), "Invalid access token") {
+		t.Errorf("FindZone() with an invalid token = %v, expected the API error", err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	api := &fakeAPI{records: map[string]Record{
+		"a": {ID: "a", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: 1, Comment: "managed"},
+		"b": {ID: "b", Name: "www.example.com", Type: "A", Content: "192.0.2.2", TTL: 1, Comment: "managed"},
+		"c": {ID: "c", Name: "www.example.com", Type: "TXT", Content: "verification", TTL: 1},
+	}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	c := &Client{Token: "token", BaseURL: server.URL}
+
+	err := c.Sync(context.Background(), "zone1", "www.example.com", "managed", []Record{
+		{Type: "A", Content: "192.0.2.1", TTL: 1, Proxied: true},
+		{Type: "A", Content: "192.0.2.3", TTL: 1, Proxied: true},
+	})
+	if err != nil {
+		t.Fatalf("Sync() = %v", err)
+	}
+	want := []string{
+		"www.example.com A 192.0.2.1 1 true managed",
+		"www.example.com A 192.0.2.3 1 true managed",
+		"www.example.com TXT verification 1 false ",
+	}
+	if got := api.list(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("records after Sync() = %q, want %q", got, want)
+	}
+
+	if err := c.Sync(context.Background(), "zone1", "www.example.com", "managed", nil); err != nil {
+		t.Fatalf("Sync() = %v", err)
+	}
+	if got := api.list(); len(got) != 1 || !strings.Contains(got[0], "TXT") {
+		t.Errorf("records after removing every record = %q, want only the unmanaged one", got)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	api := &fakeAPI{records: map[string]Record{
+		"a": {ID: "a", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: 1, Comment: "managed"},
+		"b": {ID: "b", Name: "www.example.com", Type: "A", Content: "192.0.2.2", TTL: 1, Comment: "managed"},
+	}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	c := &Client{Token: "token", BaseURL: server.URL}
+
+	before := api.list()
+	changes, err := c.Plan(context.Background(), "zone1", "www.example.com", "managed", []Record{
+		{Type: "A", Content: "192.0.2.1", TTL: 60},
+		{Type: "A", Content: "192.0.2.3", TTL: 60},
+	})
+	if err != nil {
+		t.Fatalf("Plan() = %v", err)
+	}
+	var got []string
+	for _, change := range changes {
+		got = append(got, change.Action+" "+change.Record.ID+" "+change.Record.Content)
+	}
+	want := []string{"update a 192.0.2.1", "create  192.0.2.3", "delete b 192.0.2.2"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Plan() = %q, want %q", got, want)
+	}
+	if after := api.list(); strings.Join(after, "\n") != strings.Join(before, "\n") {
+		t.Errorf("records after Plan() = %q, want them untouched", after)
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// abTestLabel is set on the variant deployments of a website to the name of the website
+	abTestLabel = "dev.mvasilenko.me/ab-test"
+
+	// canaryByHeaderAnnotation, canaryByHeaderValueAnnotation and canaryByCookieAnnotation
+	// make ingress-nginx send the requests matching a header or cookie to a canary Ingress
+	canaryByHeaderAnnotation      = "nginx.ingress.kubernetes.io/canary-by-header"
+	canaryByHeaderValueAnnotation = "nginx.ingress.kubernetes.io/canary-by-header-value"
+	canaryByCookieAnnotation      = "nginx.ingress.kubernetes.io/canary-by-cookie"
+)
+
+func variantName(name, variant string) string {
+	return fmt.Sprintf("%s-variant-%s", name, variant)
+}
+
+// reconcileABTest runs a deployment, Service and canary Ingress for every variant of the
+// A/B test of a website, and removes those of variants that are gone. The images of the
+// variants go through the checks of the website image first. It returns when a failed
+// check should be retried.
+func (r *WebsiteReconciler) reconcileABTest(ctx context.Context, website *devv1.Website, desired *appsv1.Deployment) (time.Duration, error) {
+	log := log.FromContext(ctx)
+
+	var retryAfter time.Duration
+	wanted, scanJobs := map[string]bool{}, map[string]bool{}
+	if website.Spec.ABTest != nil && website.Spec.Ingress != nil {
+		for _, variant := range website.Spec.ABTest.Variants {
+			name := variantName(resourceName(website), variant.Name)
+			wanted[name] = true
+
+			deployment := newCanaryDeployment(desired, name)
+			deployment.Labels[abTestLabel] = website.Name
+			deployment.Spec.Template.Spec.Containers[0].Image = mirrorImage(r.RegistryMirrors, fmt.Sprintf("%s:%s", imageRepository(website), variant.ImageTag))
+			variantRetryAfter, ok, err := r.checkVariantImage(ctx, website, deployment, scanJobs)
+			if err != nil {
+				return 0, err
+			}
+			retryAfter = soonest(retryAfter, variantRetryAfter)
+			if !ok {
+				// The variant only starts once its image passes the checks.
+				continue
+			}
+			if err := r.applyDeployment(ctx, deployment); err != nil {
+				return 0, err
+			}
+			if err := r.reconcileService(ctx, newCanaryService(website, name), true); err != nil {
+				return 0, err
+			}
+			ingress := newCanaryIngress(website, name, variantAnnotations(variant))
+			if err := ctrl.SetControllerReference(website, ingress, r.Scheme); err != nil {
+				return 0, err
+			}
+			if err := r.applyIngress(ctx, ingress); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := r.deleteScanJobs(ctx, website, true, scanJobs); err != nil {
+		return 0, err
+	}
+
+	deployments := appsv1.DeploymentList{}
+	if err := r.Client.List(ctx, &deployments, client.InNamespace(website.Namespace),
+		client.MatchingLabels{abTestLabel: website.Name}); err != nil {
+		log.Error(err, "Failed to list variant deployments", "action", "get")
+		return 0, err
+	}
+	for _, deployment := range deployments.Items {
+		if wanted[deployment.Name] {
+			continue
+		}
+		log.Info("Removing A/B test variant", "action", "delete", "variant", deployment.Name)
+		objects := []client.Object{
+			&networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: deployment.Name, Namespace: website.Namespace}},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: deployment.Name, Namespace: website.Namespace}},
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: deployment.Name, Namespace: website.Namespace}},
+		}
+		for _, obj := range objects {
+			if err := r.Client.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+				log.Error(err, "Failed to delete A/B test variant resource", "action", "delete", "name", deployment.Name)
+				return 0, err
+			}
+		}
+	}
+	return retryAfter, nil
+}
+
+// checkVariantImage runs the image of a variant deployment through the signature,
+// platform and vulnerability checks of the website image, pinning it to the digest
+// verified. An image failing them is replaced by the one the variant runs, and the names
+// of the scan Jobs used are added to scanJobs. It reports false when the variant runs
+// nothing yet, and returns when a failed check should be retried.
+func (r *WebsiteReconciler) checkVariantImage(ctx context.Context, website *devv1.Website, deployment *appsv1.Deployment, scanJobs map[string]bool) (time.Duration, bool, error) {
+	image := deployment.Spec.Template.Spec.Containers[0].Image
+	var reason string
+	var retryAfter time.Duration
+	if spec := website.Spec.ImageVerification; spec != nil {
+		pinned, err := r.verifySignature(ctx, image, spec)
+		if err != nil {
+			reason, retryAfter = err.Error(), verificationRetryInterval
+		} else {
+			image = pinned
+		}
+	}
+	if reason == "" && website.Spec.VerifyPlatforms {
+		var wanted []string
+		for _, platform := range website.Spec.Platforms {
+			wanted = append(wanted, platform.String())
+		}
+		missing, err := r.missingPlatforms(ctx, image, wanted)
+		if err != nil {
+			reason, retryAfter = err.Error(), verificationRetryInterval
+		} else if len(missing) > 0 {
+			reason, retryAfter = fmt.Sprintf("%s is not available for %s", image, strings.Join(missing, ", ")), verificationRetryInterval
+		}
+	}
+	if reason == "" && website.Spec.VulnerabilityScan != nil {
+		job := newScanJob(website, image)
+		job.Labels[abTestLabel] = website.Name
+		r.mirrorPodImages(&job.Spec.Template.Spec)
+		r.injectProxyEnv(&job.Spec.Template.Spec)
+		scanJobs[job.Name] = true
+		condition, err := r.runScanJob(ctx, website, job, image)
+		if err != nil {
+			return 0, false, err
+		}
+		if condition.Status != metav1.ConditionTrue {
+			reason = condition.Message
+		}
+	}
+	deployment.Spec.Template.Spec.Containers[0].Image = image
+	if reason == "" {
+		return 0, true, nil
+	}
+
+	log.FromContext(ctx).Info("Refusing to roll out variant image failing its checks", "variant", deployment.Name, "image", image, "reason", reason)
+	ok, err := r.holdImage(ctx, deployment)
+	return retryAfter, ok, err
+}
+
+// variantAnnotations returns the ingress-nginx annotations routing the requests matching
+// the rules of a variant to its canary Ingress.
+func variantAnnotations(variant devv1.ABVariant) map[string]string {
+	annotations := map[string]string{canaryAnnotation: "true"}
+	if header := variant.Header; header != nil {
+		annotations[canaryByHeaderAnnotation] = header.Name
+		if header.Value != "" {
+			annotations[canaryByHeaderValueAnnotation] = header.Value
+		}
+	} else {
+		annotations[canaryByCookieAnnotation] = variant.Cookie
+	}
+	return annotations
+}
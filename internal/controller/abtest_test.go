@@ -0,0 +1,77 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+func TestReconcileABTestScansVariants(t *testing.T) {
+	website := &devv1.Website{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: devv1.WebsiteSpec{
+			ImageTag:          "v1",
+			Ingress:           &devv1.IngressSpec{Host: "hello.example.com"},
+			VulnerabilityScan: &devv1.VulnerabilityScanSpec{},
+			ABTest: &devv1.ABTestSpec{Variants: []devv1.ABVariant{
+				{Name: "b", ImageTag: "v2", Cookie: "variant-b"},
+			}},
+		},
+	}
+	r := newTestReconciler(t, website)
+	ctx := context.Background()
+
+	if _, err := r.reconcileABTest(ctx, website, newDeployment(website)); err != nil {
+		t.Fatalf("reconcileABTest() failed: %v", err)
+	}
+	name := types.NamespacedName{Name: variantName(resourceName(website), "b"), Namespace: website.Namespace}
+	if err := r.Get(ctx, name, &appsv1.Deployment{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the variant to wait for the scan of its image, got %v", err)
+	}
+	jobs := batchv1.JobList{}
+	if err := r.List(ctx, &jobs, client.MatchingLabels{abTestLabel: website.Name}); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs.Items) != 1 {
+		t.Fatalf("expected a scan job of the variant image, got %d jobs", len(jobs.Items))
+	}
+
+	jobs.Items[0].Status.Succeeded = 1
+	if err := r.Status().Update(ctx, &jobs.Items[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.reconcileABTest(ctx, website, newDeployment(website)); err != nil {
+		t.Fatalf("reconcileABTest() failed: %v", err)
+	}
+	deployment := appsv1.Deployment{}
+	if err := r.Get(ctx, name, &deployment); err != nil {
+		t.Fatalf("expected the variant to run once its image is scanned, got %v", err)
+	}
+	if image := deployment.Spec.Template.Spec.Containers[0].Image; image != imageRepository(website)+":v2" {
+		t.Errorf("variant runs %s", image)
+	}
+}
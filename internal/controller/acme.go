@@ -0,0 +1,472 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+	"github.com/mvasilenko/helloworld-operator/internal/acme"
+)
+
+const (
+	acmeChallengeVolumeName = "acme-challenges"
+	acmeChallengeMountPath  = "/etc/nginx/acme-challenges"
+	// acmeChallengeLocation is the path the http-01 challenges are answered under
+	acmeChallengeLocation = "/.well-known/acme-challenge/"
+
+	// acmeAccountKey and acmeOrderKey are the keys of the account key and of the key of
+	// the certificate being ordered in the ACME account Secret of a website
+	acmeAccountKey = "account.key"
+	acmeOrderKey   = "order.key"
+
+	// acmeRenewBefore is how long before its expiry a certificate is renewed
+	acmeRenewBefore = 30 * 24 * time.Hour
+	// acmePollInterval is how often an order in progress is checked
+	acmePollInterval = 5 * time.Second
+	// acmeRetryInterval is how long after a failed order a new one is placed
+	acmeRetryInterval = time.Hour
+)
+
+func acmeSpec(website *devv1.Website) *devv1.ACMESpec {
+	if website.Spec.Ingress == nil || website.Spec.Ingress.TLS == nil {
+		return nil
+	}
+	return website.Spec.Ingress.TLS.ACME
+}
+
+func acmeChallengesName(name string) string {
+	return fmt.Sprintf("%s-acme-challenges", name)
+}
+
+func acmeAccountName(name string) string {
+	return fmt.Sprintf("%s-acme-account", name)
+}
+
+// acmeChallengeVolume returns the volume holding the answers to the http-01 challenges of
+// a website, and its mount into the nginx container. The ConfigMap is mounted as a
+// directory, so that the kubelet updates the answers without restarting the pods.
+func acmeChallengeVolume(website *devv1.Website) (*corev1.Volume, *corev1.VolumeMount) {
+	if acmeSpec(website) == nil {
+		return nil, nil
+	}
+	volume := &corev1.Volume{
+		Name: acmeChallengeVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: acmeChallengesName(resourceName(website))},
+				DefaultMode:          pointer.Int32(corev1.ConfigMapVolumeSourceDefaultMode),
+				Optional:             pointer.Bool(true),
+			},
+		},
+	}
+	return volume, &corev1.VolumeMount{Name: acmeChallengeVolumeName, MountPath: acmeChallengeMountPath, ReadOnly: true}
+}
+
+// acmeChallengeDirectives returns the nginx server directives answering the http-01
+// challenges of a website, or nothing when the operator does not issue its certificate.
+func acmeChallengeDirectives(website *devv1.Website) string {
+	if acmeSpec(website) == nil {
+		return ""
+	}
+	return fmt.Sprintf(`    location ^~ %s {
+        default_type text/plain;
+        alias %s/;
+    }
+`, acmeChallengeLocation, acmeChallengeMountPath)
+}
+
+// reconcileACME issues the certificate of a website with its ACME server, and renews it
+// before it expires. An order goes through several reconciles: its http-01 challenges are
+// answered by the website pods from a ConfigMap, then it is finalized and the certificate
+// is stored in the TLS Secret of the Ingress. It returns when the order should be checked
+// again.
+func (r *WebsiteReconciler) reconcileACME(ctx context.Context, website *devv1.Website) (time.Duration, error) {
+	log := log.FromContext(ctx)
+
+	spec := acmeSpec(website)
+	if spec == nil {
+		if err := r.reconcileACMEChallenges(ctx, website, nil, false); err != nil {
+			return 0, err
+		}
+		err := r.Client.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: acmeAccountName(resourceName(website)), Namespace: website.Namespace}})
+		if err != nil && !apierrors.IsNotFound(err) {
+			log.Error(err, "Failed to delete ACME account secret", "action", "delete")
+			return 0, err
+		}
+		return 0, r.setACMEStatus(ctx, website, nil)
+	}
+
+	host := website.Spec.Ingress.Host
+	status := website.Status.ACME.DeepCopy()
+	if status == nil {
+		status = &devv1.ACMEStatus{}
+	}
+	if status.OrderURL == "" {
+		certificate, err := r.tlsCertificate(ctx, types.NamespacedName{Name: tlsSecretName(website), Namespace: website.Namespace})
+		if err == nil && certificate.VerifyHostname(host) == nil && time.Until(certificate.NotAfter) > acmeRenewBefore {
+			return time.Until(certificate.NotAfter.Add(-acmeRenewBefore)), r.reconcileACMEChallenges(ctx, website, nil, true)
+		}
+		if status.FailedAt != nil && time.Since(status.FailedAt.Time) < acmeRetryInterval {
+			return acmeRetryInterval - time.Since(status.FailedAt.Time), nil
+		}
+	}
+	// The order could not be remembered, so every reconcile would place a new one.
+	if r.dryRun() {
+		log.Info("Dry run: skipping certificate order", "host", host, "order", status.OrderURL)
+		return 0, nil
+	}
+
+	account, err := r.acmeAccount(ctx, website)
+	if err != nil {
+		return 0, err
+	}
+	api := &acme.Client{DirectoryURL: spec.Server, Key: account.key}
+	if err := api.Register(ctx, spec.Email); err != nil {
+		log.Error(err, "Failed to register ACME account")
+		return 0, err
+	}
+
+	var order *acme.Order
+	if status.OrderURL == "" {
+		log.Info("Ordering certificate", "host", host)
+		if order, err = api.NewOrder(ctx, []string{host}); err != nil {
+			return 0, err
+		}
+		status.OrderURL = order.URL
+		if err := r.setACMEStatus(ctx, website, status); err != nil {
+			return 0, err
+		}
+	} else if order, err = api.Order(ctx, status.OrderURL); err != nil {
+		var problem *acme.Problem
+		if errors.As(err, &problem) {
+			// The order is gone, such as after it expired.
+			return 0, r.failACMEOrder(ctx, website, status, err.Error())
+		}
+		return 0, err
+	}
+
+	switch order.Status {
+	case "pending":
+		answers := map[string]string{}
+		for _, url := range order.Authorizations {
+			authorization, err := api.Authorization(ctx, url)
+			if err != nil {
+				return 0, err
+			}
+			if authorization.Status == "valid" {
+				continue
+			}
+			challenge := http01Challenge(authorization)
+			if challenge == nil {
+				return 0, r.failACMEOrder(ctx, website, status, "the ACME server offers no http-01 challenge")
+			}
+			answer := api.KeyAuthorization(challenge.Token)
+			answers[challenge.Token] = answer
+			// The challenge is only accepted once its answer is served, which takes the
+			// kubelet up to a minute after the ConfigMap changes.
+			if challenge.Status == "pending" && answered(ctx, host, challenge.Token, answer) {
+				log.Info("Accepting http-01 challenge", "host", authorization.Identifier.Value)
+				if err := api.Accept(ctx, challenge.URL); err != nil {
+					return 0, err
+				}
+			}
+		}
+		return acmePollInterval, r.reconcileACMEChallenges(ctx, website, answers, true)
+	case "ready":
+		key, err := acme.NewKey()
+		if err != nil {
+			return 0, err
+		}
+		if err := r.storeACMEOrderKey(ctx, account, key); err != nil {
+			return 0, err
+		}
+		csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: host},
+			DNSNames: []string{host},
+		}, key)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := api.Finalize(ctx, order, csr); err != nil {
+			return 0, err
+		}
+		return acmePollInterval, nil
+	case "processing":
+		return acmePollInterval, nil
+	case "valid":
+		if account.orderKey == nil {
+			return 0, r.failACMEOrder(ctx, website, status, "the key of the certificate was lost")
+		}
+		chain, err := api.Certificate(ctx, order.Certificate)
+		if err != nil {
+			return 0, err
+		}
+		keyDER, err := x509.MarshalECPrivateKey(account.orderKey)
+		if err != nil {
+			return 0, err
+		}
+		if err := r.writeTLSSecret(ctx, website, tlsSecretName(website), chain, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})); err != nil {
+			return 0, err
+		}
+		if err := r.storeACMEOrderKey(ctx, account, nil); err != nil {
+			return 0, err
+		}
+		log.Info("Certificate issued", "host", host)
+		if r.Recorder != nil {
+			r.Recorder.Event(website, corev1.EventTypeNormal, "CertificateIssued", fmt.Sprintf("Certificate for %s issued by the ACME server", host))
+		}
+		if err := r.reconcileACMEChallenges(ctx, website, nil, true); err != nil {
+			return 0, err
+		}
+		return acmePollInterval, r.setACMEStatus(ctx, website, &devv1.ACMEStatus{})
+	default:
+		message := fmt.Sprintf("the order is %s", order.Status)
+		if order.Error != nil {
+			message = order.Error.Error()
+		}
+		// The error of a failed order is usually the one of its challenge.
+		for _, url := range order.Authorizations {
+			if authorization, err := api.Authorization(ctx, url); err == nil {
+				if challenge := http01Challenge(authorization); challenge != nil && challenge.Error != nil {
+					message = challenge.Error.Error()
+				}
+			}
+		}
+		return 0, r.failACMEOrder(ctx, website, status, message)
+	}
+}
+
+func http01Challenge(authorization *acme.Authorization) *acme.Challenge {
+	for i, challenge := range authorization.Challenges {
+		if challenge.Type == "http-01" {
+			return &authorization.Challenges[i]
+		}
+	}
+	return nil
+}
+
+// answered checks that the answer to an http-01 challenge is served through the Ingress of
+// the website, like the ACME server will. The server follows redirects to https without
+// verifying certificates, the website having none yet.
+func answered(ctx context.Context, host, token, answer string) bool {
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}, //nolint:gosec
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+acmeChallengeLocation+token, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	return err == nil && resp.StatusCode == http.StatusOK && strings.TrimSpace(string(body)) == answer
+}
+
+func (r *WebsiteReconciler) failACMEOrder(ctx context.Context, website *devv1.Website, status *devv1.ACMEStatus, message string) error {
+	log.FromContext(ctx).Info("Certificate order failed", "order", status.OrderURL, "reason", message)
+	if r.Recorder != nil {
+		r.Recorder.Event(website, corev1.EventTypeWarning, "CertificateOrderFailed", message)
+	}
+	now := metav1.Now()
+	if err := r.reconcileACMEChallenges(ctx, website, nil, true); err != nil {
+		return err
+	}
+	return r.setACMEStatus(ctx, website, &devv1.ACMEStatus{Message: message, FailedAt: &now})
+}
+
+// reconcileACMEChallenges makes sure the ConfigMap answering the http-01 challenges of a
+// website holds the given answers while it is wanted, and removes it otherwise.
+func (r *WebsiteReconciler) reconcileACMEChallenges(ctx context.Context, website *devv1.Website, answers map[string]string, wanted bool) error {
+	labels := withRecommendedLabels(setResourceLabels(website.Name), website, componentServer)
+	labels[watchLabel] = "true"
+	desired := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      acmeChallengesName(resourceName(website)),
+			Namespace: website.Namespace,
+			Labels:    labels,
+		},
+		Data: answers,
+	}
+	if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+		return err
+	}
+	return r.reconcileConfigMap(ctx, desired, wanted)
+}
+
+// acmeAccount holds the keys of the ACME account Secret of a website.
+type acmeAccount struct {
+	secret   *corev1.Secret
+	key      *ecdsa.PrivateKey
+	orderKey *ecdsa.PrivateKey
+}
+
+// acmeAccount returns the ACME account of a website, generating its key the first time.
+func (r *WebsiteReconciler) acmeAccount(ctx context.Context, website *devv1.Website) (*acmeAccount, error) {
+	name := types.NamespacedName{Name: acmeAccountName(resourceName(website)), Namespace: website.Namespace}
+	secret := &corev1.Secret{}
+	err := r.APIReader.Get(ctx, name, secret)
+	if apierrors.IsNotFound(err) {
+		key, err := acme.NewKey()
+		if err != nil {
+			return nil, err
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name.Name,
+				Namespace: name.Namespace,
+				Labels:    withRecommendedLabels(setResourceLabels(website.Name), website, componentServer),
+			},
+			Data: map[string][]byte{acmeAccountKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})},
+		}
+		if err := ctrl.SetControllerReference(website, secret, r.Scheme); err != nil {
+			return nil, err
+		}
+		log.FromContext(ctx).Info("Creating ACME account secret", "action", "create")
+		if err := r.Client.Create(ctx, secret); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to create ACME account secret", "action", "create")
+			return nil, err
+		}
+		return &acmeAccount{secret: secret, key: key}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	account := &acmeAccount{secret: secret}
+	if account.key, err = parseECKey(secret.Data[acmeAccountKey]); err != nil {
+		return nil, fmt.Errorf("secret %s: %w", name.Name, err)
+	}
+	if _, ok := secret.Data[acmeOrderKey]; ok {
+		if account.orderKey, err = parseECKey(secret.Data[acmeOrderKey]); err != nil {
+			return nil, fmt.Errorf("secret %s: %w", name.Name, err)
+		}
+	}
+	return account, nil
+}
+
+// storeACMEOrderKey keeps the key of the certificate being ordered until the certificate
+// is issued, or removes it when nil.
+func (r *WebsiteReconciler) storeACMEOrderKey(ctx context.Context, account *acmeAccount, key *ecdsa.PrivateKey) error {
+	patch := client.MergeFrom(account.secret.DeepCopy())
+	if key == nil {
+		delete(account.secret.Data, acmeOrderKey)
+	} else {
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return err
+		}
+		account.secret.Data[acmeOrderKey] = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+	if err := r.Client.Patch(ctx, account.secret, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update ACME account secret", "action", "update")
+		return err
+	}
+	account.orderKey = key
+	return nil
+}
+
+func parseECKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM key found")
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+// writeTLSSecret stores a certificate chain and its key in a TLS Secret owned by the
+// website, creating it when needed.
+func (r *WebsiteReconciler) writeTLSSecret(ctx context.Context, website *devv1.Website, name string, chain, key []byte) error {
+	log := log.FromContext(ctx).WithValues("secret", name)
+	labels := withRecommendedLabels(setResourceLabels(website.Name), website, componentServer)
+	labels[watchLabel] = "true"
+	desired := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: website.Namespace, Labels: labels},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: chain, corev1.TLSPrivateKeyKey: key},
+	}
+	if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+		return err
+	}
+
+	err := r.Client.Create(ctx, desired)
+	if err == nil || !apierrors.IsAlreadyExists(err) {
+		if err != nil {
+			log.Error(err, "Failed to create TLS secret", "action", "create")
+		}
+		return err
+	}
+	current := &corev1.Secret{}
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Name: name, Namespace: website.Namespace}, current); err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(current.Data, desired.Data) {
+		return nil
+	}
+	patch := client.MergeFrom(current.DeepCopy())
+	syncLabels(&current.ObjectMeta, desired.Labels)
+	current.Data = desired.Data
+	log.Info("Updating TLS secret", "action", "update")
+	if err := r.Client.Patch(ctx, current, patch); err != nil {
+		log.Error(err, "Failed to update TLS secret", "action", "update")
+		return err
+	}
+	return nil
+}
+
+func (r *WebsiteReconciler) setACMEStatus(ctx context.Context, website *devv1.Website, status *devv1.ACMEStatus) error {
+	if equality.Semantic.DeepEqual(website.Status.ACME, status) {
+		return nil
+	}
+	patch := client.MergeFrom(website.DeepCopy())
+	website.Status.ACME = status
+	if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update website status", "action", "update")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+func TestReconcileACMEDryRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected request to the ACME server: %s %s", req.Method, req.URL)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	website := &devv1.Website{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: devv1.WebsiteSpec{
+			ImageTag: "v1",
+			Ingress: &devv1.IngressSpec{
+				Host: "hello.example.com",
+				TLS:  &devv1.IngressTLSSpec{ACME: &devv1.ACMESpec{Server: server.URL}},
+			},
+		},
+	}
+	r := newTestReconciler(t, website)
+	r.Client = NewDryRunClient(r.Client)
+
+	if _, err := r.reconcileACME(context.Background(), website); err != nil {
+		t.Fatalf("reconcileACME() failed: %v", err)
+	}
+	if website.Status.ACME != nil && website.Status.ACME.OrderURL != "" {
+		t.Errorf("Status.ACME = %+v, want no order", website.Status.ACME)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+	"github.com/mvasilenko/helloworld-operator/internal/prometheus"
+)
+
+const (
+	defaultAnalysisInterval = time.Minute
+	defaultAnalysisDuration = 10 * time.Minute
+)
+
+// analyzeRollout evaluates the analysis query of a website while a new image is rolled
+// out and for the analysis duration after, and rolls back to the previous image once the
+// query breaches its threshold. A rolled back image is held until the image of the
+// website changes. It returns when the query should be evaluated again.
+func (r *WebsiteReconciler) analyzeRollout(ctx context.Context, website *devv1.Website, desired *appsv1.Deployment) (time.Duration, error) {
+	log := log.FromContext(ctx)
+
+	spec := analysisSpec(website)
+	if spec == nil {
+		return 0, r.setAnalysisStatus(ctx, website, nil)
+	}
+
+	image := desired.Spec.Template.Spec.Containers[0].Image
+	status := website.Status.Analysis.DeepCopy()
+	if status == nil || status.Image != image {
+		held := desired.DeepCopy()
+		running, err := r.holdImage(ctx, held)
+		if err != nil {
+			return 0, err
+		}
+		previous := held.Spec.Template.Spec.Containers[0].Image
+		if !running || previous == image {
+			// No new image is rolled out.
+			return 0, nil
+		}
+		log.Info("Starting rollout analysis", "image", image, "previous", previous)
+		status = &devv1.AnalysisStatus{Image: image, PreviousImage: previous, Phase: devv1.AnalysisRunning, StartedAt: metav1.Now()}
+	}
+
+	switch status.Phase {
+	case devv1.AnalysisFailed:
+		desired.Spec.Template.Spec.Containers[0].Image = status.PreviousImage
+		return 0, r.setAnalysisStatus(ctx, website, status)
+	case devv1.AnalysisSuccessful:
+		return 0, r.setAnalysisStatus(ctx, website, status)
+	}
+
+	interval, duration := defaultAnalysisInterval, defaultAnalysisDuration
+	if spec.Interval != nil {
+		interval = spec.Interval.Duration
+	}
+	if spec.Duration != nil {
+		duration = spec.Duration.Duration
+	}
+	if status.LastEvaluatedAt != nil && time.Since(status.LastEvaluatedAt.Time) < interval {
+		return interval - time.Since(status.LastEvaluatedAt.Time), r.setAnalysisStatus(ctx, website, status)
+	}
+
+	now := metav1.Now()
+	status.LastEvaluatedAt = &now
+	value, breached, err := r.evaluateAnalysis(ctx, website, spec)
+	if err != nil {
+		// A failing query does not roll back, as Prometheus may just be unavailable.
+		log.Error(err, "Failed to evaluate rollout analysis", "image", image)
+		status.Message = fmt.Sprintf("evaluating the query failed: %v", err)
+	} else {
+		status.Value, status.Message = strconv.FormatFloat(value, 'g', -1, 64), ""
+	}
+
+	switch {
+	case breached:
+		status.Phase = devv1.AnalysisFailed
+		status.Message = fmt.Sprintf("%s breached the threshold %s, rolled back to %s", status.Value, spec.Threshold, status.PreviousImage)
+		log.Info("Rolling back image failing analysis", "image", image, "previous", status.PreviousImage, "value", status.Value)
+		if r.Recorder != nil {
+			r.Recorder.Event(website, corev1.EventTypeWarning, "RolledBack", fmt.Sprintf("%s: %s", image, status.Message))
+		}
+		desired.Spec.Template.Spec.Containers[0].Image = status.PreviousImage
+		return 0, r.setAnalysisStatus(ctx, website, status)
+	case err == nil && time.Since(status.StartedAt.Time) >= duration:
+		status.Phase = devv1.AnalysisSuccessful
+		status.Message = fmt.Sprintf("stayed within the threshold %s for %s", spec.Threshold, duration)
+		return 0, r.setAnalysisStatus(ctx, website, status)
+	}
+	return interval, r.setAnalysisStatus(ctx, website, status)
+}
+
+func analysisSpec(website *devv1.Website) *devv1.AnalysisSpec {
+	if website.Spec.Rollout == nil {
+		return nil
+	}
+	return website.Spec.Rollout.Analysis
+}
+
+// evaluateAnalysis evaluates the analysis query of a website, and reports whether its
+// value breaches the threshold.
+func (r *WebsiteReconciler) evaluateAnalysis(ctx context.Context, website *devv1.Website, spec *devv1.AnalysisSpec) (float64, bool, error) {
+	address := spec.Address
+	if address == "" {
+		address = r.PrometheusURL
+	}
+	if address == "" {
+		return 0, false, errors.New("no Prometheus address is configured")
+	}
+	threshold, err := strconv.ParseFloat(spec.Threshold, 64)
+	if err != nil {
+		return 0, false, err
+	}
+
+	query := strings.NewReplacer("{{namespace}}", website.Namespace, "{{website}}", website.Name).Replace(spec.Query)
+	value, err := prometheus.Query(ctx, address, query)
+	if err != nil {
+		return 0, false, err
+	}
+	if spec.FailWhen == devv1.AnalysisFailBelow {
+		return value, value < threshold, nil
+	}
+	return value, value > threshold, nil
+}
+
+func (r *WebsiteReconciler) setAnalysisStatus(ctx context.Context, website *devv1.Website, status *devv1.AnalysisStatus) error {
+	if equality.Semantic.DeepEqual(website.Status.Analysis, status) {
+		return nil
+	}
+	patch := client.MergeFrom(website.DeepCopy())
+	website.Status.Analysis = status
+	if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update website status", "action", "update")
+		return err
+	}
+	return nil
+}
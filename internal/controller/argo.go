@@ -0,0 +1,146 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// argoRolloutGVK identifies the Argo Rollouts Rollout, which is handled as unstructured
+// data so that the operator does not depend on the Argo Rollouts API module.
+var argoRolloutGVK = schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+
+// argoRollout reports whether an Argo Rollouts Rollout runs the website pods.
+func argoRollout(website *devv1.Website) bool {
+	return website.Spec.WorkloadType == devv1.WorkloadRollout
+}
+
+// reconcileWorkload makes sure the workload of the kind the website asks for runs its
+// pods, and removes the workloads of the other kinds. It returns when the website should
+// be looked at again.
+func (r *WebsiteReconciler) reconcileWorkload(ctx context.Context, website *devv1.Website, desired *appsv1.Deployment) (requeueAfter time.Duration, err error) {
+	name := types.NamespacedName{Name: deploymentName(website), Namespace: website.Namespace}
+
+	if !argoRollout(website) {
+		if err := r.deleteUnstructured(ctx, argoRolloutGVK, name); err != nil {
+			return 0, err
+		}
+	}
+	if !daemonSet(website) {
+		err := r.Client.Delete(ctx, &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace}})
+		if err == nil {
+			log.FromContext(ctx).Info("Daemon set is no longer wanted", "action", "delete")
+		} else if !errors.IsNotFound(err) {
+			log.FromContext(ctx).Error(err, "Failed to delete daemon set", "action", "delete")
+			return 0, err
+		}
+	}
+
+	switch {
+	case argoRollout(website):
+		rollout, err := newArgoRollout(website, desired)
+		if err != nil {
+			return 0, err
+		}
+		if err := ctrl.SetControllerReference(website, rollout, r.Scheme); err != nil {
+			return 0, err
+		}
+		if err := r.reconcileUnstructured(ctx, rollout); err != nil {
+			return 0, err
+		}
+	case daemonSet(website):
+		// DaemonSets roll out by themselves, a canary left from a Deployment is removed.
+		if err := r.endCanary(ctx, website); err != nil {
+			return 0, err
+		}
+		if err := r.applyDaemonSet(ctx, newDaemonSet(desired)); err != nil {
+			return 0, err
+		}
+	default:
+		requeueAfter, err := r.reconcileCanary(ctx, website, desired)
+		if err != nil {
+			return 0, err
+		}
+		return requeueAfter, r.applyDeployment(ctx, desired)
+	}
+
+	err = r.Client.Delete(ctx, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace}})
+	if err == nil {
+		log.FromContext(ctx).Info("Deployment replaced by another workload", "action", "delete", "workloadType", website.Spec.WorkloadType)
+	} else if !errors.IsNotFound(err) {
+		log.FromContext(ctx).Error(err, "Failed to delete deployment", "action", "delete")
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Create an Argo Rollouts Rollout running the pods of the desired deployment of a website.
+func newArgoRollout(website *devv1.Website, desired *appsv1.Deployment) (*unstructured.Unstructured, error) {
+	desired = desired.DeepCopy()
+	setVersionLabel(desired)
+	template, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&desired.Spec.Template)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := runtime.DefaultUnstructuredConverter.ToUnstructured(desired.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var strategy map[string]interface{}
+	if rollout := website.Spec.Rollout; rollout != nil && rollout.ArgoStrategy != nil && len(rollout.ArgoStrategy.Raw) > 0 {
+		if err := json.Unmarshal(rollout.ArgoStrategy.Raw, &strategy); err != nil {
+			return nil, err
+		}
+	} else {
+		var canary *devv1.CanarySpec
+		if rollout != nil {
+			canary = rollout.Canary
+		}
+		weights, stepDuration := canarySteps(canary)
+		steps := make([]interface{}, 0, 2*len(weights))
+		for _, weight := range weights {
+			steps = append(steps,
+				map[string]interface{}{"setWeight": int64(weight)},
+				map[string]interface{}{"pause": map[string]interface{}{"duration": stepDuration.String()}},
+			)
+		}
+		strategy = map[string]interface{}{"canary": map[string]interface{}{"steps": steps}}
+	}
+
+	return newUnstructured(argoRolloutGVK, types.NamespacedName{Name: deploymentName(website), Namespace: website.Namespace},
+		desired.Labels, map[string]interface{}{
+			"replicas": int64(*desired.Spec.Replicas),
+			"selector": selector,
+			"template": template,
+			"strategy": strategy,
+		})
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// verticalPodAutoscalerGVK identifies the VerticalPodAutoscaler, which is handled as
+// unstructured data as its CRD is not part of Kubernetes itself.
+var verticalPodAutoscalerGVK = schema.GroupVersionKind{Group: "autoscaling.k8s.io", Version: "v1", Kind: "VerticalPodAutoscaler"}
+
+// reconcileAutoscaling creates or updates the VerticalPodAutoscaler of a website, and
+// deletes it once vertical autoscaling is turned off.
+func (r *WebsiteReconciler) reconcileAutoscaling(ctx context.Context, website *devv1.Website) error {
+	name := types.NamespacedName{Name: resourceName(website), Namespace: website.Namespace}
+
+	autoscaling := website.Spec.Autoscaling
+	if autoscaling == nil || autoscaling.Vertical == nil {
+		return r.deleteUnstructured(ctx, verticalPodAutoscalerGVK, name)
+	}
+
+	desired, err := newVerticalPodAutoscaler(website, autoscaling.Vertical)
+	if err != nil {
+		return err
+	}
+	if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+		return err
+	}
+	return r.reconcileUnstructured(ctx, desired)
+}
+
+// Create a VerticalPodAutoscaler for the website container of the workload. Sidecars
+// are left alone.
+func newVerticalPodAutoscaler(website *devv1.Website, spec *devv1.VerticalAutoscalingSpec) (*unstructured.Unstructured, error) {
+	updateMode := spec.UpdateMode
+	if updateMode == "" {
+		updateMode = "Auto"
+	}
+
+	containerPolicy := map[string]interface{}{"containerName": "nginx"}
+	if len(spec.MinAllowed) > 0 {
+		containerPolicy["minAllowed"] = resourceListValue(spec.MinAllowed)
+	}
+	if len(spec.MaxAllowed) > 0 {
+		containerPolicy["maxAllowed"] = resourceListValue(spec.MaxAllowed)
+	}
+
+	targetRef := map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": deploymentName(website)}
+	if argoRollout(website) {
+		targetRef = map[string]interface{}{"apiVersion": argoRolloutGVK.GroupVersion().String(), "kind": argoRolloutGVK.Kind, "name": deploymentName(website)}
+	} else if daemonSet(website) {
+		targetRef = map[string]interface{}{"apiVersion": "apps/v1", "kind": "DaemonSet", "name": deploymentName(website)}
+	}
+
+	return newUnstructured(verticalPodAutoscalerGVK, types.NamespacedName{Name: resourceName(website), Namespace: website.Namespace},
+		withRecommendedLabels(setResourceLabels(website.Name), website, componentServer), map[string]interface{}{
+			"targetRef":    targetRef,
+			"updatePolicy": map[string]interface{}{"updateMode": updateMode},
+			"resourcePolicy": map[string]interface{}{
+				"containerPolicies": []interface{}{
+					containerPolicy,
+					map[string]interface{}{"containerName": "*", "mode": "Off"},
+				},
+			},
+		})
+}
+
+// resourceListValue converts a resource list into unstructured data.
+func resourceListValue(resources corev1.ResourceList) map[string]interface{} {
+	value := map[string]interface{}{}
+	for name, quantity := range resources {
+		value[string(name)] = quantity.String()
+	}
+	return value
+}
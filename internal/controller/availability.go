@@ -0,0 +1,177 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+	"github.com/mvasilenko/helloworld-operator/internal/prometheus"
+)
+
+const (
+	// availabilityInterval is how often the availability of a website is computed.
+	availabilityInterval = 5 * time.Minute
+	// defaultAvailabilityWindow is the window the availability is computed over by default.
+	defaultAvailabilityWindow = 7 * 24 * time.Hour
+)
+
+// reconcileAvailability computes the share of successful probes of a website over its
+// availability windows, and reports it in the status and the website_availability_ratio
+// metric. It returns when the availability should be computed again.
+func (r *WebsiteReconciler) reconcileAvailability(ctx context.Context, website *devv1.Website) (time.Duration, error) {
+	name := types.NamespacedName{Name: website.Name, Namespace: website.Namespace}
+	var address string
+	if r.proberURL(website) != "" {
+		address = r.availabilityAddress(website)
+	}
+	if address == "" || website.Status.URL == "" {
+		deleteWebsiteMetrics(name)
+		if err := r.setCondition(ctx, website, devv1.ConditionAvailabilityTargetMet, nil); err != nil {
+			return 0, err
+		}
+		return 0, r.setAvailabilityStatus(ctx, website, nil)
+	}
+
+	status := website.Status.Availability.DeepCopy()
+	if status == nil {
+		status = &devv1.AvailabilityStatus{}
+	}
+	if status.LastEvaluatedAt != nil && time.Since(status.LastEvaluatedAt.Time) < availabilityInterval {
+		return availabilityInterval - time.Since(status.LastEvaluatedAt.Time), nil
+	}
+
+	now := metav1.Now()
+	status.LastEvaluatedAt = &now
+	windows, ratios, err := queryAvailability(ctx, address, website)
+	if err != nil {
+		// The previous availability is kept, as Prometheus may just be unavailable.
+		log.FromContext(ctx).Error(err, "Failed to compute website availability")
+		status.Message = fmt.Sprintf("querying the probe results failed: %v", err)
+		return availabilityInterval, r.setAvailabilityStatus(ctx, website, status)
+	}
+
+	status.Windows, status.Message = nil, ""
+	deleteWebsiteMetrics(name)
+	for i, window := range windows {
+		status.Windows = append(status.Windows, devv1.WindowAvailability{
+			Window:     metav1.Duration{Duration: window},
+			Percentage: strconv.FormatFloat(ratios[i]*100, 'f', 2, 64) + "%",
+		})
+		availabilityRatio.WithLabelValues(website.Namespace, website.Name, model.Duration(window).String()).Set(ratios[i])
+	}
+	if err := r.setCondition(ctx, website, devv1.ConditionAvailabilityTargetMet, availabilityCondition(website, windows, ratios)); err != nil {
+		return 0, err
+	}
+	return availabilityInterval, r.setAvailabilityStatus(ctx, website, status)
+}
+
+func (r *WebsiteReconciler) availabilityAddress(website *devv1.Website) string {
+	if spec := availabilitySpec(website); spec != nil && spec.Address != "" {
+		return spec.Address
+	}
+	return r.PrometheusURL
+}
+
+// availabilitySpec returns the availability settings of a website, or nil when it has none.
+func availabilitySpec(website *devv1.Website) *devv1.AvailabilitySpec {
+	if website.Spec.Monitoring == nil {
+		return nil
+	}
+	return website.Spec.Monitoring.Availability
+}
+
+// queryAvailability returns the share of successful probes of a website over each of its
+// windows.
+func queryAvailability(ctx context.Context, address string, website *devv1.Website) ([]time.Duration, []float64, error) {
+	windows := []time.Duration{defaultAvailabilityWindow}
+	if spec := availabilitySpec(website); spec != nil && len(spec.Windows) > 0 {
+		windows = nil
+		for _, window := range spec.Windows {
+			windows = append(windows, window.Duration)
+		}
+	}
+
+	ratios := make([]float64, 0, len(windows))
+	for _, window := range windows {
+		if window <= 0 {
+			return nil, nil, errors.New("availability windows must be positive")
+		}
+		// Series of a former URL are averaged in, so the query always returns one sample.
+		query := fmt.Sprintf(`avg(avg_over_time(probe_success{namespace="%s", website="%s"}[%s]))`,
+			website.Namespace, website.Name, model.Duration(window))
+		ratio, err := prometheus.Query(ctx, address, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		ratios = append(ratios, ratio)
+	}
+	return windows, ratios, nil
+}
+
+// availabilityCondition reports whether a website meets its availability target over
+// every window, or returns nil when it has none.
+func availabilityCondition(website *devv1.Website, windows []time.Duration, ratios []float64) *metav1.Condition {
+	spec := availabilitySpec(website)
+	if spec == nil || spec.Target == "" {
+		return nil
+	}
+	target, err := strconv.ParseFloat(spec.Target, 64)
+	if err != nil {
+		return nil
+	}
+	for i, window := range windows {
+		if ratios[i]*100 < target {
+			return &metav1.Condition{
+				Type:    devv1.ConditionAvailabilityTargetMet,
+				Status:  metav1.ConditionFalse,
+				Reason:  devv1.ReasonTargetMissed,
+				Message: fmt.Sprintf("%.2f%% over %s, below the target of %s%%", ratios[i]*100, model.Duration(window), spec.Target),
+			}
+		}
+	}
+	return &metav1.Condition{
+		Type:    devv1.ConditionAvailabilityTargetMet,
+		Status:  metav1.ConditionTrue,
+		Reason:  devv1.ReasonTargetMet,
+		Message: fmt.Sprintf("at least %s%% over every window", spec.Target),
+	}
+}
+
+func (r *WebsiteReconciler) setAvailabilityStatus(ctx context.Context, website *devv1.Website, status *devv1.AvailabilityStatus) error {
+	if equality.Semantic.DeepEqual(website.Status.Availability, status) {
+		return nil
+	}
+	patch := client.MergeFrom(website.DeepCopy())
+	website.Status.Availability = status
+	if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update website status", "action", "update")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// backendProtocolAnnotation tells ingress-nginx how to talk to the backend Service
+	backendProtocolAnnotation = "nginx.ingress.kubernetes.io/backend-protocol"
+
+	backendTLSServicePort  = 443
+	backendTLSConfVolume   = "backend-tls-conf"
+	backendTLSCertVolume   = "backend-tls"
+	backendTLSFileName     = "backend-tls.conf"
+	backendTLSCertMountDir = "/etc/nginx/tls"
+
+	tlsReloaderImage         = "busybox:1.36"
+	tlsReloaderContainerName = "tls-reloader"
+	tlsReloadInterval        = 10
+)
+
+// certificateGVK identifies the cert-manager Certificate, which is handled as unstructured
+// data so that the operator does not depend on the cert-manager API module.
+var certificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+func backendTLSConfName(name string) string {
+	return fmt.Sprintf("%s-backend-tls-conf", name)
+}
+
+// backendTLSSecretName returns the name of the Secret holding the serving certificate of
+// the website pods.
+func backendTLSSecretName(website *devv1.Website) string {
+	if tls := website.Spec.BackendTLS; tls != nil && tls.SecretName != "" {
+		return tls.SecretName
+	}
+	return fmt.Sprintf("%s-backend-tls", resourceName(website))
+}
+
+func backendTLSPort(tls *devv1.BackendTLSSpec) int32 {
+	if tls != nil && tls.Port != 0 {
+		return tls.Port
+	}
+	return devv1.DefaultBackendTLSPort
+}
+
+// reconcileBackendTLS makes sure the nginx configuration serving TLS in the website pods,
+// and the Certificate issuing their serving certificate, exist while they are wanted, and
+// removes them otherwise.
+func (r *WebsiteReconciler) reconcileBackendTLS(ctx context.Context, website *devv1.Website) error {
+	tls := website.Spec.BackendTLS
+
+	if tls == nil || tls.Issuer == "" {
+		if err := r.deleteUnstructured(ctx, certificateGVK, types.NamespacedName{Name: backendTLSSecretName(website), Namespace: website.Namespace}); err != nil {
+			return err
+		}
+	} else {
+		desired, err := newBackendCertificate(website)
+		if err != nil {
+			return err
+		}
+		if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.reconcileUnstructured(ctx, desired); err != nil {
+			return err
+		}
+	}
+
+	desired := newBackendTLSConfigMap(website)
+	if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+		return err
+	}
+	return r.reconcileConfigMap(ctx, desired, tls != nil)
+}
+
+// backendTLSVolumes returns the volumes holding the nginx TLS server configuration and the
+// serving certificate, and their mounts into the nginx container.
+func backendTLSVolumes(website *devv1.Website) ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes := []corev1.Volume{
+		{
+			Name: backendTLSConfVolume,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: backendTLSConfName(resourceName(website))},
+					DefaultMode:          pointer.Int32(corev1.ConfigMapVolumeSourceDefaultMode),
+				},
+			},
+		},
+		{
+			Name: backendTLSCertVolume,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  backendTLSSecretName(website),
+					DefaultMode: pointer.Int32(corev1.SecretVolumeSourceDefaultMode),
+				},
+			},
+		},
+	}
+	mounts := []corev1.VolumeMount{
+		{Name: backendTLSConfVolume, MountPath: "/etc/nginx/conf.d/" + backendTLSFileName, SubPath: backendTLSFileName, ReadOnly: true},
+		{Name: backendTLSCertVolume, MountPath: backendTLSCertMountDir, ReadOnly: true},
+	}
+	return volumes, mounts
+}
+
+// tlsReloaderSidecar returns the container reloading nginx when the kubelet updates the
+// mounted certificate. It polls a checksum of the certificate and sends SIGHUP to the nginx
+// master process, which reloads its configuration and certificates without dropping
+// connections.
+func tlsReloaderSidecar() corev1.Container {
+	script := fmt.Sprintf(`last=""
+while true; do
+  sum=$(cat %[1]s/tls.crt %[1]s/tls.key 2>/dev/null | md5sum)
+  if [ -n "$last" ] && [ "$sum" != "$last" ]; then
+    for cmdline in /proc/[0-9]*/cmdline; do
+      if grep -q '^nginx: master' "$cmdline" 2>/dev/null; then
+        pid=${cmdline#/proc/}
+        echo "certificate changed, reloading nginx"
+        kill -HUP "${pid%%/cmdline}"
+      fi
+    done
+  fi
+  last=$sum
+  sleep %[2]d
+done
+`, backendTLSCertMountDir, tlsReloadInterval)
+	return corev1.Container{
+		Name:         tlsReloaderContainerName,
+		Image:        tlsReloaderImage,
+		Command:      []string{"/bin/sh", "-c", script},
+		VolumeMounts: []corev1.VolumeMount{{Name: backendTLSCertVolume, MountPath: backendTLSCertMountDir, ReadOnly: true}},
+
+		// The values below are what the API server defaults them to.
+		ImagePullPolicy:          corev1.PullIfNotPresent,
+		TerminationMessagePath:   corev1.TerminationMessagePathDefault,
+		TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+	}
+}
+
+// Create the ConfigMap holding the nginx server serving the website over TLS, from the
+// same root and with the same error pages as the default server.
+func newBackendTLSConfigMap(website *devv1.Website) *corev1.ConfigMap {
+	labels := withRecommendedLabels(setResourceLabels(website.Name), website, componentServer)
+	labels[watchLabel] = "true"
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backendTLSConfName(resourceName(website)),
+			Namespace: website.Namespace,
+			Labels:    labels,
+		},
+		Data: map[string]string{
+			backendTLSFileName: nginxServer(website, []string{fmt.Sprintf("%d ssl", backendTLSPort(website.Spec.BackendTLS))},
+				fmt.Sprintf("ssl_certificate %s/tls.crt", backendTLSCertMountDir),
+				fmt.Sprintf("ssl_certificate_key %s/tls.key", backendTLSCertMountDir)),
+		},
+	}
+}
+
+// Create a cert-manager Certificate for the names the website Service resolves under
+// inside the cluster, which are the ones the Ingress controller connects to.
+func newBackendCertificate(website *devv1.Website) (*unstructured.Unstructured, error) {
+	service, namespace := serviceName(website), website.Namespace
+	return newUnstructured(certificateGVK, types.NamespacedName{Name: backendTLSSecretName(website), Namespace: namespace},
+		withRecommendedLabels(setResourceLabels(website.Name), website, componentServer), map[string]interface{}{
+			"secretName": backendTLSSecretName(website),
+			"dnsNames": []interface{}{
+				service,
+				fmt.Sprintf("%s.%s", service, namespace),
+				fmt.Sprintf("%s.%s.svc", service, namespace),
+				fmt.Sprintf("%s.%s.svc.cluster.local", service, namespace),
+			},
+			"issuerRef": map[string]interface{}{
+				"name": website.Spec.BackendTLS.Issuer,
+				"kind": "ClusterIssuer",
+			},
+		})
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	buildVolumeName  = "build"
+	gitImage         = "alpine/git:2.40.1"
+	publishImage     = "busybox:1.36"
+	sourceMountPath  = "/src"
+	publishMountPath = "/site"
+
+	// buildTTL is how long finished build Jobs are kept around for their logs
+	buildTTL = 24 * 60 * 60
+)
+
+var defaultBuildSize = resource.MustParse("1Gi")
+
+func buildName(name string) string {
+	return fmt.Sprintf("%s-build", name)
+}
+
+// reconcileBuild builds the content of a website from source. Every build spec is built
+// once by a Job, which publishes the site to a directory of its own on the build volume.
+// Once the Job succeeded, the build is recorded as published in the website status and
+// the website pods are rolled out to serve it.
+func (r *WebsiteReconciler) reconcileBuild(ctx context.Context, website *devv1.Website) error {
+	log := log.FromContext(ctx)
+
+	if website.Spec.Build == nil {
+		err := r.Client.Delete(ctx, &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: buildName(resourceName(website)), Namespace: website.Namespace}})
+		if err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete build volume", "action", "delete")
+			return err
+		}
+		return r.setBuildStatus(ctx, website, nil)
+	}
+
+	claim := newBuildVolumeClaim(website)
+	if err := ctrl.SetControllerReference(website, claim, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Client.Create(ctx, claim); err != nil && !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create build volume", "action", "create")
+		return err
+	}
+
+	status := &devv1.BuildStatus{}
+	if website.Status.Build != nil {
+		status = website.Status.Build.DeepCopy()
+	}
+	id := buildID(website.Spec.Build)
+	if status.ID == id && status.Phase != devv1.BuildRunning {
+		return nil
+	}
+
+	desired := newBuildJob(website, id, status.Published)
+	r.mirrorPodImages(&desired.Spec.Template.Spec)
+	r.injectProxyEnv(&desired.Spec.Template.Spec)
+	if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Client.Create(ctx, desired); err == nil {
+		log.Info("Building website", "action", "create", "job", desired.Name)
+	} else if !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create build job", "action", "create")
+		return err
+	}
+
+	status.ID, status.Job, status.Phase = id, desired.Name, devv1.BuildRunning
+	job := batchv1.Job{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &job)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to retrieve build job", "action", "get")
+		return err
+	}
+	if job.Status.Succeeded > 0 {
+		log.Info("Publishing build", "job", job.Name)
+		status.Phase, status.Published = devv1.BuildSucceeded, id
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			log.Info("Build failed", "job", job.Name, "reason", condition.Reason)
+			status.Phase = devv1.BuildFailed
+		}
+	}
+	return r.setBuildStatus(ctx, website, status)
+}
+
+func (r *WebsiteReconciler) setBuildStatus(ctx context.Context, website *devv1.Website, status *devv1.BuildStatus) error {
+	if equality.Semantic.DeepEqual(website.Status.Build, status) {
+		return nil
+	}
+	patch := client.MergeFrom(website.DeepCopy())
+	website.Status.Build = status
+	if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update website status", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// buildID identifies the build of a build spec.
+func buildID(spec *devv1.BuildSpec) string {
+	return checksum(map[string][]byte{
+		"repository":   []byte(spec.Repository),
+		"revision":     []byte(spec.Revision),
+		"builderImage": []byte(spec.BuilderImage),
+		"command":      []byte(strings.Join(spec.Command, "\x00")),
+		"outputPath":   []byte(spec.OutputPath),
+	})[:10]
+}
+
+// buildVolume returns the volume and mount serving the published build of a website, if any.
+func buildVolume(website *devv1.Website) (*corev1.Volume, *corev1.VolumeMount) {
+	if website.Spec.Build == nil || website.Status.Build == nil || website.Status.Build.Published == "" {
+		return nil, nil
+	}
+	volume := &corev1.Volume{
+		Name: buildVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: buildName(resourceName(website)), ReadOnly: true},
+		},
+	}
+	return volume, &corev1.VolumeMount{
+		Name:      buildVolumeName,
+		MountPath: defaultContentMountPath,
+		SubPath:   website.Status.Build.Published,
+		ReadOnly:  true,
+	}
+}
+
+// Create the volume claim the builds of a website are published to.
+func newBuildVolumeClaim(website *devv1.Website) *corev1.PersistentVolumeClaim {
+	size := defaultBuildSize
+	if website.Spec.Build.Size != nil {
+		size = *website.Spec.Build.Size
+	}
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      buildName(resourceName(website)),
+			Namespace: website.Namespace,
+			Labels:    withRecommendedLabels(setResourceLabels(website.Name), website, componentBuild),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			StorageClassName: website.Spec.Build.StorageClassName,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+		},
+	}
+}
+
+// Create the Job building a website. It checks the repository out, runs the builder image
+// in it and copies the output to a directory named after the build on the build volume,
+// removing older builds except the one being served. The Job and its pods are labelled
+// as a website of their own, so that the website Service does not select them.
+func newBuildJob(website *devv1.Website, id, published string) *batchv1.Job {
+	spec := website.Spec.Build
+	name := fmt.Sprintf("%s-%s", buildName(resourceName(website)), id)
+
+	revision := spec.Revision
+	if revision == "" {
+		revision = "HEAD"
+	}
+	outputPath := spec.OutputPath
+	if outputPath == "" {
+		outputPath = "public"
+	}
+
+	sourceMount := corev1.VolumeMount{Name: "source", MountPath: sourceMountPath}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: website.Namespace,
+			Labels:    withRecommendedLabels(setResourceLabels(name), website, componentBuild),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            pointer.Int32(2),
+			TTLSecondsAfterFinished: pointer.Int32(buildTTL),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      withJobMeshLabels(withRecommendedLabels(setResourceLabels(name), website, componentBuild), website),
+					Annotations: jobMeshAnnotations(website),
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:   corev1.RestartPolicyNever,
+					SecurityContext: &corev1.PodSecurityContext{SeccompProfile: seccompProfile(website)},
+					InitContainers: []corev1.Container{
+						{
+							Name:  "checkout",
+							Image: gitImage,
+							Command: []string{"/bin/sh", "-c",
+								`set -e; git init -q /src; cd /src; git fetch -q --depth 1 "$0" "$1"; git checkout -q FETCH_HEAD`,
+								spec.Repository, revision},
+							VolumeMounts: []corev1.VolumeMount{sourceMount},
+						},
+						{
+							Name:         "build",
+							Image:        spec.BuilderImage,
+							Command:      spec.Command,
+							WorkingDir:   sourceMountPath,
+							VolumeMounts: []corev1.VolumeMount{sourceMount},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:  "publish",
+							Image: publishImage,
+							Command: []string{"/bin/sh", "-c",
+								`set -e; rm -rf "/site/$0"; cp -r "/src/$1" "/site/$0"
+for dir in /site/*; do case "${dir##*/}" in "$0"|"$2"|lost+found) ;; *) rm -rf "$dir" ;; esac; done`,
+								id, outputPath, published},
+							VolumeMounts: []corev1.VolumeMount{
+								sourceMount,
+								{Name: buildVolumeName, MountPath: publishMountPath},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{Name: "source", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+						{Name: buildVolumeName, VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: buildName(resourceName(website))},
+						}},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// cachingDirectives returns the nginx locations setting the Cache-Control header of the
+// responses of a website. Every rule is a regular expression location, as nginx checks
+// those in the order they are listed, which gives the rules their order.
+func cachingDirectives(website *devv1.Website) string {
+	if website.Spec.Caching == nil {
+		return ""
+	}
+	var directives strings.Builder
+	for _, rule := range website.Spec.Caching.Rules {
+		fmt.Fprintf(&directives, `    location ~ %s {
+        add_header Cache-Control "%s";
+%s%s    }
+`, cacheRulePattern(rule), cacheControl(rule), securityHeaderDirectives(website, "        "), tryFilesDirective(website, "        "))
+	}
+	return directives.String()
+}
+
+// cacheRulePattern returns the regular expression matching the request paths of a rule.
+func cacheRulePattern(rule devv1.CacheRule) string {
+	pattern := "^" + regexp.QuoteMeta(rule.Path)
+	if rule.Path == "" {
+		pattern = "^/"
+	}
+	if len(rule.Extensions) > 0 {
+		pattern += fmt.Sprintf(`.*\.(%s)$`, strings.Join(rule.Extensions, "|"))
+	}
+	return pattern
+}
+
+// cacheControl returns the Cache-Control header value of a rule.
+func cacheControl(rule devv1.CacheRule) string {
+	if rule.MaxAge == nil {
+		return "no-cache"
+	}
+	value := fmt.Sprintf("public, max-age=%d", int64(rule.MaxAge.Seconds()))
+	if rule.Immutable {
+		value += ", immutable"
+	}
+	return value
+}
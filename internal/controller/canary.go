@@ -0,0 +1,244 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// canaryAnnotation and canaryWeightAnnotation make ingress-nginx send a share of the
+	// traffic of a host to the backend of a second Ingress
+	canaryAnnotation       = "nginx.ingress.kubernetes.io/canary"
+	canaryWeightAnnotation = "nginx.ingress.kubernetes.io/canary-weight"
+
+	defaultCanaryStepDuration = 5 * time.Minute
+	canaryPollInterval        = 10 * time.Second
+)
+
+var defaultCanarySteps = []int32{10, 25, 50}
+
+func canaryName(name string) string {
+	return fmt.Sprintf("%s-canary", name)
+}
+
+// reconcileCanary rolls a new image out to a website progressively. While the image of
+// the desired deployment differs from the one running, the running image is kept on the
+// main deployment, and the new one runs on a canary deployment receiving the traffic
+// share of the current step. After the last step the new image is promoted to the main
+// deployment and the canary removed. It returns when the website should be looked at
+// again.
+func (r *WebsiteReconciler) reconcileCanary(ctx context.Context, website *devv1.Website, desired *appsv1.Deployment) (time.Duration, error) {
+	log := log.FromContext(ctx)
+
+	current := appsv1.Deployment{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &current)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to retrieve deployment", "action", "get")
+		return 0, err
+	}
+
+	image := desired.Spec.Template.Spec.Containers[0].Image
+	canary := website.Spec.Rollout != nil && website.Spec.Rollout.Canary != nil && !flaggerRollout(website)
+	if errors.IsNotFound(err) || !canary || current.Spec.Template.Spec.Containers[0].Image == image {
+		return 0, r.endCanary(ctx, website)
+	}
+	stable := current.Spec.Template.Spec.Containers[0].Image
+
+	steps, stepDuration := canarySteps(website.Spec.Rollout.Canary)
+
+	status := website.Status.Canary.DeepCopy()
+	if status == nil || status.Image != image {
+		log.Info("Starting canary rollout", "image", image)
+		status = &devv1.CanaryStatus{Image: image, StepStartedAt: metav1.Now()}
+	}
+	// The steps may have been shortened since the rollout started.
+	if int(status.Step) >= len(steps) {
+		status.Step = int32(len(steps) - 1)
+	}
+
+	canaryDeployment := newCanaryDeployment(desired, canaryName(resourceName(website)))
+	if err := r.applyDeployment(ctx, canaryDeployment); err != nil {
+		return 0, err
+	}
+	ready, err := r.deploymentReady(ctx, canaryDeployment)
+	if err != nil {
+		return 0, err
+	}
+
+	// A step only ends once the canary pods are ready.
+	if ready && time.Since(status.StepStartedAt.Time) >= stepDuration {
+		status.Step++
+		status.StepStartedAt = metav1.Now()
+		if int(status.Step) >= len(steps) {
+			log.Info("Promoting canary", "image", image)
+			if err := r.applyDeployment(ctx, desired); err != nil {
+				return 0, err
+			}
+			return 0, r.endCanary(ctx, website)
+		}
+		log.Info("Advancing canary", "image", image, "weight", steps[status.Step])
+	}
+	status.Weight = steps[status.Step]
+
+	service := newCanaryService(website, canaryName(resourceName(website)))
+	if err := r.reconcileService(ctx, service, true); err != nil {
+		return 0, err
+	}
+	ingress := newCanaryIngress(website, canaryName(resourceName(website)), map[string]string{
+		canaryAnnotation:       "true",
+		canaryWeightAnnotation: strconv.Itoa(int(status.Weight)),
+	})
+	if err := ctrl.SetControllerReference(website, ingress, r.Scheme); err != nil {
+		return 0, err
+	}
+	if err := r.applyIngress(ctx, ingress); err != nil {
+		return 0, err
+	}
+
+	// Keep the main deployment on the image that was running.
+	desired.Spec.Template.Spec.Containers[0].Image = stable
+	if err := r.setCanaryStatus(ctx, website, status); err != nil {
+		return 0, err
+	}
+	// The canary deployment is not watched, so its readiness is polled.
+	if !ready {
+		return canaryPollInterval, nil
+	}
+	return stepDuration - time.Since(status.StepStartedAt.Time), nil
+}
+
+// canarySteps returns the traffic percentages and step duration of a canary rollout.
+func canarySteps(spec *devv1.CanarySpec) ([]int32, time.Duration) {
+	steps, stepDuration := defaultCanarySteps, defaultCanaryStepDuration
+	if spec == nil {
+		return steps, stepDuration
+	}
+	if len(spec.Steps) > 0 {
+		steps = spec.Steps
+	}
+	if spec.StepDuration != nil {
+		stepDuration = spec.StepDuration.Duration
+	}
+	return steps, stepDuration
+}
+
+// endCanary removes the canary resources of a website and clears its canary status.
+func (r *WebsiteReconciler) endCanary(ctx context.Context, website *devv1.Website) error {
+	name := canaryName(resourceName(website))
+	objects := []client.Object{
+		&networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: website.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: website.Namespace}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: website.Namespace}},
+	}
+	if website.Status.Canary != nil {
+		log.FromContext(ctx).Info("Removing canary", "action", "delete", "image", website.Status.Canary.Image)
+		for _, obj := range objects {
+			if err := r.Client.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+				log.FromContext(ctx).Error(err, "Failed to delete canary resource", "action", "delete", "name", name)
+				return err
+			}
+		}
+	}
+	return r.setCanaryStatus(ctx, website, nil)
+}
+
+func (r *WebsiteReconciler) setCanaryStatus(ctx context.Context, website *devv1.Website, status *devv1.CanaryStatus) error {
+	if equality.Semantic.DeepEqual(website.Status.Canary, status) {
+		return nil
+	}
+	patch := client.MergeFrom(website.DeepCopy())
+	website.Status.Canary = status
+	if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update website status", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// deploymentReady reports whether every pod of the current revision of a deployment is ready.
+func (r *WebsiteReconciler) deploymentReady(ctx context.Context, desired *appsv1.Deployment) (bool, error) {
+	deployment := appsv1.Deployment{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &deployment); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	return deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.UpdatedReplicas == replicas && deployment.Status.ReadyReplicas >= replicas, nil
+}
+
+// Create a canary deployment from the desired deployment of a website. Its pods are
+// labelled as a website of their own, so that the main Service does not select them.
+func newCanaryDeployment(desired *appsv1.Deployment, name string) *appsv1.Deployment {
+	replicas := int32(1)
+
+	canary := desired.DeepCopy()
+	canary.Name = name
+	canary.Labels = withLabels(setResourceLabels(name), desired.Labels, recommendedLabelPrefix)
+	canary.Spec.Replicas = &replicas
+	canary.Spec.Selector = &metav1.LabelSelector{MatchLabels: setResourceLabels(name)}
+	canary.Spec.Template.Labels = withLabels(setResourceLabels(name), desired.Spec.Template.Labels, recommendedLabelPrefix)
+	canary.Spec.Template.Labels = withLabels(canary.Spec.Template.Labels, desired.Spec.Template.Labels, istioInjectLabel)
+	return canary
+}
+
+// Create the ClusterIP Service in front of the pods of a canary deployment.
+func newCanaryService(website *devv1.Website, name string) *corev1.Service {
+	service := newHeadlessService(website)
+	service.Name = name
+	service.Labels = withLabels(setResourceLabels(service.Name), service.Labels, recommendedLabelPrefix)
+	service.Spec.Selector = setResourceLabels(service.Name)
+	service.Spec.ClusterIP = ""
+	return service
+}
+
+// Create a canary Ingress, sending the traffic of the website host selected by its
+// ingress-nginx canary annotations to the Service of the same name. TLS is terminated by
+// the main Ingress, only the protocol spoken to the backend is kept.
+func newCanaryIngress(website *devv1.Website, name string, annotations map[string]string) *networkingv1.Ingress {
+	ingress := newIngress(website)
+	ingress.Name = name
+	ingress.Labels = withLabels(setResourceLabels(ingress.Name), ingress.Labels, recommendedLabelPrefix)
+	ingress.Spec.TLS = nil
+	ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name = name
+	protocol := ingress.Annotations[backendProtocolAnnotation]
+	ingress.Annotations = nil
+	if protocol != "" {
+		annotations[backendProtocolAnnotation] = protocol
+	}
+	syncAnnotations(&ingress.ObjectMeta, annotations)
+	return ingress
+}
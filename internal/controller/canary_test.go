@@ -0,0 +1,51 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+func TestReconcileCanaryShortenedSteps(t *testing.T) {
+	website := &devv1.Website{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: devv1.WebsiteSpec{
+			ImageTag: "v1",
+			Ingress:  &devv1.IngressSpec{Host: "hello.example.com"},
+			Rollout:  &devv1.RolloutSpec{Canary: &devv1.CanarySpec{Steps: []int32{50}}},
+		},
+	}
+	stable := newDeployment(website)
+	website.Spec.ImageTag = "v2"
+	desired := newDeployment(website)
+	image := desired.Spec.Template.Spec.Containers[0].Image
+	// The rollout was at its third step when the steps were shortened to one.
+	website.Status.Canary = &devv1.CanaryStatus{Image: image, Step: 2, Weight: 50, StepStartedAt: metav1.Now()}
+	r := newTestReconciler(t, website, stable)
+
+	if _, err := r.reconcileCanary(context.Background(), website, desired); err != nil {
+		t.Fatalf("reconcileCanary() failed: %v", err)
+	}
+	if status := website.Status.Canary; status == nil || status.Step != 0 || status.Weight != 50 {
+		t.Errorf("Status.Canary = %+v, want step 0 with a weight of 50", status)
+	}
+}
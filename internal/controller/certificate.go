@@ -0,0 +1,157 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// defaultCertificateExpiryWarning is how long before its expiry a certificate is
+	// reported as expiring by default. cert-manager renews certificates well before, so a
+	// certificate this close to its expiry means renewal is stuck.
+	defaultCertificateExpiryWarning = 14 * 24 * time.Hour
+	// certificateRetryInterval is how often a certificate that cannot be read is read again.
+	certificateRetryInterval = 5 * time.Minute
+)
+
+// servingCertificateSecret returns the Secret holding the certificate clients see: the one
+// of the Ingress when it terminates TLS, or else the one the pods serve. It returns an
+// empty string when the website does not serve TLS.
+func servingCertificateSecret(website *devv1.Website) string {
+	if website.Spec.Ingress != nil && website.Spec.Ingress.TLS != nil {
+		return tlsSecretName(website)
+	}
+	if website.Spec.BackendTLS != nil {
+		return backendTLSSecretName(website)
+	}
+	return ""
+}
+
+// reconcileCertificateExpiry reads the expiry of the serving certificate of a website and
+// reports it in the status, the CertificateExpiring condition and the
+// website_certificate_expiry_timestamp_seconds metric. A Warning event is emitted when the
+// certificate starts expiring. It returns when the certificate should be checked again.
+func (r *WebsiteReconciler) reconcileCertificateExpiry(ctx context.Context, website *devv1.Website) (time.Duration, error) {
+	secretName := servingCertificateSecret(website)
+	deleteCertificateMetric(website)
+	if secretName == "" {
+		if err := r.setCondition(ctx, website, devv1.ConditionCertificateExpiring, nil); err != nil {
+			return 0, err
+		}
+		return 0, r.setCertificateStatus(ctx, website, nil)
+	}
+
+	status := &devv1.CertificateStatus{SecretName: secretName}
+	certificate, err := r.tlsCertificate(ctx, types.NamespacedName{Name: secretName, Namespace: website.Namespace})
+	if err != nil {
+		// The Secret may not be issued yet.
+		status.Message = err.Error()
+		if err := r.setCondition(ctx, website, devv1.ConditionCertificateExpiring, nil); err != nil {
+			return 0, err
+		}
+		return certificateRetryInterval, r.setCertificateStatus(ctx, website, status)
+	}
+	notAfter := certificate.NotAfter
+	status.NotAfter = &metav1.Time{Time: notAfter}
+	certificateExpiry.WithLabelValues(website.Namespace, website.Name, secretName).Set(float64(notAfter.Unix()))
+
+	warning := r.CertificateExpiryWarning
+	if warning == 0 {
+		warning = defaultCertificateExpiryWarning
+	}
+	condition := &metav1.Condition{
+		Type:    devv1.ConditionCertificateExpiring,
+		Status:  metav1.ConditionFalse,
+		Reason:  devv1.ReasonCertificateValid,
+		Message: fmt.Sprintf("expires at %s", notAfter.UTC().Format(time.RFC3339)),
+	}
+	retryAfter := time.Until(notAfter.Add(-warning))
+	switch {
+	case !time.Now().Before(notAfter):
+		condition.Status, condition.Reason = metav1.ConditionTrue, devv1.ReasonExpired
+		condition.Message = fmt.Sprintf("expired at %s", notAfter.UTC().Format(time.RFC3339))
+		retryAfter = 0
+	case retryAfter <= 0:
+		condition.Status, condition.Reason = metav1.ConditionTrue, devv1.ReasonExpiringSoon
+		retryAfter = time.Until(notAfter)
+	}
+
+	if condition.Status == metav1.ConditionTrue {
+		previous := meta.FindStatusCondition(website.Status.Conditions, devv1.ConditionCertificateExpiring)
+		if (previous == nil || previous.Reason != condition.Reason) && r.Recorder != nil {
+			r.Recorder.Event(website, corev1.EventTypeWarning, condition.Reason, fmt.Sprintf("Certificate in Secret %s %s", secretName, condition.Message))
+		}
+		log.FromContext(ctx).Info("Serving certificate is expiring", "secret", secretName, "notAfter", notAfter)
+	}
+	if err := r.setCondition(ctx, website, devv1.ConditionCertificateExpiring, condition); err != nil {
+		return 0, err
+	}
+	return retryAfter, r.setCertificateStatus(ctx, website, status)
+}
+
+// tlsCertificate returns the leaf certificate of a TLS Secret.
+func (r *WebsiteReconciler) tlsCertificate(ctx context.Context, name types.NamespacedName) (*x509.Certificate, error) {
+	secret := corev1.Secret{}
+	if err := r.APIReader.Get(ctx, name, &secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("secret %s does not exist", name.Name)
+		}
+		return nil, err
+	}
+	block, _ := pem.Decode(secret.Data[corev1.TLSCertKey])
+	if block == nil {
+		return nil, fmt.Errorf("secret %s holds no PEM certificate in %s", name.Name, corev1.TLSCertKey)
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the certificate of secret %s: %w", name.Name, err)
+	}
+	return certificate, nil
+}
+
+func deleteCertificateMetric(website *devv1.Website) {
+	certificateExpiry.DeletePartialMatch(map[string]string{"namespace": website.Namespace, "website": website.Name})
+}
+
+func (r *WebsiteReconciler) setCertificateStatus(ctx context.Context, website *devv1.Website, status *devv1.CertificateStatus) error {
+	if equality.Semantic.DeepEqual(website.Status.Certificate, status) {
+		return nil
+	}
+	patch := client.MergeFrom(website.DeepCopy())
+	website.Status.Certificate = status
+	if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update website status", "action", "update")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// classIndex indexes Websites by the name of the WebsiteClass they name
+const classIndex = "website.className"
+
+// websiteClass returns the class a website takes its defaults from, or nil when it names
+// none and there is no default class. Classes are cluster-scoped, so an operator watching
+// a single namespace has none.
+func (r *WebsiteReconciler) websiteClass(ctx context.Context, website *devv1.Website) (*devv1.WebsiteClass, error) {
+	if r.Namespace != "" {
+		if website.Spec.ClassName != "" {
+			return nil, fmt.Errorf("website class %q: classes are not available to an operator watching a single namespace",
+				website.Spec.ClassName)
+		}
+		return nil, nil
+	}
+
+	if website.Spec.ClassName != "" {
+		class := &devv1.WebsiteClass{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: website.Spec.ClassName}, class); err != nil {
+			return nil, fmt.Errorf("website class %q: %w", website.Spec.ClassName, err)
+		}
+		return class, nil
+	}
+
+	classes := devv1.WebsiteClassList{}
+	if err := r.Client.List(ctx, &classes); err != nil {
+		return nil, err
+	}
+	for i := range classes.Items {
+		if classes.Items[i].Annotations[devv1.IsDefaultClassAnnotation] == "true" {
+			return &classes.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// withClassDefaults returns a copy of the website with the fields it leaves unset
+// defaulted from its class.
+func withClassDefaults(website *devv1.Website, class *devv1.WebsiteClass) *devv1.Website {
+	website = website.DeepCopy()
+	if class == nil {
+		return website
+	}
+
+	spec, defaults := &website.Spec, class.Spec
+	if spec.ImageRepository == "" {
+		spec.ImageRepository = defaults.ImageRepository
+	}
+	if spec.Resources == nil {
+		spec.Resources = defaults.Resources
+	}
+	if spec.SecurityContext == nil {
+		spec.SecurityContext = defaults.SecurityContext
+	}
+	if ingress := spec.Ingress; ingress != nil {
+		if ingress.ClassName == "" {
+			ingress.ClassName = defaults.IngressClassName
+		}
+		if ingress.TLS != nil && ingress.TLS.Issuer == "" && ingress.TLS.ACME == nil && !ingress.TLS.SelfSigned {
+			ingress.TLS.Issuer = defaults.TLSIssuer
+		}
+	}
+	return website
+}
+
+// websitesOfClass maps a WebsiteClass to the Websites taking their defaults from it.
+func (r *WebsiteReconciler) websitesOfClass(obj client.Object) []reconcile.Request {
+	names := []string{obj.GetName()}
+	if obj.GetAnnotations()[devv1.IsDefaultClassAnnotation] == "true" {
+		names = append(names, "")
+	}
+
+	var requests []reconcile.Request
+	for _, name := range names {
+		websites := devv1.WebsiteList{}
+		if err := r.Client.List(context.Background(), &websites, client.MatchingFields{classIndex: name}); err != nil {
+			log.Log.Error(err, "Failed to list websites of class", "class", obj.GetName())
+			return nil
+		}
+		for _, website := range websites.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: website.Name, Namespace: website.Namespace},
+			})
+		}
+	}
+	return requests
+}
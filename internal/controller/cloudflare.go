@@ -0,0 +1,88 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+	"github.com/mvasilenko/helloworld-operator/internal/cloudflare"
+)
+
+// cloudflareTokenKey is the key of the Cloudflare API token in its Secret
+const cloudflareTokenKey = "apiToken"
+
+// syncCloudflareRecords makes the Cloudflare records of the host of a website match the
+// given records.
+func (r *WebsiteReconciler) syncCloudflareRecords(ctx context.Context, website *devv1.Website, records []dnsRecord) error {
+	spec := website.Spec.DNS.Cloudflare
+	ttl := website.Spec.DNS.TTL
+	// Proxied records always use the automatic TTL, which the API denotes with 1.
+	if ttl == 0 || spec.Proxied {
+		ttl = 1
+	}
+	var desired []cloudflare.Record
+	for _, record := range records {
+		for _, target := range record.targets {
+			desired = append(desired, cloudflare.Record{Type: record.recordType, Content: target, TTL: ttl, Proxied: spec.Proxied})
+		}
+	}
+
+	host := dnsHost(website)
+	secret := corev1.Secret{}
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Name: spec.APITokenSecretName, Namespace: website.Namespace}, &secret); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to retrieve Cloudflare API token", "action", "get")
+		return err
+	}
+	token, ok := secret.Data[cloudflareTokenKey]
+	if !ok {
+		return fmt.Errorf("secret %q has no key %q", secret.Name, cloudflareTokenKey)
+	}
+	api := &cloudflare.Client{Token: string(token)}
+
+	zoneID := spec.ZoneID
+	if zoneID == "" {
+		var err error
+		if zoneID, err = api.FindZone(ctx, host); err != nil {
+			return err
+		}
+	}
+	// The comment marks the records of the website, so that the other records of the host
+	// are left alone.
+	comment := fmt.Sprintf("Managed by website-operator for %s/%s", website.Namespace, website.Name)
+	if r.dryRun() {
+		changes, err := api.Plan(ctx, zoneID, host, comment, desired)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			log.FromContext(ctx).Info("Dry run: skipping Cloudflare change", "action", change.Action, "host", host,
+				"type", change.Record.Type, "content", change.Record.Content)
+		}
+		return nil
+	}
+	if err := api.Sync(ctx, zoneID, host, comment, desired); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to sync Cloudflare records", "action", "update", "host", host)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const defaultKubeconfigKey = "kubeconfig"
+
+// clusterClients caches the clients of remote clusters, keyed by the kubeconfig Secret
+// they were built from. A client is rebuilt when its Secret changes.
+type clusterClients struct {
+	mu      sync.Mutex
+	clients map[types.UID]clusterClient
+}
+
+type clusterClient struct {
+	resourceVersion string
+	client          client.Client
+}
+
+// clusterClient returns a client for the remote cluster a target points at.
+func (r *WebsiteReconciler) clusterClient(ctx context.Context, namespace string, target devv1.ClusterTarget) (client.Client, error) {
+	secret := corev1.Secret{}
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Name: target.KubeconfigSecretRef.Name, Namespace: namespace}, &secret); err != nil {
+		return nil, err
+	}
+
+	r.clusters.mu.Lock()
+	defer r.clusters.mu.Unlock()
+	if cached, ok := r.clusters.clients[secret.UID]; ok && cached.resourceVersion == secret.ResourceVersion {
+		return cached.client, nil
+	}
+
+	key := target.KubeconfigSecretRef.Key
+	if key == "" {
+		key = defaultKubeconfigKey
+	}
+	kubeconfig, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("secret %q has no key %q", secret.Name, key)
+	}
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(config, client.Options{Scheme: r.Scheme})
+	if err != nil {
+		return nil, err
+	}
+
+	if r.clusters.clients == nil {
+		r.clusters.clients = map[types.UID]clusterClient{}
+	}
+	r.clusters.clients[secret.UID] = clusterClient{resourceVersion: secret.ResourceVersion, client: c}
+	return c, nil
+}
+
+// reconcileClusters deploys the website to each of its remote clusters, removes it from
+// the clusters dropped from its spec, and records the state of every cluster in status.
+// A cluster that fails does not stop the others from being reconciled.
+func (r *WebsiteReconciler) reconcileClusters(ctx context.Context, website *devv1.Website) error {
+	log := log.FromContext(ctx)
+
+	var errs []error
+	var statuses []devv1.ClusterStatus
+	wanted := map[string]bool{}
+	for _, target := range website.Spec.Clusters {
+		wanted[target.Name] = true
+		ctx := logr.NewContext(ctx, log.WithValues("cluster", target.Name))
+
+		status := devv1.ClusterStatus{Name: target.Name, KubeconfigSecretRef: target.KubeconfigSecretRef}
+		readyReplicas, err := r.reconcileCluster(ctx, website, target)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", target.Name, err))
+			status.Message = err.Error()
+		} else {
+			status.Synced = true
+			status.ReadyReplicas = readyReplicas
+		}
+		statuses = append(statuses, status)
+	}
+
+	// A cluster is only known to hold the website while it is listed in status, so the
+	// clusters dropped from the spec are cleaned up from there, as long as their
+	// kubeconfig Secret is still around.
+	for _, status := range website.Status.Clusters {
+		if wanted[status.Name] {
+			continue
+		}
+		log.Info("Cluster is no longer wanted", "action", "delete", "cluster", status.Name)
+		target := devv1.ClusterTarget{Name: status.Name, KubeconfigSecretRef: status.KubeconfigSecretRef}
+		if err := r.removeFromCluster(ctx, website, target); err != nil {
+			log.Error(err, "Failed to remove website from cluster", "action", "delete", "cluster", status.Name)
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(statuses, website.Status.Clusters) {
+		patch := client.MergeFrom(website.DeepCopy())
+		website.Status.Clusters = statuses
+		if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+			log.Error(err, "Failed to update website status", "action", "update")
+			return err
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// reconcileCluster reconciles the Deployment and Services of a website in a remote
+// cluster, and returns how many of its pods are ready there.
+func (r *WebsiteReconciler) reconcileCluster(ctx context.Context, website *devv1.Website, target devv1.ClusterTarget) (int32, error) {
+	c, err := r.clusterClient(ctx, website.Namespace, target)
+	if err != nil {
+		return 0, err
+	}
+
+	remote := r.remote(c)
+	if err := remote.reconcileDeployment(ctx, website); err != nil {
+		return 0, err
+	}
+	if err := remote.reconcileServices(ctx, website); err != nil {
+		return 0, err
+	}
+
+	deployment := appsv1.Deployment{}
+	if err := c.Get(ctx, types.NamespacedName{Name: deploymentName(website), Namespace: website.Namespace}, &deployment); err != nil {
+		return 0, err
+	}
+	return deployment.Status.ReadyReplicas, nil
+}
+
+// removeFromCluster deletes the Deployment, Services and pull Secret of a website from a
+// remote cluster.
+func (r *WebsiteReconciler) removeFromCluster(ctx context.Context, website *devv1.Website, target devv1.ClusterTarget) error {
+	c, err := r.clusterClient(ctx, website.Namespace, target)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	objects := []client.Object{
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: deploymentName(website), Namespace: website.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: serviceName(website), Namespace: website.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: headlessServiceName(serviceName(website)), Namespace: website.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: metricsServiceName(resourceName(website)), Namespace: website.Namespace}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: pullSecretName(resourceName(website)), Namespace: website.Namespace}},
+	}
+	remote := r.remote(c)
+	for _, obj := range objects {
+		if err := remote.Client.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// remote returns a reconciler with the configuration of r, writing to a remote cluster.
+// Writes stay dry runs in dry-run mode. The sources of the website, such as its content
+// and the registry credentials, are read from the local cluster.
+func (r *WebsiteReconciler) remote(c client.Client) *WebsiteReconciler {
+	if r.dryRun() {
+		c = NewDryRunClient(c)
+	}
+	return &WebsiteReconciler{
+		Client:              c,
+		Scheme:              r.Scheme,
+		APIReader:           r.APIReader,
+		RegistryCredentials: r.RegistryCredentials,
+		RegistryMirrors:     r.RegistryMirrors,
+		HTTPProxy:           r.HTTPProxy,
+		HTTPSProxy:          r.HTTPSProxy,
+		NoProxy:             r.NoProxy,
+		remoteCluster:       true,
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+func TestRemoteReconciler(t *testing.T) {
+	website := &devv1.Website{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: devv1.WebsiteSpec{
+			ImageTag: "v1",
+			Content:  &devv1.ContentSpec{ConfigMapName: "hello-content"},
+		},
+	}
+	content := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello-content", Namespace: "default"},
+		Data:       map[string]string{"index.html": "hello"},
+	}
+	r := newTestReconciler(t, website, content)
+	r.RegistryMirrors = map[string]string{"docker.io": "mirror.example.com/dockerhub"}
+	r.HTTPProxy = "http://proxy.example.com:3128"
+	ctx := context.Background()
+	name := types.NamespacedName{Name: deploymentName(website), Namespace: website.Namespace}
+
+	// The content only exists in the local cluster.
+	c := fake.NewClientBuilder().WithScheme(r.Scheme).Build()
+	if err := r.remote(c).reconcileDeployment(ctx, website); err != nil {
+		t.Fatalf("reconcileDeployment() failed: %v", err)
+	}
+	deployment := appsv1.Deployment{}
+	if err := c.Get(ctx, name, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	template := deployment.Spec.Template
+	if template.Annotations[devv1.ContentChecksumAnnotation] == "" {
+		t.Error("expected the checksum of the local content")
+	}
+	if image := template.Spec.Containers[0].Image; !strings.HasPrefix(image, "mirror.example.com/dockerhub/") {
+		t.Errorf("remote deployment runs %s, expected the registry mirror", image)
+	}
+	if len(template.Spec.Containers[0].Env) == 0 {
+		t.Error("expected the proxy variables on the remote deployment")
+	}
+
+	r.Client = NewDryRunClient(r.Client)
+	c = fake.NewClientBuilder().WithScheme(r.Scheme).Build()
+	if err := r.remote(c).reconcileDeployment(ctx, website); err != nil {
+		t.Fatalf("reconcileDeployment() failed: %v", err)
+	}
+	if err := c.Get(ctx, name, &appsv1.Deployment{}); !errors.IsNotFound(err) {
+		t.Errorf("expected no remote write in dry-run mode, got %v", err)
+	}
+}
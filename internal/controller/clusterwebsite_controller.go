@@ -0,0 +1,296 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// ClusterWebsiteReconciler reconciles a ClusterWebsite object
+type ClusterWebsiteReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Sharder, when set, limits this replica to the ClusterWebsites of its shard.
+	Sharder Sharder
+}
+
+//+kubebuilder:rbac:groups=dev.mvasilenko.me,resources=clusterwebsites,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=dev.mvasilenko.me,resources=clusterwebsites/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=dev.mvasilenko.me,resources=clusterwebsites/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile creates a Website from the template of a ClusterWebsite in every namespace
+// matching its selector, keeps their specs in line with the template, and deletes the
+// Websites of namespaces that no longer match.
+func (r *ClusterWebsiteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx).WithValues("clusterWebsite", req.Name)
+
+	if r.Sharder != nil && !r.Sharder.Owns(req.NamespacedName) {
+		log.V(1).Info("ClusterWebsite belongs to another shard")
+		return ctrl.Result{}, nil
+	}
+
+	clusterWebsite := &devv1.ClusterWebsite{}
+	if err := r.Client.Get(ctx, req.NamespacedName, clusterWebsite); err != nil {
+		if errors.IsNotFound(err) {
+			// The generated Websites are garbage collected through their owner reference.
+			log.Info("Custom resource for cluster website does not exist")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to retrieve custom resource")
+		return ctrl.Result{}, err
+	}
+	log = log.WithValues("generation", clusterWebsite.Generation)
+	ctx = ctrllog.IntoContext(ctx, log)
+
+	namespaces := corev1.NamespaceList{}
+	if clusterWebsite.Spec.TargetNamespace != "" {
+		namespace, err := r.reconcileNamespace(ctx, clusterWebsite)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if namespace != nil {
+			namespaces.Items = append(namespaces.Items, *namespace)
+		}
+	} else {
+		selector, err := metav1.LabelSelectorAsSelector(&clusterWebsite.Spec.NamespaceSelector)
+		if err != nil {
+			log.Error(err, "Invalid namespace selector")
+			return ctrl.Result{}, nil
+		}
+		if err := r.Client.List(ctx, &namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			log.Error(err, "Failed to list namespaces", "action", "get")
+			return ctrl.Result{}, err
+		}
+	}
+	if err := r.deleteNamespaces(ctx, clusterWebsite); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var deployed []string
+	wanted := map[string]bool{}
+	for _, namespace := range namespaces.Items {
+		if namespace.Status.Phase == corev1.NamespaceTerminating {
+			continue
+		}
+		wanted[namespace.Name] = true
+		ok, err := r.reconcileWebsite(ctx, clusterWebsite, namespace.Name)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if ok {
+			deployed = append(deployed, namespace.Name)
+		}
+	}
+
+	websites := devv1.WebsiteList{}
+	if err := r.Client.List(ctx, &websites, client.MatchingLabels{devv1.ClusterWebsiteLabel: clusterWebsite.Name}); err != nil {
+		log.Error(err, "Failed to list websites", "action", "get")
+		return ctrl.Result{}, err
+	}
+	for i := range websites.Items {
+		website := &websites.Items[i]
+		if wanted[website.Namespace] || !metav1.IsControlledBy(website, clusterWebsite) {
+			continue
+		}
+		log.Info("Namespace no longer matches", "action", "delete", "namespace", website.Namespace)
+		if err := r.Client.Delete(ctx, website); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete website", "action", "delete", "namespace", website.Namespace)
+			return ctrl.Result{}, err
+		}
+	}
+
+	sort.Strings(deployed)
+	status := devv1.ClusterWebsiteStatus{Namespaces: deployed, NamespaceCount: int32(len(deployed))}
+	if !equality.Semantic.DeepEqual(status, clusterWebsite.Status) {
+		patch := client.MergeFrom(clusterWebsite.DeepCopy())
+		clusterWebsite.Status = status
+		if err := r.Client.Status().Patch(ctx, clusterWebsite, patch); err != nil {
+			log.Error(err, "Failed to update cluster website status", "action", "update")
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// reconcileWebsite creates or updates the Website of a ClusterWebsite in a namespace. It
+// reports false when a Website of the same name, not owned by the ClusterWebsite,
+// already exists there; that Website is left alone.
+func (r *ClusterWebsiteReconciler) reconcileWebsite(ctx context.Context, clusterWebsite *devv1.ClusterWebsite, namespace string) (bool, error) {
+	log := ctrllog.FromContext(ctx).WithValues("namespace", namespace)
+
+	desired := &devv1.Website{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      clusterWebsite.Name,
+			Namespace: namespace,
+			Labels:    map[string]string{devv1.ClusterWebsiteLabel: clusterWebsite.Name},
+		},
+		Spec: clusterWebsite.Spec.Template,
+	}
+	if err := ctrl.SetControllerReference(clusterWebsite, desired, r.Scheme); err != nil {
+		return false, err
+	}
+
+	err := r.Client.Create(ctx, desired)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create website", "action", "create")
+		return false, err
+	}
+
+	current := devv1.Website{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: namespace}, &current); err != nil {
+		log.Error(err, "Failed to retrieve website", "action", "get")
+		return false, err
+	}
+	if !metav1.IsControlledBy(&current, clusterWebsite) {
+		log.Info("Website exists and is not managed by this cluster website")
+		return false, nil
+	}
+	if equality.Semantic.DeepEqual(current.Spec, desired.Spec) {
+		return true, nil
+	}
+
+	log.Info("Website has changed", "action", "update")
+	patch := client.MergeFrom(current.DeepCopy())
+	current.Spec = desired.Spec
+	if err := r.Client.Patch(ctx, &current, patch); err != nil {
+		log.Error(err, "Failed to update website", "action", "update")
+		return false, err
+	}
+	return true, nil
+}
+
+// reconcileNamespace returns the target namespace of a ClusterWebsite, creating it when
+// asked to. The labels of the namespaces it created are kept in line with its spec, other
+// namespaces are left alone. It returns nil when the namespace does not exist.
+func (r *ClusterWebsiteReconciler) reconcileNamespace(ctx context.Context, clusterWebsite *devv1.ClusterWebsite) (*corev1.Namespace, error) {
+	log := ctrllog.FromContext(ctx).WithValues("namespace", clusterWebsite.Spec.TargetNamespace)
+
+	labels := map[string]string{}
+	for key, value := range clusterWebsite.Spec.NamespaceLabels {
+		labels[key] = value
+	}
+	labels[devv1.ClusterWebsiteLabel] = clusterWebsite.Name
+	labels[managedByLabel] = managedBy
+
+	namespace := &corev1.Namespace{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: clusterWebsite.Spec.TargetNamespace}, namespace)
+	if errors.IsNotFound(err) {
+		if !clusterWebsite.Spec.CreateNamespace {
+			log.Info("Target namespace does not exist")
+			return nil, nil
+		}
+		namespace = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: clusterWebsite.Spec.TargetNamespace, Labels: labels}}
+		if err := ctrl.SetControllerReference(clusterWebsite, namespace, r.Scheme); err != nil {
+			return nil, err
+		}
+		log.Info("Creating target namespace", "action", "create")
+		if err := r.Client.Create(ctx, namespace); err != nil {
+			log.Error(err, "Failed to create namespace", "action", "create")
+			return nil, err
+		}
+		return namespace, nil
+	}
+	if err != nil {
+		log.Error(err, "Failed to retrieve namespace", "action", "get")
+		return nil, err
+	}
+	if namespace.Status.Phase == corev1.NamespaceTerminating || !metav1.IsControlledBy(namespace, clusterWebsite) {
+		return namespace, nil
+	}
+
+	patch := client.MergeFrom(namespace.DeepCopy())
+	if !syncLabels(&namespace.ObjectMeta, labels) {
+		return namespace, nil
+	}
+	log.Info("Namespace labels have changed", "action", "update")
+	if err := r.Client.Patch(ctx, namespace, patch); err != nil {
+		log.Error(err, "Failed to update namespace", "action", "update")
+		return nil, err
+	}
+	return namespace, nil
+}
+
+// deleteNamespaces deletes the namespaces a ClusterWebsite created that it no longer
+// targets.
+func (r *ClusterWebsiteReconciler) deleteNamespaces(ctx context.Context, clusterWebsite *devv1.ClusterWebsite) error {
+	log := ctrllog.FromContext(ctx)
+
+	namespaces := corev1.NamespaceList{}
+	if err := r.Client.List(ctx, &namespaces, client.MatchingLabels{devv1.ClusterWebsiteLabel: clusterWebsite.Name}); err != nil {
+		log.Error(err, "Failed to list namespaces", "action", "get")
+		return err
+	}
+	for i := range namespaces.Items {
+		namespace := &namespaces.Items[i]
+		if namespace.Name == clusterWebsite.Spec.TargetNamespace || namespace.Status.Phase == corev1.NamespaceTerminating ||
+			!metav1.IsControlledBy(namespace, clusterWebsite) {
+			continue
+		}
+		log.Info("Namespace is no longer targeted", "action", "delete", "namespace", namespace.Name)
+		if err := r.Client.Delete(ctx, namespace); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete namespace", "action", "delete", "namespace", namespace.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterWebsiteReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&devv1.ClusterWebsite{}).
+		Owns(&devv1.Website{}).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.allClusterWebsites)).
+		Complete(r)
+}
+
+// allClusterWebsites enqueues every ClusterWebsite, as any of them may select a namespace
+// that was just created or relabelled.
+func (r *ClusterWebsiteReconciler) allClusterWebsites(_ client.Object) []reconcile.Request {
+	clusterWebsites := devv1.ClusterWebsiteList{}
+	if err := r.Client.List(context.Background(), &clusterWebsites); err != nil {
+		ctrllog.Log.Error(err, "Failed to list cluster websites")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(clusterWebsites.Items))
+	for _, clusterWebsite := range clusterWebsites.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: clusterWebsite.Name}})
+	}
+	return requests
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const defaultCompressionLevel = 5
+
+// defaultCompressionTypes are the MIME types compressed by default. nginx always
+// compresses text/html.
+var defaultCompressionTypes = []string{
+	"text/css", "text/plain", "text/xml", "application/javascript", "application/json",
+	"application/xml", "application/rss+xml", "image/svg+xml",
+}
+
+// compressionDirectives returns the nginx directives compressing the responses of a
+// website, or nothing when it does not ask for compression.
+func compressionDirectives(website *devv1.Website) string {
+	spec := website.Spec.Compression
+	if spec == nil || !spec.Enabled {
+		return ""
+	}
+	algorithms := spec.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []devv1.CompressionAlgorithm{devv1.CompressionGzip}
+	}
+	level := spec.Level
+	if level == 0 {
+		level = defaultCompressionLevel
+	}
+	// Listing text/html makes nginx warn about a duplicate MIME type.
+	var types []string
+	for _, mimeType := range spec.Types {
+		if mimeType != "text/html" {
+			types = append(types, mimeType)
+		}
+	}
+	if len(spec.Types) == 0 {
+		types = defaultCompressionTypes
+	}
+
+	var directives strings.Builder
+	for _, algorithm := range algorithms {
+		fmt.Fprintf(&directives, "%s on;\n", algorithm)
+		fmt.Fprintf(&directives, "%s_comp_level %d;\n", algorithm, level)
+		if len(types) > 0 {
+			fmt.Fprintf(&directives, "%s_types %s;\n", algorithm, strings.Join(types, " "))
+		}
+	}
+	// Responses are compressed for proxies too, as the Ingress controller sits between
+	// nginx and the clients, and caches are told the response depends on Accept-Encoding.
+	directives.WriteString("gzip_proxied any;\ngzip_vary on;\n")
+	return directives.String()
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// reconcileConfigMap creates the desired ConfigMap, or brings the labels and data of an
+// existing one back in line with it. When the ConfigMap is not wanted, it is deleted.
+func (r *WebsiteReconciler) reconcileConfigMap(ctx context.Context, desired *corev1.ConfigMap, wanted bool) error {
+	log := log.FromContext(ctx).WithValues("configMap", desired.Name)
+
+	if !wanted {
+		if err := r.Client.Delete(ctx, desired); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete configmap", "action", "delete")
+			return err
+		}
+		return nil
+	}
+
+	err := r.Client.Create(ctx, desired)
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create configmap", "action", "create")
+		return err
+	}
+
+	current := corev1.ConfigMap{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &current); err != nil {
+		log.Error(err, "Failed to retrieve configmap", "action", "get")
+		return err
+	}
+	patch := client.MergeFrom(current.DeepCopy())
+	changed := syncLabels(&current.ObjectMeta, desired.Labels)
+	changed = syncField(&current.Data, desired.Data) || changed
+	if !changed {
+		return nil
+	}
+	log.Info("ConfigMap has changed", "action", "update")
+	if err := r.Client.Patch(ctx, &current, patch); err != nil {
+		log.Error(err, "Failed to update configmap", "action", "update")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// defaultContentMountPath is where nginx serves files from by default
+	defaultContentMountPath = "/usr/share/nginx/html"
+	contentVolumeName       = "content"
+)
+
+// contentChecksum hashes the ConfigMap or Secret holding the website content. It returns
+// an empty checksum when the website serves the files baked into its image.
+func (r *WebsiteReconciler) contentChecksum(ctx context.Context, website *devv1.Website) (string, error) {
+	content := website.Spec.Content
+	if content == nil {
+		return "", nil
+	}
+
+	// Content objects are owned by the user and do not carry the operator's labels, so
+	// they are invisible to the scoped cache and read from the API server instead.
+	data := map[string][]byte{}
+	if content.ConfigMapName != "" {
+		configMap := corev1.ConfigMap{}
+		if err := r.APIReader.Get(ctx, types.NamespacedName{Name: content.ConfigMapName, Namespace: website.Namespace}, &configMap); err != nil {
+			return "", err
+		}
+		for key, value := range configMap.Data {
+			data[key] = []byte(value)
+		}
+		for key, value := range configMap.BinaryData {
+			data[key] = value
+		}
+	} else {
+		secret := corev1.Secret{}
+		if err := r.APIReader.Get(ctx, types.NamespacedName{Name: content.SecretName, Namespace: website.Namespace}, &secret); err != nil {
+			return "", err
+		}
+		data = secret.Data
+	}
+
+	return checksum(data), nil
+}
+
+// checksum returns a stable hash of a set of files.
+func checksum(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, key := range keys {
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write(data[key])
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// contentRoot returns the directory nginx serves the website content from.
+func contentRoot(website *devv1.Website) string {
+	if content := website.Spec.Content; content != nil && content.MountPath != "" {
+		return content.MountPath
+	}
+	return defaultContentMountPath
+}
+
+// contentVolume returns the volume and mount serving the website content, if any.
+func contentVolume(website *devv1.Website) (*corev1.Volume, *corev1.VolumeMount) {
+	content := website.Spec.Content
+	if content == nil {
+		return nil, nil
+	}
+
+	// The default mode is set explicitly as the API server would otherwise default it,
+	// making the generated pod template differ from the one in the cluster.
+	volume := &corev1.Volume{Name: contentVolumeName}
+	if content.ConfigMapName != "" {
+		volume.ConfigMap = &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: content.ConfigMapName},
+			DefaultMode:          pointer.Int32(corev1.ConfigMapVolumeSourceDefaultMode),
+		}
+	} else {
+		volume.Secret = &corev1.SecretVolumeSource{
+			SecretName:  content.SecretName,
+			DefaultMode: pointer.Int32(corev1.SecretVolumeSourceDefaultMode),
+		}
+	}
+
+	return volume, &corev1.VolumeMount{Name: contentVolumeName, MountPath: contentRoot(website), ReadOnly: true}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// daemonSet reports whether a DaemonSet runs the website pods.
+func daemonSet(website *devv1.Website) bool {
+	return website.Spec.WorkloadType == devv1.WorkloadDaemonSet
+}
+
+// applyDaemonSet creates the desired daemon set, or brings the fields the operator owns on
+// the existing daemon set back in line with it.
+func (r *WebsiteReconciler) applyDaemonSet(ctx context.Context, desired *appsv1.DaemonSet) error {
+	log := log.FromContext(ctx).WithValues("daemonSet", desired.Name)
+
+	err := r.Client.Create(ctx, desired)
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create daemon set", "action", "create")
+		return err
+	}
+
+	current := appsv1.DaemonSet{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &current); err != nil {
+		log.Error(err, "Failed to retrieve daemon set", "action", "get")
+		return err
+	}
+
+	patch := client.StrategicMergeFrom(current.DeepCopy())
+	changed := syncLabels(&current.ObjectMeta, desired.Labels)
+	changed = syncPodTemplate(&current.Spec.Template, &desired.Spec.Template) || changed
+	if !changed {
+		return nil
+	}
+
+	log.Info("Daemon set has changed", "action", "update", "image", desired.Spec.Template.Spec.Containers[0].Image)
+	if err := r.Client.Patch(ctx, &current, patch); err != nil {
+		log.Error(err, "Failed to update daemon set", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// Create a DaemonSet running the pods of the desired deployment of a website on every
+// node matching its node selector.
+func newDaemonSet(desired *appsv1.Deployment) *appsv1.DaemonSet {
+	desired = desired.DeepCopy()
+	setVersionLabel(desired)
+
+	// The values below are what the API server defaults them to.
+	maxUnavailable, maxSurge := intstr.FromInt(1), intstr.FromInt(0)
+	revisionHistoryLimit := int32(10)
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: desired.ObjectMeta,
+		Spec: appsv1.DaemonSetSpec{
+			Selector: desired.Spec.Selector,
+			Template: desired.Spec.Template,
+			UpdateStrategy: appsv1.DaemonSetUpdateStrategy{
+				Type:          appsv1.RollingUpdateDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: &maxUnavailable, MaxSurge: &maxSurge},
+			},
+			RevisionHistoryLimit: &revisionHistoryLimit,
+		},
+	}
+}
+
+// daemonSetStatus describes the status of a daemon set the way a Deployment would, as
+// DaemonSets have no conditions of their own.
+func daemonSetStatus(daemonSet *appsv1.DaemonSet) *appsv1.DeploymentStatus {
+	status := daemonSet.Status
+	result := &appsv1.DeploymentStatus{
+		ObservedGeneration: status.ObservedGeneration,
+		Replicas:           status.CurrentNumberScheduled,
+		UpdatedReplicas:    status.UpdatedNumberScheduled,
+		ReadyReplicas:      status.NumberReady,
+		AvailableReplicas:  status.NumberAvailable,
+	}
+
+	available := appsv1.DeploymentCondition{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue, Reason: "PodsAvailable",
+		Message: fmt.Sprintf("%d of %d scheduled pods are available", status.NumberAvailable, status.DesiredNumberScheduled)}
+	switch {
+	case status.DesiredNumberScheduled == 0:
+		available.Status, available.Reason = corev1.ConditionFalse, "NoNodesSelected"
+		available.Message = "no node matches the node selector of the website"
+	case status.NumberUnavailable > 0:
+		available.Status, available.Reason = corev1.ConditionFalse, "PodsUnavailable"
+	}
+
+	progressing := appsv1.DeploymentCondition{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionTrue, Reason: newReplicaSetAvailable,
+		Message: "daemon set has successfully progressed"}
+	if status.ObservedGeneration < daemonSet.Generation || status.UpdatedNumberScheduled < status.DesiredNumberScheduled {
+		progressing.Reason = "RollingUpdate"
+		progressing.Message = fmt.Sprintf("%d of %d scheduled pods are updated", status.UpdatedNumberScheduled, status.DesiredNumberScheduled)
+	}
+
+	result.Conditions = []appsv1.DeploymentCondition{available, progressing}
+	return result
+}
+
+// daemonSetProgressed lets through the DaemonSet events that can change the conditions of
+// a website, see daemonSetStatus.
+var daemonSetProgressed = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldDaemonSet, newDaemonSet := e.ObjectOld.(*appsv1.DaemonSet), e.ObjectNew.(*appsv1.DaemonSet)
+		oldStatus, newStatus := oldDaemonSet.Status, newDaemonSet.Status
+		return oldStatus.ObservedGeneration != newStatus.ObservedGeneration ||
+			oldStatus.DesiredNumberScheduled != newStatus.DesiredNumberScheduled ||
+			oldStatus.UpdatedNumberScheduled != newStatus.UpdatedNumberScheduled ||
+			(oldStatus.NumberUnavailable > 0) != (newStatus.NumberUnavailable > 0)
+	},
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// grafanaDashboardLabel is the label the Grafana dashboard sidecar watches for
+// in order to load ConfigMaps as dashboards.
+const grafanaDashboardLabel = "grafana_dashboard"
+
+// reconcileDashboard makes sure a Grafana dashboard ConfigMap exists for the website
+// while monitoring is enabled, and removes it once monitoring is turned off.
+func (r *WebsiteReconciler) reconcileDashboard(ctx context.Context, website *devv1.Website) error {
+	log := log.FromContext(ctx)
+
+	name := types.NamespacedName{Name: dashboardName(resourceName(website)), Namespace: website.Namespace}
+
+	if website.Spec.Monitoring == nil || !website.Spec.Monitoring.Enabled {
+		err := r.Client.Delete(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace}})
+		if err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete dashboard", "action", "delete")
+			return err
+		}
+		return nil
+	}
+
+	desired, err := newDashboardConfigMap(website)
+	if err != nil {
+		return err
+	}
+	if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+		return err
+	}
+
+	err = r.Client.Create(ctx, desired)
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create dashboard", "action", "create")
+		return err
+	}
+
+	// Dashboards created before they carried the watch label are not in the cache, so
+	// the current one is read from the API server.
+	current := corev1.ConfigMap{}
+	if err := r.APIReader.Get(ctx, name, &current); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(current.DeepCopy())
+	changed := syncLabels(&current.ObjectMeta, desired.Labels)
+	changed = syncField(&current.Data, desired.Data) || changed
+	if !changed {
+		return nil
+	}
+
+	log.Info("Dashboard for website has changed", "action", "update")
+	if err := r.Client.Patch(ctx, &current, patch); err != nil {
+		log.Error(err, "Failed to update dashboard", "action", "update")
+		return err
+	}
+	return nil
+}
+
+func dashboardName(name string) string {
+	return fmt.Sprintf("%s-dashboard", name)
+}
+
+// Create a ConfigMap holding a Grafana dashboard for a single website. The queries rely on kube-state-metrics and, for probe results, on the blackbox exporter.
+func newDashboardConfigMap(website *devv1.Website) (*corev1.ConfigMap, error) {
+	name, namespace := website.Name, website.Namespace
+	dashboard, err := json.MarshalIndent(newDashboard(name, namespace, deploymentName(website)), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	labels := withRecommendedLabels(setResourceLabels(name), website, componentMonitoring)
+	labels[grafanaDashboardLabel] = "1"
+	labels[watchLabel] = "true"
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dashboardName(resourceName(website)),
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Data: map[string]string{
+			fmt.Sprintf("website-%s-%s.json", namespace, name): string(dashboard),
+		},
+	}, nil
+}
+
+func newDashboard(name, namespace, workload string) map[string]interface{} {
+	deployment := fmt.Sprintf(`namespace="%s", deployment="%s"`, namespace, workload)
+	pods := fmt.Sprintf(`namespace="%s", pod=~"%s-.*"`, namespace, workload)
+
+	panel := func(id int, title, expr, legend string, x, y int) map[string]interface{} {
+		return map[string]interface{}{
+			"id":         id,
+			"title":      title,
+			"type":       "timeseries",
+			"datasource": map[string]string{"type": "prometheus", "uid": "${datasource}"},
+			"gridPos":    map[string]int{"h": 8, "w": 12, "x": x, "y": y},
+			"targets": []map[string]string{{
+				"expr":         expr,
+				"legendFormat": legend,
+				"refId":        "A",
+			}},
+		}
+	}
+
+	return map[string]interface{}{
+		"uid":           fmt.Sprintf("website-%s-%s", namespace, name),
+		"title":         fmt.Sprintf("Website %s/%s", namespace, name),
+		"tags":          []string{"website-operator"},
+		"schemaVersion": 36,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []map[string]interface{}{{
+				"name":  "datasource",
+				"type":  "datasource",
+				"query": "prometheus",
+			}},
+		},
+		"panels": []map[string]interface{}{
+			panel(1, "Replicas",
+				fmt.Sprintf(`kube_deployment_status_replicas_available{%s}`, deployment), "available", 0, 0),
+			panel(2, "Container restarts",
+				fmt.Sprintf(`sum by (pod) (increase(kube_pod_container_status_restarts_total{%s}[5m]))`, pods), "{{pod}}", 12, 0),
+			panel(3, "HTTP probe success",
+				fmt.Sprintf(`probe_success{namespace="%s", website="%s"}`, namespace, name), "{{instance}}", 0, 8),
+			panel(4, "Rollout history",
+				fmt.Sprintf(`kube_deployment_status_observed_generation{%s}`, deployment), "generation", 12, 8),
+		},
+	}
+}
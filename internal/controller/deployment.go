@@ -0,0 +1,462 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// defaultImageRepository is the repository of the website image when neither the website
+// nor its class name one.
+const defaultImageRepository = "abangser/todo-local-storage"
+
+// managedReplicasAnnotation marks the deployments whose replicas the operator keeps in
+// sync with the website, rather than only setting them on creation.
+const managedReplicasAnnotation = "dev.mvasilenko.me/managed-replicas"
+
+// reconcileDeployment creates the deployment for a website, or brings the fields the
+// operator owns on an existing deployment back in line with the custom resource.
+func (r *WebsiteReconciler) reconcileDeployment(ctx context.Context, website *devv1.Website) error {
+	desired, err := r.desiredDeployment(ctx, website)
+	if err != nil {
+		return err
+	}
+	return r.applyDeployment(ctx, desired)
+}
+
+// desiredDeployment returns the deployment of a website, including the checksum of its
+// content and the pull Secret of its image.
+func (r *WebsiteReconciler) desiredDeployment(ctx context.Context, website *devv1.Website) (*appsv1.Deployment, error) {
+	desired := newDeployment(website)
+	r.mirrorPodImages(&desired.Spec.Template.Spec)
+	r.injectProxyEnv(&desired.Spec.Template.Spec)
+
+	checksum, err := r.contentChecksum(ctx, website)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to read website content", "action", "get")
+		return nil, err
+	}
+	if checksum != "" {
+		if desired.Spec.Template.Annotations == nil {
+			desired.Spec.Template.Annotations = map[string]string{}
+		}
+		desired.Spec.Template.Annotations[devv1.ContentChecksumAnnotation] = checksum
+	}
+
+	pullSecrets, err := r.reconcilePullSecret(ctx, website)
+	if err != nil {
+		return nil, err
+	}
+	desired.Spec.Template.Spec.ImagePullSecrets = pullSecrets
+	return desired, nil
+}
+
+// PodTemplate returns the template of the pods generated for a website, with the defaults
+// of its class applied, for the admission webhook to check.
+func (r *WebsiteReconciler) PodTemplate(ctx context.Context, website *devv1.Website) (*corev1.PodTemplateSpec, error) {
+	class, err := r.websiteClass(ctx, website)
+	if err != nil {
+		return nil, err
+	}
+	return &newDeployment(withClassDefaults(website, class)).Spec.Template, nil
+}
+
+// applyDeployment creates the desired deployment, or brings the fields the operator owns
+// on the existing deployment back in line with it.
+func (r *WebsiteReconciler) applyDeployment(ctx context.Context, desired *appsv1.Deployment) error {
+	log := log.FromContext(ctx).WithValues("deployment", desired.Name)
+
+	setVersionLabel(desired)
+	err := r.Client.Create(ctx, desired)
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create deployment", "action", "create")
+		return err
+	}
+
+	log.V(1).Info("Deployment for website already exists", "action", "get")
+	// Retrieve the current deployment for this website
+	deployment := appsv1.Deployment{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &deployment)
+	if err != nil {
+		log.Error(err, "Failed to retrieve deployment", "action", "get")
+		return err
+	}
+
+	// This operator only cares about the fields it sets itself, it does not want
+	// to alter any other changes that may be acceptable. Therefore, this update
+	// will only patch those fields!
+	patch := client.StrategicMergeFrom(deployment.DeepCopy())
+	if !syncDeployment(&deployment, desired) {
+		return nil
+	}
+
+	log.Info("Deployment has changed", "action", "update", "image", desired.Spec.Template.Spec.Containers[0].Image)
+	// Try and apply this patch, if it fails, return the failure
+	if err := r.Client.Patch(ctx, &deployment, patch); err != nil {
+		log.Error(err, "Failed to update deployment", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// syncDeployment copies every field the operator owns from the desired deployment onto
+// the current one, and reports whether anything changed.
+func syncDeployment(current, desired *appsv1.Deployment) bool {
+	changed := syncLabels(&current.ObjectMeta, desired.Labels)
+	changed = syncAnnotation(&current.ObjectMeta, desired.Annotations, managedReplicasAnnotation) || changed
+	if _, ok := desired.Annotations[managedReplicasAnnotation]; ok {
+		changed = syncField(&current.Spec.Replicas, desired.Spec.Replicas) || changed
+	}
+	return syncPodTemplate(&current.Spec.Template, &desired.Spec.Template) || changed
+}
+
+// syncPodTemplate copies every field the operator owns from the desired pod template of a
+// workload onto the current one, and reports whether anything changed.
+func syncPodTemplate(current, desired *corev1.PodTemplateSpec) bool {
+	currentPod, desiredPod := &current.Spec, &desired.Spec
+
+	changed := syncLabels(&current.ObjectMeta, desired.Labels)
+	changed = syncAnnotation(&current.ObjectMeta, desired.Annotations, devv1.RedeployAnnotation) || changed
+	changed = syncAnnotation(&current.ObjectMeta, desired.Annotations, devv1.ContentChecksumAnnotation) || changed
+	changed = syncAnnotation(&current.ObjectMeta, desired.Annotations, nginxConfigChecksumAnnotation) || changed
+	changed = syncAnnotationPrefix(&current.ObjectMeta, desired.Annotations, vaultAnnotationPrefix) || changed
+	changed = syncAnnotation(&current.ObjectMeta, desired.Annotations, safeToEvictAnnotation) || changed
+	changed = syncAnnotation(&current.ObjectMeta, desired.Annotations, doNotDisruptAnnotation) || changed
+	changed = syncAnnotationPrefix(&current.ObjectMeta, desired.Annotations, appArmorAnnotationPrefix) || changed
+	changed = syncAnnotation(&current.ObjectMeta, desired.Annotations, managedLabelsKey) || changed
+	changed = syncAnnotation(&current.ObjectMeta, desired.Annotations, istioExcludeInboundPortsAnnotation) || changed
+	changed = syncAnnotation(&current.ObjectMeta, desired.Annotations, linkerdInjectAnnotation) || changed
+	changed = syncAnnotationPrefix(&current.ObjectMeta, desired.Annotations, linkerdConfigAnnotationPrefix) || changed
+	changed = syncSidecars(&currentPod.Containers, desiredPod.Containers) || changed
+	changed = syncField(&currentPod.Volumes, desiredPod.Volumes) || changed
+	changed = syncField(&currentPod.ImagePullSecrets, desiredPod.ImagePullSecrets) || changed
+	changed = syncField(&currentPod.Containers[0].VolumeMounts, desiredPod.Containers[0].VolumeMounts) || changed
+	changed = syncField(&currentPod.Containers[0].EnvFrom, desiredPod.Containers[0].EnvFrom) || changed
+	changed = syncField(&currentPod.Containers[0].Env, desiredPod.Containers[0].Env) || changed
+	changed = syncField(&currentPod.Containers[0].Image, desiredPod.Containers[0].Image) || changed
+	changed = syncField(&currentPod.Containers[0].ImagePullPolicy, desiredPod.Containers[0].ImagePullPolicy) || changed
+	changed = syncField(&currentPod.Containers[0].Command, desiredPod.Containers[0].Command) || changed
+	changed = syncField(&currentPod.Containers[0].Args, desiredPod.Containers[0].Args) || changed
+	changed = syncField(&currentPod.Containers[0].Resources, desiredPod.Containers[0].Resources) || changed
+	changed = syncField(&currentPod.SecurityContext, desiredPod.SecurityContext) || changed
+	changed = syncField(&currentPod.Containers[0].SecurityContext, desiredPod.Containers[0].SecurityContext) || changed
+	changed = syncField(&currentPod.Containers[0].Ports, desiredPod.Containers[0].Ports) || changed
+	changed = syncField(&currentPod.HostAliases, desiredPod.HostAliases) || changed
+	changed = syncField(&currentPod.HostNetwork, desiredPod.HostNetwork) || changed
+	changed = syncField(&currentPod.ShareProcessNamespace, desiredPod.ShareProcessNamespace) || changed
+	changed = syncField(&currentPod.RuntimeClassName, desiredPod.RuntimeClassName) || changed
+	changed = syncField(&currentPod.AutomountServiceAccountToken, desiredPod.AutomountServiceAccountToken) || changed
+	changed = syncField(&currentPod.DNSPolicy, desiredPod.DNSPolicy) || changed
+	changed = syncField(&currentPod.DNSConfig, desiredPod.DNSConfig) || changed
+	changed = syncField(&currentPod.TerminationGracePeriodSeconds, desiredPod.TerminationGracePeriodSeconds) || changed
+	changed = syncField(&currentPod.Containers[0].Lifecycle, desiredPod.Containers[0].Lifecycle) || changed
+	changed = syncField(&currentPod.ReadinessGates, desiredPod.ReadinessGates) || changed
+	changed = syncField(&currentPod.Affinity, desiredPod.Affinity) || changed
+	changed = syncField(&currentPod.NodeSelector, desiredPod.NodeSelector) || changed
+	return changed
+}
+
+func imageRepository(website *devv1.Website) string {
+	if website.Spec.ImageRepository != "" {
+		return website.Spec.ImageRepository
+	}
+	return defaultImageRepository
+}
+
+// deploymentName returns the name of the Deployment, or Rollout, running the website pods.
+func deploymentName(website *devv1.Website) string {
+	if website.Spec.DeploymentName != "" {
+		return website.Spec.DeploymentName
+	}
+	return resourceName(website)
+}
+
+// Create a deployment with the correct field values. By creating this in a function,
+// it can be reused by all lifecycle functions (create, update, delete).
+func newDeployment(website *devv1.Website) *appsv1.Deployment {
+	name, namespace, imageTag := website.Name, website.Namespace, website.Spec.ImageTag
+	replicas := devv1.DefaultReplicas
+	var annotations map[string]string
+	if website.Spec.Replicas != nil {
+		replicas = *website.Spec.Replicas
+		annotations = map[string]string{managedReplicasAnnotation: "true"}
+	}
+
+	image := fmt.Sprintf("%s:%s", imageRepository(website), imageTag)
+	if website.Spec.ImageDigest != "" {
+		image = fmt.Sprintf("%s@%s", imageRepository(website), website.Spec.ImageDigest)
+	}
+	// The pull policy is set explicitly as the API server would otherwise default it.
+	pullPolicy := website.Spec.ImagePullPolicy
+	if pullPolicy == "" {
+		pullPolicy = corev1.PullIfNotPresent
+		if website.Spec.ImageDigest == "" && (imageTag == "latest" || imageTag == "") {
+			pullPolicy = corev1.PullAlways
+		}
+	}
+	var resources corev1.ResourceRequirements
+	if website.Spec.Resources != nil {
+		resources = *website.Spec.Resources.DeepCopy()
+	}
+	// The API server defaults missing requests to their limits.
+	for resource, limit := range resources.Limits {
+		if _, ok := resources.Requests[resource]; !ok {
+			if resources.Requests == nil {
+				resources.Requests = corev1.ResourceList{}
+			}
+			resources.Requests[resource] = limit
+		}
+	}
+	securityContext := podSecurityContext(website)
+
+	podAnnotations := map[string]string{}
+	if redeploy := website.Annotations[devv1.RedeployAnnotation]; redeploy != "" {
+		podAnnotations[devv1.RedeployAnnotation] = redeploy
+	}
+	for key, value := range evictionAnnotations(website.Spec.Eviction) {
+		podAnnotations[key] = value
+	}
+
+	// Pods on the host network only resolve cluster names with ClusterFirstWithHostNet.
+	dnsPolicy := website.Spec.DNSPolicy
+	if dnsPolicy == "" && website.Spec.HostNetwork {
+		dnsPolicy = corev1.DNSClusterFirstWithHostNet
+	} else if dnsPolicy == "" {
+		dnsPolicy = corev1.DNSClusterFirst
+	}
+
+	terminationGracePeriod := website.Spec.TerminationGracePeriodSeconds
+	if terminationGracePeriod == nil {
+		defaultGracePeriod := int64(corev1.DefaultTerminationGracePeriodSeconds)
+		terminationGracePeriod = &defaultGracePeriod
+	}
+
+	// Sleeping before asking nginx to quit gives load balancers time to deregister the
+	// pod, after which nginx finishes in-flight requests before exiting.
+	var lifecycle *corev1.Lifecycle
+	if website.Spec.PreStopSleepSeconds != nil {
+		lifecycle = &corev1.Lifecycle{
+			PreStop: &corev1.LifecycleHandler{
+				Exec: &corev1.ExecAction{
+					Command: []string{"/bin/sh", "-c", fmt.Sprintf("sleep %d && nginx -s quit", *website.Spec.PreStopSleepSeconds)},
+				},
+			},
+		}
+	}
+
+	// Replicas prefer different nodes, so that losing a node does not take the website down.
+	// A DaemonSet runs a single pod per node anyway.
+	affinity := website.Spec.Affinity
+	if affinity == nil && replicas > 1 && !daemonSet(website) {
+		affinity = &corev1.Affinity{
+			PodAntiAffinity: &corev1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+					{
+						Weight: 100,
+						PodAffinityTerm: corev1.PodAffinityTerm{
+							LabelSelector: &metav1.LabelSelector{MatchLabels: setResourceLabels(name)},
+							TopologyKey:   corev1.LabelHostname,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	affinity = withPlatforms(affinity, website.Spec.Platforms)
+
+	var containerPorts []corev1.ContainerPort
+	for _, port := range websitePorts(website) {
+		containerPorts = append(containerPorts, corev1.ContainerPort{
+			Name:          port.Name,
+			ContainerPort: port.ContainerPort,
+			HostPort:      port.HostPort,
+			Protocol:      port.Protocol,
+		})
+	}
+
+	var volumes []corev1.Volume
+	var volumeMounts []corev1.VolumeMount
+	if volume, mount := contentVolume(website); volume != nil {
+		volumes = append(volumes, *volume)
+		volumeMounts = append(volumeMounts, *mount)
+	}
+	if volume, mount := buildVolume(website); volume != nil {
+		volumes = append(volumes, *volume)
+		volumeMounts = append(volumeMounts, *mount)
+	}
+	if volume, mounts := cacheVolume(website); volume != nil {
+		volumes = append(volumes, *volume)
+		volumeMounts = append(volumeMounts, mounts...)
+	}
+	if website.Spec.BackendTLS != nil {
+		tlsVolumes, tlsMounts := backendTLSVolumes(website)
+		volumes = append(volumes, tlsVolumes...)
+		volumeMounts = append(volumeMounts, tlsMounts...)
+	}
+	if volume, mounts := nginxConfigVolume(website); volume != nil {
+		volumes = append(volumes, *volume)
+		volumeMounts = append(volumeMounts, mounts...)
+	}
+	if volume, mount := errorPagesVolume(website); volume != nil {
+		volumes = append(volumes, *volume)
+		volumeMounts = append(volumeMounts, *mount)
+	}
+	if volume, mount := acmeChallengeVolume(website); volume != nil {
+		volumes = append(volumes, *volume)
+		volumeMounts = append(volumeMounts, *mount)
+	}
+	secretVolumes, secretMounts, envFrom := externalSecretInjection(website)
+	volumes = append(volumes, secretVolumes...)
+	volumeMounts = append(volumeMounts, secretMounts...)
+
+	var sidecars []corev1.Container
+	if website.Spec.Logging != nil {
+		sidecar, volume, mount := loggingSidecar(website)
+		sidecars = append(sidecars, sidecar)
+		volumes = append(volumes, volume)
+		volumeMounts = append(volumeMounts, mount)
+	}
+	if exporterEnabled(website) {
+		sidecar, volume, mount := exporterSidecar(website)
+		sidecars = append(sidecars, sidecar)
+		volumes = append(volumes, volume)
+		volumeMounts = append(volumeMounts, mount)
+	}
+	var shareProcessNamespace *bool
+	if website.Spec.BackendTLS != nil {
+		sidecars = append(sidecars, tlsReloaderSidecar())
+		shareProcessNamespace = pointer.Bool(true)
+	}
+	if vault := website.Spec.Vault; vault != nil {
+		if vault.Mode == devv1.VaultSidecar {
+			sidecar, volume, mount := vaultSidecar(vault)
+			sidecars = append(sidecars, sidecar)
+			volumes = append(volumes, volume)
+			volumeMounts = append(volumeMounts, mount)
+		} else {
+			for key, value := range vaultAnnotations(vault) {
+				podAnnotations[key] = value
+			}
+		}
+	}
+
+	if volume, mount := serviceAccountTokenVolume(website); volume != nil {
+		volumes = append(volumes, *volume)
+		volumeMounts = append(volumeMounts, *mount)
+	}
+	volumes = append(volumes, websiteVolumes(website)...)
+	addVolumeMounts(website, &volumeMounts, sidecars)
+	for _, sidecar := range website.Spec.SidecarSecurityContexts {
+		for i := range sidecars {
+			if sidecars[i].Name == sidecar.Container {
+				sidecars[i].SecurityContext = sidecar.SecurityContext.DeepCopy()
+			}
+		}
+	}
+
+	if profile := appArmorProfile(website); profile != "" {
+		for _, container := range append([]string{"nginx"}, containerNames(sidecars)...) {
+			podAnnotations[appArmorAnnotationPrefix+container] = profile
+		}
+	}
+
+	for key, value := range meshAnnotations(website) {
+		podAnnotations[key] = value
+	}
+	if sum := nginxConfigChecksum(website); sum != "" {
+		podAnnotations[nginxConfigChecksumAnnotation] = sum
+	}
+
+	// The API server exposes every container port of a pod on the host network as a host port.
+	if website.Spec.HostNetwork {
+		for i := range sidecars {
+			for j := range sidecars[i].Ports {
+				sidecars[i].Ports[j].HostPort = sidecars[i].Ports[j].ContainerPort
+			}
+		}
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        deploymentName(website),
+			Namespace:   namespace,
+			Labels:      withRecommendedLabels(setResourceLabels(name), website, componentServer),
+			Annotations: annotations,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: setResourceLabels(name)},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      withRecommendedLabels(setResourceLabels(name), website, componentServer),
+					Annotations: podAnnotations,
+				},
+				Spec: corev1.PodSpec{
+					Containers: append([]corev1.Container{
+						{
+							Name: "nginx",
+							// This is a publicly available container.  Note the use of
+							//`imageTag` as defined by the original resource request spec.
+							Image:           image,
+							ImagePullPolicy: pullPolicy,
+							Command:         website.Spec.Command,
+							Args:            website.Spec.Args,
+							Resources:       resources,
+							Ports:           containerPorts,
+							Lifecycle:       lifecycle,
+							VolumeMounts:    volumeMounts,
+							EnvFrom:         envFrom,
+							SecurityContext: containerSecurityContext(website),
+						},
+					}, sidecars...),
+					HostAliases:           website.Spec.HostAliases,
+					HostNetwork:           website.Spec.HostNetwork,
+					ShareProcessNamespace: shareProcessNamespace,
+					RuntimeClassName:      website.Spec.RuntimeClassName,
+					DNSPolicy:             dnsPolicy,
+					DNSConfig:             website.Spec.DNSConfig,
+
+					TerminationGracePeriodSeconds: terminationGracePeriod,
+					ReadinessGates:                website.Spec.ReadinessGates,
+					Affinity:                      affinity,
+					NodeSelector:                  website.Spec.NodeSelector,
+					AutomountServiceAccountToken:  automountServiceAccountToken(website),
+					SecurityContext:               securityContext,
+					Volumes:                       volumes,
+				},
+			},
+		},
+	}
+	addUserLabels(&deployment.Spec.Template.ObjectMeta, meshLabels(website))
+	return deployment
+}
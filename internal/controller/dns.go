@@ -0,0 +1,119 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// dnsVerifyInterval is how often the records of a website host are checked.
+	dnsVerifyInterval = 5 * time.Minute
+	// dnsLookupTimeout bounds the lookups of a single check.
+	dnsLookupTimeout = 10 * time.Second
+)
+
+// verifyDNS checks that the host of a website resolves to the load balancer of its
+// Ingress, and reports the outcome in the DNSMisconfigured condition. Nothing is reported
+// until the Ingress has been given an address. It returns when the records should be
+// checked again.
+func (r *WebsiteReconciler) verifyDNS(ctx context.Context, website *devv1.Website) (time.Duration, error) {
+	spec := website.Spec.Ingress
+	if spec == nil || !spec.VerifyDNS {
+		return 0, r.setCondition(ctx, website, devv1.ConditionDNSMisconfigured, nil)
+	}
+
+	ips, hostnames, err := r.loadBalancerAddresses(ctx, website)
+	if err != nil {
+		return 0, err
+	}
+	if len(ips) == 0 && len(hostnames) == 0 {
+		return dnsVerifyInterval, r.setCondition(ctx, website, devv1.ConditionDNSMisconfigured, nil)
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, dnsLookupTimeout)
+	defer cancel()
+	condition := dnsCondition(lookupCtx, net.DefaultResolver, spec.Host, ips, hostnames)
+	if condition.Status == metav1.ConditionTrue {
+		log.FromContext(ctx).Info("Website host does not resolve to its load balancer", "host", spec.Host, "records", condition.Message)
+	}
+	return dnsVerifyInterval, r.setCondition(ctx, website, devv1.ConditionDNSMisconfigured, condition)
+}
+
+// dnsCondition resolves a host and compares its records with the IPs and hostnames of a
+// load balancer. The host matches when it is an alias of one of the hostnames, or when
+// every address it resolves to is one of the load balancer.
+func dnsCondition(ctx context.Context, resolver *net.Resolver, host string, ips, hostnames []string) *metav1.Condition {
+	condition := &metav1.Condition{Type: devv1.ConditionDNSMisconfigured, Status: metav1.ConditionTrue}
+
+	if cname, err := resolver.LookupCNAME(ctx, host); err == nil {
+		for _, hostname := range hostnames {
+			if strings.EqualFold(strings.TrimSuffix(cname, "."), strings.TrimSuffix(hostname, ".")) {
+				condition.Status, condition.Reason = metav1.ConditionFalse, devv1.ReasonRecordsMatch
+				condition.Message = fmt.Sprintf("%s is an alias of %s", host, hostname)
+				return condition
+			}
+		}
+	}
+
+	observed, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		condition.Reason, condition.Message = devv1.ReasonLookupFailed, err.Error()
+		return condition
+	}
+	expected := map[string]bool{}
+	for _, ip := range ips {
+		expected[ip] = true
+	}
+	for _, hostname := range hostnames {
+		addresses, err := resolver.LookupHost(ctx, hostname)
+		if err != nil {
+			condition.Reason, condition.Message = devv1.ReasonLookupFailed, err.Error()
+			return condition
+		}
+		for _, address := range addresses {
+			expected[address] = true
+		}
+	}
+
+	sort.Strings(observed)
+	for _, address := range observed {
+		if !expected[address] {
+			wanted := make([]string, 0, len(expected))
+			for address := range expected {
+				wanted = append(wanted, address)
+			}
+			sort.Strings(wanted)
+			condition.Reason = devv1.ReasonRecordsMismatch
+			condition.Message = fmt.Sprintf("%s resolves to %s, expected %s", host, strings.Join(observed, ", "), strings.Join(wanted, ", "))
+			return condition
+		}
+	}
+	condition.Status, condition.Reason = metav1.ConditionFalse, devv1.ReasonRecordsMatch
+	condition.Message = fmt.Sprintf("%s resolves to %s", host, strings.Join(observed, ", "))
+	return condition
+}
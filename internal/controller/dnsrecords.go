@@ -0,0 +1,283 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// dnsRecordsFinalizer keeps a Website around until the DNS records the operator
+// registered for it with a provider API are removed.
+const dnsRecordsFinalizer = "dev.mvasilenko.me/dns-records"
+
+// dnsEndpointGVK identifies the DNSEndpoint of the external-dns CRD source, which is
+// handled as unstructured data.
+var dnsEndpointGVK = schema.GroupVersionKind{Group: "externaldns.k8s.io", Version: "v1alpha1", Kind: "DNSEndpoint"}
+
+// dnsHost returns the host the DNS records of a website are registered for.
+func dnsHost(website *devv1.Website) string {
+	if website.Spec.DNS.Host != "" {
+		return website.Spec.DNS.Host
+	}
+	if website.Spec.Ingress != nil {
+		return website.Spec.Ingress.Host
+	}
+	return ""
+}
+
+// dnsRecord is a DNS record set of a website, independent of the provider registering it.
+type dnsRecord struct {
+	recordType string
+	targets    []string
+}
+
+// dnsRecords returns the records pointing the host of a website at the given load
+// balancer addresses, filtered by the record type of the website when it sets one.
+func dnsRecords(website *devv1.Website, ips, hostnames []string) []dnsRecord {
+	var v4, v6 []string
+	for _, ip := range ips {
+		if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+			v6 = append(v6, ip)
+		} else {
+			v4 = append(v4, ip)
+		}
+	}
+
+	var records []dnsRecord
+	switch recordType := website.Spec.DNS.RecordType; {
+	case recordType == "CNAME", recordType == "" && len(hostnames) > 0:
+		// A CNAME may only point at a single name.
+		if len(hostnames) > 0 {
+			records = append(records, dnsRecord{recordType: "CNAME", targets: hostnames[:1]})
+		}
+	default:
+		if len(v4) > 0 && recordType != "AAAA" {
+			records = append(records, dnsRecord{recordType: "A", targets: v4})
+		}
+		if len(v6) > 0 && recordType != "A" {
+			records = append(records, dnsRecord{recordType: "AAAA", targets: v6})
+		}
+	}
+	return records
+}
+
+// reconcileDNSRecords registers the host of a website against the address of its load
+// balancer with the DNS provider of the website, and removes the records when DNS is
+// disabled or the load balancer has no address. Records registered through a provider
+// API under former settings are removed first.
+func (r *WebsiteReconciler) reconcileDNSRecords(ctx context.Context, website *devv1.Website) error {
+	name := types.NamespacedName{Name: resourceName(website), Namespace: website.Namespace}
+	if err := r.removeFormerDNSRecords(ctx, website); err != nil {
+		return err
+	}
+	if website.Spec.DNS == nil {
+		if err := r.removeDNSFinalizer(ctx, website); err != nil {
+			return err
+		}
+		return r.deleteUnstructured(ctx, dnsEndpointGVK, name)
+	}
+
+	ips, hostnames, err := r.loadBalancerAddresses(ctx, website)
+	if err != nil {
+		return err
+	}
+	records := dnsRecords(website, ips, hostnames)
+	switch website.Spec.DNS.Provider {
+	case devv1.DNSProviderCloudflare, devv1.DNSProviderRoute53:
+		if err := r.deleteUnstructured(ctx, dnsEndpointGVK, name); err != nil {
+			return err
+		}
+		// The website keeps a finalizer while it may have records.
+		if err := r.addDNSFinalizer(ctx, website); err != nil {
+			return err
+		}
+		if err := r.syncProviderRecords(ctx, website, records); err != nil {
+			return err
+		}
+		return r.setDNSRecordsStatus(ctx, website, dnsRecordsStatus(website))
+	}
+	if err := r.removeDNSFinalizer(ctx, website); err != nil {
+		return err
+	}
+	if len(records) == 0 || dnsHost(website) == "" {
+		return r.deleteUnstructured(ctx, dnsEndpointGVK, name)
+	}
+
+	desired, err := newDNSEndpoint(website, records)
+	if err != nil {
+		return err
+	}
+	if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+		return err
+	}
+	return r.reconcileUnstructured(ctx, desired)
+}
+
+// Create a DNSEndpoint holding the records of a website for external-dns.
+func newDNSEndpoint(website *devv1.Website, records []dnsRecord) (*unstructured.Unstructured, error) {
+	endpoints := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		targets := make([]interface{}, 0, len(record.targets))
+		for _, target := range record.targets {
+			targets = append(targets, target)
+		}
+		endpoint := map[string]interface{}{
+			"dnsName":    dnsHost(website),
+			"recordType": record.recordType,
+			"targets":    targets,
+		}
+		if website.Spec.DNS.TTL != 0 {
+			endpoint["recordTTL"] = website.Spec.DNS.TTL
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return newUnstructured(dnsEndpointGVK, types.NamespacedName{Name: resourceName(website), Namespace: website.Namespace},
+		withRecommendedLabels(setResourceLabels(website.Name), website, componentServer),
+		map[string]interface{}{"endpoints": endpoints})
+}
+
+// syncProviderRecords makes the records of the host of a website match the given records
+// with the provider API of the website.
+func (r *WebsiteReconciler) syncProviderRecords(ctx context.Context, website *devv1.Website, records []dnsRecord) error {
+	if dnsHost(website) == "" {
+		return nil
+	}
+	switch dns := website.Spec.DNS; {
+	case dns.Provider == devv1.DNSProviderCloudflare && dns.Cloudflare != nil:
+		return r.syncCloudflareRecords(ctx, website, records)
+	case dns.Provider == devv1.DNSProviderRoute53 && dns.Route53 != nil:
+		return r.syncRoute53Records(ctx, website, records)
+	}
+	return nil
+}
+
+// dnsRecordsStatus returns the provider settings the records of a website are registered
+// with, or nil when they are not registered through a provider API. Only the settings
+// locating the records are kept.
+func dnsRecordsStatus(website *devv1.Website) *devv1.DNSRecordsStatus {
+	dns := website.Spec.DNS
+	if dns == nil || dnsHost(website) == "" {
+		return nil
+	}
+	status := &devv1.DNSRecordsStatus{Provider: dns.Provider, Host: dnsHost(website)}
+	switch {
+	case dns.Provider == devv1.DNSProviderCloudflare && dns.Cloudflare != nil:
+		status.Cloudflare = &devv1.CloudflareSpec{APITokenSecretName: dns.Cloudflare.APITokenSecretName, ZoneID: dns.Cloudflare.ZoneID}
+	case dns.Provider == devv1.DNSProviderRoute53 && dns.Route53 != nil:
+		status.Route53 = &devv1.Route53Spec{HostedZoneID: dns.Route53.HostedZoneID, CredentialsSecretName: dns.Route53.CredentialsSecretName}
+	default:
+		return nil
+	}
+	return status
+}
+
+// removeFormerDNSRecords removes the records a website registered through a provider API
+// under settings it no longer has, such as another host or provider, or none at all.
+func (r *WebsiteReconciler) removeFormerDNSRecords(ctx context.Context, website *devv1.Website) error {
+	former := website.Status.DNSRecords
+	if former == nil || equality.Semantic.DeepEqual(former, dnsRecordsStatus(website)) {
+		return nil
+	}
+	log.FromContext(ctx).Info("Removing DNS records of former settings", "action", "delete", "host", former.Host, "provider", former.Provider)
+	if err := r.syncProviderRecords(ctx, formerDNSWebsite(website, former), nil); err != nil {
+		return err
+	}
+	return r.setDNSRecordsStatus(ctx, website, nil)
+}
+
+// formerDNSWebsite returns a copy of a website with the DNS settings its records were
+// registered with.
+func formerDNSWebsite(website *devv1.Website, former *devv1.DNSRecordsStatus) *devv1.Website {
+	website = website.DeepCopy()
+	website.Spec.DNS = &devv1.DNSSpec{
+		Provider:   former.Provider,
+		Host:       former.Host,
+		Cloudflare: former.Cloudflare,
+		Route53:    former.Route53,
+	}
+	return website
+}
+
+func (r *WebsiteReconciler) setDNSRecordsStatus(ctx context.Context, website *devv1.Website, status *devv1.DNSRecordsStatus) error {
+	if equality.Semantic.DeepEqual(website.Status.DNSRecords, status) {
+		return nil
+	}
+	patch := client.MergeFrom(website.DeepCopy())
+	website.Status.DNSRecords = status
+	if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update website status", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// finalizeDNSRecords removes the records of a deleted website from its DNS provider and
+// then lets the website go. The records are looked up where they were registered, or
+// with the settings of the website when that was not recorded.
+func (r *WebsiteReconciler) finalizeDNSRecords(ctx context.Context, website *devv1.Website) error {
+	registered := website
+	if former := website.Status.DNSRecords; former != nil {
+		registered = formerDNSWebsite(website, former)
+	}
+	if registered.Spec.DNS != nil {
+		log.FromContext(ctx).Info("Removing DNS records of deleted website", "action", "delete", "host", dnsHost(registered))
+		if err := r.syncProviderRecords(ctx, registered, nil); err != nil {
+			return err
+		}
+	}
+	return r.removeDNSFinalizer(ctx, website)
+}
+
+func (r *WebsiteReconciler) addDNSFinalizer(ctx context.Context, website *devv1.Website) error {
+	if controllerutil.ContainsFinalizer(website, dnsRecordsFinalizer) {
+		return nil
+	}
+	return r.patchDNSFinalizer(ctx, website, controllerutil.AddFinalizer)
+}
+
+func (r *WebsiteReconciler) removeDNSFinalizer(ctx context.Context, website *devv1.Website) error {
+	if !controllerutil.ContainsFinalizer(website, dnsRecordsFinalizer) {
+		return nil
+	}
+	return r.patchDNSFinalizer(ctx, website, controllerutil.RemoveFinalizer)
+}
+
+// patchDNSFinalizer patches the finalizers of a website alone, leaving the spec of the
+// given website, which may carry the defaults of its class, untouched.
+func (r *WebsiteReconciler) patchDNSFinalizer(ctx context.Context, website *devv1.Website, change func(client.Object, string) bool) error {
+	latest := website.DeepCopy()
+	patch := client.MergeFrom(latest.DeepCopy())
+	change(latest, dnsRecordsFinalizer)
+	if err := r.Client.Patch(ctx, latest, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update website finalizers", "action", "update")
+		return err
+	}
+	website.Finalizers, website.ResourceVersion = latest.Finalizers, latest.ResourceVersion
+	return nil
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+func TestDNSRecordsStatus(t *testing.T) {
+	website := &devv1.Website{Spec: devv1.WebsiteSpec{
+		Ingress: &devv1.IngressSpec{Host: "www.example.com"},
+		DNS: &devv1.DNSSpec{
+			Provider:   devv1.DNSProviderCloudflare,
+			Cloudflare: &devv1.CloudflareSpec{APITokenSecretName: "cloudflare", ZoneID: "zone1"},
+		},
+	}}
+	registered := dnsRecordsStatus(website)
+	if registered == nil || registered.Host != "www.example.com" {
+		t.Fatalf("dnsRecordsStatus() = %+v, want the records of www.example.com", registered)
+	}
+
+	// Settings that do not move the records keep them where they are.
+	website.Spec.DNS.TTL, website.Spec.DNS.Cloudflare.Proxied = 60, true
+	if status := dnsRecordsStatus(website); !equality.Semantic.DeepEqual(status, registered) {
+		t.Errorf("dnsRecordsStatus() = %+v after changing the TTL, want %+v", status, registered)
+	}
+
+	website.Spec.DNS.Host = "shop.example.com"
+	if status := dnsRecordsStatus(website); equality.Semantic.DeepEqual(status, registered) {
+		t.Error("expected another host to move the records")
+	}
+	website.Spec.DNS = &devv1.DNSSpec{Provider: devv1.DNSProviderExternalDNS}
+	if status := dnsRecordsStatus(website); status != nil {
+		t.Errorf("dnsRecordsStatus() = %+v for external-dns, want nil", status)
+	}
+
+	former := formerDNSWebsite(website, registered)
+	if dnsHost(former) != "www.example.com" || former.Spec.DNS.Cloudflare.ZoneID != "zone1" {
+		t.Errorf("formerDNSWebsite() = %+v, want the registered settings", former.Spec.DNS)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// NewDryRunClient wraps a client so that every write is sent to the API server as a
+// server-side dry run and logged instead of being persisted. Reads are untouched, so
+// the reconciler still computes its desired state against the real cluster.
+func NewDryRunClient(c client.Client) client.Client {
+	return &dryRunClient{Client: client.NewDryRunClient(c)}
+}
+
+type dryRunClient struct {
+	client.Client
+}
+
+// dryRun reports whether the writes of a reconciler are dry runs, so that the writes it
+// makes outside of Kubernetes must be skipped as well.
+func (r *WebsiteReconciler) dryRun() bool {
+	_, ok := r.Client.(*dryRunClient)
+	return ok
+}
+
+func (c *dryRunClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.record(ctx, "create", obj)
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *dryRunClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.record(ctx, "update", obj)
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *dryRunClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	data, _ := patch.Data(obj)
+	c.record(ctx, "update", obj, "patch", string(data))
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *dryRunClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.record(ctx, "delete", obj)
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *dryRunClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	c.record(ctx, "delete", obj)
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *dryRunClient) record(ctx context.Context, action string, obj client.Object, keysAndValues ...interface{}) {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+	keysAndValues = append([]interface{}{"action", action, "kind", kind, "name", obj.GetName()}, keysAndValues...)
+	log.FromContext(ctx).Info("Dry run: skipping write", keysAndValues...)
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// environmentOfLabel is set on the Websites of the environments of a Website to its name
+	environmentOfLabel = "dev.mvasilenko.me/environment-of"
+	// environmentLabel is set on the Websites of the environments of a Website to the name
+	// of their environment
+	environmentLabel = "dev.mvasilenko.me/environment"
+)
+
+func environmentWebsiteName(website *devv1.Website, environment string) string {
+	return fmt.Sprintf("%s-%s", website.Name, environment)
+}
+
+// reconcileEnvironments keeps a Website per environment of a website, copying its spec
+// with the overrides of the environment, and deletes the ones of dropped environments.
+// The website itself runs nothing, so what it ran before it had environments is removed.
+func (r *WebsiteReconciler) reconcileEnvironments(ctx context.Context, website *devv1.Website) error {
+	if err := r.promote(ctx, website); err != nil {
+		return err
+	}
+	if err := r.deleteWorkload(ctx, website); err != nil {
+		return err
+	}
+
+	var statuses []devv1.EnvironmentStatus
+	wanted := map[string]bool{}
+	for _, environment := range website.Spec.Environments {
+		desired := newEnvironmentWebsite(website, environment)
+		wanted[desired.Name] = true
+		if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+			return err
+		}
+		current, err := r.applyEnvironmentWebsite(ctx, website, desired)
+		if err != nil {
+			return err
+		}
+		statuses = append(statuses, devv1.EnvironmentStatus{
+			Name:        environment.Name,
+			WebsiteName: desired.Name,
+			ImageTag:    desired.Spec.ImageTag,
+			Phase:       current.Status.Phase,
+			URL:         current.Status.URL,
+		})
+	}
+
+	websites := devv1.WebsiteList{}
+	if err := r.Client.List(ctx, &websites, client.InNamespace(website.Namespace), client.MatchingLabels{environmentOfLabel: website.Name}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list environment websites", "action", "get")
+		return err
+	}
+	for i, existing := range websites.Items {
+		if wanted[existing.Name] || !metav1.IsControlledBy(&existing, website) {
+			continue
+		}
+		log.FromContext(ctx).Info("Environment is no longer wanted", "action", "delete", "environment", existing.Labels[environmentLabel])
+		if err := r.Client.Delete(ctx, &websites.Items[i]); err != nil && !errors.IsNotFound(err) {
+			log.FromContext(ctx).Error(err, "Failed to delete environment website", "action", "delete")
+			return err
+		}
+	}
+
+	if equality.Semantic.DeepEqual(website.Status.Environments, statuses) {
+		return nil
+	}
+	patch := client.MergeFrom(website.DeepCopy())
+	website.Status.Environments = statuses
+	if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update website status", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// newEnvironmentWebsite returns the Website of an environment of a website. Like
+// previews, environments run under names of their own and in the cluster of the website
+// only.
+func newEnvironmentWebsite(website *devv1.Website, environment devv1.EnvironmentSpec) *devv1.Website {
+	spec := website.Spec.DeepCopy()
+	spec.Environments = nil
+	spec.Clusters = nil
+	spec.DeploymentName, spec.ServiceName = "", ""
+	if environment.ImageTag != "" {
+		spec.ImageTag = environment.ImageTag
+		spec.ImageDigest, spec.ImageTagConstraint = "", ""
+	}
+	if environment.Replicas != nil {
+		spec.Replicas = environment.Replicas
+	}
+	if spec.Ingress != nil {
+		spec.Ingress.Host = environmentHost(environment, spec.Ingress.Host)
+		if spec.Ingress.TLS != nil {
+			spec.Ingress.TLS.SecretName = ""
+		}
+	}
+	if spec.DNS != nil && spec.DNS.Host != "" {
+		spec.DNS.Host = environmentHost(environment, spec.DNS.Host)
+	}
+	// A node port can only be pinned by one Service of the cluster.
+	if spec.Service != nil {
+		spec.Service.NodePort, spec.Service.HealthCheckNodePort = 0, 0
+	}
+
+	return &devv1.Website{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      environmentWebsiteName(website, environment.Name),
+			Namespace: website.Namespace,
+			Labels:    map[string]string{environmentOfLabel: website.Name, environmentLabel: environment.Name},
+		},
+		Spec: *spec,
+	}
+}
+
+func environmentHost(environment devv1.EnvironmentSpec, host string) string {
+	if environment.Host != "" {
+		return environment.Host
+	}
+	return fmt.Sprintf("%s.%s", environment.Name, host)
+}
+
+// applyEnvironmentWebsite creates the Website of an environment, or brings its spec and
+// labels back in line with the desired one. It returns the current Website.
+func (r *WebsiteReconciler) applyEnvironmentWebsite(ctx context.Context, website, desired *devv1.Website) (*devv1.Website, error) {
+	log := log.FromContext(ctx).WithValues("environment", desired.Labels[environmentLabel])
+
+	err := r.Client.Create(ctx, desired)
+	if err == nil {
+		log.Info("Creating environment website", "action", "create")
+		return desired, nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create environment website", "action", "create")
+		return nil, err
+	}
+
+	current := &devv1.Website{}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(desired), current); err != nil {
+		log.Error(err, "Failed to retrieve environment website", "action", "get")
+		return nil, err
+	}
+	if !metav1.IsControlledBy(current, website) {
+		return nil, fmt.Errorf("website %q exists and is not managed by website %q", current.Name, website.Name)
+	}
+	patch := client.MergeFrom(current.DeepCopy())
+	changed := syncLabels(&current.ObjectMeta, desired.Labels)
+	changed = syncField(&current.Spec, desired.Spec) || changed
+	if !changed {
+		return current, nil
+	}
+	log.Info("Environment website has changed", "action", "update")
+	if err := r.Client.Patch(ctx, current, patch); err != nil {
+		log.Error(err, "Failed to update environment website", "action", "update")
+		return nil, err
+	}
+	return current, nil
+}
+
+// promote copies the image tag of an environment of a website to another, as asked by
+// the promote annotation, and removes the annotation.
+func (r *WebsiteReconciler) promote(ctx context.Context, website *devv1.Website) error {
+	value, ok := website.Annotations[devv1.PromoteAnnotation]
+	if !ok {
+		return nil
+	}
+
+	patch := client.MergeFrom(website.DeepCopy())
+	delete(website.Annotations, devv1.PromoteAnnotation)
+	from, to, _ := strings.Cut(value, "=")
+	source, target := -1, -1
+	for i, environment := range website.Spec.Environments {
+		switch environment.Name {
+		case from:
+			source = i
+		case to:
+			target = i
+		}
+	}
+	if source < 0 || target < 0 {
+		if r.Recorder != nil {
+			r.Recorder.Eventf(website, corev1.EventTypeWarning, "PromotionFailed",
+				"%s must be <from>=<to> naming two environments, got %q", devv1.PromoteAnnotation, value)
+		}
+	} else {
+		tag := website.Spec.Environments[source].ImageTag
+		if tag == "" {
+			tag = website.Spec.ImageTag
+		}
+		website.Spec.Environments[target].ImageTag = tag
+		log.FromContext(ctx).Info("Promoting image tag", "from", from, "to", to, "imageTag", tag)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(website, corev1.EventTypeNormal, "Promoted", "Promoted %s from %s to %s", tag, from, to)
+		}
+	}
+	if err := r.Client.Patch(ctx, website, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to promote website", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// deleteWorkload deletes the workload, Services and Ingress of a website.
+func (r *WebsiteReconciler) deleteWorkload(ctx context.Context, website *devv1.Website) error {
+	meta := metav1.ObjectMeta{Name: deploymentName(website), Namespace: website.Namespace}
+	for _, obj := range []client.Object{&appsv1.Deployment{ObjectMeta: meta}, &appsv1.DaemonSet{ObjectMeta: meta}} {
+		if err := r.Client.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			log.FromContext(ctx).Error(err, "Failed to delete workload", "action", "delete")
+			return err
+		}
+	}
+	for _, service := range []*corev1.Service{newService(website), newHeadlessService(website), newMetricsService(website)} {
+		if err := r.reconcileService(ctx, service, false); err != nil {
+			return err
+		}
+	}
+	err := r.Client.Delete(ctx, &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: resourceName(website), Namespace: website.Namespace}})
+	if err != nil && !errors.IsNotFound(err) {
+		log.FromContext(ctx).Error(err, "Failed to delete ingress", "action", "delete")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+func TestNewEnvironmentWebsiteNodePort(t *testing.T) {
+	website := &devv1.Website{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: devv1.WebsiteSpec{
+			ImageTag: "v1",
+			Service: &devv1.ServiceSpec{
+				Type:                corev1.ServiceTypeLoadBalancer,
+				NodePort:            30080,
+				HealthCheckNodePort: 30081,
+			},
+			Environments: []devv1.EnvironmentSpec{{Name: "staging"}},
+		},
+	}
+
+	environment := newEnvironmentWebsite(website, website.Spec.Environments[0])
+	if service := environment.Spec.Service; service.NodePort != 0 || service.HealthCheckNodePort != 0 {
+		t.Errorf("environment node ports = %d/%d, want unpinned", service.NodePort, service.HealthCheckNodePort)
+	}
+	if website.Spec.Service.NodePort != 30080 {
+		t.Errorf("website node port = %d, want 30080", website.Spec.Service.NodePort)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	errorPagesVolumeName = "error-pages"
+	errorPagesMountPath  = "/usr/share/nginx/errors"
+	// errorPagesLocation is the internal location the error pages are served under
+	errorPagesLocation = "/_errors/"
+)
+
+// defaultErrorPages are the error pages of the nginx image.
+var defaultErrorPages = []devv1.ErrorPage{
+	{Codes: []devv1.ErrorPageCode{404}, Key: "404.html"},
+	{Codes: []devv1.ErrorPageCode{500, 502, 503, 504}, Key: "50x.html"},
+}
+
+// errorPagesVolume returns the volume holding the error pages of a website, and its mount
+// into the nginx container.
+func errorPagesVolume(website *devv1.Website) (*corev1.Volume, *corev1.VolumeMount) {
+	if website.Spec.ErrorPages == nil {
+		return nil, nil
+	}
+	volume := &corev1.Volume{
+		Name: errorPagesVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: website.Spec.ErrorPages.ConfigMapName},
+				DefaultMode:          pointer.Int32(corev1.ConfigMapVolumeSourceDefaultMode),
+			},
+		},
+	}
+	return volume, &corev1.VolumeMount{Name: errorPagesVolumeName, MountPath: errorPagesMountPath, ReadOnly: true}
+}
+
+// errorPageDirectives returns the nginx server directives answering errors with the error
+// pages of a website, or nothing when it has none.
+func errorPageDirectives(website *devv1.Website) string {
+	spec := website.Spec.ErrorPages
+	if spec == nil {
+		return ""
+	}
+	pages := spec.Pages
+	if len(pages) == 0 {
+		pages = defaultErrorPages
+	}
+
+	var directives strings.Builder
+	for _, page := range pages {
+		directives.WriteString("    error_page")
+		for _, code := range page.Codes {
+			fmt.Fprintf(&directives, " %d", code)
+		}
+		fmt.Fprintf(&directives, " %s%s;\n", errorPagesLocation, page.Key)
+	}
+	fmt.Fprintf(&directives, `    location ^~ %s {
+        internal;
+        alias %s/;
+    }
+`, errorPagesLocation, errorPagesMountPath)
+	return directives.String()
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strconv"
+
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// safeToEvictAnnotation tells the cluster autoscaler whether a pod may be evicted
+	safeToEvictAnnotation = "cluster-autoscaler.kubernetes.io/safe-to-evict"
+	// doNotDisruptAnnotation stops Karpenter from voluntarily disrupting a pod
+	doNotDisruptAnnotation = "karpenter.sh/do-not-disrupt"
+)
+
+// evictionAnnotations returns the pod annotations controlling autoscaler evictions.
+func evictionAnnotations(eviction *devv1.EvictionSpec) map[string]string {
+	annotations := map[string]string{}
+	if eviction == nil {
+		return annotations
+	}
+	if eviction.SafeToEvict != nil {
+		annotations[safeToEvictAnnotation] = strconv.FormatBool(*eviction.SafeToEvict)
+	}
+	if eviction.Disallow {
+		annotations[safeToEvictAnnotation] = "false"
+		annotations[doNotDisruptAnnotation] = "true"
+	}
+	return annotations
+}
+
+// reconcileDisruptionBudget makes sure a PodDisruptionBudget blocks evictions while the
+// website disallows them, and removes it otherwise.
+func (r *WebsiteReconciler) reconcileDisruptionBudget(ctx context.Context, website *devv1.Website) error {
+	log := log.FromContext(ctx)
+
+	if website.Spec.Eviction == nil || !website.Spec.Eviction.Disallow {
+		err := r.Client.Delete(ctx, &policyv1.PodDisruptionBudget{ObjectMeta: metav1.ObjectMeta{Name: resourceName(website), Namespace: website.Namespace}})
+		if err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete pod disruption budget", "action", "delete")
+			return err
+		}
+		return nil
+	}
+
+	desired := newDisruptionBudget(website)
+	if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+		return err
+	}
+
+	err := r.Client.Create(ctx, desired)
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create pod disruption budget", "action", "create")
+		return err
+	}
+
+	current := policyv1.PodDisruptionBudget{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: resourceName(website), Namespace: website.Namespace}, &current); err != nil {
+		log.Error(err, "Failed to retrieve pod disruption budget", "action", "get")
+		return err
+	}
+	patch := client.MergeFrom(current.DeepCopy())
+	changed := syncLabels(&current.ObjectMeta, desired.Labels)
+	changed = syncField(&current.Spec, desired.Spec) || changed
+	if !changed {
+		return nil
+	}
+
+	log.Info("Pod disruption budget has changed", "action", "update")
+	if err := r.Client.Patch(ctx, &current, patch); err != nil {
+		log.Error(err, "Failed to update pod disruption budget", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// Create a PodDisruptionBudget that allows none of the website pods to be evicted.
+func newDisruptionBudget(website *devv1.Website) *policyv1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(0)
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      resourceName(website),
+			Namespace: website.Namespace,
+			Labels:    withRecommendedLabels(setResourceLabels(website.Name), website, componentServer),
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector:       &metav1.LabelSelector{MatchLabels: setResourceLabels(website.Name)},
+		},
+	}
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	exporterImage      = "nginx/nginx-prometheus-exporter:0.11.0"
+	metricsPortName    = "metrics"
+	metricsPort        = 9113
+	stubStatusPort     = 8081
+	stubStatusVolume   = "stub-status"
+	stubStatusFileName = "stub-status.conf"
+)
+
+// serviceMonitorGVK identifies the prometheus-operator ServiceMonitor, which is handled as
+// unstructured data so that the operator does not depend on the prometheus-operator API module.
+var serviceMonitorGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"}
+
+// exporterEnabled reports whether the nginx Prometheus exporter runs next to the website.
+func exporterEnabled(website *devv1.Website) bool {
+	return website.Spec.Monitoring != nil && website.Spec.Monitoring.Exporter
+}
+
+func stubStatusName(name string) string {
+	return fmt.Sprintf("%s-stub-status", name)
+}
+
+// reconcileExporter makes sure the nginx configuration exposing the stub status page the
+// exporter reads, and the ServiceMonitor scraping the exporter, exist while they are
+// wanted, and removes them otherwise.
+func (r *WebsiteReconciler) reconcileExporter(ctx context.Context, website *devv1.Website) error {
+	log := log.FromContext(ctx)
+	name := types.NamespacedName{Name: stubStatusName(resourceName(website)), Namespace: website.Namespace}
+
+	if !exporterEnabled(website) || !website.Spec.Monitoring.ServiceMonitor {
+		if err := r.deleteUnstructured(ctx, serviceMonitorGVK, types.NamespacedName{Name: resourceName(website), Namespace: website.Namespace}); err != nil {
+			return err
+		}
+	} else {
+		desired, err := newServiceMonitor(website)
+		if err != nil {
+			return err
+		}
+		if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.reconcileUnstructured(ctx, desired); err != nil {
+			return err
+		}
+	}
+
+	if !exporterEnabled(website) {
+		err := r.Client.Delete(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace}})
+		if err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete stub status configuration", "action", "delete")
+			return err
+		}
+		return nil
+	}
+
+	desired := newStubStatusConfigMap(website)
+	if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+		return err
+	}
+	err := r.Client.Create(ctx, desired)
+	if err == nil || !errors.IsAlreadyExists(err) {
+		if err != nil {
+			log.Error(err, "Failed to create stub status configuration", "action", "create")
+		}
+		return err
+	}
+
+	current := corev1.ConfigMap{}
+	if err := r.Client.Get(ctx, name, &current); err != nil {
+		log.Error(err, "Failed to retrieve stub status configuration", "action", "get")
+		return err
+	}
+	patch := client.MergeFrom(current.DeepCopy())
+	changed := syncLabels(&current.ObjectMeta, desired.Labels)
+	changed = syncField(&current.Data, desired.Data) || changed
+	if !changed {
+		return nil
+	}
+	log.Info("Stub status configuration has changed", "action", "update")
+	if err := r.Client.Patch(ctx, &current, patch); err != nil {
+		log.Error(err, "Failed to update stub status configuration", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// exporterSidecar returns the exporter container, the volume holding the stub status
+// configuration, and its mount into the nginx container.
+func exporterSidecar(website *devv1.Website) (corev1.Container, corev1.Volume, corev1.VolumeMount) {
+	container := corev1.Container{
+		Name:  "nginx-exporter",
+		Image: exporterImage,
+		Args:  []string{fmt.Sprintf("-nginx.scrape-uri=http://127.0.0.1:%d/stub_status", stubStatusPort)},
+		Ports: []corev1.ContainerPort{{Name: metricsPortName, ContainerPort: metricsPort, Protocol: corev1.ProtocolTCP}},
+
+		// The values below are what the API server defaults them to.
+		ImagePullPolicy:          corev1.PullIfNotPresent,
+		TerminationMessagePath:   corev1.TerminationMessagePathDefault,
+		TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+	}
+	volume := corev1.Volume{
+		Name: stubStatusVolume,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: stubStatusName(resourceName(website))},
+				DefaultMode:          pointer.Int32(corev1.ConfigMapVolumeSourceDefaultMode),
+			},
+		},
+	}
+	mount := corev1.VolumeMount{
+		Name:      stubStatusVolume,
+		MountPath: "/etc/nginx/conf.d/" + stubStatusFileName,
+		SubPath:   stubStatusFileName,
+		ReadOnly:  true,
+	}
+	return container, volume, mount
+}
+
+// Create the ConfigMap holding the nginx server exposing the stub status page to the
+// exporter. It only listens on the loopback interface of the pod.
+func newStubStatusConfigMap(website *devv1.Website) *corev1.ConfigMap {
+	labels := withRecommendedLabels(setResourceLabels(website.Name), website, componentMonitoring)
+	labels[watchLabel] = "true"
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      stubStatusName(resourceName(website)),
+			Namespace: website.Namespace,
+			Labels:    labels,
+		},
+		Data: map[string]string{
+			stubStatusFileName: fmt.Sprintf(`server {
+    listen 127.0.0.1:%d;
+    location = /stub_status {
+        stub_status;
+    }
+}
+`, stubStatusPort),
+		},
+	}
+}
+
+// metricsServicePort returns the Service port exposing the exporter.
+func metricsServiceName(name string) string {
+	return fmt.Sprintf("%s-metrics", name)
+}
+
+// Create a ClusterIP Service exposing only the exporter of the website pods, so that
+// scraping can be allowed separately from the traffic to the website.
+func newMetricsService(website *devv1.Website) *corev1.Service {
+	internalTrafficPolicy := corev1.ServiceInternalTrafficPolicyCluster
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      metricsServiceName(resourceName(website)),
+			Namespace: website.Namespace,
+			Labels:    withRecommendedLabels(setResourceLabels(website.Name), website, componentMonitoring),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name:       metricsPortName,
+				Protocol:   corev1.ProtocolTCP,
+				Port:       metricsPort,
+				TargetPort: intstr.FromString(metricsPortName),
+			}},
+			Selector: setResourceLabels(website.Name),
+			Type:     corev1.ServiceTypeClusterIP,
+
+			// The values below are what the API server defaults them to.
+			SessionAffinity:       corev1.ServiceAffinityNone,
+			InternalTrafficPolicy: &internalTrafficPolicy,
+		},
+	}
+}
+
+// Create a ServiceMonitor scraping the exporter through the metrics Service of the website.
+func newServiceMonitor(website *devv1.Website) (*unstructured.Unstructured, error) {
+	return newUnstructured(serviceMonitorGVK, types.NamespacedName{Name: resourceName(website), Namespace: website.Namespace},
+		withRecommendedLabels(setResourceLabels(website.Name), website, componentMonitoring), map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{
+					websiteLabel:   website.Name,
+					typeLabel:      "Website",
+					componentLabel: componentMonitoring,
+				},
+			},
+			"endpoints": []interface{}{
+				map[string]interface{}{"port": metricsPortName},
+			},
+		})
+}
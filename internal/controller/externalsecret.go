@@ -0,0 +1,164 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// externalSecretGVK identifies the External Secrets Operator ExternalSecret. It is handled
+// as unstructured data so that the operator does not depend on the External Secrets
+// Operator API module, nor require its CRDs unless Websites use them.
+var externalSecretGVK = schema.GroupVersionKind{Group: "external-secrets.io", Version: "v1beta1", Kind: "ExternalSecret"}
+
+const defaultExternalSecretRefreshInterval = "1h"
+
+func externalSecretName(website, name string) string {
+	return fmt.Sprintf("%s-%s", website, name)
+}
+
+// reconcileExternalSecrets creates or updates the ExternalSecrets of a website, and
+// deletes the ones its spec no longer lists.
+func (r *WebsiteReconciler) reconcileExternalSecrets(ctx context.Context, website *devv1.Website) error {
+	log := log.FromContext(ctx)
+
+	wanted := map[string]bool{}
+	for _, spec := range website.Spec.ExternalSecrets {
+		desired, err := newExternalSecret(website, spec)
+		if err != nil {
+			return err
+		}
+		if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+			return err
+		}
+		wanted[desired.GetName()] = true
+		if err := r.reconcileUnstructured(ctx, desired); err != nil {
+			return err
+		}
+	}
+
+	// ExternalSecrets are read from the API server, so that the manager does not start an
+	// informer for a CRD that may not be installed.
+	existing := unstructured.UnstructuredList{}
+	existing.SetGroupVersionKind(externalSecretGVK.GroupVersion().WithKind(externalSecretGVK.Kind + "List"))
+	err := r.APIReader.List(ctx, &existing, client.InNamespace(website.Namespace), client.MatchingLabels(setResourceLabels(website.Name)))
+	if meta.IsNoMatchError(err) && len(wanted) == 0 {
+		return nil
+	}
+	if err != nil {
+		log.Error(err, "Failed to list external secrets", "action", "get")
+		return err
+	}
+	for i := range existing.Items {
+		externalSecret := &existing.Items[i]
+		if wanted[externalSecret.GetName()] {
+			continue
+		}
+		log.Info("External secret is no longer wanted", "action", "delete", "externalSecret", externalSecret.GetName())
+		if err := r.Client.Delete(ctx, externalSecret); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete external secret", "action", "delete", "externalSecret", externalSecret.GetName())
+			return err
+		}
+	}
+	return nil
+}
+
+// Create an ExternalSecret whose target Secret is owned by it, and so garbage collected
+// along with it.
+func newExternalSecret(website *devv1.Website, spec devv1.ExternalSecretSpec) (*unstructured.Unstructured, error) {
+	name := externalSecretName(resourceName(website), spec.Name)
+
+	storeKind := spec.SecretStoreRef.Kind
+	if storeKind == "" {
+		storeKind = "SecretStore"
+	}
+	refreshInterval := defaultExternalSecretRefreshInterval
+	if spec.RefreshInterval != nil {
+		refreshInterval = spec.RefreshInterval.Duration.String()
+	}
+
+	data := make([]interface{}, 0, len(spec.Data))
+	for _, entry := range spec.Data {
+		remoteRef := map[string]interface{}{"key": entry.RemoteKey}
+		if entry.Property != "" {
+			remoteRef["property"] = entry.Property
+		}
+		data = append(data, map[string]interface{}{
+			"secretKey": entry.SecretKey,
+			"remoteRef": remoteRef,
+		})
+	}
+
+	externalSecretSpec := map[string]interface{}{
+		"refreshInterval": refreshInterval,
+		"secretStoreRef": map[string]interface{}{
+			"name": spec.SecretStoreRef.Name,
+			"kind": storeKind,
+		},
+		"target": map[string]interface{}{
+			"name":           name,
+			"creationPolicy": "Owner",
+		},
+		"data": data,
+	}
+	return newUnstructured(externalSecretGVK, types.NamespacedName{Name: name, Namespace: website.Namespace},
+		withRecommendedLabels(setResourceLabels(website.Name), website, componentCredentials), externalSecretSpec)
+}
+
+// externalSecretInjection returns the volumes, mounts and environment sources that expose
+// the Secrets resulting from the ExternalSecrets of a website to its container.
+func externalSecretInjection(website *devv1.Website) ([]corev1.Volume, []corev1.VolumeMount, []corev1.EnvFromSource) {
+	var volumes []corev1.Volume
+	var mounts []corev1.VolumeMount
+	var envFrom []corev1.EnvFromSource
+	for _, spec := range website.Spec.ExternalSecrets {
+		secretName := externalSecretName(resourceName(website), spec.Name)
+		if spec.MountPath == "" {
+			envFrom = append(envFrom, corev1.EnvFromSource{
+				SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: secretName}},
+			})
+			continue
+		}
+
+		volumeName := fmt.Sprintf("secret-%s", spec.Name)
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  secretName,
+					DefaultMode: pointer.Int32(corev1.SecretVolumeSourceDefaultMode),
+				},
+			},
+		})
+		mounts = append(mounts, corev1.VolumeMount{Name: volumeName, MountPath: spec.MountPath, ReadOnly: true})
+	}
+	return volumes, mounts, envFrom
+}
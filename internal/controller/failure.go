@@ -0,0 +1,161 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// defaultMaxFailures is how many reconciliations of a website generation may fail in a
+	// row before it is considered failed
+	defaultMaxFailures = 5
+	// defaultFailedRetryInterval is how often a failed website is retried
+	defaultFailedRetryInterval = 10 * time.Minute
+)
+
+// failureCount is the number of failed reconciliations in a row of a website generation.
+type failureCount struct {
+	generation int64
+	count      int
+}
+
+// failureTracker counts the failed reconciliations of every website.
+type failureTracker struct {
+	mu     sync.Mutex
+	counts map[types.NamespacedName]failureCount
+}
+
+// fail records a failed reconciliation and returns the number of failures in a row of
+// the generation.
+func (t *failureTracker) fail(name types.NamespacedName, generation int64) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.counts == nil {
+		t.counts = map[types.NamespacedName]failureCount{}
+	}
+	count := t.counts[name]
+	if count.generation != generation {
+		count = failureCount{generation: generation}
+	}
+	count.count++
+	t.counts[name] = count
+	return count.count
+}
+
+// succeed forgets the failures of a website.
+func (t *failureTracker) succeed(name types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.counts, name)
+}
+
+// handleResult bounds the retries of a website whose reconciliation keeps failing. Once
+// the same generation failed MaxFailures times in a row, the website is marked Failed with
+// the underlying reason, a single warning event is emitted, and it is only retried every
+// FailedRetryInterval instead of backing off from a hot loop. A successful reconciliation
+// clears the Failed condition.
+func (r *WebsiteReconciler) handleResult(ctx context.Context, name types.NamespacedName, result ctrl.Result, reconcileErr error) (ctrl.Result, error) {
+	website := &devv1.Website{}
+	if err := r.Client.Get(ctx, name, website); err != nil {
+		if errors.IsNotFound(err) {
+			r.failures.succeed(name)
+		}
+		return result, reconcileErr
+	}
+
+	if reconcileErr == nil {
+		r.failures.succeed(name)
+		if meta.FindStatusCondition(website.Status.Conditions, devv1.ConditionFailed) == nil {
+			return result, nil
+		}
+		log.FromContext(ctx).Info("Website recovered")
+		return result, r.setFailedCondition(ctx, website, nil)
+	}
+
+	maxFailures := r.MaxFailures
+	if maxFailures <= 0 {
+		maxFailures = defaultMaxFailures
+	}
+	if r.failures.fail(name, website.Generation) < maxFailures {
+		return result, reconcileErr
+	}
+
+	retryInterval := r.FailedRetryInterval
+	if retryInterval <= 0 {
+		retryInterval = defaultFailedRetryInterval
+	}
+	reason := string(errors.ReasonForError(reconcileErr))
+	if reason == "" {
+		reason = devv1.ReasonReconcileError
+	}
+
+	log.FromContext(ctx).Error(reconcileErr, "Website failed, retrying at a slow interval", "retryAfter", retryInterval)
+	if r.Recorder != nil && !meta.IsStatusConditionTrue(website.Status.Conditions, devv1.ConditionFailed) {
+		r.Recorder.Event(website, corev1.EventTypeWarning, reason,
+			fmt.Sprintf("Reconciliation failed %d times in a row, retrying every %s: %s", maxFailures, retryInterval, reconcileErr))
+	}
+	if err := r.setFailedCondition(ctx, website, &metav1.Condition{
+		Type:    devv1.ConditionFailed,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: reconcileErr.Error(),
+	}); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: retryInterval}, nil
+}
+
+// setFailedCondition sets the Failed condition of a website, or removes it when nil, along
+// with the phase it results in, as failed reconciliations do not reach reconcileStatus.
+func (r *WebsiteReconciler) setFailedCondition(ctx context.Context, website *devv1.Website, condition *metav1.Condition) error {
+	conditions := append([]metav1.Condition{}, website.Status.Conditions...)
+	if condition == nil {
+		meta.RemoveStatusCondition(&conditions, devv1.ConditionFailed)
+	} else {
+		condition.ObservedGeneration = website.Generation
+		meta.SetStatusCondition(&conditions, *condition)
+	}
+	phase := websitePhase(website, conditions)
+	if equality.Semantic.DeepEqual(website.Status.Conditions, conditions) && website.Status.Phase == phase {
+		return nil
+	}
+
+	patch := client.MergeFrom(website.DeepCopy())
+	website.Status.Conditions = conditions
+	website.Status.Phase = phase
+	if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update website status", "action", "update")
+		return err
+	}
+	return nil
+}
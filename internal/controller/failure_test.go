@@ -0,0 +1,64 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+func TestHandleResultSetsFailedPhase(t *testing.T) {
+	website := &devv1.Website{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec:       devv1.WebsiteSpec{ImageTag: "v1"},
+	}
+	r := newTestReconciler(t, website)
+	r.MaxFailures = 2
+	ctx := context.Background()
+	name := types.NamespacedName{Name: website.Name, Namespace: website.Namespace}
+
+	failed := errors.New("boom")
+	if _, err := r.handleResult(ctx, name, ctrl.Result{}, failed); err != failed {
+		t.Fatalf("handleResult() = %v, want the reconcile error below MaxFailures", err)
+	}
+	if _, err := r.handleResult(ctx, name, ctrl.Result{}, failed); err != nil {
+		t.Fatalf("handleResult() failed: %v", err)
+	}
+	if err := r.Get(ctx, name, website); err != nil {
+		t.Fatal(err)
+	}
+	if website.Status.Phase != devv1.PhaseFailed {
+		t.Errorf("Status.Phase = %q after failing, want %q", website.Status.Phase, devv1.PhaseFailed)
+	}
+
+	if _, err := r.handleResult(ctx, name, ctrl.Result{}, nil); err != nil {
+		t.Fatalf("handleResult() failed: %v", err)
+	}
+	if err := r.Get(ctx, name, website); err != nil {
+		t.Fatal(err)
+	}
+	if website.Status.Phase == devv1.PhaseFailed {
+		t.Errorf("Status.Phase = %q after recovering", website.Status.Phase)
+	}
+}
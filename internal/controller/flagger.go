@@ -0,0 +1,93 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// flaggerCanaryGVK identifies the Flagger Canary, which is handled as unstructured data
+// so that the operator does not depend on the Flagger API module.
+var flaggerCanaryGVK = schema.GroupVersionKind{Group: "flagger.app", Version: "v1beta1", Kind: "Canary"}
+
+// flaggerRollout reports whether Flagger rolls the website out.
+func flaggerRollout(website *devv1.Website) bool {
+	return website.Spec.Rollout != nil && website.Spec.Rollout.Provider == devv1.RolloutFlagger
+}
+
+// reconcileFlagger creates or updates the Flagger Canary of a website, and deletes it once
+// Flagger no longer rolls the website out.
+func (r *WebsiteReconciler) reconcileFlagger(ctx context.Context, website *devv1.Website) error {
+	if !flaggerRollout(website) {
+		return r.deleteUnstructured(ctx, flaggerCanaryGVK, types.NamespacedName{Name: resourceName(website), Namespace: website.Namespace})
+	}
+
+	desired, err := newFlaggerCanary(website)
+	if err != nil {
+		return err
+	}
+	if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+		return err
+	}
+	return r.reconcileUnstructured(ctx, desired)
+}
+
+// Create a Flagger Canary targeting the website Deployment. When the website has an
+// Ingress, Flagger shifts traffic through ingress-nginx, like the operator itself would.
+func newFlaggerCanary(website *devv1.Website) (*unstructured.Unstructured, error) {
+	steps, stepDuration := canarySteps(website.Spec.Rollout.Canary)
+	stepWeights := make([]interface{}, 0, len(steps))
+	for _, weight := range steps {
+		stepWeights = append(stepWeights, int64(weight))
+	}
+	port := websitePorts(website)[0]
+
+	spec := map[string]interface{}{
+		"targetRef": map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"name":       deploymentName(website),
+		},
+		"service": map[string]interface{}{
+			"port":       int64(port.ServicePort),
+			"targetPort": int64(port.ContainerPort),
+		},
+		"analysis": map[string]interface{}{
+			"interval":    stepDuration.String(),
+			"threshold":   int64(5),
+			"stepWeights": stepWeights,
+		},
+	}
+	if website.Spec.Ingress != nil {
+		spec["provider"] = "nginx"
+		spec["ingressRef"] = map[string]interface{}{
+			"apiVersion": "networking.k8s.io/v1",
+			"kind":       "Ingress",
+			"name":       resourceName(website),
+		}
+	}
+
+	return newUnstructured(flaggerCanaryGVK, types.NamespacedName{Name: resourceName(website), Namespace: website.Namespace},
+		withRecommendedLabels(setResourceLabels(website.Name), website, componentServer), spec)
+}
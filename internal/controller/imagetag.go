@@ -0,0 +1,133 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+	"github.com/mvasilenko/helloworld-operator/internal/cosign"
+	"github.com/mvasilenko/helloworld-operator/internal/semver"
+)
+
+// imageTagCheckInterval is how often the registry is checked for new tags satisfying the
+// image tag constraint of a website.
+const imageTagCheckInterval = 15 * time.Minute
+
+// resolveImageTag records in the status of a website the newest tag of its image
+// repository satisfying its image tag constraint, and the digest it points to. It returns
+// when the registry should be checked again.
+func (r *WebsiteReconciler) resolveImageTag(ctx context.Context, website *devv1.Website) (time.Duration, error) {
+	constraint := website.Spec.ImageTagConstraint
+	if constraint == "" {
+		return 0, r.setResolvedImage(ctx, website, nil)
+	}
+	repository := imageRepository(website)
+	status := website.Status.ResolvedImage.DeepCopy()
+	if status == nil || status.Repository != repository || status.Constraint != constraint {
+		status = &devv1.ResolvedImageStatus{Repository: repository, Constraint: constraint}
+	} else if status.LastCheckedAt != nil && time.Since(status.LastCheckedAt.Time) < imageTagCheckInterval {
+		return imageTagCheckInterval - time.Since(status.LastCheckedAt.Time), nil
+	}
+
+	now := metav1.Now()
+	status.LastCheckedAt = &now
+	tag, digest, err := r.latestImageTag(ctx, mirrorImage(r.RegistryMirrors, repository), constraint)
+	if err != nil {
+		// The tag resolved last keeps being deployed until the registry answers again.
+		log.FromContext(ctx).Info("Failed to resolve image tag constraint", "constraint", constraint, "reason", err.Error())
+		status.Message = err.Error()
+		return imageTagCheckInterval, r.setResolvedImage(ctx, website, status)
+	}
+	status.Message = ""
+	if tag == "" {
+		status.Message = fmt.Sprintf("no tag of %s satisfies %s", repository, constraint)
+	} else if tag != status.Tag || digest != status.Digest {
+		log.FromContext(ctx).Info("Resolved image tag constraint", "constraint", constraint, "tag", tag, "digest", digest)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(website, corev1.EventTypeNormal, "ImageTagResolved", "Resolved %s to %s:%s", constraint, repository, tag)
+		}
+		status.Tag, status.Digest = tag, digest
+	}
+	return imageTagCheckInterval, r.setResolvedImage(ctx, website, status)
+}
+
+// latestImageTag returns the newest tag of a repository satisfying a constraint and its
+// digest, or nothing when no tag does.
+func (r *WebsiteReconciler) latestImageTag(ctx context.Context, repository, constraint string) (string, string, error) {
+	parsed, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return "", "", err
+	}
+	var username, password string
+	if r.RegistryCredentials.Name != "" {
+		credentials := corev1.Secret{}
+		if err := r.APIReader.Get(ctx, r.RegistryCredentials, &credentials); err != nil {
+			return "", "", err
+		}
+		username, password, _ = registryLogin(&credentials, repository)
+	}
+
+	tags, err := cosign.Tags(ctx, repository, username, password)
+	if err != nil {
+		return "", "", err
+	}
+	tag, ok := parsed.Latest(tags)
+	if !ok {
+		return "", "", nil
+	}
+	digest, err := cosign.Digest(ctx, repository+":"+tag, username, password)
+	if err != nil {
+		return "", "", err
+	}
+	return tag, digest, nil
+}
+
+// withResolvedImage returns the website with the tag its image tag constraint resolved to
+// in place of its image tag, pinned to its digest.
+func withResolvedImage(website *devv1.Website) *devv1.Website {
+	resolved := website.Status.ResolvedImage
+	if website.Spec.ImageTagConstraint == "" || resolved == nil || resolved.Tag == "" ||
+		resolved.Repository != imageRepository(website) || resolved.Constraint != website.Spec.ImageTagConstraint {
+		return website
+	}
+	website = website.DeepCopy()
+	website.Spec.ImageTag = resolved.Tag
+	website.Spec.ImageDigest = resolved.Digest
+	return website
+}
+
+func (r *WebsiteReconciler) setResolvedImage(ctx context.Context, website *devv1.Website, status *devv1.ResolvedImageStatus) error {
+	if equality.Semantic.DeepEqual(website.Status.ResolvedImage, status) {
+		return nil
+	}
+	patch := client.MergeFrom(website.DeepCopy())
+	website.Status.ResolvedImage = status
+	if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update website status", "action", "update")
+		return err
+	}
+	return nil
+}
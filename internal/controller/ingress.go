@@ -0,0 +1,168 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// clusterIssuerAnnotation asks cert-manager to issue the certificate of an Ingress
+const clusterIssuerAnnotation = "cert-manager.io/cluster-issuer"
+
+// reconcileIngress creates or updates the Ingress of a website, and deletes it once the
+// website no longer asks for one.
+func (r *WebsiteReconciler) reconcileIngress(ctx context.Context, website *devv1.Website) error {
+	log := log.FromContext(ctx)
+
+	if website.Spec.Ingress == nil {
+		err := r.Client.Delete(ctx, &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: resourceName(website), Namespace: website.Namespace}})
+		if err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete ingress", "action", "delete")
+			return err
+		}
+		return nil
+	}
+
+	desired := newIngress(website)
+	if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+		return err
+	}
+	return r.applyIngress(ctx, desired)
+}
+
+// applyIngress creates the desired Ingress, or brings the fields the operator owns on the
+// existing Ingress back in line with it.
+func (r *WebsiteReconciler) applyIngress(ctx context.Context, desired *networkingv1.Ingress) error {
+	log := log.FromContext(ctx).WithValues("ingress", desired.Name)
+
+	err := r.Client.Create(ctx, desired)
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create ingress", "action", "create")
+		return err
+	}
+
+	ingress := networkingv1.Ingress{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &ingress); err != nil {
+		log.Error(err, "Failed to retrieve ingress", "action", "get")
+		return err
+	}
+
+	patch := client.MergeFrom(ingress.DeepCopy())
+	changed := syncLabels(&ingress.ObjectMeta, desired.Labels)
+	changed = syncAnnotations(&ingress.ObjectMeta, desired.Annotations) || changed
+	changed = syncField(&ingress.Spec, desired.Spec) || changed
+	if !changed {
+		return nil
+	}
+
+	log.Info("Ingress has changed", "action", "update")
+	if err := r.Client.Patch(ctx, &ingress, patch); err != nil {
+		log.Error(err, "Failed to update ingress", "action", "update")
+		return err
+	}
+	return nil
+}
+
+func tlsSecretName(website *devv1.Website) string {
+	if tls := website.Spec.Ingress.TLS; tls != nil && tls.SecretName != "" {
+		return tls.SecretName
+	}
+	return fmt.Sprintf("%s-tls", resourceName(website))
+}
+
+// Create an Ingress routing the website host to the first port of its Service, or to its
+// https port over TLS when the website pods serve TLS themselves.
+func newIngress(website *devv1.Website) *networkingv1.Ingress {
+	spec := website.Spec.Ingress
+
+	path := spec.Path
+	if path == "" {
+		path = "/"
+	}
+	pathType := networkingv1.PathTypePrefix
+	port := websitePorts(website)[0].ServicePort
+	if website.Spec.BackendTLS != nil {
+		port = backendTLSServicePort
+	}
+
+	var className *string
+	if spec.ClassName != "" {
+		className = &spec.ClassName
+	}
+
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      resourceName(website),
+			Namespace: website.Namespace,
+			Labels:    withRecommendedLabels(setResourceLabels(website.Name), website, componentServer),
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: className,
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: spec.Host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     path,
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: serviceName(website),
+											Port: networkingv1.ServiceBackendPort{Number: port},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	annotations := map[string]string{}
+	if tls := spec.TLS; tls != nil {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{spec.Host}, SecretName: tlsSecretName(website)}}
+		if tls.Issuer != "" {
+			annotations[clusterIssuerAnnotation] = tls.Issuer
+		}
+	}
+	if website.Spec.BackendTLS != nil {
+		annotations[backendProtocolAnnotation] = "HTTPS"
+	}
+	for key, value := range rateLimitAnnotations(website) {
+		annotations[key] = value
+	}
+	syncAnnotations(&ingress.ObjectMeta, annotations)
+	return ingress
+}
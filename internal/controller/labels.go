@@ -0,0 +1,155 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// The labels recommended by Kubernetes for tools to categorize the resources of an
+// application, see https://kubernetes.io/docs/concepts/overview/working-with-objects/common-labels/.
+// They are informational only: selectors keep matching the website labels, as the
+// recommended ones change with the image.
+const (
+	recommendedLabelPrefix = "app.kubernetes.io/"
+	nameLabel              = recommendedLabelPrefix + "name"
+	instanceLabel          = recommendedLabelPrefix + "instance"
+	versionLabel           = recommendedLabelPrefix + "version"
+	componentLabel         = recommendedLabelPrefix + "component"
+	managedByLabel         = recommendedLabelPrefix + "managed-by"
+
+	managedBy = "website-operator"
+)
+
+// The components of a website, as told by the component label of its resources.
+const (
+	componentServer      = "server"
+	componentBuild       = "build"
+	componentScan        = "scan"
+	componentMonitoring  = "monitoring"
+	componentCredentials = "credentials"
+)
+
+// withRecommendedLabels adds the recommended labels of a resource generated for a website
+// to its labels, and returns them. The version label is added to workloads only, from the
+// image they run, see setVersionLabel.
+func withRecommendedLabels(labels map[string]string, website *devv1.Website, component string) map[string]string {
+	labels[nameLabel] = website.Name
+	labels[instanceLabel] = resourceName(website)
+	labels[componentLabel] = component
+	labels[managedByLabel] = managedBy
+	return labels
+}
+
+// setVersionLabel labels a workload and its pod template with the tag of the image of the
+// website container. Images pinned to a digest only have no version.
+func setVersionLabel(deployment *appsv1.Deployment) {
+	version := imageVersion(deployment.Spec.Template.Spec.Containers[0].Image)
+	for _, meta := range []*metav1.ObjectMeta{&deployment.ObjectMeta, &deployment.Spec.Template.ObjectMeta} {
+		if meta.Labels == nil {
+			meta.Labels = map[string]string{}
+		}
+		if version == "" {
+			delete(meta.Labels, versionLabel)
+		} else {
+			meta.Labels[versionLabel] = version
+		}
+	}
+}
+
+// imageVersion returns the tag of an image reference, or an empty string when it has
+// none that is a valid label value.
+func imageVersion(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return ""
+	}
+	tag := image[i+1:]
+	if len(validation.IsValidLabelValue(tag)) > 0 {
+		return ""
+	}
+	return tag
+}
+
+// managedLabelsKey lists the labels of an object taken from the website spec, so that
+// they can be removed once they are dropped from the spec.
+const managedLabelsKey = "dev.mvasilenko.me/managed-labels"
+
+// addUserLabels adds labels from the website spec to an object, and lists them in its
+// annotations, which the object has to be synced with too.
+func addUserLabels(meta *metav1.ObjectMeta, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(labels))
+	for key, value := range labels {
+		meta.Labels[key] = value
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[managedLabelsKey] = strings.Join(keys, ",")
+}
+
+// syncLabels sets the desired labels of an object, and removes the recommended labels and
+// the labels taken from the website spec the operator no longer wants on it. Other labels
+// are left alone.
+func syncLabels(current *metav1.ObjectMeta, desired map[string]string) bool {
+	changed := false
+	managed := map[string]bool{}
+	for _, key := range strings.Split(current.Annotations[managedLabelsKey], ",") {
+		managed[key] = true
+	}
+	for key := range current.Labels {
+		if _, wanted := desired[key]; (strings.HasPrefix(key, recommendedLabelPrefix) || managed[key]) && !wanted {
+			delete(current.Labels, key)
+			changed = true
+		}
+	}
+	for key, value := range desired {
+		if existing, present := current.Labels[key]; present && existing == value {
+			continue
+		}
+		if current.Labels == nil {
+			current.Labels = map[string]string{}
+		}
+		current.Labels[key] = value
+		changed = true
+	}
+	return changed
+}
+
+// withLabels copies the labels under a prefix from one set of labels to another, and
+// returns the latter.
+func withLabels(labels, from map[string]string, prefix string) map[string]string {
+	for key, value := range from {
+		if strings.HasPrefix(key, prefix) {
+			labels[key] = value
+		}
+	}
+	return labels
+}
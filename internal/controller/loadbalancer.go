@@ -0,0 +1,114 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// loadBalancerAddresses returns the IPs and hostnames of the load balancer a website is
+// exposed through: the one of its Ingress, or the one of its Service when it is of the
+// LoadBalancer type. They are empty until the load balancer has been provisioned.
+func (r *WebsiteReconciler) loadBalancerAddresses(ctx context.Context, website *devv1.Website) ([]string, []string, error) {
+	var ips, hostnames []string
+	if website.Spec.Ingress != nil {
+		ingress := networkingv1.Ingress{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: resourceName(website), Namespace: website.Namespace}, &ingress); err != nil {
+			if err := client.IgnoreNotFound(err); err != nil {
+				log.FromContext(ctx).Error(err, "Failed to retrieve ingress", "action", "get")
+				return nil, nil, err
+			}
+		}
+		for _, lb := range ingress.Status.LoadBalancer.Ingress {
+			ips, hostnames = appendAddress(ips, lb.IP), appendAddress(hostnames, lb.Hostname)
+		}
+		return ips, hostnames, nil
+	}
+
+	if website.Spec.Service == nil || website.Spec.Service.Type != corev1.ServiceTypeLoadBalancer {
+		return nil, nil, nil
+	}
+	service := corev1.Service{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: serviceName(website), Namespace: website.Namespace}, &service); err != nil {
+		if err := client.IgnoreNotFound(err); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to retrieve service", "action", "get")
+			return nil, nil, err
+		}
+	}
+	for _, lb := range service.Status.LoadBalancer.Ingress {
+		ips, hostnames = appendAddress(ips, lb.IP), appendAddress(hostnames, lb.Hostname)
+	}
+	return ips, hostnames, nil
+}
+
+// nodePort returns the node port the first port of the Service of a website was allocated,
+// or zero when it is not exposed on the nodes.
+func (r *WebsiteReconciler) nodePort(ctx context.Context, website *devv1.Website) (int32, error) {
+	if website.Spec.Service != nil && website.Spec.Service.Type == corev1.ServiceTypeClusterIP {
+		return 0, nil
+	}
+	service := corev1.Service{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: serviceName(website), Namespace: website.Namespace}, &service); err != nil {
+		if err := client.IgnoreNotFound(err); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to retrieve service", "action", "get")
+			return 0, err
+		}
+	}
+	if len(service.Spec.Ports) == 0 {
+		return 0, nil
+	}
+	return service.Spec.Ports[0].NodePort, nil
+}
+
+func appendAddress(addresses []string, address string) []string {
+	if address == "" {
+		return addresses
+	}
+	return append(addresses, address)
+}
+
+// loadBalancerChanged lets through the Service and Ingress events that change the address
+// of their load balancer, and the ones of Services whose node ports were allocated.
+var loadBalancerChanged = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		service, ok := e.Object.(*corev1.Service)
+		return ok && service.Spec.Type != corev1.ServiceTypeClusterIP
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		switch newObject := e.ObjectNew.(type) {
+		case *corev1.Service:
+			oldObject := e.ObjectOld.(*corev1.Service)
+			return !equality.Semantic.DeepEqual(oldObject.Status.LoadBalancer, newObject.Status.LoadBalancer) ||
+				!equality.Semantic.DeepEqual(oldObject.Spec.Ports, newObject.Spec.Ports)
+		case *networkingv1.Ingress:
+			return !equality.Semantic.DeepEqual(e.ObjectOld.(*networkingv1.Ingress).Status.LoadBalancer, newObject.Status.LoadBalancer)
+		}
+		return false
+	},
+	DeleteFunc: func(event.DeleteEvent) bool { return false },
+}
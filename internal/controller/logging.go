@@ -0,0 +1,104 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/url"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	fluentBitImage    = "fluent/fluent-bit:2.1.2"
+	logsVolumeName    = "logs"
+	nginxLogMountPath = "/var/log/nginx"
+	defaultLogIndex   = "websites"
+)
+
+var defaultLogBufferSize = resource.MustParse("500Mi")
+
+// loggingSidecar returns the fluent-bit container shipping the access logs of a website,
+// the volume nginx writes them to and its mount into the nginx container. Mounting the
+// volume over the log directory replaces the links of the nginx image to the container
+// output with plain files. The pipeline is passed on the command line, so that it does
+// not need a configuration file.
+func loggingSidecar(website *devv1.Website) (corev1.Container, corev1.Volume, corev1.VolumeMount) {
+	spec := website.Spec.Logging
+
+	args := []string{
+		"-i", "tail", "-p", "path=" + nginxLogMountPath + "/access.log", "-p", "tag=access", "-p", "read_from_head=true",
+	}
+	switch spec.Output {
+	case devv1.LogOutputLoki:
+		args = append(args, "-o", "loki", "-m", "*", "-p", "uri=/loki/api/v1/push",
+			"-p", fmt.Sprintf("labels=job=website,website=%s,namespace=%s", website.Name, website.Namespace))
+		args = append(args, endpointArgs(spec.URL, "3100")...)
+	case devv1.LogOutputElasticsearch:
+		index := spec.Index
+		if index == "" {
+			index = defaultLogIndex
+		}
+		args = append(args, "-o", "es", "-m", "*", "-p", "index="+index, "-p", "suppress_type_name=on")
+		args = append(args, endpointArgs(spec.URL, "9200")...)
+	default:
+		args = append(args, "-o", "stdout", "-m", "*", "-p", "format=json_lines")
+	}
+
+	mount := corev1.VolumeMount{Name: logsVolumeName, MountPath: nginxLogMountPath}
+	container := corev1.Container{
+		Name:         "log-shipper",
+		Image:        fluentBitImage,
+		Args:         args,
+		VolumeMounts: []corev1.VolumeMount{{Name: logsVolumeName, MountPath: nginxLogMountPath, ReadOnly: true}},
+
+		// The values below are what the API server defaults them to.
+		ImagePullPolicy:          corev1.PullIfNotPresent,
+		TerminationMessagePath:   corev1.TerminationMessagePathDefault,
+		TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+	}
+
+	size := defaultLogBufferSize
+	if spec.BufferSize != nil {
+		size = *spec.BufferSize
+	}
+	volume := corev1.Volume{
+		Name:         logsVolumeName,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{SizeLimit: &size}},
+	}
+	return container, volume, mount
+}
+
+// endpointArgs returns the fluent-bit output parameters addressing the server of a URL.
+func endpointArgs(rawURL, defaultPort string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	tls := "off"
+	if u.Scheme == "https" {
+		tls = "on"
+	}
+	return []string{"-p", "host=" + u.Hostname(), "-p", "port=" + port, "-p", "tls=" + tls}
+}
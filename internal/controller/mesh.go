@@ -0,0 +1,101 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strconv"
+	"strings"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// istioInjectLabel turns the injection of the Istio sidecar into a pod on or off
+	istioInjectLabel = "sidecar.istio.io/inject"
+	// istioExcludeInboundPortsAnnotation lists the ports the Istio sidecar does not intercept
+	istioExcludeInboundPortsAnnotation = "traffic.sidecar.istio.io/excludeInboundPorts"
+
+	// linkerdInjectAnnotation turns the injection of the Linkerd proxy into a pod on or off
+	linkerdInjectAnnotation = "linkerd.io/inject"
+	// linkerdConfigAnnotationPrefix prefixes the annotations configuring the Linkerd proxy
+	linkerdConfigAnnotationPrefix = "config.linkerd.io/"
+)
+
+// meshLabels returns the labels of the website pods selecting their mesh sidecars.
+func meshLabels(website *devv1.Website) map[string]string {
+	labels := map[string]string{}
+	if mesh := website.Spec.Mesh; mesh != nil && mesh.Istio != nil {
+		labels[istioInjectLabel] = strconv.FormatBool(mesh.Istio.Enabled)
+	}
+	return labels
+}
+
+// meshAnnotations returns the annotations of the website pods configuring their mesh sidecars.
+func meshAnnotations(website *devv1.Website) map[string]string {
+	annotations := map[string]string{}
+	mesh := website.Spec.Mesh
+	if mesh == nil {
+		return annotations
+	}
+	// Prometheus scrapes the exporter without going through the mesh.
+	if mesh.Istio != nil && mesh.Istio.Enabled && exporterEnabled(website) {
+		annotations[istioExcludeInboundPortsAnnotation] = strconv.Itoa(metricsPort)
+	}
+
+	if linkerd := mesh.Linkerd; linkerd != nil {
+		if linkerd.Inject != "" {
+			annotations[linkerdInjectAnnotation] = string(linkerd.Inject)
+		}
+		for name, ports := range map[string][]int32{
+			"skip-inbound-ports":  linkerd.SkipInboundPorts,
+			"skip-outbound-ports": linkerd.SkipOutboundPorts,
+			"opaque-ports":        linkerd.OpaquePorts,
+		} {
+			if len(ports) > 0 {
+				annotations[linkerdConfigAnnotationPrefix+name] = portList(ports)
+			}
+		}
+	}
+	return annotations
+}
+
+// portList formats ports the way mesh annotations list them.
+func portList(ports []int32) string {
+	values := make([]string, 0, len(ports))
+	for _, port := range ports {
+		values = append(values, strconv.Itoa(int(port)))
+	}
+	return strings.Join(values, ",")
+}
+
+// withJobMeshLabels keeps the mesh sidecars out of the Job pods of a meshed website, as
+// Jobs never complete while a sidecar keeps running. It returns the labels.
+func withJobMeshLabels(labels map[string]string, website *devv1.Website) map[string]string {
+	if mesh := website.Spec.Mesh; mesh != nil && mesh.Istio != nil {
+		labels[istioInjectLabel] = "false"
+	}
+	return labels
+}
+
+// jobMeshAnnotations returns the annotations keeping the Linkerd proxy out of the Job pods
+// of a meshed website, see withJobMeshLabels.
+func jobMeshAnnotations(website *devv1.Website) map[string]string {
+	if mesh := website.Spec.Mesh; mesh != nil && mesh.Linkerd != nil {
+		return map[string]string{linkerdInjectAnnotation: "disabled"}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// availabilityRatio exposes the share of successful probes of every website over its
+// availability windows.
+var availabilityRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "website_availability_ratio",
+	Help: "Share of successful probes of the website URL over a rolling window.",
+}, []string{"namespace", "website", "window"})
+
+// certificateExpiry exposes when the serving certificate of every website expires.
+var certificateExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "website_certificate_expiry_timestamp_seconds",
+	Help: "Unix time the serving certificate of the website expires at.",
+}, []string{"namespace", "website", "secret"})
+
+func init() {
+	metrics.Registry.MustRegister(availabilityRatio, certificateExpiry)
+}
+
+// deleteWebsiteMetrics drops the series of a website.
+func deleteWebsiteMetrics(website types.NamespacedName) {
+	labels := prometheus.Labels{"namespace": website.Namespace, "website": website.Name}
+	availabilityRatio.DeletePartialMatch(labels)
+	certificateExpiry.DeletePartialMatch(labels)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// dockerHub is the name registry mirrors of Docker Hub are configured under.
+const dockerHub = "docker.io"
+
+// mirrorImage rewrites an image reference to pull it from the mirror of its registry, for
+// clusters without access to the upstream registries. Mirrors map a registry, optionally
+// followed by a repository path, to the registry and path prefix mirroring it, such as
+// docker.io to mirror.example.com/dockerhub. The longest match wins. Images without a
+// registry come from Docker Hub, and official images are under library/.
+func mirrorImage(mirrors map[string]string, image string) string {
+	if len(mirrors) == 0 || image == "" {
+		return image
+	}
+	name, suffix := image, ""
+	if i := strings.IndexByte(name, '@'); i >= 0 {
+		name, suffix = name[:i], name[i:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, suffix = name[:i], name[i:]+suffix
+	}
+
+	host, path, found := strings.Cut(name, "/")
+	if !found || !strings.ContainsAny(host, ".:") && host != "localhost" {
+		host, path = dockerHub, name
+		if !found {
+			path = "library/" + name
+		}
+	}
+	if host == "index.docker.io" || host == "registry-1.docker.io" {
+		host = dockerHub
+	}
+	name = host + "/" + path
+
+	var upstream string
+	for prefix := range mirrors {
+		if (name == prefix || strings.HasPrefix(name, prefix+"/")) && len(prefix) > len(upstream) {
+			upstream = prefix
+		}
+	}
+	if upstream == "" {
+		return image
+	}
+	return strings.TrimSuffix(mirrors[upstream], "/") + name[len(upstream):] + suffix
+}
+
+// mirrorPodImages rewrites the images of every container of a pod to pull them from the
+// registry mirrors of the operator.
+func (r *WebsiteReconciler) mirrorPodImages(spec *corev1.PodSpec) {
+	for i := range spec.InitContainers {
+		spec.InitContainers[i].Image = mirrorImage(r.RegistryMirrors, spec.InitContainers[i].Image)
+	}
+	for i := range spec.Containers {
+		spec.Containers[i].Image = mirrorImage(r.RegistryMirrors, spec.Containers[i].Image)
+	}
+}
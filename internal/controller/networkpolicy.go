@@ -0,0 +1,116 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// reconcileNetworkPolicy makes sure a NetworkPolicy restricts the egress of the website
+// pods while the website lists allowed destinations, and removes it otherwise.
+func (r *WebsiteReconciler) reconcileNetworkPolicy(ctx context.Context, website *devv1.Website) error {
+	log := log.FromContext(ctx)
+
+	if website.Spec.NetworkPolicy == nil || len(website.Spec.NetworkPolicy.Egress) == 0 {
+		err := r.Client.Delete(ctx, &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: resourceName(website), Namespace: website.Namespace}})
+		if err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete network policy", "action", "delete")
+			return err
+		}
+		return nil
+	}
+
+	desired := newNetworkPolicy(website)
+	if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+		return err
+	}
+
+	err := r.Client.Create(ctx, desired)
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create network policy", "action", "create")
+		return err
+	}
+
+	current := networkingv1.NetworkPolicy{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &current); err != nil {
+		log.Error(err, "Failed to retrieve network policy", "action", "get")
+		return err
+	}
+	patch := client.MergeFrom(current.DeepCopy())
+	changed := syncLabels(&current.ObjectMeta, desired.Labels)
+	changed = syncField(&current.Spec, desired.Spec) || changed
+	if !changed {
+		return nil
+	}
+
+	log.Info("Network policy has changed", "action", "update")
+	if err := r.Client.Patch(ctx, &current, patch); err != nil {
+		log.Error(err, "Failed to update network policy", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// Create a NetworkPolicy allowing the website pods to connect to the destinations listed
+// by the website, and to resolve names. Ingress traffic is left alone.
+func newNetworkPolicy(website *devv1.Website) *networkingv1.NetworkPolicy {
+	udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+	dnsPort := intstr.FromInt(53)
+	dns := networkingv1.NetworkPolicyEgressRule{
+		Ports: []networkingv1.NetworkPolicyPort{{Protocol: &udp, Port: &dnsPort}, {Protocol: &tcp, Port: &dnsPort}},
+	}
+
+	egress := []networkingv1.NetworkPolicyEgressRule{dns}
+	for _, rule := range website.Spec.NetworkPolicy.Egress {
+		rule := *rule.DeepCopy()
+		// The API server defaults the protocol of ports to TCP.
+		for i := range rule.Ports {
+			if rule.Ports[i].Protocol == nil {
+				rule.Ports[i].Protocol = &tcp
+			}
+		}
+		egress = append(egress, rule)
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      resourceName(website),
+			Namespace: website.Namespace,
+			Labels:    withRecommendedLabels(setResourceLabels(website.Name), website, componentServer),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: setResourceLabels(website.Name)},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      egress,
+		},
+	}
+}
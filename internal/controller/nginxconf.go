@@ -0,0 +1,173 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// nginxConfigChecksumAnnotation holds a checksum of the generated nginx configuration on
+	// the pod template. The files are mounted through subPaths, which the kubelet does not
+	// update, so configuration changes roll the pods.
+	nginxConfigChecksumAnnotation = "dev.mvasilenko.me/nginx-config-checksum"
+
+	nginxConfVolume = "nginx-conf"
+	// httpConfFile holds the directives applying to every server of nginx
+	httpConfFile = "website.conf"
+	// defaultServerFile replaces the default server of the nginx image
+	defaultServerFile = "default.conf"
+)
+
+func nginxConfName(name string) string {
+	return fmt.Sprintf("%s-nginx-conf", name)
+}
+
+// ownsDefaultServer reports whether the website needs server directives the default server
+// of the nginx image does not have, in which case the operator replaces it.
+func ownsDefaultServer(website *devv1.Website) bool {
+	return website.Spec.ErrorPages != nil || website.Spec.Caching != nil || podRateLimit(website) ||
+		len(website.Spec.Redirects) > 0 || len(website.Spec.Rewrites) > 0 || website.Spec.TrailingSlash != "" ||
+		acmeSpec(website) != nil
+}
+
+// serverDirectives returns the directives the servers of a website are configured with.
+func serverDirectives(website *devv1.Website) string {
+	directives := acmeChallengeDirectives(website) + rateLimitDirectives(website) + redirectDirectives(website) +
+		errorPageDirectives(website) + cachingDirectives(website)
+	if tryFiles := tryFilesDirective(website, "        "); tryFiles != "" {
+		directives += "    location / {\n" + tryFiles + "    }\n"
+	}
+	return directives
+}
+
+// httpDirectives returns the directives applying to every server of a website.
+func httpDirectives(website *devv1.Website) string {
+	return compressionDirectives(website) + rateLimitZoneDirectives(website) + securityHeaderDirectives(website, "")
+}
+
+// nginxServer renders an nginx server listening on the given addresses and serving the
+// website content like the default server of the nginx image, configured with the server
+// directives of the website. The directives are added before those of the website.
+func nginxServer(website *devv1.Website, listen []string, directives ...string) string {
+	var server strings.Builder
+	server.WriteString("server {\n")
+	for _, address := range listen {
+		fmt.Fprintf(&server, "    listen %s;\n", address)
+	}
+	for _, directive := range directives {
+		fmt.Fprintf(&server, "    %s;\n", directive)
+	}
+	fmt.Fprintf(&server, `    server_name localhost;
+    root %s;
+    index index.html index.htm;
+%s}
+`, contentRoot(website), serverDirectives(website))
+	return server.String()
+}
+
+// nginxConfig returns the nginx configuration files generated for a website.
+func nginxConfig(website *devv1.Website) map[string]string {
+	files := map[string]string{}
+	if directives := httpDirectives(website); directives != "" {
+		files[httpConfFile] = directives
+	}
+	if ownsDefaultServer(website) {
+		var listen []string
+		for _, port := range websitePorts(website) {
+			if port.Protocol == corev1.ProtocolTCP && port.Name != devv1.BackendTLSPortName {
+				listen = append(listen, fmt.Sprint(port.ContainerPort))
+			}
+		}
+		files[defaultServerFile] = nginxServer(website, listen)
+	}
+	return files
+}
+
+// nginxConfigChecksum returns a checksum of the nginx configuration generated for a
+// website, or an empty string when there is none.
+func nginxConfigChecksum(website *devv1.Website) string {
+	files := nginxConfig(website)
+	if len(files) == 0 {
+		return ""
+	}
+	data := map[string][]byte{}
+	for name, content := range files {
+		data[name] = []byte(content)
+	}
+	return checksum(data)
+}
+
+// reconcileNginxConfig makes sure the nginx configuration generated for a website exists
+// while the website needs one, and removes it otherwise.
+func (r *WebsiteReconciler) reconcileNginxConfig(ctx context.Context, website *devv1.Website) error {
+	desired := newNginxConfigMap(website)
+	if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+		return err
+	}
+	return r.reconcileConfigMap(ctx, desired, len(desired.Data) > 0)
+}
+
+// nginxConfigVolume returns the volume holding the nginx configuration generated for a
+// website, and the mounts of its files into the configuration directory of nginx.
+func nginxConfigVolume(website *devv1.Website) (*corev1.Volume, []corev1.VolumeMount) {
+	files := nginxConfig(website)
+	if len(files) == 0 {
+		return nil, nil
+	}
+	volume := &corev1.Volume{
+		Name: nginxConfVolume,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: nginxConfName(resourceName(website))},
+				DefaultMode:          pointer.Int32(corev1.ConfigMapVolumeSourceDefaultMode),
+			},
+		},
+	}
+	// The files are mounted in a fixed order, so that the pod template does not change
+	// between reconciles.
+	var mounts []corev1.VolumeMount
+	for _, file := range []string{httpConfFile, defaultServerFile} {
+		if _, ok := files[file]; ok {
+			mounts = append(mounts, corev1.VolumeMount{Name: nginxConfVolume, MountPath: "/etc/nginx/conf.d/" + file, SubPath: file, ReadOnly: true})
+		}
+	}
+	return volume, mounts
+}
+
+// Create the ConfigMap holding the nginx configuration generated for a website.
+func newNginxConfigMap(website *devv1.Website) *corev1.ConfigMap {
+	labels := withRecommendedLabels(setResourceLabels(website.Name), website, componentServer)
+	labels[watchLabel] = "true"
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nginxConfName(resourceName(website)),
+			Namespace: website.Namespace,
+			Labels:    labels,
+		},
+		Data: nginxConfig(website),
+	}
+}
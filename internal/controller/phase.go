@@ -0,0 +1,223 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// newReplicaSetAvailable is the reason of the Progressing condition of a workload that
+// finished rolling out
+const newReplicaSetAvailable = "NewReplicaSetAvailable"
+
+// reconcileStatus mirrors the Available and Progressing conditions of the website
+// workload into the website status and derives its phase from its conditions.
+func (r *WebsiteReconciler) reconcileStatus(ctx context.Context, website *devv1.Website) error {
+	workload, err := r.workloadStatus(ctx, website)
+	if err != nil {
+		return err
+	}
+
+	conditions := append([]metav1.Condition{}, website.Status.Conditions...)
+	for _, conditionType := range []string{devv1.ConditionAvailable, devv1.ConditionProgressing} {
+		mirrored := false
+		if workload != nil {
+			for _, c := range workload.Conditions {
+				if string(c.Type) != conditionType {
+					continue
+				}
+				mirrored = true
+				condition := metav1.Condition{
+					Type:               conditionType,
+					Status:             metav1.ConditionStatus(c.Status),
+					ObservedGeneration: website.Generation,
+					Reason:             c.Reason,
+					Message:            c.Message,
+				}
+				// A rollout past its progress deadline is explained by what keeps its pods
+				// from becoming ready.
+				if conditionType == devv1.ConditionProgressing && c.Status == corev1.ConditionFalse {
+					if err := r.explainStuckRollout(ctx, website, &condition); err != nil {
+						return err
+					}
+				}
+				meta.SetStatusCondition(&conditions, condition)
+			}
+		}
+		if !mirrored {
+			meta.RemoveStatusCondition(&conditions, conditionType)
+		}
+	}
+
+	phase := websitePhase(website, conditions)
+	if equality.Semantic.DeepEqual(website.Status.Conditions, conditions) && website.Status.Phase == phase {
+		return nil
+	}
+	patch := client.MergeFrom(website.DeepCopy())
+	website.Status.Conditions = conditions
+	website.Status.Phase = phase
+	if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update website status", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// explainStuckRollout replaces the reason of the Progressing condition of a stuck rollout
+// with the first reason found on the website pods, such as ImagePullBackOff,
+// CrashLoopBackOff or Unschedulable.
+func (r *WebsiteReconciler) explainStuckRollout(ctx context.Context, website *devv1.Website, condition *metav1.Condition) error {
+	pods := corev1.PodList{}
+	if err := r.Client.List(ctx, &pods, client.InNamespace(website.Namespace), client.MatchingLabels(setResourceLabels(website.Name))); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list website pods", "action", "get")
+		return err
+	}
+	for _, pod := range pods.Items {
+		if reason, message := podProblem(&pod); reason != "" {
+			condition.Reason = reason
+			condition.Message = fmt.Sprintf("%s; pod %s: %s", condition.Message, pod.Name, message)
+			return nil
+		}
+	}
+	return nil
+}
+
+// podProblem returns why a pod does not become ready, if it is stuck.
+func podProblem(pod *corev1.Pod) (string, string) {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse && c.Reason == corev1.PodReasonUnschedulable {
+			return c.Reason, c.Message
+		}
+	}
+	for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		if waiting := status.State.Waiting; waiting != nil {
+			switch waiting.Reason {
+			case "ImagePullBackOff", "ErrImagePull", "CrashLoopBackOff", "CreateContainerConfigError", "InvalidImageName":
+				return waiting.Reason, fmt.Sprintf("container %s: %s", status.Name, waiting.Message)
+			}
+		}
+	}
+	return "", ""
+}
+
+// workloadStatus returns the status of the Deployment, Rollout or DaemonSet running the
+// website pods, or nil when there is none yet. Rollouts report their conditions the way
+// Deployments do.
+func (r *WebsiteReconciler) workloadStatus(ctx context.Context, website *devv1.Website) (*appsv1.DeploymentStatus, error) {
+	name := types.NamespacedName{Name: deploymentName(website), Namespace: website.Namespace}
+
+	if daemonSet(website) {
+		daemonSet := appsv1.DaemonSet{}
+		if err := r.Client.Get(ctx, name, &daemonSet); err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+		return daemonSetStatus(&daemonSet), nil
+	}
+
+	if !argoRollout(website) {
+		deployment := appsv1.Deployment{}
+		if err := r.Client.Get(ctx, name, &deployment); err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+		return &deployment.Status, nil
+	}
+
+	rollout := &unstructured.Unstructured{}
+	rollout.SetGroupVersionKind(argoRolloutGVK)
+	if err := r.APIReader.Get(ctx, name, rollout); err != nil {
+		if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	status, _, err := unstructured.NestedMap(rollout.Object, "status")
+	if err != nil || status == nil {
+		return nil, err
+	}
+	result := &appsv1.DeploymentStatus{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(status, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// websitePhase sums the conditions of a website up in one word.
+func websitePhase(website *devv1.Website, conditions []metav1.Condition) devv1.WebsitePhase {
+	if website.DeletionTimestamp != nil {
+		return devv1.PhaseTerminating
+	}
+
+	available := meta.FindStatusCondition(conditions, devv1.ConditionAvailable)
+	progressing := meta.FindStatusCondition(conditions, devv1.ConditionProgressing)
+	refused := meta.IsStatusConditionFalse(conditions, devv1.ConditionImageVerified) ||
+		meta.IsStatusConditionFalse(conditions, devv1.ConditionImageScanned)
+	scan := meta.FindStatusCondition(conditions, devv1.ConditionImageScanned)
+	scanning := scan != nil && scan.Status == metav1.ConditionUnknown
+
+	switch {
+	case meta.IsStatusConditionTrue(conditions, devv1.ConditionFailed):
+		return devv1.PhaseFailed
+	case available == nil && refused:
+		return devv1.PhaseFailed
+	case available == nil:
+		return devv1.PhasePending
+	case refused, available.Status != metav1.ConditionTrue:
+		return devv1.PhaseDegraded
+	case progressing != nil && progressing.Status == metav1.ConditionFalse:
+		return devv1.PhaseDegraded
+	case meta.IsStatusConditionTrue(conditions, devv1.ConditionDNSMisconfigured):
+		return devv1.PhaseDegraded
+	case scanning, progressing != nil && progressing.Reason != newReplicaSetAvailable:
+		return devv1.PhaseDeploying
+	}
+	return devv1.PhaseReady
+}
+
+// workloadConditionsChanged lets through the Deployment events that can change the
+// conditions of a website, ignoring the frequent replica count updates.
+var workloadConditionsChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldDeployment, newDeployment := e.ObjectOld.(*appsv1.Deployment), e.ObjectNew.(*appsv1.Deployment)
+		if len(oldDeployment.Status.Conditions) != len(newDeployment.Status.Conditions) {
+			return true
+		}
+		for i, c := range newDeployment.Status.Conditions {
+			old := oldDeployment.Status.Conditions[i]
+			if c.Type != old.Type || c.Status != old.Status || c.Reason != old.Reason {
+				return true
+			}
+		}
+		return false
+	},
+}
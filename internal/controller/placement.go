@@ -0,0 +1,132 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// reconcilePlacement records in the website status which zones and nodes its pods are
+// scheduled on, and the URL, load balancer address and node port it is reachable at. Zones are not recorded by an operator
+// watching a single namespace.
+func (r *WebsiteReconciler) reconcilePlacement(ctx context.Context, website *devv1.Website) error {
+	log := log.FromContext(ctx)
+
+	pods := corev1.PodList{}
+	if err := r.Client.List(ctx, &pods, client.InNamespace(website.Namespace), client.MatchingLabels(setResourceLabels(website.Name))); err != nil {
+		log.Error(err, "Failed to list pods", "action", "get")
+		return err
+	}
+
+	zones, nodes := map[string]int32{}, map[string]int32{}
+	var urlPod *corev1.Pod
+	for i, pod := range pods.Items {
+		if pod.Spec.NodeName == "" || pod.DeletionTimestamp != nil ||
+			pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		nodes[pod.Spec.NodeName]++
+		// The URL of a website on host ports points to the first node running one of its
+		// pods, so that it does not move around between reconciles.
+		if pod.Status.Phase == corev1.PodRunning && (urlPod == nil || pod.Spec.NodeName < urlPod.Spec.NodeName) {
+			urlPod = &pods.Items[i]
+		}
+		// Nodes are cluster-scoped, an operator watching a single namespace cannot read them.
+		if r.Namespace != "" {
+			continue
+		}
+
+		node := corev1.Node{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, &node); err != nil {
+			log.Error(err, "Failed to retrieve node", "action", "get", "node", pod.Spec.NodeName)
+			return err
+		}
+		if zone := node.Labels[corev1.LabelTopologyZone]; zone != "" {
+			zones[zone]++
+		}
+	}
+
+	ips, hostnames, err := r.loadBalancerAddresses(ctx, website)
+	if err != nil {
+		return err
+	}
+
+	status := website.Status.DeepCopy()
+	status.Address = ""
+	if addresses := append(ips, hostnames...); len(addresses) > 0 {
+		status.Address = addresses[0]
+	}
+	status.Zones = placements(zones)
+	status.Nodes = placements(nodes)
+	status.ZoneCount = int32(len(zones))
+	status.URL = websiteURL(website, urlPod, status.Address)
+	if status.NodePort, err = r.nodePort(ctx, website); err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(*status, website.Status) {
+		return nil
+	}
+
+	patch := client.MergeFrom(website.DeepCopy())
+	website.Status = *status
+	if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+		log.Error(err, "Failed to update website status", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// placements turns pod counts into a list sorted by name.
+func placements(counts map[string]int32) []devv1.PodPlacement {
+	var list []devv1.PodPlacement
+	for name, pods := range counts {
+		list = append(list, devv1.PodPlacement{Name: name, Pods: pods})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// websiteOf maps an object generated for a website, such as a pod, to its Website.
+func websiteOf(obj client.Object) []reconcile.Request {
+	name := obj.GetLabels()[websiteLabel]
+	if name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: obj.GetNamespace()}}}
+}
+
+// podPlacementChanged lets through the pod events that can change where a website runs,
+// ignoring the frequent status updates of running pods.
+var podPlacementChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldPod, newPod := e.ObjectOld.(*corev1.Pod), e.ObjectNew.(*corev1.Pod)
+		return oldPod.Spec.NodeName != newPod.Spec.NodeName || oldPod.Status.Phase != newPod.Status.Phase ||
+			(oldPod.DeletionTimestamp == nil) != (newPod.DeletionTimestamp == nil)
+	},
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+	"github.com/mvasilenko/helloworld-operator/internal/cosign"
+)
+
+// withPlatforms restricts an affinity to the nodes running one of the given platforms.
+// Node selector terms are ORed, so every term of the affinity is combined with every
+// platform.
+func withPlatforms(affinity *corev1.Affinity, platforms []devv1.Platform) *corev1.Affinity {
+	if len(platforms) == 0 {
+		return affinity
+	}
+	affinity = affinity.DeepCopy()
+	if affinity == nil {
+		affinity = &corev1.Affinity{}
+	}
+	if affinity.NodeAffinity == nil {
+		affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	selector := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if selector == nil || len(selector.NodeSelectorTerms) == 0 {
+		selector = &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{}}}
+	}
+
+	var terms []corev1.NodeSelectorTerm
+	for _, term := range selector.NodeSelectorTerms {
+		for _, platform := range platforms {
+			os, arch, _ := strings.Cut(platform.String(), "/")
+			combined := *term.DeepCopy()
+			combined.MatchExpressions = append(combined.MatchExpressions,
+				corev1.NodeSelectorRequirement{Key: corev1.LabelOSStable, Operator: corev1.NodeSelectorOpIn, Values: []string{os}},
+				corev1.NodeSelectorRequirement{Key: corev1.LabelArchStable, Operator: corev1.NodeSelectorOpIn, Values: []string{arch}})
+			terms = append(terms, combined)
+		}
+	}
+	affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{NodeSelectorTerms: terms}
+	return affinity
+}
+
+// verifyPlatforms checks that the image of the desired deployment is available for every
+// platform of the website. An image missing one is replaced by the one running, so that
+// it is not rolled out. It reports false when no image can be rolled out at all, and
+// returns when a failed check should be retried.
+func (r *WebsiteReconciler) verifyPlatforms(ctx context.Context, website *devv1.Website, desired *appsv1.Deployment) (time.Duration, bool, error) {
+	if !website.Spec.VerifyPlatforms {
+		return 0, true, r.setCondition(ctx, website, devv1.ConditionImagePlatforms, nil)
+	}
+
+	image := desired.Spec.Template.Spec.Containers[0].Image
+	var wanted []string
+	for _, platform := range website.Spec.Platforms {
+		wanted = append(wanted, platform.String())
+	}
+	condition := &metav1.Condition{
+		Type:    devv1.ConditionImagePlatforms,
+		Status:  metav1.ConditionTrue,
+		Reason:  devv1.ReasonPlatformsAvailable,
+		Message: fmt.Sprintf("%s is available for %s", image, strings.Join(wanted, ", ")),
+	}
+
+	missing, err := r.missingPlatforms(ctx, image, wanted)
+	if err != nil {
+		condition.Status, condition.Reason, condition.Message = metav1.ConditionUnknown, devv1.ReasonPlatformCheckFailed, err.Error()
+	} else if len(missing) > 0 {
+		condition.Status, condition.Reason = metav1.ConditionFalse, devv1.ReasonPlatformsMissing
+		condition.Message = fmt.Sprintf("%s is not available for %s", image, strings.Join(missing, ", "))
+	}
+	if err := r.setCondition(ctx, website, devv1.ConditionImagePlatforms, condition); err != nil {
+		return 0, false, err
+	}
+	if condition.Status == metav1.ConditionTrue {
+		return 0, true, nil
+	}
+
+	log.FromContext(ctx).Info("Refusing to roll out image missing platforms", "image", image, "reason", condition.Message)
+	ok, err := r.holdImage(ctx, desired)
+	return verificationRetryInterval, ok, err
+}
+
+// missingPlatforms returns the platforms an image is not available for, remembering the
+// images found complete so that registries are not asked on every reconcile.
+func (r *WebsiteReconciler) missingPlatforms(ctx context.Context, image string, wanted []string) ([]string, error) {
+	cacheKey := image + "\x00platforms\x00" + strings.Join(wanted, ",")
+	if _, ok := r.verifiedImages.Load(cacheKey); ok {
+		return nil, nil
+	}
+
+	var username, password string
+	if r.RegistryCredentials.Name != "" {
+		credentials := corev1.Secret{}
+		if err := r.APIReader.Get(ctx, r.RegistryCredentials, &credentials); err != nil {
+			return nil, err
+		}
+		username, password, _ = registryLogin(&credentials, image)
+	}
+	available, err := cosign.Platforms(ctx, image, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, platform := range wanted {
+		found := false
+		for _, candidate := range available {
+			// Variants such as linux/arm64/v8 are not told apart.
+			if candidate == platform || strings.HasPrefix(candidate, platform+"/") {
+				found = true
+			}
+		}
+		if !found {
+			missing = append(missing, platform)
+		}
+	}
+	if len(missing) == 0 {
+		r.verifiedImages.Store(cacheKey, true)
+	}
+	return missing, nil
+}
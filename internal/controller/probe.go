@@ -0,0 +1,94 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const defaultProbeModule = "http_2xx"
+
+// probeGVK identifies the prometheus-operator Probe, which is handled as unstructured data
+// like the ServiceMonitor.
+var probeGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "Probe"}
+
+// proberURL returns the blackbox exporter probing a website, or an empty string when it
+// is not probed.
+func (r *WebsiteReconciler) proberURL(website *devv1.Website) string {
+	monitoring := website.Spec.Monitoring
+	if monitoring == nil || !monitoring.Enabled {
+		return ""
+	}
+	if monitoring.Probe != nil && monitoring.Probe.ProberURL != "" {
+		return monitoring.Probe.ProberURL
+	}
+	return r.BlackboxExporterURL
+}
+
+// reconcileProbe makes sure a Probe checks the URL of a monitored website once it has one,
+// and removes it otherwise.
+func (r *WebsiteReconciler) reconcileProbe(ctx context.Context, website *devv1.Website) error {
+	prober := r.proberURL(website)
+	if prober == "" || website.Status.URL == "" {
+		return r.deleteUnstructured(ctx, probeGVK, types.NamespacedName{Name: resourceName(website), Namespace: website.Namespace})
+	}
+
+	desired, err := newProbe(website, prober)
+	if err != nil {
+		return err
+	}
+	if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+		return err
+	}
+	return r.reconcileUnstructured(ctx, desired)
+}
+
+// Create a Probe sending the URL of a website to a blackbox exporter.
+func newProbe(website *devv1.Website, prober string) (*unstructured.Unstructured, error) {
+	module := defaultProbeModule
+	spec := map[string]interface{}{
+		"jobName": "website",
+		"prober":  map[string]interface{}{"url": prober},
+		"targets": map[string]interface{}{
+			"staticConfig": map[string]interface{}{
+				"static": []interface{}{website.Status.URL},
+				"labels": map[string]interface{}{
+					"website":   website.Name,
+					"namespace": website.Namespace,
+				},
+			},
+		},
+	}
+	if probe := website.Spec.Monitoring.Probe; probe != nil {
+		if probe.Module != "" {
+			module = probe.Module
+		}
+		if probe.Interval != nil {
+			spec["interval"] = probe.Interval.Duration.String()
+		}
+	}
+	spec["module"] = module
+	return newUnstructured(probeGVK, types.NamespacedName{Name: resourceName(website), Namespace: website.Namespace},
+		withRecommendedLabels(setResourceLabels(website.Name), website, componentMonitoring), spec)
+}
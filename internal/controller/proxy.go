@@ -0,0 +1,63 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// proxyEnv returns the proxy environment variables of the operator configuration, in
+// both cases as tools disagree on which one they read.
+func (r *WebsiteReconciler) proxyEnv() []corev1.EnvVar {
+	var env []corev1.EnvVar
+	for _, proxy := range []struct{ name, lower, value string }{
+		{"HTTP_PROXY", "http_proxy", r.HTTPProxy},
+		{"HTTPS_PROXY", "https_proxy", r.HTTPSProxy},
+		{"NO_PROXY", "no_proxy", r.NoProxy},
+	} {
+		if proxy.value != "" {
+			env = append(env, corev1.EnvVar{Name: proxy.name, Value: proxy.value}, corev1.EnvVar{Name: proxy.lower, Value: proxy.value})
+		}
+	}
+	return env
+}
+
+// injectProxyEnv adds the proxy environment variables to every container of a pod. The
+// variables a container already sets are left alone.
+func (r *WebsiteReconciler) injectProxyEnv(spec *corev1.PodSpec) {
+	env := r.proxyEnv()
+	if len(env) == 0 {
+		return
+	}
+	inject := func(container *corev1.Container) {
+		set := map[string]bool{}
+		for _, variable := range container.Env {
+			set[variable.Name] = true
+		}
+		for _, variable := range env {
+			if !set[variable.Name] {
+				container.Env = append(container.Env, variable)
+			}
+		}
+	}
+	for i := range spec.InitContainers {
+		inject(&spec.InitContainers[i])
+	}
+	for i := range spec.Containers {
+		inject(&spec.Containers[i])
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+func pullSecretName(name string) string {
+	return fmt.Sprintf("%s-pull", name)
+}
+
+// reconcilePullSecret copies the registry credentials of the operator into the namespace
+// of a website whose image comes from one of their registries, and removes the copy once
+// the website no longer needs it. It returns the pull Secrets the website pods use.
+func (r *WebsiteReconciler) reconcilePullSecret(ctx context.Context, website *devv1.Website) ([]corev1.LocalObjectReference, error) {
+	log := log.FromContext(ctx)
+	name := types.NamespacedName{Name: pullSecretName(resourceName(website)), Namespace: website.Namespace}
+
+	var credentials *corev1.Secret
+	if r.RegistryCredentials.Name != "" {
+		credentials = &corev1.Secret{}
+		if err := r.APIReader.Get(ctx, r.RegistryCredentials, credentials); err != nil {
+			log.Error(err, "Failed to retrieve registry credentials", "action", "get", "secret", r.RegistryCredentials)
+			return nil, err
+		}
+	}
+
+	if credentials == nil || !hasRegistryCredentials(credentials, mirrorImage(r.RegistryMirrors, imageRepository(website))) {
+		err := r.Client.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace}})
+		if err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete pull secret", "action", "delete")
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	labels := withRecommendedLabels(setResourceLabels(website.Name), website, componentCredentials)
+	labels[watchLabel] = "true"
+	desired := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace, Labels: labels},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       credentials.Data,
+	}
+	// In a remote cluster, the owner would not exist and the copy be garbage collected.
+	if !r.remoteCluster {
+		if err := ctrl.SetControllerReference(website, desired, r.Scheme); err != nil {
+			return nil, err
+		}
+	}
+	refs := []corev1.LocalObjectReference{{Name: name.Name}}
+
+	err := r.Client.Create(ctx, desired)
+	if err == nil {
+		return refs, nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create pull secret", "action", "create")
+		return nil, err
+	}
+
+	current := corev1.Secret{}
+	if err := r.Client.Get(ctx, name, &current); err != nil {
+		log.Error(err, "Failed to retrieve pull secret", "action", "get")
+		return nil, err
+	}
+	patch := client.MergeFrom(current.DeepCopy())
+	changed := syncLabels(&current.ObjectMeta, desired.Labels)
+	changed = syncField(&current.Data, desired.Data) || changed
+	if !changed {
+		return refs, nil
+	}
+
+	log.Info("Registry credentials have changed", "action", "update")
+	if err := r.Client.Patch(ctx, &current, patch); err != nil {
+		log.Error(err, "Failed to update pull secret", "action", "update")
+		return nil, err
+	}
+	return refs, nil
+}
+
+// hasRegistryCredentials reports whether a docker config Secret holds credentials for the
+// registry of an image repository.
+func hasRegistryCredentials(secret *corev1.Secret, repository string) bool {
+	_, _, ok := registryLogin(secret, repository)
+	return ok
+}
+
+// registryLogin returns the credentials a docker config Secret holds for the registry of
+// an image repository.
+func registryLogin(secret *corev1.Secret, repository string) (username, password string, ok bool) {
+	var config struct {
+		Auths map[string]struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Auth     string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &config); err != nil {
+		return "", "", false
+	}
+	registry := registryHost(repository)
+	for server, auth := range config.Auths {
+		if registryHost(server) != registry {
+			continue
+		}
+		if auth.Auth != "" {
+			if decoded, err := base64.StdEncoding.DecodeString(auth.Auth); err == nil {
+				auth.Username, auth.Password, _ = strings.Cut(string(decoded), ":")
+			}
+		}
+		return auth.Username, auth.Password, true
+	}
+	return "", "", false
+}
+
+// registryHost returns the registry an image repository, or a docker config server entry,
+// refers to. Repositories without a registry host come from Docker Hub.
+func registryHost(repository string) string {
+	repository = strings.TrimPrefix(strings.TrimPrefix(repository, "https://"), "http://")
+	host, _, _ := strings.Cut(repository, "/")
+	if !strings.ContainsAny(host, ".:") && host != "localhost" {
+		return "docker.io"
+	}
+	if host == "index.docker.io" || host == "registry-1.docker.io" {
+		return "docker.io"
+	}
+	return host
+}
+
+// setupPullSecretWatch labels the registry credentials of the operator for watching and
+// re-reconciles every Website whenever they change, so that their copies are rotated.
+func (r *WebsiteReconciler) setupPullSecretWatch(mgr ctrl.Manager, bldr *ctrl.Builder) error {
+	if r.RegistryCredentials.Name == "" {
+		return nil
+	}
+
+	bldr.Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		if obj.GetName() != r.RegistryCredentials.Name || obj.GetNamespace() != r.RegistryCredentials.Namespace {
+			return nil
+		}
+		websites := devv1.WebsiteList{}
+		if err := r.Client.List(context.Background(), &websites); err != nil {
+			log.Log.Error(err, "Failed to list websites for registry credentials")
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(websites.Items))
+		for _, website := range websites.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: website.Name, Namespace: website.Namespace},
+			})
+		}
+		return requests
+	}))
+
+	return mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		obj := &metav1.PartialObjectMetadata{}
+		obj.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+		if err := r.APIReader.Get(ctx, r.RegistryCredentials, obj); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if obj.Labels[watchLabel] == "true" {
+			return nil
+		}
+		patch := client.MergeFrom(obj.DeepCopy())
+		if obj.Labels == nil {
+			obj.Labels = map[string]string{}
+		}
+		obj.Labels[watchLabel] = "true"
+		return r.Client.Patch(ctx, obj, patch)
+	}))
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// limitRPSAnnotation, limitRPMAnnotation and limitBurstMultiplierAnnotation make
+	// ingress-nginx limit the requests of every client address
+	limitRPSAnnotation             = "nginx.ingress.kubernetes.io/limit-rps"
+	limitRPMAnnotation             = "nginx.ingress.kubernetes.io/limit-rpm"
+	limitBurstMultiplierAnnotation = "nginx.ingress.kubernetes.io/limit-burst-multiplier"
+
+	rateLimitZone = "website"
+)
+
+// podRateLimit reports whether nginx in the website pods limits the rate of requests,
+// which it does for websites without an Ingress.
+func podRateLimit(website *devv1.Website) bool {
+	return website.Spec.RateLimit != nil && website.Spec.Ingress == nil
+}
+
+func rateLimitUnit(spec *devv1.RateLimitSpec) string {
+	if spec.Period == devv1.RateLimitPerMinute {
+		return "m"
+	}
+	return "s"
+}
+
+// rateLimitAnnotations returns the Ingress annotations limiting the rate of requests of a
+// website.
+func rateLimitAnnotations(website *devv1.Website) map[string]string {
+	spec := website.Spec.RateLimit
+	if spec == nil {
+		return nil
+	}
+	annotation := limitRPSAnnotation
+	if spec.Period == devv1.RateLimitPerMinute {
+		annotation = limitRPMAnnotation
+	}
+	// ingress-nginx allows bursts of the rate times the multiplier.
+	multiplier := (spec.Burst + spec.Requests - 1) / spec.Requests
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	return map[string]string{
+		annotation:                     strconv.Itoa(int(spec.Requests)),
+		limitBurstMultiplierAnnotation: strconv.Itoa(int(multiplier)),
+	}
+}
+
+// rateLimitZoneDirectives returns the nginx directive declaring the shared memory zone
+// requests are counted in, or nothing when nginx does not limit requests.
+func rateLimitZoneDirectives(website *devv1.Website) string {
+	if !podRateLimit(website) {
+		return ""
+	}
+	spec := website.Spec.RateLimit
+	return fmt.Sprintf("limit_req_zone $binary_remote_addr zone=%s:10m rate=%dr/%s;\n", rateLimitZone, spec.Requests, rateLimitUnit(spec))
+}
+
+// rateLimitDirectives returns the nginx server directives limiting requests, or nothing
+// when nginx does not limit requests.
+func rateLimitDirectives(website *devv1.Website) string {
+	if !podRateLimit(website) {
+		return ""
+	}
+	return fmt.Sprintf("    limit_req zone=%s burst=%d nodelay;\n    limit_req_status 429;\n", rateLimitZone, website.Spec.RateLimit.Burst)
+}
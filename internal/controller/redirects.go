@@ -0,0 +1,77 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// redirectVariable holds the host and path of a request, so that redirects can match both
+// in a single condition.
+const redirectVariable = "$website_request"
+
+// redirectDirectives returns the nginx server directives applying the redirects, rewrites
+// and trailing slash policy of a website. They run before a location is selected, in the
+// order they are listed.
+func redirectDirectives(website *devv1.Website) string {
+	var directives strings.Builder
+	if len(website.Spec.Redirects) > 0 {
+		fmt.Fprintf(&directives, "    set %s \"$host$uri\";\n", redirectVariable)
+	}
+	for _, redirect := range website.Spec.Redirects {
+		code := redirect.Code
+		if code == 0 {
+			code = 301
+		}
+		host := "[^/]*"
+		if redirect.Host != "" {
+			host = regexp.QuoteMeta(redirect.Host)
+		}
+		pattern, target := host+regexp.QuoteMeta(redirect.Path), redirect.To
+		if redirect.PathType == devv1.RedirectPathExact {
+			pattern += "$"
+		} else {
+			pattern += "(.*)$"
+			target += "$1"
+		}
+		fmt.Fprintf(&directives, "    if (%s ~ \"^%s\") {\n        return %d \"%s$is_args$args\";\n    }\n", redirectVariable, pattern, code, target)
+	}
+	for _, rewrite := range website.Spec.Rewrites {
+		fmt.Fprintf(&directives, "    rewrite \"%s\" \"%s\" last;\n", rewrite.Regex, rewrite.Replacement)
+	}
+	switch website.Spec.TrailingSlash {
+	case devv1.TrailingSlashAdd:
+		directives.WriteString("    rewrite \"^([^.]*[^/])$\" \"$1/\" permanent;\n")
+	case devv1.TrailingSlashRemove:
+		directives.WriteString("    rewrite \"^(/.+)/$\" \"$1\" permanent;\n")
+	}
+	return directives.String()
+}
+
+// tryFilesDirective returns the directive serving the paths of a website. Without trailing
+// slashes, directories are served through their index rather than redirected to the path
+// with a slash, which would loop.
+func tryFilesDirective(website *devv1.Website, indent string) string {
+	if website.Spec.TrailingSlash != devv1.TrailingSlashRemove {
+		return ""
+	}
+	return indent + "try_files $uri $uri/index.html $uri.html =404;\n"
+}
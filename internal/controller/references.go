@@ -0,0 +1,145 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// watchLabel makes ConfigMaps and Secrets visible to the operator's scoped cache. It is
+	// set on the ones the operator generates and stamped onto the ones Websites reference.
+	watchLabel = "dev.mvasilenko.me/watch"
+
+	// configMapIndex and secretIndex index Websites by the ConfigMaps and Secrets they reference
+	configMapIndex = "website.configMapRefs"
+	secretIndex    = "website.secretRefs"
+)
+
+// referencedConfigMaps lists the names of the ConfigMaps a website depends on.
+func referencedConfigMaps(website *devv1.Website) []string {
+	var names []string
+	if content := website.Spec.Content; content != nil && content.ConfigMapName != "" {
+		names = append(names, content.ConfigMapName)
+	}
+	return names
+}
+
+// referencedSecrets lists the names of the Secrets a website depends on.
+func referencedSecrets(website *devv1.Website) []string {
+	var names []string
+	if content := website.Spec.Content; content != nil && content.SecretName != "" {
+		names = append(names, content.SecretName)
+	}
+	for _, cluster := range website.Spec.Clusters {
+		names = append(names, cluster.KubeconfigSecretRef.Name)
+	}
+	if name := servingCertificateSecret(website); name != "" {
+		names = append(names, name)
+	}
+	return names
+}
+
+// labelReferences stamps the watch label onto every ConfigMap and Secret the website
+// references, so that changes to them are seen by the scoped cache and re-reconcile
+// the website. References that do not exist yet are skipped.
+func (r *WebsiteReconciler) labelReferences(ctx context.Context, website *devv1.Website) error {
+	refs := map[string][]string{
+		"ConfigMap": referencedConfigMaps(website),
+		"Secret":    referencedSecrets(website),
+	}
+	for kind, names := range refs {
+		for _, name := range names {
+			obj := &metav1.PartialObjectMetadata{}
+			obj.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind(kind))
+			err := r.APIReader.Get(ctx, types.NamespacedName{Name: name, Namespace: website.Namespace}, obj)
+			if errors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if obj.Labels[watchLabel] == "true" {
+				continue
+			}
+
+			log.FromContext(ctx).Info("Labelling referenced object for watching", "action", "update", "kind", kind, "name", name)
+			patch := client.MergeFrom(obj.DeepCopy())
+			if obj.Labels == nil {
+				obj.Labels = map[string]string{}
+			}
+			obj.Labels[watchLabel] = "true"
+			if err := r.Client.Patch(ctx, obj, patch); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setupReferenceWatches indexes Websites by the ConfigMaps and Secrets they reference and
+// re-reconciles the dependent Websites whenever one of those changes.
+func (r *WebsiteReconciler) setupReferenceWatches(mgr ctrl.Manager, bldr *ctrl.Builder) error {
+	indexer := mgr.GetFieldIndexer()
+	if err := indexer.IndexField(context.Background(), &devv1.Website{}, configMapIndex, func(obj client.Object) []string {
+		return referencedConfigMaps(obj.(*devv1.Website))
+	}); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(context.Background(), &devv1.Website{}, secretIndex, func(obj client.Object) []string {
+		return referencedSecrets(obj.(*devv1.Website))
+	}); err != nil {
+		return err
+	}
+
+	bldr.Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.websitesReferencing(configMapIndex))).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.websitesReferencing(secretIndex)))
+	return nil
+}
+
+// websitesReferencing maps an object to the Websites referencing it by name through the given index.
+func (r *WebsiteReconciler) websitesReferencing(index string) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		websites := devv1.WebsiteList{}
+		if err := r.Client.List(context.Background(), &websites,
+			client.InNamespace(obj.GetNamespace()), client.MatchingFields{index: obj.GetName()}); err != nil {
+			log.Log.Error(err, "Failed to list websites referencing object", "name", obj.GetName(), "namespace", obj.GetNamespace())
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(websites.Items))
+		for _, website := range websites.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: website.Name, Namespace: website.Namespace},
+			})
+		}
+		return requests
+	}
+}
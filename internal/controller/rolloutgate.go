@@ -0,0 +1,144 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+	"github.com/mvasilenko/helloworld-operator/internal/schedule"
+)
+
+// requireApproval reports whether new images of a website wait for their approval.
+func requireApproval(website *devv1.Website) bool {
+	return website.Spec.Rollout != nil && website.Spec.Rollout.RequireApproval
+}
+
+// gateRollout holds a new image of the desired deployment until it is approved and the
+// rollout window of the website is open, and reports it as the pending image along with
+// the PendingRollout condition. It returns when the window opens next. Websites with
+// nothing running yet are rolled out right away.
+func (r *WebsiteReconciler) gateRollout(ctx context.Context, website *devv1.Website, desired *appsv1.Deployment) (time.Duration, error) {
+	image := desired.Spec.Template.Spec.Containers[0].Image
+	var condition *metav1.Condition
+	var opensAfter time.Duration
+
+	if requireApproval(website) || website.Spec.RolloutWindow != nil {
+		// Holding the image leaves it unchanged when it is the one running already.
+		held := desired.DeepCopy()
+		running, err := r.holdImage(ctx, held)
+		if err != nil {
+			return 0, err
+		}
+		if running && held.Spec.Template.Spec.Containers[0].Image != image {
+			condition, opensAfter, err = pendingRollout(website, image)
+			if err != nil {
+				return 0, err
+			}
+		}
+		if condition != nil {
+			log.FromContext(ctx).Info("Holding image", "image", image, "reason", condition.Reason)
+			desired.Spec.Template.Spec.Containers[0].Image = held.Spec.Template.Spec.Containers[0].Image
+		}
+	}
+
+	pendingImage := ""
+	if condition != nil {
+		pendingImage = image
+	}
+	if err := r.setPendingImage(ctx, website, pendingImage); err != nil {
+		return 0, err
+	}
+	return opensAfter, r.setCondition(ctx, website, devv1.ConditionPendingRollout, condition)
+}
+
+// pendingRollout returns the PendingRollout condition of a new image of a website, or nil
+// when it can be rolled out, along with when the rollout window opens next.
+func pendingRollout(website *devv1.Website, image string) (*metav1.Condition, time.Duration, error) {
+	if requireApproval(website) && website.Annotations[devv1.ApprovedImageAnnotation] != image {
+		return &metav1.Condition{
+			Type:    devv1.ConditionPendingRollout,
+			Status:  metav1.ConditionTrue,
+			Reason:  devv1.ReasonAwaitingApproval,
+			Message: fmt.Sprintf("%s waits for approval, given by setting the %s annotation to it", image, devv1.ApprovedImageAnnotation),
+		}, 0, nil
+	}
+
+	spec := website.Spec.RolloutWindow
+	if spec == nil {
+		return nil, 0, nil
+	}
+	location, err := time.LoadLocation(spec.TimeZone)
+	if err != nil {
+		return nil, 0, err
+	}
+	now := time.Now().In(location)
+	open, next, err := rolloutWindowOpen(spec, now)
+	if err != nil || open {
+		return nil, 0, err
+	}
+	condition := &metav1.Condition{
+		Type:    devv1.ConditionPendingRollout,
+		Status:  metav1.ConditionTrue,
+		Reason:  devv1.ReasonOutsideRolloutWindow,
+		Message: fmt.Sprintf("%s waits for the rollout window", image),
+	}
+	if next.IsZero() {
+		return condition, 0, nil
+	}
+	condition.Message = fmt.Sprintf("%s waits for the rollout window opening at %s", image, next.Format(time.RFC3339))
+	return condition, next.Sub(now), nil
+}
+
+func (r *WebsiteReconciler) setPendingImage(ctx context.Context, website *devv1.Website, image string) error {
+	if website.Status.PendingImage == image {
+		return nil
+	}
+	patch := client.MergeFrom(website.DeepCopy())
+	website.Status.PendingImage = image
+	if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update website status", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// rolloutWindowOpen reports whether one of the rollout windows is open at the given time,
+// and otherwise when the next one opens.
+func rolloutWindowOpen(spec *devv1.RolloutWindowSpec, now time.Time) (bool, time.Time, error) {
+	var next time.Time
+	for _, window := range spec.Windows {
+		s, err := schedule.Parse(window.Schedule)
+		if err != nil {
+			return false, time.Time{}, err
+		}
+		if opened := s.Last(now, window.Duration.Duration); !opened.IsZero() && now.Before(opened.Add(window.Duration.Duration)) {
+			return true, time.Time{}, nil
+		}
+		if opens := s.Next(now); !opens.IsZero() && (next.IsZero() || opens.Before(next)) {
+			next = opens
+		}
+	}
+	return false, next, nil
+}
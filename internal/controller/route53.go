@@ -0,0 +1,104 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+	"github.com/mvasilenko/helloworld-operator/internal/route53"
+)
+
+// defaultRoute53TTL is the TTL of Route 53 records when the website sets none, as Route
+// 53 requires one.
+const defaultRoute53TTL = 300
+
+// syncRoute53Records makes the Route 53 records of the host of a website match the given
+// records. A CNAME to the load balancer becomes an alias record, evaluating the health of
+// the load balancer, when its hosted zone is known.
+func (r *WebsiteReconciler) syncRoute53Records(ctx context.Context, website *devv1.Website, records []dnsRecord) error {
+	spec := website.Spec.DNS.Route53
+	ttl := website.Spec.DNS.TTL
+	if ttl == 0 {
+		ttl = defaultRoute53TTL
+	}
+	var desired []route53.RecordSet
+	for _, record := range records {
+		if record.recordType == "CNAME" && spec.LoadBalancerHostedZoneID != "" {
+			desired = append(desired, route53.RecordSet{Type: "A", Alias: &route53.AliasTarget{
+				HostedZoneID:         spec.LoadBalancerHostedZoneID,
+				DNSName:              record.targets[0],
+				EvaluateTargetHealth: true,
+			}})
+			continue
+		}
+		desired = append(desired, route53.RecordSet{Type: record.recordType, TTL: ttl, Values: record.targets})
+	}
+
+	credentials, err := r.route53Credentials(ctx, website)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to retrieve AWS credentials", "action", "get")
+		return err
+	}
+	api := &route53.Client{Credentials: credentials}
+	host := dnsHost(website)
+	owner := website.Namespace + "/" + website.Name
+	if r.dryRun() {
+		changes, err := api.Plan(ctx, spec.HostedZoneID, host, owner, desired)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			log.FromContext(ctx).Info("Dry run: skipping Route 53 change", "action", change.Action, "host", change.RecordSet.Name,
+				"type", change.RecordSet.Type)
+		}
+		return nil
+	}
+	if err := api.Sync(ctx, spec.HostedZoneID, host, owner, desired); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to sync Route 53 records", "action", "update", "host", host)
+		return err
+	}
+	return nil
+}
+
+// route53Credentials returns the credentials of the Secret of a website, or else those of
+// the operator.
+func (r *WebsiteReconciler) route53Credentials(ctx context.Context, website *devv1.Website) (route53.Credentials, error) {
+	name := website.Spec.DNS.Route53.CredentialsSecretName
+	if name == "" {
+		return route53.EnvironmentCredentials(ctx)
+	}
+
+	secret := corev1.Secret{}
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Name: name, Namespace: website.Namespace}, &secret); err != nil {
+		return route53.Credentials{}, err
+	}
+	credentials := route53.Credentials{
+		AccessKeyID:     string(secret.Data["AWS_ACCESS_KEY_ID"]),
+		SecretAccessKey: string(secret.Data["AWS_SECRET_ACCESS_KEY"]),
+		SessionToken:    string(secret.Data["AWS_SESSION_TOKEN"]),
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return route53.Credentials{}, fmt.Errorf("secret %q has no AWS_ACCESS_KEY_ID or AWS_SECRET_ACCESS_KEY", name)
+	}
+	return credentials, nil
+}
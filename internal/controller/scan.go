@@ -0,0 +1,237 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// scanLabel is set on the scan Jobs of a website to the name of the website
+	scanLabel = "dev.mvasilenko.me/scan"
+
+	trivyImage = "aquasec/trivy:0.41.0"
+	grypeImage = "anchore/grype:v0.62.1"
+
+	// vulnerableExitCode is what Trivy is told to exit with when it finds vulnerabilities,
+	// telling them apart from scan errors, which are retried
+	vulnerableExitCode = 3
+)
+
+var severities = []devv1.Severity{"LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// scanImage scans the image of the desired deployment with a Job before it is rolled out.
+// While the scan runs, or when it fails, the image running is kept in the desired
+// deployment. It reports false when no image can be rolled out at all. Scan Jobs are
+// owned by the website, so their completion re-reconciles it.
+func (r *WebsiteReconciler) scanImage(ctx context.Context, website *devv1.Website, desired *appsv1.Deployment) (bool, error) {
+	log := log.FromContext(ctx)
+
+	var job *batchv1.Job
+	keep := map[string]bool{}
+	if website.Spec.VulnerabilityScan != nil {
+		job = newScanJob(website, desired.Spec.Template.Spec.Containers[0].Image)
+		r.mirrorPodImages(&job.Spec.Template.Spec)
+		r.injectProxyEnv(&job.Spec.Template.Spec)
+		keep[job.Name] = true
+	}
+	if err := r.deleteScanJobs(ctx, website, false, keep); err != nil {
+		return false, err
+	}
+	if job == nil {
+		return true, r.setCondition(ctx, website, devv1.ConditionImageScanned, nil)
+	}
+
+	image := desired.Spec.Template.Spec.Containers[0].Image
+	condition, err := r.runScanJob(ctx, website, job, image)
+	if err != nil {
+		return false, err
+	}
+	if err := r.setCondition(ctx, website, devv1.ConditionImageScanned, condition); err != nil {
+		return false, err
+	}
+
+	if condition.Status == metav1.ConditionTrue {
+		return true, nil
+	}
+	if condition.Status == metav1.ConditionFalse {
+		log.Info("Refusing to roll out image failing its scan", "image", image, "reason", condition.Reason)
+	}
+	return r.holdImage(ctx, desired)
+}
+
+// runScanJob creates the Job scanning an image of a website unless it exists, and returns
+// the scan condition its outcome amounts to.
+func (r *WebsiteReconciler) runScanJob(ctx context.Context, website *devv1.Website, job *batchv1.Job, image string) (*metav1.Condition, error) {
+	log := log.FromContext(ctx)
+
+	if err := ctrl.SetControllerReference(website, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Client.Create(ctx, job); err == nil {
+		log.Info("Scanning image", "action", "create", "job", job.Name)
+	} else if !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create scan job", "action", "create")
+		return nil, err
+	}
+
+	condition := &metav1.Condition{
+		Type:    devv1.ConditionImageScanned,
+		Status:  metav1.ConditionUnknown,
+		Reason:  devv1.ReasonScanning,
+		Message: fmt.Sprintf("Job %s is scanning %s", job.Name, image),
+	}
+	current := batchv1.Job{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, &current)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to retrieve scan job", "action", "get")
+		return nil, err
+	}
+	if current.Status.Succeeded > 0 {
+		condition.Status, condition.Reason = metav1.ConditionTrue, devv1.ReasonScanPassed
+		condition.Message = fmt.Sprintf("%s has no vulnerabilities of severity %s or higher", image, scanThreshold(website))
+	}
+	for _, c := range current.Status.Conditions {
+		if c.Type != batchv1.JobFailed || c.Status != corev1.ConditionTrue {
+			continue
+		}
+		condition.Status, condition.Reason = metav1.ConditionFalse, devv1.ReasonScanFailed
+		condition.Message = fmt.Sprintf("Job %s failed to scan %s: %s", job.Name, image, c.Message)
+		if c.Reason == "PodFailurePolicy" || website.Spec.VulnerabilityScan.Scanner == devv1.ScannerGrype {
+			condition.Reason = devv1.ReasonVulnerabilitiesFound
+			condition.Message = fmt.Sprintf("%s has vulnerabilities of severity %s or higher, see the logs of job %s",
+				image, scanThreshold(website), job.Name)
+		}
+	}
+	return condition, nil
+}
+
+// deleteScanJobs removes the scan Jobs of a website, or those of the variants of its A/B
+// test, other than the ones to keep.
+func (r *WebsiteReconciler) deleteScanJobs(ctx context.Context, website *devv1.Website, variants bool, keep map[string]bool) error {
+	jobs := batchv1.JobList{}
+	if err := r.Client.List(ctx, &jobs, client.InNamespace(website.Namespace), client.MatchingLabels{scanLabel: website.Name}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list scan jobs", "action", "get")
+		return err
+	}
+	for i := range jobs.Items {
+		if _, variant := jobs.Items[i].Labels[abTestLabel]; variant != variants || keep[jobs.Items[i].Name] {
+			continue
+		}
+		err := r.Client.Delete(ctx, &jobs.Items[i], client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !errors.IsNotFound(err) {
+			log.FromContext(ctx).Error(err, "Failed to delete scan job", "action", "delete", "job", jobs.Items[i].Name)
+			return err
+		}
+	}
+	return nil
+}
+
+func scanThreshold(website *devv1.Website) devv1.Severity {
+	if threshold := website.Spec.VulnerabilityScan.SeverityThreshold; threshold != "" {
+		return threshold
+	}
+	return "CRITICAL"
+}
+
+// Create the Job scanning an image of a website. Its name changes with the image and the
+// scan settings, so that each of them is scanned once.
+func newScanJob(website *devv1.Website, image string) *batchv1.Job {
+	spec := website.Spec.VulnerabilityScan
+	threshold := scanThreshold(website)
+	id := checksum(map[string][]byte{
+		"image":     []byte(image),
+		"scanner":   []byte(spec.Scanner),
+		"threshold": []byte(threshold),
+	})[:10]
+	name := fmt.Sprintf("%s-scan-%s", resourceName(website), id)
+
+	var blocking []string
+	for i, severity := range severities {
+		if severity == threshold {
+			for _, s := range severities[i:] {
+				blocking = append(blocking, string(s))
+			}
+		}
+	}
+
+	container := corev1.Container{
+		Name:  "scan",
+		Image: trivyImage,
+		Args: []string{"image", "--no-progress", "--severity", strings.Join(blocking, ","),
+			"--exit-code", fmt.Sprint(vulnerableExitCode), image},
+	}
+	backoffLimit := int32(2)
+	var failurePolicy *batchv1.PodFailurePolicy
+	if spec.Scanner == devv1.ScannerGrype {
+		container.Image = grypeImage
+		container.Args = []string{image, "--fail-on", strings.ToLower(string(threshold))}
+		// Retrying would only find the same vulnerabilities again.
+		backoffLimit = 0
+	} else {
+		failurePolicy = &batchv1.PodFailurePolicy{
+			Rules: []batchv1.PodFailurePolicyRule{{
+				Action: batchv1.PodFailurePolicyActionFailJob,
+				OnExitCodes: &batchv1.PodFailurePolicyOnExitCodesRequirement{
+					ContainerName: pointer.String(container.Name),
+					Operator:      batchv1.PodFailurePolicyOnExitCodesOpIn,
+					Values:        []int32{vulnerableExitCode},
+				},
+			}},
+		}
+	}
+
+	labels := withRecommendedLabels(setResourceLabels(name), website, componentScan)
+	labels[scanLabel] = website.Name
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: website.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:     &backoffLimit,
+			PodFailurePolicy: failurePolicy,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      withJobMeshLabels(withRecommendedLabels(setResourceLabels(name), website, componentScan), website),
+					Annotations: jobMeshAnnotations(website),
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:   corev1.RestartPolicyNever,
+					SecurityContext: &corev1.PodSecurityContext{SeccompProfile: seccompProfile(website)},
+					Containers:      []corev1.Container{container},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// appArmorAnnotationPrefix followed by the name of a container selects its AppArmor profile.
+const appArmorAnnotationPrefix = "container.apparmor.security.beta.kubernetes.io/"
+
+// podSecurityContext returns the security context of the website pods: the one of the
+// website spec, with the seccomp profile of the website.
+func podSecurityContext(website *devv1.Website) *corev1.PodSecurityContext {
+	// An empty security context is what the API server defaults a missing one to.
+	context := website.Spec.SecurityContext.DeepCopy()
+	if context == nil {
+		context = &corev1.PodSecurityContext{}
+	}
+	context.SeccompProfile = seccompProfile(website)
+	return context
+}
+
+// seccompProfile returns the seccomp profile of the pods generated for a website.
+func seccompProfile(website *devv1.Website) *corev1.SeccompProfile {
+	if website.Spec.SeccompProfile != nil {
+		return website.Spec.SeccompProfile.DeepCopy()
+	}
+	if context := website.Spec.SecurityContext; context != nil && context.SeccompProfile != nil {
+		return context.SeccompProfile.DeepCopy()
+	}
+	return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+}
+
+// appArmorProfile returns the AppArmor annotation value selecting the profile of the
+// containers of a website, or an empty string when it does not select one.
+func appArmorProfile(website *devv1.Website) string {
+	profile := website.Spec.AppArmorProfile
+	if profile == nil {
+		return ""
+	}
+	switch profile.Type {
+	case devv1.AppArmorProfileLocalhost:
+		return "localhost/" + profile.LocalhostProfile
+	case devv1.AppArmorProfileUnconfined:
+		return "unconfined"
+	default:
+		return "runtime/default"
+	}
+}
+
+// containerNames returns the names of containers.
+func containerNames(containers []corev1.Container) []string {
+	var names []string
+	for _, container := range containers {
+		names = append(names, container.Name)
+	}
+	return names
+}
+
+// readOnlyRootFilesystem reports whether the website container runs with a read-only root
+// filesystem, which it does unless the website opts out.
+func readOnlyRootFilesystem(website *devv1.Website) bool {
+	if context := website.Spec.ContainerSecurityContext; context != nil && context.ReadOnlyRootFilesystem != nil {
+		return *context.ReadOnlyRootFilesystem
+	}
+	return website.Spec.ReadOnlyRootFilesystem == nil || *website.Spec.ReadOnlyRootFilesystem
+}
+
+// containerSecurityContext returns the security context of the website container: the
+// one of the website spec, with a read-only root filesystem unless it opts out.
+func containerSecurityContext(website *devv1.Website) *corev1.SecurityContext {
+	context := website.Spec.ContainerSecurityContext.DeepCopy()
+	if !readOnlyRootFilesystem(website) {
+		return context
+	}
+	if context == nil {
+		context = &corev1.SecurityContext{}
+	}
+	context.ReadOnlyRootFilesystem = pointer.Bool(true)
+	return context
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// securityHeader is a response header nginx adds for every website, with its default value.
+type securityHeader struct {
+	name, value string
+	override    func(*devv1.SecurityHeadersSpec) *string
+}
+
+var securityHeaders = []securityHeader{
+	{"Strict-Transport-Security", "max-age=31536000", func(spec *devv1.SecurityHeadersSpec) *string { return spec.StrictTransportSecurity }},
+	{"Content-Security-Policy", "frame-ancestors 'self'; object-src 'none'; base-uri 'self'", func(spec *devv1.SecurityHeadersSpec) *string { return spec.ContentSecurityPolicy }},
+	{"X-Frame-Options", "SAMEORIGIN", func(spec *devv1.SecurityHeadersSpec) *string { return spec.FrameOptions }},
+	{"Referrer-Policy", "strict-origin-when-cross-origin", func(spec *devv1.SecurityHeadersSpec) *string { return spec.ReferrerPolicy }},
+	{"X-Content-Type-Options", "nosniff", func(spec *devv1.SecurityHeadersSpec) *string { return spec.ContentTypeOptions }},
+}
+
+// securityHeaderDirectives returns the nginx directives adding the security headers of a
+// website to every response, indented by the given prefix. nginx only inherits add_header
+// directives into blocks that have none of their own, so blocks adding headers repeat them.
+func securityHeaderDirectives(website *devv1.Website, indent string) string {
+	var directives strings.Builder
+	for _, header := range securityHeaders {
+		value := header.value
+		if spec := website.Spec.SecurityHeaders; spec != nil && header.override(spec) != nil {
+			value = *header.override(spec)
+		}
+		if value != "" {
+			fmt.Fprintf(&directives, "%sadd_header %s \"%s\" always;\n", indent, header.name, value)
+		}
+	}
+	return directives.String()
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+	"github.com/mvasilenko/helloworld-operator/internal/acme"
+)
+
+const (
+	// selfSignedValidity is how long a self-signed certificate is valid for
+	selfSignedValidity = 365 * 24 * time.Hour
+	// selfSignedRenewBefore is how long before its expiry a self-signed certificate is
+	// replaced
+	selfSignedRenewBefore = 30 * 24 * time.Hour
+)
+
+// reconcileSelfSignedCertificate generates the self-signed certificate of a website into
+// the TLS Secret of its Ingress, and replaces it before it expires or when the host
+// changes. It returns when the certificate should be checked again.
+func (r *WebsiteReconciler) reconcileSelfSignedCertificate(ctx context.Context, website *devv1.Website) (time.Duration, error) {
+	ingress := website.Spec.Ingress
+	if ingress == nil || ingress.TLS == nil || !ingress.TLS.SelfSigned {
+		return 0, nil
+	}
+
+	secretName := tlsSecretName(website)
+	certificate, err := r.tlsCertificate(ctx, types.NamespacedName{Name: secretName, Namespace: website.Namespace})
+	if err == nil && certificate.VerifyHostname(ingress.Host) == nil && time.Until(certificate.NotAfter) > selfSignedRenewBefore {
+		return time.Until(certificate.NotAfter.Add(-selfSignedRenewBefore)), nil
+	}
+
+	key, err := acme.NewKey()
+	if err != nil {
+		return 0, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: ingress.Host},
+		DNSNames:              []string{ingress.Host},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certificateDER, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return 0, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return 0, err
+	}
+
+	log.FromContext(ctx).Info("Generating self-signed certificate", "host", ingress.Host, "secret", secretName)
+	err = r.writeTLSSecret(ctx, website, secretName,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	if err != nil {
+		return 0, err
+	}
+	// In a dry run the Secret was not written, so nothing was issued.
+	if r.Recorder != nil && !r.dryRun() {
+		r.Recorder.Eventf(website, corev1.EventTypeNormal, "CertificateIssued", "Self-signed certificate for %s generated", ingress.Host)
+	}
+	return selfSignedValidity - selfSignedRenewBefore, nil
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+func TestReconcileSelfSignedCertificateDryRun(t *testing.T) {
+	website := &devv1.Website{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: devv1.WebsiteSpec{
+			ImageTag: "v1",
+			Ingress: &devv1.IngressSpec{
+				Host: "hello.example.com",
+				TLS:  &devv1.IngressTLSSpec{SelfSigned: true},
+			},
+		},
+	}
+	r := newTestReconciler(t, website)
+	r.Client = NewDryRunClient(r.Client)
+	recorder := record.NewFakeRecorder(1)
+	r.Recorder = recorder
+
+	if _, err := r.reconcileSelfSignedCertificate(context.Background(), website); err != nil {
+		t.Fatalf("reconcileSelfSignedCertificate() failed: %v", err)
+	}
+	select {
+	case event := <-recorder.Events:
+		t.Errorf("unexpected event in a dry run: %s", event)
+	default:
+	}
+}
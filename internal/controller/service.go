@@ -0,0 +1,301 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// reconcileServices creates or updates the regular, headless and metrics Services of a
+// website, and deletes the ones its spec no longer asks for.
+func (r *WebsiteReconciler) reconcileServices(ctx context.Context, website *devv1.Website) error {
+	headless := devv1.HeadlessNone
+	if website.Spec.Service != nil && website.Spec.Service.Headless != "" {
+		headless = website.Spec.Service.Headless
+	}
+
+	// Flagger owns the main Service of the websites it rolls out.
+	if !flaggerRollout(website) {
+		if err := r.reconcileService(ctx, newService(website), headless != devv1.HeadlessOnly); err != nil {
+			return err
+		}
+	}
+	if err := r.reconcileService(ctx, newHeadlessService(website), headless != devv1.HeadlessNone); err != nil {
+		return err
+	}
+	return r.reconcileService(ctx, newMetricsService(website), exporterEnabled(website))
+}
+
+// reconcileService creates the desired Service, or brings the fields the operator owns on
+// an existing Service back in line with it. When the Service is not wanted, it is deleted.
+func (r *WebsiteReconciler) reconcileService(ctx context.Context, desired *corev1.Service, wanted bool) error {
+	log := log.FromContext(ctx).WithValues("service", desired.Name)
+
+	// The Service is looked up before creating it: creating a Service that already exists
+	// fails on its node port allocation before the name conflict is detected.
+	service := corev1.Service{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &service)
+	if errors.IsNotFound(err) {
+		if !wanted {
+			return nil
+		}
+		if err := r.Client.Create(ctx, desired); err != nil {
+			log.Error(err, "Failed to create service", "action", "create")
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		log.Error(err, "Failed to retrieve service", "action", "get")
+		return err
+	}
+
+	if !wanted {
+		log.Info("Service is no longer wanted", "action", "delete")
+		if err := r.Client.Delete(ctx, &service); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete service", "action", "delete")
+			return err
+		}
+		return nil
+	}
+
+	// A merge patch replaces the port list as a whole, which is what we want as the
+	// operator owns every port of the Service.
+	patch := client.MergeFrom(service.DeepCopy())
+	if !syncService(&service, desired) {
+		return nil
+	}
+
+	log.Info("Service has changed", "action", "update")
+	if err := r.Client.Patch(ctx, &service, patch); err != nil {
+		log.Error(err, "Failed to update service", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// syncService copies every field the operator owns from the desired Service onto the
+// current one, and reports whether anything changed.
+func syncService(current, desired *corev1.Service) bool {
+	// Keep node ports the API server allocated for ports that do not ask for a specific one.
+	if desired.Spec.Type != corev1.ServiceTypeClusterIP {
+		allocated := map[string]int32{}
+		for _, port := range current.Spec.Ports {
+			allocated[port.Name] = port.NodePort
+		}
+		for i := range desired.Spec.Ports {
+			if desired.Spec.Ports[i].NodePort == 0 {
+				desired.Spec.Ports[i].NodePort = allocated[desired.Spec.Ports[i].Name]
+			}
+		}
+	}
+	if desired.Spec.HealthCheckNodePort == 0 && desired.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal &&
+		desired.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		desired.Spec.HealthCheckNodePort = current.Spec.HealthCheckNodePort
+	}
+
+	changed := syncLabels(&current.ObjectMeta, desired.Labels)
+	changed = syncAnnotations(&current.ObjectMeta, desired.Annotations) || changed
+	changed = syncField(&current.Spec.Ports, desired.Spec.Ports) || changed
+	changed = syncField(&current.Spec.Selector, desired.Spec.Selector) || changed
+	changed = syncField(&current.Spec.Type, desired.Spec.Type) || changed
+	changed = syncField(&current.Spec.SessionAffinity, desired.Spec.SessionAffinity) || changed
+	changed = syncField(&current.Spec.SessionAffinityConfig, desired.Spec.SessionAffinityConfig) || changed
+	changed = syncField(&current.Spec.ExternalTrafficPolicy, desired.Spec.ExternalTrafficPolicy) || changed
+	changed = syncField(&current.Spec.HealthCheckNodePort, desired.Spec.HealthCheckNodePort) || changed
+	changed = syncField(&current.Spec.InternalTrafficPolicy, desired.Spec.InternalTrafficPolicy) || changed
+	// IP families are defaulted by the cluster, so they are only reconciled when set.
+	if desired.Spec.IPFamilyPolicy != nil {
+		changed = syncField(&current.Spec.IPFamilyPolicy, desired.Spec.IPFamilyPolicy) || changed
+	}
+	if len(desired.Spec.IPFamilies) > 0 {
+		changed = syncField(&current.Spec.IPFamilies, desired.Spec.IPFamilies) || changed
+	}
+	return changed
+}
+
+// serviceName returns the name of the regular Service of a website.
+func serviceName(website *devv1.Website) string {
+	if website.Spec.ServiceName != "" {
+		return website.Spec.ServiceName
+	}
+	return resourceName(website)
+}
+
+func headlessServiceName(name string) string {
+	return fmt.Sprintf("%s-headless", name)
+}
+
+// Create a headless service, exposing the same ports as the regular service but
+// resolving directly to the website pods.
+func newHeadlessService(website *devv1.Website) *corev1.Service {
+	service := newService(website)
+	service.Name = headlessServiceName(serviceName(website))
+	service.Spec.Type = corev1.ServiceTypeClusterIP
+	service.Spec.ClusterIP = corev1.ClusterIPNone
+	for i := range service.Spec.Ports {
+		service.Spec.Ports[i].NodePort = 0
+	}
+	// Clients of a headless Service pick pods themselves, so there is nothing to stick to.
+	service.Spec.SessionAffinity = corev1.ServiceAffinityNone
+	service.Spec.SessionAffinityConfig = nil
+	service.Spec.ExternalTrafficPolicy = ""
+	service.Spec.HealthCheckNodePort = 0
+	return service
+}
+
+// websitePorts returns the ports of a website with their defaults applied.
+func websitePorts(website *devv1.Website) []devv1.WebsitePort {
+	if len(website.Spec.Ports) == 0 {
+		port := devv1.WebsitePort{Name: "http", ContainerPort: 80, ServicePort: 80, Protocol: corev1.ProtocolTCP}
+		if website.Spec.HostNetwork {
+			port.HostPort = port.ContainerPort
+		}
+		return withBackendTLSPort(website, []devv1.WebsitePort{port})
+	}
+
+	ports := make([]devv1.WebsitePort, 0, len(website.Spec.Ports)+1)
+	for _, port := range website.Spec.Ports {
+		if port.ServicePort == 0 {
+			port.ServicePort = port.ContainerPort
+		}
+		if port.Protocol == "" {
+			port.Protocol = corev1.ProtocolTCP
+		}
+		if website.Spec.HostNetwork {
+			port.HostPort = port.ContainerPort
+		}
+		ports = append(ports, port)
+	}
+	return withBackendTLSPort(website, ports)
+}
+
+// withBackendTLSPort appends the port nginx serves TLS on to the ports of a website, when
+// it serves TLS itself.
+func withBackendTLSPort(website *devv1.Website, ports []devv1.WebsitePort) []devv1.WebsitePort {
+	if website.Spec.BackendTLS == nil {
+		return ports
+	}
+	appProtocol := "https"
+	port := devv1.WebsitePort{
+		Name:          devv1.BackendTLSPortName,
+		ContainerPort: backendTLSPort(website.Spec.BackendTLS),
+		ServicePort:   backendTLSServicePort,
+		Protocol:      corev1.ProtocolTCP,
+		AppProtocol:   &appProtocol,
+	}
+	if website.Spec.HostNetwork {
+		port.HostPort = port.ContainerPort
+	}
+	return append(ports, port)
+}
+
+// Create a service with the correct field values. By creating this in a function,
+// it can be reused by all lifecycle functions (create, update, delete).
+func newService(website *devv1.Website) *corev1.Service {
+	name, namespace := website.Name, website.Namespace
+
+	serviceType := corev1.ServiceTypeNodePort
+	var externalTrafficPolicy corev1.ServiceExternalTrafficPolicyType
+	var healthCheckNodePort, nodePort int32
+	var annotations, labels map[string]string
+	internalTrafficPolicy := corev1.ServiceInternalTrafficPolicyCluster
+	var ipFamilyPolicy *corev1.IPFamilyPolicy
+	var ipFamilies []corev1.IPFamily
+	if spec := website.Spec.Service; spec != nil {
+		annotations = spec.Annotations
+		labels = spec.Labels
+		ipFamilyPolicy = spec.IPFamilyPolicy
+		ipFamilies = spec.IPFamilies
+		if spec.InternalTrafficPolicy != nil {
+			internalTrafficPolicy = *spec.InternalTrafficPolicy
+		}
+		if spec.Type != "" {
+			serviceType = spec.Type
+		}
+		externalTrafficPolicy = spec.ExternalTrafficPolicy
+		healthCheckNodePort = spec.HealthCheckNodePort
+		nodePort = spec.NodePort
+	}
+	if serviceType != corev1.ServiceTypeClusterIP && externalTrafficPolicy == "" {
+		externalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+	}
+
+	var ports []corev1.ServicePort
+	for i, port := range websitePorts(website) {
+		servicePort := corev1.ServicePort{
+			Name:        port.Name,
+			Protocol:    port.Protocol,
+			AppProtocol: port.AppProtocol,
+			Port:        port.ServicePort,
+			TargetPort:  intstr.FromInt(int(port.ContainerPort)),
+		}
+		if i == 0 && serviceType != corev1.ServiceTypeClusterIP {
+			servicePort.NodePort = nodePort
+		}
+		ports = append(ports, servicePort)
+	}
+
+	// The values below match what the API server defaults them to, so that an
+	// unconfigured website does not look different from its Service on every reconcile.
+	sessionAffinity := corev1.ServiceAffinityNone
+	var sessionAffinityConfig *corev1.SessionAffinityConfig
+	if spec := website.Spec.Service; spec != nil && spec.SessionAffinity == corev1.ServiceAffinityClientIP {
+		sessionAffinity = corev1.ServiceAffinityClientIP
+		timeout := int32(corev1.DefaultClientIPServiceAffinitySeconds)
+		if spec.SessionAffinityTimeoutSeconds != nil {
+			timeout = *spec.SessionAffinityTimeoutSeconds
+		}
+		sessionAffinityConfig = &corev1.SessionAffinityConfig{
+			ClientIP: &corev1.ClientIPConfig{TimeoutSeconds: &timeout},
+		}
+	}
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceName(website),
+			Namespace: namespace,
+			Labels:    withRecommendedLabels(setResourceLabels(name), website, componentServer),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports:                 ports,
+			Selector:              setResourceLabels(name),
+			Type:                  serviceType,
+			SessionAffinity:       sessionAffinity,
+			SessionAffinityConfig: sessionAffinityConfig,
+			ExternalTrafficPolicy: externalTrafficPolicy,
+			HealthCheckNodePort:   healthCheckNodePort,
+			InternalTrafficPolicy: &internalTrafficPolicy,
+			IPFamilyPolicy:        ipFamilyPolicy,
+			IPFamilies:            ipFamilies,
+		},
+	}
+	syncAnnotations(&service.ObjectMeta, annotations)
+	addUserLabels(&service.ObjectMeta, labels)
+	return service
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// Sharder decides which Websites this replica reconciles when several replicas are active.
+type Sharder interface {
+	// Owns reports whether this replica is responsible for the given Website.
+	Owns(key types.NamespacedName) bool
+	// Changes is signalled whenever the set of owned Websites may have changed.
+	Changes() <-chan struct{}
+}
+
+// resyncOnShardChange enqueues every Website each time shard ownership changes, so a
+// replica picks up the Websites it has just become responsible for.
+func (r *WebsiteReconciler) resyncOnShardChange(ctx context.Context, events chan<- event.GenericEvent) error {
+	log := log.FromContext(ctx).WithName("sharding")
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.Sharder.Changes():
+		}
+
+		websites := devv1.WebsiteList{}
+		if err := r.Client.List(ctx, &websites); err != nil {
+			log.Error(err, "Failed to list websites after a shard change")
+			continue
+		}
+		for i := range websites.Items {
+			if !r.Sharder.Owns(types.NamespacedName{Name: websites.Items[i].Name, Namespace: websites.Items[i].Namespace}) {
+				continue
+			}
+			select {
+			case events <- event.GenericEvent{Object: &websites.Items[i]}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// snapshotIndex indexes Websites by the WebsiteSnapshot they are restored from
+const snapshotIndex = "website.fromSnapshot"
+
+// withSnapshot returns the website as captured by the snapshot it is restored from, or
+// the website itself when it is not restored from a snapshot.
+func (r *WebsiteReconciler) withSnapshot(ctx context.Context, website *devv1.Website) (*devv1.Website, error) {
+	name := website.Spec.FromSnapshot
+	if name == "" {
+		return website, nil
+	}
+
+	snapshot := devv1.WebsiteSnapshot{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: website.Namespace}, &snapshot); err != nil {
+		return nil, fmt.Errorf("website snapshot %q: %w", name, err)
+	}
+	if snapshot.Status.CapturedAt == nil || snapshot.Status.WebsiteSpec == nil {
+		return nil, fmt.Errorf("website snapshot %q has not been captured", name)
+	}
+
+	restored := website.DeepCopy()
+	restored.Spec = *snapshot.Status.WebsiteSpec.DeepCopy()
+	restored.Spec.FromSnapshot = name
+	restored.Spec.ImageDigest = snapshot.Status.ImageDigest
+	if snapshot.Status.Content != nil {
+		restored.Spec.Content = snapshot.Status.Content.DeepCopy()
+	}
+	return restored, nil
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// managedAnnotationsKey records which annotations of a generated object were set by the
+// operator from the Website spec, so that they can be removed again once dropped from the
+// spec without touching annotations added by anyone else.
+const managedAnnotationsKey = "dev.mvasilenko.me/managed-annotations"
+
+// syncField sets current to desired when they differ, and reports whether it did.
+func syncField[T any](current *T, desired T) bool {
+	if equality.Semantic.DeepEqual(*current, desired) {
+		return false
+	}
+	*current = desired
+	return true
+}
+
+// syncAnnotation sets or removes a single annotation so that it matches the desired
+// annotations, leaving every other annotation alone.
+func syncAnnotation(current *metav1.ObjectMeta, desired map[string]string, key string) bool {
+	value, wanted := desired[key]
+	existing, present := current.Annotations[key]
+	switch {
+	case wanted && (!present || existing != value):
+		if current.Annotations == nil {
+			current.Annotations = map[string]string{}
+		}
+		current.Annotations[key] = value
+		return true
+	case !wanted && present:
+		delete(current.Annotations, key)
+		return true
+	}
+	return false
+}
+
+// syncAnnotations makes the operator-managed annotations of an object match the desired
+// ones: desired annotations are set, and annotations the operator set previously but that
+// are no longer desired are removed.
+func syncAnnotations(current *metav1.ObjectMeta, desired map[string]string) bool {
+	changed := false
+	for _, key := range strings.Split(current.Annotations[managedAnnotationsKey], ",") {
+		if _, wanted := desired[key]; key != "" && !wanted {
+			changed = syncAnnotation(current, desired, key) || changed
+		}
+	}
+
+	keys := make([]string, 0, len(desired))
+	for key := range desired {
+		if key == managedAnnotationsKey {
+			continue
+		}
+		keys = append(keys, key)
+		changed = syncAnnotation(current, desired, key) || changed
+	}
+	sort.Strings(keys)
+	managed := map[string]string{}
+	if len(keys) > 0 {
+		managed[managedAnnotationsKey] = strings.Join(keys, ",")
+	}
+	return syncAnnotation(current, managed, managedAnnotationsKey) || changed
+}
+
+// syncAnnotationPrefix makes the annotations under a prefix the operator owns match the
+// desired ones, leaving every other annotation alone.
+func syncAnnotationPrefix(current *metav1.ObjectMeta, desired map[string]string, prefix string) bool {
+	changed := false
+	for key := range current.Annotations {
+		if _, wanted := desired[key]; strings.HasPrefix(key, prefix) && !wanted {
+			delete(current.Annotations, key)
+			changed = true
+		}
+	}
+	for key := range desired {
+		if strings.HasPrefix(key, prefix) {
+			changed = syncAnnotation(current, desired, key) || changed
+		}
+	}
+	return changed
+}
+
+// syncSidecars makes the containers after the first one, which are all generated by the
+// operator, match the desired ones.
+func syncSidecars(current *[]corev1.Container, desired []corev1.Container) bool {
+	if equality.Semantic.DeepEqual((*current)[1:], desired[1:]) {
+		return false
+	}
+	*current = append((*current)[:1], desired[1:]...)
+	return true
+}
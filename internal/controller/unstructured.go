@@ -0,0 +1,116 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// specChecksumAnnotation holds a checksum of the spec the operator last wrote to a
+// resource it does not have the Go types of. The controller of that resource defaults
+// fields in its spec, so comparing the specs themselves would never settle.
+const specChecksumAnnotation = "dev.mvasilenko.me/spec-checksum"
+
+// reconcileUnstructured creates a desired resource the operator does not have the Go
+// types of, or replaces the spec of an existing one when the website asks for a different
+// one. The desired resource must carry the checksum of its spec.
+func (r *WebsiteReconciler) reconcileUnstructured(ctx context.Context, desired *unstructured.Unstructured) error {
+	log := log.FromContext(ctx).WithValues("kind", desired.GetKind(), "name", desired.GetName())
+
+	err := r.Client.Create(ctx, desired)
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create resource", "action", "create")
+		return err
+	}
+
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(desired.GroupVersionKind())
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Name: desired.GetName(), Namespace: desired.GetNamespace()}, current); err != nil {
+		log.Error(err, "Failed to retrieve resource", "action", "get")
+		return err
+	}
+	patch := client.MergeFrom(current.DeepCopy())
+	meta := metav1.ObjectMeta{Labels: current.GetLabels()}
+	labelsChanged := syncLabels(&meta, desired.GetLabels())
+	if !labelsChanged && current.GetAnnotations()[specChecksumAnnotation] == desired.GetAnnotations()[specChecksumAnnotation] {
+		return nil
+	}
+
+	log.Info("Resource has changed", "action", "update")
+	current.SetLabels(meta.Labels)
+	annotations := current.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[specChecksumAnnotation] = desired.GetAnnotations()[specChecksumAnnotation]
+	current.SetAnnotations(annotations)
+	current.Object["spec"] = desired.Object["spec"]
+	if err := r.Client.Patch(ctx, current, patch); err != nil {
+		log.Error(err, "Failed to update resource", "action", "update")
+		return err
+	}
+	return nil
+}
+
+// deleteUnstructured deletes a resource the operator does not have the Go types of. A
+// resource that is already gone, or whose CRD is not installed, is not an error.
+func (r *WebsiteReconciler) deleteUnstructured(ctx context.Context, gvk schema.GroupVersionKind, name types.NamespacedName) error {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName(name.Name)
+	obj.SetNamespace(name.Namespace)
+	err := r.Client.Delete(ctx, obj)
+	if err == nil {
+		log.FromContext(ctx).Info("Resource is no longer wanted", "action", "delete", "kind", gvk.Kind, "name", name.Name)
+		return nil
+	}
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil
+	}
+	log.FromContext(ctx).Error(err, "Failed to delete resource", "action", "delete", "kind", gvk.Kind, "name", name.Name)
+	return err
+}
+
+// newUnstructured creates a resource the operator does not have the Go types of, annotated
+// with the checksum of its spec.
+func newUnstructured(gvk schema.GroupVersionKind, name types.NamespacedName, labels map[string]string, spec map[string]interface{}) (*unstructured.Unstructured, error) {
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName(name.Name)
+	obj.SetNamespace(name.Namespace)
+	obj.SetLabels(labels)
+	obj.SetAnnotations(map[string]string{specChecksumAnnotation: checksum(map[string][]byte{"spec": encoded})})
+	return obj, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// websiteURL returns the URL a website is reachable at, for its status. Websites exposed on
+// host ports are addressed through the node of the given pod, and have no URL until one
+// of their pods is running. Websites with a LoadBalancer Service are addressed through the
+// given load balancer address once it is provisioned.
+func websiteURL(website *devv1.Website, pod *corev1.Pod, address string) string {
+	if ingress := website.Spec.Ingress; ingress != nil {
+		scheme := "http"
+		if ingress.TLS != nil {
+			scheme = "https"
+		}
+		path := ingress.Path
+		if path == "/" {
+			path = ""
+		}
+		return fmt.Sprintf("%s://%s%s", scheme, ingress.Host, path)
+	}
+
+	ports := websitePorts(website)
+	for _, port := range ports {
+		if port.HostPort == 0 || port.Protocol != corev1.ProtocolTCP {
+			continue
+		}
+		if pod == nil || pod.Status.HostIP == "" {
+			return ""
+		}
+		return portURL(port, net.JoinHostPort(pod.Status.HostIP, strconv.Itoa(int(port.HostPort))))
+	}
+
+	if address != "" {
+		return portURL(ports[0], net.JoinHostPort(address, strconv.Itoa(int(ports[0].ServicePort))))
+	}
+
+	name := serviceName(website)
+	if website.Spec.Service != nil && website.Spec.Service.Headless == devv1.HeadlessOnly {
+		name = headlessServiceName(name)
+	}
+	host := fmt.Sprintf("%s.%s.svc", name, website.Namespace)
+	return portURL(ports[0], net.JoinHostPort(host, strconv.Itoa(int(ports[0].ServicePort))))
+}
+
+// portURL returns the URL of a host and port serving a website port.
+func portURL(port devv1.WebsitePort, hostPort string) string {
+	scheme := "http"
+	if port.AppProtocol != nil && *port.AppProtocol == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, hostPort)
+}
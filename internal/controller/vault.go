@@ -0,0 +1,130 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// vaultAnnotationPrefix prefixes the pod annotations read by the Vault Agent injector
+	vaultAnnotationPrefix = "vault.hashicorp.com/"
+
+	defaultVaultMountPath = "/vault/secrets"
+	defaultVaultAuthPath  = "auth/kubernetes"
+	defaultVaultImage     = "hashicorp/vault:1.15"
+
+	vaultContainerName = "vault-agent"
+	vaultVolumeName    = "vault-secrets"
+)
+
+// vaultTemplate returns the template rendering a Vault secret, defaulting to the one the
+// Vault Agent injector uses.
+func vaultTemplate(secret devv1.VaultSecret) string {
+	if secret.Template != "" {
+		return secret.Template
+	}
+	return fmt.Sprintf("{{ with secret %q }}{{ range $k, $v := .Data }}{{ $k }}: {{ $v }}\n{{ end }}{{ end }}", secret.Path)
+}
+
+// vaultAnnotations returns the pod annotations asking the Vault Agent injector to render
+// the secrets of a website.
+func vaultAnnotations(vault *devv1.VaultSpec) map[string]string {
+	annotations := map[string]string{
+		vaultAnnotationPrefix + "agent-inject": "true",
+		vaultAnnotationPrefix + "role":         vault.Role,
+	}
+	if vault.Address != "" {
+		annotations[vaultAnnotationPrefix+"service"] = vault.Address
+	}
+	if vault.AuthPath != "" {
+		annotations[vaultAnnotationPrefix+"auth-path"] = vault.AuthPath
+	}
+	if vault.MountPath != "" {
+		annotations[vaultAnnotationPrefix+"secret-volume-path"] = vault.MountPath
+	}
+	for _, secret := range vault.Secrets {
+		annotations[vaultAnnotationPrefix+"agent-inject-secret-"+secret.Name] = secret.Path
+		if secret.Template != "" {
+			annotations[vaultAnnotationPrefix+"agent-inject-template-"+secret.Name] = secret.Template
+		}
+	}
+	return annotations
+}
+
+// vaultAgentConfig renders the configuration of the Vault Agent sidecar.
+func vaultAgentConfig(vault *devv1.VaultSpec) string {
+	authPath := vault.AuthPath
+	if authPath == "" {
+		authPath = defaultVaultAuthPath
+	}
+
+	var config strings.Builder
+	fmt.Fprintf(&config, "vault {\n  address = %s\n}\n", strconv.Quote(vault.Address))
+	fmt.Fprintf(&config, "auto_auth {\n  method \"kubernetes\" {\n    mount_path = %s\n    config = {\n      role = %s\n    }\n  }\n}\n",
+		strconv.Quote(authPath), strconv.Quote(vault.Role))
+	for _, secret := range vault.Secrets {
+		fmt.Fprintf(&config, "template {\n  destination = %s\n  contents = %s\n}\n",
+			strconv.Quote(defaultVaultMountPath+"/"+secret.Name), strconv.Quote(vaultTemplate(secret)))
+	}
+	return config.String()
+}
+
+// vaultSidecar returns the Vault Agent sidecar of a website, the in-memory volume it
+// renders secrets into, and the mount of that volume in the website container.
+func vaultSidecar(vault *devv1.VaultSpec) (corev1.Container, corev1.Volume, corev1.VolumeMount) {
+	image := vault.Image
+	if image == "" {
+		image = defaultVaultImage
+	}
+	mountPath := vault.MountPath
+	if mountPath == "" {
+		mountPath = defaultVaultMountPath
+	}
+
+	// The configuration is passed through the environment rather than a ConfigMap, so
+	// that changing it changes the pod template and rolls the pods, as the agent does
+	// not reload it. Fields the API server would default are set explicitly so that the
+	// generated container does not differ from the one in the cluster.
+	container := corev1.Container{
+		Name:    vaultContainerName,
+		Image:   image,
+		Command: []string{"/bin/sh", "-c", `printenv VAULT_AGENT_CONFIG > /tmp/agent.hcl && exec vault agent -config=/tmp/agent.hcl`},
+		Env: []corev1.EnvVar{
+			{Name: "VAULT_AGENT_CONFIG", Value: vaultAgentConfig(vault)},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: vaultVolumeName, MountPath: defaultVaultMountPath},
+		},
+		TerminationMessagePath:   corev1.TerminationMessagePathDefault,
+		TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+		ImagePullPolicy:          corev1.PullIfNotPresent,
+	}
+	volume := corev1.Volume{
+		Name: vaultVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory},
+		},
+	}
+	return container, volume, corev1.VolumeMount{Name: vaultVolumeName, MountPath: mountPath, ReadOnly: true}
+}
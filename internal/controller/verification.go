@@ -0,0 +1,175 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+	"github.com/mvasilenko/helloworld-operator/internal/cosign"
+)
+
+// verificationRetryInterval is how often an image failing verification is verified again,
+// in case a signature was pushed since.
+const verificationRetryInterval = 5 * time.Minute
+
+// verifyImage checks the cosign signature of the image of the desired deployment, and pins
+// it to the digest verified, so that the tag cannot be moved to another image afterwards.
+// An image failing verification is replaced by the one running, so that it is not rolled
+// out. It reports false when no image can be rolled out at all, and returns when a failed
+// verification should be retried.
+func (r *WebsiteReconciler) verifyImage(ctx context.Context, website *devv1.Website, desired *appsv1.Deployment) (time.Duration, bool, error) {
+	log := log.FromContext(ctx)
+
+	spec := website.Spec.ImageVerification
+	if spec == nil {
+		return 0, true, r.setCondition(ctx, website, devv1.ConditionImageVerified, nil)
+	}
+
+	image := desired.Spec.Template.Spec.Containers[0].Image
+	pinned, err := r.verifySignature(ctx, image, spec)
+	if err == nil {
+		desired.Spec.Template.Spec.Containers[0].Image = pinned
+		return 0, true, r.setCondition(ctx, website, devv1.ConditionImageVerified, &metav1.Condition{
+			Type:    devv1.ConditionImageVerified,
+			Status:  metav1.ConditionTrue,
+			Reason:  devv1.ReasonVerified,
+			Message: fmt.Sprintf("%s carries a valid signature", image),
+		})
+	}
+
+	log.Info("Refusing to roll out image failing verification", "image", image, "reason", err.Error())
+	if err := r.setCondition(ctx, website, devv1.ConditionImageVerified, &metav1.Condition{
+		Type:    devv1.ConditionImageVerified,
+		Status:  metav1.ConditionFalse,
+		Reason:  devv1.ReasonVerificationFailed,
+		Message: err.Error(),
+	}); err != nil {
+		return 0, false, err
+	}
+
+	ok, err := r.holdImage(ctx, desired)
+	return verificationRetryInterval, ok, err
+}
+
+// holdImage keeps the image running on the deployment of a website in the desired
+// deployment, so that a new image is not rolled out. It reports false when nothing runs yet.
+func (r *WebsiteReconciler) holdImage(ctx context.Context, desired *appsv1.Deployment) (bool, error) {
+	name := types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}
+	current := appsv1.Deployment{}
+	err := r.Client.Get(ctx, name, &current)
+	if errors.IsNotFound(err) {
+		// Websites run by a DaemonSet hold their image on it instead.
+		daemonSet := appsv1.DaemonSet{}
+		if err := r.Client.Get(ctx, name, &daemonSet); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		current.Spec.Template = daemonSet.Spec.Template
+		err = nil
+	}
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to retrieve deployment", "action", "get")
+		return false, err
+	}
+	desired.Spec.Template.Spec.Containers[0].Image = current.Spec.Template.Spec.Containers[0].Image
+	return true, nil
+}
+
+// verifySignature verifies the signature of an image and returns the image pinned to the
+// digest verified. The digests verified against each verification spec are remembered, so
+// that their signatures are not fetched on every reconcile, while a tag moved to another
+// image is verified again.
+func (r *WebsiteReconciler) verifySignature(ctx context.Context, image string, spec *devv1.ImageVerificationSpec) (string, error) {
+	key, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+
+	opts := cosign.Options{Roots: r.SigstoreRoots}
+	if spec.PublicKey != "" {
+		opts.PublicKey = []byte(spec.PublicKey)
+	}
+	if keyless := spec.Keyless; keyless != nil {
+		opts.Identity = &cosign.Identity{Issuer: keyless.Issuer, Subject: keyless.Subject}
+	}
+	if r.RegistryCredentials.Name != "" {
+		credentials := corev1.Secret{}
+		if err := r.APIReader.Get(ctx, r.RegistryCredentials, &credentials); err != nil {
+			return "", err
+		}
+		opts.Username, opts.Password, _ = registryLogin(&credentials, image)
+	}
+
+	digest, err := cosign.Digest(ctx, image, opts.Username, opts.Password)
+	if err != nil {
+		return "", err
+	}
+	pinned := pinImage(image, digest)
+	cacheKey := pinned + "\x00" + string(key)
+	if _, ok := r.verifiedImages.Load(cacheKey); ok {
+		return pinned, nil
+	}
+	if _, err := cosign.Verify(ctx, pinned, opts); err != nil {
+		return "", err
+	}
+	r.verifiedImages.Store(cacheKey, true)
+	return pinned, nil
+}
+
+// pinImage pins an image reference to a digest. Its tag is kept, as the version of the
+// website, but no longer decides which image runs.
+func pinImage(image, digest string) string {
+	name, _, _ := strings.Cut(image, "@")
+	return name + "@" + digest
+}
+
+// setCondition sets a condition of the website status, or removes the condition of the
+// given type when nil.
+func (r *WebsiteReconciler) setCondition(ctx context.Context, website *devv1.Website, conditionType string, condition *metav1.Condition) error {
+	conditions := append([]metav1.Condition{}, website.Status.Conditions...)
+	if condition == nil {
+		meta.RemoveStatusCondition(&conditions, conditionType)
+	} else {
+		condition.ObservedGeneration = website.Generation
+		meta.SetStatusCondition(&conditions, *condition)
+	}
+	if equality.Semantic.DeepEqual(website.Status.Conditions, conditions) {
+		return nil
+	}
+
+	patch := client.MergeFrom(website.DeepCopy())
+	website.Status.Conditions = conditions
+	if err := r.Client.Status().Patch(ctx, website, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update website status", "action", "update")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import "testing"
+
+func TestPinImage(t *testing.T) {
+	for image, want := range map[string]string{
+		"nginx:1.25":                "nginx:1.25@sha256:b",
+		"ghcr.io/org/site@sha256:a": "ghcr.io/org/site@sha256:b",
+	} {
+		if got := pinImage(image, "sha256:b"); got != want {
+			t.Errorf("pinImage(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	cacheVolumeName = "cache"
+
+	serviceAccountTokenVolumeName       = "service-account-token"
+	defaultServiceAccountTokenMountPath = "/var/run/secrets/tokens"
+	defaultServiceAccountTokenExpiry    = int64(3600)
+)
+
+// cacheMountPaths are the paths nginx writes to at run time, by subdirectory of the cache
+// volume: its cache and temporary files, its pid file, and the usual scratch space.
+var cacheMountPaths = []struct{ subPath, mountPath string }{
+	{"cache", "/var/cache/nginx"},
+	{"run", "/var/run"},
+	{"tmp", "/tmp"},
+}
+
+// cacheVolume returns the emptyDir volume nginx writes to and its mounts, if the website
+// asks for one or cannot write to its root filesystem.
+func cacheVolume(website *devv1.Website) (*corev1.Volume, []corev1.VolumeMount) {
+	spec := website.Spec.CacheVolume
+	if spec == nil && !readOnlyRootFilesystem(website) {
+		return nil, nil
+	}
+	if spec == nil {
+		spec = &devv1.CacheVolumeSpec{}
+	}
+	volume := &corev1.Volume{
+		Name: cacheVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{Medium: spec.Medium, SizeLimit: spec.SizeLimit},
+		},
+	}
+	var mounts []corev1.VolumeMount
+	for _, path := range cacheMountPaths {
+		mounts = append(mounts, corev1.VolumeMount{Name: cacheVolumeName, MountPath: path.mountPath, SubPath: path.subPath})
+	}
+	return volume, mounts
+}
+
+// serviceAccountTokenVolume returns the projected volume holding a service account token
+// for the audience of the website and its mount, if the website asks for one.
+func serviceAccountTokenVolume(website *devv1.Website) (*corev1.Volume, *corev1.VolumeMount) {
+	spec := website.Spec.ServiceAccountToken
+	if spec == nil || spec.Audience == "" {
+		return nil, nil
+	}
+	expiration := defaultServiceAccountTokenExpiry
+	if spec.ExpirationSeconds != nil {
+		expiration = *spec.ExpirationSeconds
+	}
+	mountPath := spec.MountPath
+	if mountPath == "" {
+		mountPath = defaultServiceAccountTokenMountPath
+	}
+
+	volume := &corev1.Volume{
+		Name: serviceAccountTokenVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{{
+					ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+						Audience:          spec.Audience,
+						ExpirationSeconds: &expiration,
+						Path:              "token",
+					},
+				}},
+				DefaultMode: pointer.Int32(corev1.ProjectedVolumeSourceDefaultMode),
+			},
+		},
+	}
+	return volume, &corev1.VolumeMount{Name: serviceAccountTokenVolumeName, MountPath: mountPath, ReadOnly: true}
+}
+
+// automountServiceAccountToken returns whether the API token of the service account is
+// mounted into the website pods, nil leaving it to the service account.
+func automountServiceAccountToken(website *devv1.Website) *bool {
+	if website.Spec.ServiceAccountToken == nil {
+		return nil
+	}
+	return website.Spec.ServiceAccountToken.Automount
+}
+
+// websiteVolumes returns the volumes the website spec adds to its pods, with the fields the
+// API server would default set explicitly, so that they do not differ from the ones in
+// the cluster.
+func websiteVolumes(website *devv1.Website) []corev1.Volume {
+	var volumes []corev1.Volume
+	for _, volume := range website.Spec.Volumes {
+		volume := *volume.DeepCopy()
+		source := &volume.VolumeSource
+		switch {
+		case source.Secret != nil && source.Secret.DefaultMode == nil:
+			source.Secret.DefaultMode = pointer.Int32(corev1.SecretVolumeSourceDefaultMode)
+		case source.ConfigMap != nil && source.ConfigMap.DefaultMode == nil:
+			source.ConfigMap.DefaultMode = pointer.Int32(corev1.ConfigMapVolumeSourceDefaultMode)
+		case source.DownwardAPI != nil && source.DownwardAPI.DefaultMode == nil:
+			source.DownwardAPI.DefaultMode = pointer.Int32(corev1.DownwardAPIVolumeSourceDefaultMode)
+		case source.Projected != nil:
+			if source.Projected.DefaultMode == nil {
+				source.Projected.DefaultMode = pointer.Int32(corev1.ProjectedVolumeSourceDefaultMode)
+			}
+			for _, projection := range source.Projected.Sources {
+				if token := projection.ServiceAccountToken; token != nil && token.ExpirationSeconds == nil {
+					token.ExpirationSeconds = pointer.Int64(defaultServiceAccountTokenExpiry)
+				}
+			}
+		case source.HostPath != nil && source.HostPath.Type == nil:
+			hostPathType := corev1.HostPathUnset
+			source.HostPath.Type = &hostPathType
+		case source.ISCSI != nil && source.ISCSI.ISCSIInterface == "":
+			source.ISCSI.ISCSIInterface = "default"
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes
+}
+
+// addVolumeMounts adds the volume mounts of the website spec to the mounts of the website
+// container and to the sidecars.
+func addVolumeMounts(website *devv1.Website, mounts *[]corev1.VolumeMount, sidecars []corev1.Container) {
+	for _, mount := range website.Spec.VolumeMounts {
+		if mount.Container == "" || mount.Container == "nginx" {
+			*mounts = append(*mounts, mount.VolumeMount)
+			continue
+		}
+		for i := range sidecars {
+			if sidecars[i].Name == mount.Container {
+				sidecars[i].VolumeMounts = append(sidecars[i].VolumeMounts, mount.VolumeMount)
+			}
+		}
+	}
+}
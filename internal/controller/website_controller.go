@@ -18,20 +18,34 @@ package controller
 
 import (
 	"context"
-	"fmt"
-	"strings"
+	"crypto/x509"
+	"sync"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	//"k8s.io/apiextensions-apiserver/pkg/registry/customresource"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
 )
@@ -40,13 +54,92 @@ import (
 type WebsiteReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// APIReader reads objects the scoped cache does not hold, such as user-owned ConfigMaps.
+	APIReader client.Reader
+
+	// Sharder, when set, limits this replica to the Websites of its shard.
+	Sharder Sharder
+
+	// Namespace, when set, is the only namespace the operator watches. The operator then
+	// has no cluster-wide permissions and leaves out the cluster-scoped WebsiteClasses and
+	// Nodes.
+	Namespace string
+
+	// RateLimiter, when set, replaces the default rate limiter of the controller workqueue.
+	RateLimiter ratelimiter.RateLimiter
+
+	// RegistryCredentials, when set, names a docker config Secret copied into the namespace
+	// of every Website whose image comes from one of its registries.
+	RegistryCredentials types.NamespacedName
+
+	// RegistryMirrors map upstream registries to the mirrors the images of Websites are
+	// pulled from instead, such as docker.io to mirror.example.com/dockerhub.
+	RegistryMirrors map[string]string
+
+	// HTTPProxy, HTTPSProxy and NoProxy, when set, are passed to every container of the
+	// pods generated for Websites in the proxy environment variables.
+	HTTPProxy, HTTPSProxy, NoProxy string
+
+	// SigstoreRoots are the certificate authorities trusted to issue keyless image signing
+	// certificates.
+	SigstoreRoots *x509.CertPool
+
+	// Recorder, when set, emits the events of Websites.
+	Recorder record.EventRecorder
+
+	// PrometheusURL is the address of the Prometheus server rollout analyses query by
+	// default.
+	PrometheusURL string
+
+	// CertificateExpiryWarning is how long before its expiry the serving certificate of a
+	// Website is reported as expiring.
+	CertificateExpiryWarning time.Duration
+
+	// BlackboxExporterURL is the host and port of the blackbox exporter probing the URL of
+	// monitored Websites by default.
+	BlackboxExporterURL string
+
+	// MaxFailures is how many reconciliations of a Website may fail in a row before it is
+	// marked Failed, and FailedRetryInterval how often it is retried from then on.
+	MaxFailures         int
+	FailedRetryInterval time.Duration
+
+	clusters       *clusterClients
+	verifiedImages sync.Map
+	failures       failureTracker
+
+	// remoteCluster is set on the reconcilers of the remote clusters of a Website, where
+	// the Website itself does not exist.
+	remoteCluster bool
 }
 
 //+kubebuilder:rbac:groups=dev.mvasilenko.me,resources=websites,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=dev.mvasilenko.me,resources=websites/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=dev.mvasilenko.me,resources=websites/finalizers,verbs=update
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=dev.mvasilenko.me,resources=websiteclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups=dev.mvasilenko.me,resources=websitesnapshots,verbs=get;list;watch
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups=external-secrets.io,resources=externalsecrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=argoproj.io,resources=rollouts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=flagger.app,resources=canaries,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=monitoring.coreos.com,resources=probes,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=externaldns.k8s.io,resources=dnsendpoints,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=autoscaling.k8s.io,resources=verticalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -58,7 +151,19 @@ type WebsiteReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.4/pkg/reconcile
 func (r *WebsiteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := log.FromContext(ctx)
+	log := ctrllog.FromContext(ctx).WithValues("website", req.Name, "namespace", req.Namespace)
+
+	if r.Sharder != nil && !r.Sharder.Owns(req.NamespacedName) {
+		log.V(1).Info("Website belongs to another shard")
+		return ctrl.Result{}, nil
+	}
+
+	result, err := r.reconcile(ctrllog.IntoContext(ctx, log), req)
+	return r.handleResult(ctrllog.IntoContext(ctx, log), req.NamespacedName, result, err)
+}
+
+func (r *WebsiteReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
 
 	// Start by declaring the custom resource to be type "Website"
 	customResource := &devv1.Website{}
@@ -70,136 +175,325 @@ func (r *WebsiteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// TODO: handle deletes gracefully
-			log.Info(fmt.Sprintf(`Custom resource for website "%s" does not exist`, req.Name))
+			log.Info("Custom resource for website does not exist")
+			deleteWebsiteMetrics(req.NamespacedName)
 			return ctrl.Result{}, nil
 		} else {
-			log.Error(err, fmt.Sprintf(`Failed to retrieve custom resource "%s"`, req.Name))
+			log.Error(err, "Failed to retrieve custom resource")
 			return ctrl.Result{}, err
 		}
 	}
 
-	// Use the `ImageTag` field from the website spec to personalise the log
-	log.Info(fmt.Sprintf(`Hello from your new website reconciler with tag "%s"!`, customResource.Spec.ImageTag))
+	// Every log line from here on, including those of the helpers below, carries
+	// the generation being reconciled.
+	log = log.WithValues("generation", customResource.Generation)
+	ctx = ctrllog.IntoContext(ctx, log)
+
+	if customResource.DeletionTimestamp != nil && controllerutil.ContainsFinalizer(customResource, dnsRecordsFinalizer) {
+		return ctrl.Result{}, r.finalizeDNSRecords(ctx, customResource)
+	}
+
+	if customResource.Annotations[devv1.PausedAnnotation] == "true" {
+		log.Info("Reconciliation is paused")
+		return ctrl.Result{}, nil
+	}
+
+	log.V(1).Info("Reconciling website", "imageTag", customResource.Spec.ImageTag)
+
+	// A website with environments only stands for the Websites deploying them.
+	if len(customResource.Spec.Environments) > 0 {
+		return ctrl.Result{}, r.reconcileEnvironments(ctx, customResource)
+	}
 
-	err = r.Client.Create(ctx, newDeployment(customResource.Name, customResource.Namespace, customResource.Spec.ImageTag))
+	customResource, err = r.withSnapshot(ctx, customResource)
 	if err != nil {
-		if errors.IsAlreadyExists(err) {
-			log.Info(fmt.Sprintf(`Deployment for website "%s" already exists"`, customResource.Name))
-			// Retrieve the current deployment for this website
-			deploymentNamespacedName := types.NamespacedName{
-				Name:      customResource.Name,
-				Namespace: customResource.Namespace,
-			}
-			deployment := appsv1.Deployment{}
-			r.Client.Get(ctx, deploymentNamespacedName, &deployment)
-			// Update can be based on any or all fields of the resource. In this simple operator, only
-			// the imageTag field which is being provided by the custom resource will be validated.
-			currentImage := deployment.Spec.Template.Spec.Containers[0].Image
-			desiredImage := fmt.Sprintf("abangser/todo-local-storage:%s", customResource.Spec.ImageTag)
-			if currentImage != desiredImage {
-				log.Info(fmt.Sprintf(`Image tag has updated from "%s" to "%s"`, currentImage, desiredImage))
-
-				// This operator only cares about the one field, it does not want
-				// to alter any other changes that may be acceptable. Therefore,
-				// this update will only patch the single field!
-				patch := client.StrategicMergeFrom(deployment.DeepCopy())
-				deployment.Spec.Template.Spec.Containers[0].Image = desiredImage
-				patch.Data(&deployment)
-
-				// Try and apply this patch, if it fails, return the failure
-				err := r.Client.Patch(ctx, &deployment, patch)
-				if err != nil {
-					log.Error(err, fmt.Sprintf(`Failed to update deployment for website "%s"`, customResource.Name))
-					return ctrl.Result{}, err
-				}
-			}
-		} else {
-			log.Error(err, fmt.Sprintf(`Failed to create deployment for website "%s"`, customResource.Name))
-			return ctrl.Result{}, err
-		}
+		log.Error(err, "Failed to restore website from snapshot", "action", "get")
+		return ctrl.Result{}, err
 	}
 
-	err = r.Client.Create(ctx, newService(customResource.Name, customResource.Namespace))
+	class, err := r.websiteClass(ctx, customResource)
 	if err != nil {
-		if errors.IsInvalid(err) && strings.Contains(err.Error(), "provided port is already allocated") {
-			log.Info(fmt.Sprintf(`Service for website "%s" already exists`, customResource.Name))
-			// TODO: handle service updates gracefully
-		} else {
-			log.Error(err, fmt.Sprintf(`Failed to create service for website "%s"`, customResource.Name))
+		log.Error(err, "Failed to retrieve website class", "action", "get")
+		return ctrl.Result{}, err
+	}
+	// Everything below works on the website with the defaults of its class applied.
+	customResource = withClassDefaults(customResource, class)
+
+	tagRetryAfter, err := r.resolveImageTag(ctx, customResource)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	customResource = withResolvedImage(customResource)
+
+	if err := r.labelReferences(ctx, customResource); err != nil {
+		log.Error(err, "Failed to label referenced objects", "action", "update")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileExternalSecrets(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileBuild(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	desired, err := r.desiredDeployment(ctx, customResource)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	retryAfter, ok, err := r.verifyImage(ctx, customResource, desired)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	retryAfter = soonest(retryAfter, tagRetryAfter)
+	if ok {
+		var platformsRetryAfter time.Duration
+		platformsRetryAfter, ok, err = r.verifyPlatforms(ctx, customResource, desired)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		retryAfter = soonest(retryAfter, platformsRetryAfter)
+	}
+	if ok {
+		ok, err = r.scanImage(ctx, customResource, desired)
+		if err != nil {
 			return ctrl.Result{}, err
 		}
 	}
+	if !ok {
+		// Nothing can be rolled out until the image passes its checks.
+		return ctrl.Result{RequeueAfter: retryAfter}, r.reconcileStatus(ctx, customResource)
+	}
+	gateRetryAfter, err := r.gateRollout(ctx, customResource, desired)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	retryAfter = soonest(retryAfter, gateRetryAfter)
+	analysisRetryAfter, err := r.analyzeRollout(ctx, customResource, desired)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	retryAfter = soonest(retryAfter, analysisRetryAfter)
+	requeueAfter, err := r.reconcileWorkload(ctx, customResource, desired)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	requeueAfter = soonest(requeueAfter, retryAfter)
+	abTestRetryAfter, err := r.reconcileABTest(ctx, customResource, desired)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	requeueAfter = soonest(requeueAfter, abTestRetryAfter)
+
+	if err := r.reconcileServices(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
 
-	return ctrl.Result{}, nil
+	if err := r.reconcileIngress(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	dnsRetryAfter, err := r.verifyDNS(ctx, customResource)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	requeueAfter = soonest(requeueAfter, dnsRetryAfter)
+
+	if err := r.reconcileDNSRecords(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	acmeRetryAfter, err := r.reconcileACME(ctx, customResource)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	requeueAfter = soonest(requeueAfter, acmeRetryAfter)
+
+	selfSignedRenewAfter, err := r.reconcileSelfSignedCertificate(ctx, customResource)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	requeueAfter = soonest(requeueAfter, selfSignedRenewAfter)
+
+	if err := r.reconcileFlagger(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileDashboard(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileExporter(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileBackendTLS(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileNginxConfig(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileAutoscaling(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileDisruptionBudget(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileNetworkPolicy(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcilePlacement(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// The Probe checks the URL the placement reports.
+	if err := r.reconcileProbe(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+	availabilityRetryAfter, err := r.reconcileAvailability(ctx, customResource)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	requeueAfter = soonest(requeueAfter, availabilityRetryAfter)
+
+	certificateRetryAfter, err := r.reconcileCertificateExpiry(ctx, customResource)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	requeueAfter = soonest(requeueAfter, certificateRetryAfter)
+
+	if err := r.reconcileClusters(ctx, customResource); err != nil {
+		log.Error(err, "Failed to reconcile remote clusters")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileStatus(ctx, customResource); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *WebsiteReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	r.clusters = &clusterClients{}
+
+	bldr := ctrl.NewControllerManagedBy(mgr).
 		For(&devv1.Website{}).
-		Complete(r)
+		Owns(&batchv1.Job{}).
+		Owns(&devv1.Website{}).
+		WithOptions(controller.Options{RateLimiter: r.RateLimiter}).
+		Watches(&source.Kind{Type: &corev1.Pod{}}, handler.EnqueueRequestsFromMapFunc(websiteOf),
+			builder.WithPredicates(podPlacementChanged)).
+		Watches(&source.Kind{Type: &appsv1.Deployment{}}, handler.EnqueueRequestsFromMapFunc(websiteOf),
+			builder.WithPredicates(workloadConditionsChanged)).
+		Watches(&source.Kind{Type: &appsv1.DaemonSet{}}, handler.EnqueueRequestsFromMapFunc(websiteOf),
+			builder.WithPredicates(daemonSetProgressed)).
+		Watches(&source.Kind{Type: &corev1.Service{}}, handler.EnqueueRequestsFromMapFunc(websiteOf),
+			builder.WithPredicates(loadBalancerChanged)).
+		Watches(&source.Kind{Type: &networkingv1.Ingress{}}, handler.EnqueueRequestsFromMapFunc(websiteOf),
+			builder.WithPredicates(loadBalancerChanged)).
+		Watches(&source.Kind{Type: &devv1.WebsiteSnapshot{}}, handler.EnqueueRequestsFromMapFunc(r.websitesReferencing(snapshotIndex)))
+	if r.Namespace == "" {
+		bldr = bldr.Watches(&source.Kind{Type: &devv1.WebsiteClass{}}, handler.EnqueueRequestsFromMapFunc(r.websitesOfClass))
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &devv1.Website{}, classIndex, func(obj client.Object) []string {
+		return []string{obj.(*devv1.Website).Spec.ClassName}
+	}); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &devv1.Website{}, snapshotIndex, func(obj client.Object) []string {
+		return []string{obj.(*devv1.Website).Spec.FromSnapshot}
+	}); err != nil {
+		return err
+	}
+
+	if err := r.setupReferenceWatches(mgr, bldr); err != nil {
+		return err
+	}
+	if err := r.setupPullSecretWatch(mgr, bldr); err != nil {
+		return err
+	}
+
+	if r.Sharder != nil {
+		events := make(chan event.GenericEvent)
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			return r.resyncOnShardChange(ctx, events)
+		})); err != nil {
+			return err
+		}
+		bldr = bldr.Watches(&source.Channel{Source: events}, &handler.EnqueueRequestForObject{})
+	}
+
+	return bldr.Complete(r)
+}
+
+// soonest returns the shortest of the non-zero durations, or zero when there are none.
+func soonest(durations ...time.Duration) time.Duration {
+	var result time.Duration
+	for _, d := range durations {
+		if d > 0 && (result == 0 || d < result) {
+			result = d
+		}
+	}
+	return result
+}
+
+const (
+	// websiteLabel holds the name of the website a generated resource belongs to
+	websiteLabel = "website"
+	// typeLabel marks every resource generated by the operator
+	typeLabel = "type"
+)
+
+// namePrefix and nameSuffix are added to the names of the resources generated for every
+// website, see SetNameAffixes.
+var namePrefix, nameSuffix string
+
+// SetNameAffixes makes the names of the resources generated for websites follow a naming
+// convention, e.g. web-<name>-prod. It must be called before the controllers start.
+// Labels keep the plain website name.
+func SetNameAffixes(prefix, suffix string) {
+	namePrefix, nameSuffix = prefix, suffix
+}
+
+// resourceName returns the name the resources generated for a website are named after.
+func resourceName(website *devv1.Website) string {
+	return namePrefix + website.Name + nameSuffix
 }
 
 // Create a single reference for labels as it is a reused variable
 func setResourceLabels(name string) map[string]string {
 	return map[string]string{
-		"website": name,
-		"type":    "Website",
+		websiteLabel: name,
+		typeLabel:    "Website",
 	}
 }
 
-// Create a deployment with the correct field values. By creating this in a function,
-// it can be reused by all lifecycle functions (create, update, delete).
-func newDeployment(name, namespace, imageTag string) *appsv1.Deployment {
-	replicas := int32(2)
-
-	return &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
-			Labels:    setResourceLabels(name),
-		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: &replicas,
-			Selector: &metav1.LabelSelector{MatchLabels: setResourceLabels(name)},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{Labels: setResourceLabels(name)},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name: "nginx",
-							// This is a publicly available container.  Note the use of
-							//`imageTag` as defined by the original resource request spec.
-							Image: fmt.Sprintf("abangser/todo-local-storage:%s", imageTag),
-							Ports: []corev1.ContainerPort{{
-								ContainerPort: 80,
-							}},
-						},
-					},
-				},
-			},
-		},
-	}
-}
+// CacheSelectors limits the manager cache to the resources generated or referenced by the
+// operator, so that it does not hold every Deployment, Service, Pod, ConfigMap and Secret of
+// the cluster in memory. Objects without the operator's labels are invisible to the cached client.
+func CacheSelectors() cache.SelectorsByObject {
+	managed := cache.ObjectSelector{Label: labels.SelectorFromSet(labels.Set{typeLabel: "Website"})}
+	watched := cache.ObjectSelector{Label: labels.SelectorFromSet(labels.Set{watchLabel: "true"})}
+	return cache.SelectorsByObject{
+		&appsv1.Deployment{}: managed,
+		&appsv1.DaemonSet{}:  managed,
+		&corev1.Service{}:    managed,
+		&corev1.Pod{}:        managed,
+		&corev1.ConfigMap{}:  watched,
+		&corev1.Secret{}:     watched,
 
-// Create a service with the correct field values. By creating this in a function,
-// it can be reused by all lifecycle functions (create, update, delete).
-func newService(name, namespace string) *corev1.Service {
-	return &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
-			Labels:    setResourceLabels(name),
-		},
-		Spec: corev1.ServiceSpec{
-			Ports: []corev1.ServicePort{
-				{
-					Port:     80,
-					NodePort: 31000,
-				},
-			},
-			Selector: setResourceLabels(name),
-			Type:     corev1.ServiceTypeNodePort,
-		},
+		&policyv1.PodDisruptionBudget{}: managed,
+		&networkingv1.Ingress{}:         managed,
+		&networkingv1.NetworkPolicy{}:   managed,
+		&batchv1.Job{}:                  managed,
 	}
 }
@@ -0,0 +1,65 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// newTestReconciler returns a reconciler backed by a fake client holding objects.
+func newTestReconciler(t *testing.T, objects ...client.Object) *WebsiteReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := devv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	return &WebsiteReconciler{Client: c, APIReader: c, Scheme: scheme}
+}
+
+func TestReconcileWithoutMonitoring(t *testing.T) {
+	website := &devv1.Website{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec:       devv1.WebsiteSpec{ImageTag: "v1"},
+	}
+	r := newTestReconciler(t, website)
+
+	name := types.NamespacedName{Name: website.Name, Namespace: website.Namespace}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatalf("Reconcile() failed: %v", err)
+	}
+	if err := r.Get(context.Background(), name, website); err != nil {
+		t.Fatal(err)
+	}
+	if website.Status.Availability != nil {
+		t.Errorf("Status.Availability = %+v, want nil", website.Status.Availability)
+	}
+}
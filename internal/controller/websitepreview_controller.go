@@ -0,0 +1,219 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	// previewLabel is set on the Websites generated for a WebsitePreview to the name of that preview
+	previewLabel = "dev.mvasilenko.me/preview"
+	// previewIndex indexes WebsitePreviews by the Website they copy
+	previewIndex = "websitepreview.websiteName"
+
+	defaultPreviewTTL = 72 * time.Hour
+)
+
+// WebsitePreviewReconciler reconciles a WebsitePreview object
+type WebsitePreviewReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Sharder, when set, limits this replica to the WebsitePreviews of its shard.
+	Sharder Sharder
+}
+
+//+kubebuilder:rbac:groups=dev.mvasilenko.me,resources=websitepreviews,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=dev.mvasilenko.me,resources=websitepreviews/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=dev.mvasilenko.me,resources=websitepreviews/finalizers,verbs=update
+
+// Reconcile keeps the Website of a WebsitePreview a copy of the previewed website, and
+// deletes the preview, and with it its Website, once its TTL has passed.
+func (r *WebsitePreviewReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx).WithValues("websitePreview", req.Name, "namespace", req.Namespace)
+	ctx = ctrllog.IntoContext(ctx, log)
+
+	if r.Sharder != nil && !r.Sharder.Owns(req.NamespacedName) {
+		log.V(1).Info("WebsitePreview belongs to another shard")
+		return ctrl.Result{}, nil
+	}
+
+	preview := &devv1.WebsitePreview{}
+	if err := r.Client.Get(ctx, req.NamespacedName, preview); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to retrieve custom resource")
+		return ctrl.Result{}, err
+	}
+
+	ttl := defaultPreviewTTL
+	if preview.Spec.TTL != nil {
+		ttl = preview.Spec.TTL.Duration
+	}
+	expiresAt := preview.CreationTimestamp.Add(ttl)
+	if remaining := time.Until(expiresAt); remaining <= 0 {
+		log.Info("Website preview has expired", "action", "delete")
+		if err := r.Client.Delete(ctx, preview); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete expired website preview", "action", "delete")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	status := devv1.WebsitePreviewStatus{ExpiresAt: &metav1.Time{Time: expiresAt}}
+	host, err := r.reconcileWebsite(ctx, preview)
+	if err != nil {
+		status.Message = err.Error()
+	}
+	status.Host = host
+
+	if !equality.Semantic.DeepEqual(status, preview.Status) {
+		patch := client.MergeFrom(preview.DeepCopy())
+		preview.Status = status
+		if err := r.Client.Status().Patch(ctx, preview, patch); err != nil {
+			log.Error(err, "Failed to update website preview status", "action", "update")
+			return ctrl.Result{}, err
+		}
+	}
+	if err != nil {
+		log.Error(err, "Failed to reconcile preview website")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: time.Until(expiresAt)}, nil
+}
+
+// reconcileWebsite creates or updates the Website of a preview, and returns the host it
+// is served on.
+func (r *WebsitePreviewReconciler) reconcileWebsite(ctx context.Context, preview *devv1.WebsitePreview) (string, error) {
+	log := ctrllog.FromContext(ctx)
+
+	base := devv1.Website{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: preview.Spec.WebsiteName, Namespace: preview.Namespace}, &base); err != nil {
+		return "", fmt.Errorf("website %q: %w", preview.Spec.WebsiteName, err)
+	}
+
+	// Previews only run in the cluster of the website, under names of their own.
+	spec := base.Spec.DeepCopy()
+	spec.Clusters = nil
+	spec.DeploymentName, spec.ServiceName = "", ""
+	if preview.Spec.ImageTag != "" {
+		spec.ImageTag = preview.Spec.ImageTag
+		spec.ImageDigest = ""
+	}
+	var host string
+	if spec.Ingress != nil {
+		host = preview.Spec.Host
+		if host == "" {
+			host = fmt.Sprintf("%s.%s", preview.Name, spec.Ingress.Host)
+		}
+		spec.Ingress.Host = host
+		if spec.Ingress.TLS != nil {
+			spec.Ingress.TLS.SecretName = ""
+		}
+	}
+
+	desired := &devv1.Website{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      preview.Name,
+			Namespace: preview.Namespace,
+			Labels:    map[string]string{previewLabel: preview.Name},
+		},
+		Spec: *spec,
+	}
+	if err := ctrl.SetControllerReference(preview, desired, r.Scheme); err != nil {
+		return "", err
+	}
+
+	err := r.Client.Create(ctx, desired)
+	if err == nil {
+		return host, nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create website", "action", "create")
+		return "", err
+	}
+
+	current := devv1.Website{}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(desired), &current); err != nil {
+		log.Error(err, "Failed to retrieve website", "action", "get")
+		return "", err
+	}
+	if !metav1.IsControlledBy(&current, preview) {
+		return "", fmt.Errorf("website %q exists and is not managed by this preview", current.Name)
+	}
+	if equality.Semantic.DeepEqual(current.Spec, desired.Spec) {
+		return host, nil
+	}
+
+	log.Info("Website has changed", "action", "update")
+	patch := client.MergeFrom(current.DeepCopy())
+	current.Spec = desired.Spec
+	if err := r.Client.Patch(ctx, &current, patch); err != nil {
+		log.Error(err, "Failed to update website", "action", "update")
+		return "", err
+	}
+	return host, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *WebsitePreviewReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &devv1.WebsitePreview{}, previewIndex, func(obj client.Object) []string {
+		return []string{obj.(*devv1.WebsitePreview).Spec.WebsiteName}
+	}); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&devv1.WebsitePreview{}).
+		Owns(&devv1.Website{}).
+		Watches(&source.Kind{Type: &devv1.Website{}}, handler.EnqueueRequestsFromMapFunc(r.previewsOf)).
+		Complete(r)
+}
+
+// previewsOf maps a Website to the previews copying it.
+func (r *WebsitePreviewReconciler) previewsOf(obj client.Object) []reconcile.Request {
+	previews := devv1.WebsitePreviewList{}
+	if err := r.Client.List(context.Background(), &previews,
+		client.InNamespace(obj.GetNamespace()), client.MatchingFields{previewIndex: obj.GetName()}); err != nil {
+		ctrllog.Log.Error(err, "Failed to list website previews", "website", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(previews.Items))
+	for _, preview := range previews.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&preview)})
+	}
+	return requests
+}
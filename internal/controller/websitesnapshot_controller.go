@@ -0,0 +1,184 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+// WebsiteSnapshotReconciler reconciles a WebsiteSnapshot object
+type WebsiteSnapshotReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// APIReader reads the user-owned content objects the scoped cache may not hold yet.
+	APIReader client.Reader
+
+	// Sharder, when set, limits this replica to the WebsiteSnapshots of its shard.
+	Sharder Sharder
+}
+
+//+kubebuilder:rbac:groups=dev.mvasilenko.me,resources=websitesnapshots,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=dev.mvasilenko.me,resources=websitesnapshots/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=dev.mvasilenko.me,resources=websitesnapshots/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=create
+
+// Reconcile captures a WebsiteSnapshot: the spec of its website, the image digest its
+// pods run and, if asked for, a copy of its content. Captured snapshots are left alone.
+func (r *WebsiteSnapshotReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx).WithValues("websiteSnapshot", req.Name, "namespace", req.Namespace)
+	ctx = ctrllog.IntoContext(ctx, log)
+
+	if r.Sharder != nil && !r.Sharder.Owns(req.NamespacedName) {
+		log.V(1).Info("WebsiteSnapshot belongs to another shard")
+		return ctrl.Result{}, nil
+	}
+
+	snapshot := &devv1.WebsiteSnapshot{}
+	if err := r.Client.Get(ctx, req.NamespacedName, snapshot); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to retrieve custom resource")
+		return ctrl.Result{}, err
+	}
+	if snapshot.Status.CapturedAt != nil {
+		return ctrl.Result{}, nil
+	}
+
+	status, err := r.capture(ctx, snapshot)
+	if err != nil {
+		log.Error(err, "Failed to capture website snapshot")
+		status = devv1.WebsiteSnapshotStatus{Message: err.Error()}
+	}
+
+	patch := client.MergeFrom(snapshot.DeepCopy())
+	snapshot.Status = status
+	if err := r.Client.Status().Patch(ctx, snapshot, patch); err != nil {
+		log.Error(err, "Failed to update website snapshot status", "action", "update")
+		return ctrl.Result{}, err
+	}
+	if status.CapturedAt != nil {
+		log.Info("Captured website snapshot", "imageDigest", status.ImageDigest)
+	}
+	return ctrl.Result{}, err
+}
+
+// capture reads the state of the website of a snapshot.
+func (r *WebsiteSnapshotReconciler) capture(ctx context.Context, snapshot *devv1.WebsiteSnapshot) (devv1.WebsiteSnapshotStatus, error) {
+	website := devv1.Website{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: snapshot.Spec.WebsiteName, Namespace: snapshot.Namespace}, &website); err != nil {
+		return devv1.WebsiteSnapshotStatus{}, err
+	}
+
+	// A website restored from a snapshot is captured as it runs.
+	spec := website.Spec.DeepCopy()
+	spec.FromSnapshot = ""
+
+	pods := corev1.PodList{}
+	if err := r.Client.List(ctx, &pods, client.InNamespace(website.Namespace), client.MatchingLabels(setResourceLabels(website.Name))); err != nil {
+		return devv1.WebsiteSnapshotStatus{}, err
+	}
+	digest := runningImageDigest(pods.Items)
+	if digest == "" {
+		return devv1.WebsiteSnapshotStatus{}, fmt.Errorf("no running pod of website %q reports its image digest", website.Name)
+	}
+
+	status := devv1.WebsiteSnapshotStatus{WebsiteSpec: spec, ImageDigest: digest}
+	if snapshot.Spec.IncludeContent && spec.Content != nil {
+		content, err := r.copyContent(ctx, snapshot, spec.Content)
+		if err != nil {
+			return devv1.WebsiteSnapshotStatus{}, err
+		}
+		status.Content = content
+	}
+
+	now := metav1.Now()
+	status.CapturedAt = &now
+	return status, nil
+}
+
+// runningImageDigest returns the digest of the image the website container of the pods runs.
+func runningImageDigest(pods []corev1.Pod) string {
+	for _, pod := range pods {
+		for _, container := range pod.Status.ContainerStatuses {
+			if container.Name != "nginx" || !container.Ready {
+				continue
+			}
+			if i := strings.LastIndex(container.ImageID, "@"); i >= 0 {
+				return container.ImageID[i+1:]
+			}
+		}
+	}
+	return ""
+}
+
+// copyContent copies the content of a website into an object of the same kind owned by
+// the snapshot, so that later changes to the content do not affect the snapshot.
+func (r *WebsiteSnapshotReconciler) copyContent(ctx context.Context, snapshot *devv1.WebsiteSnapshot, content *devv1.ContentSpec) (*devv1.ContentSpec, error) {
+	meta := metav1.ObjectMeta{
+		Name:      fmt.Sprintf("%s-content", snapshot.Name),
+		Namespace: snapshot.Namespace,
+		Labels:    map[string]string{watchLabel: "true"},
+	}
+
+	var copied client.Object
+	copiedContent := &devv1.ContentSpec{MountPath: content.MountPath}
+	if content.ConfigMapName != "" {
+		configMap := corev1.ConfigMap{}
+		if err := r.APIReader.Get(ctx, types.NamespacedName{Name: content.ConfigMapName, Namespace: snapshot.Namespace}, &configMap); err != nil {
+			return nil, err
+		}
+		copied = &corev1.ConfigMap{ObjectMeta: meta, Data: configMap.Data, BinaryData: configMap.BinaryData}
+		copiedContent.ConfigMapName = meta.Name
+	} else {
+		secret := corev1.Secret{}
+		if err := r.APIReader.Get(ctx, types.NamespacedName{Name: content.SecretName, Namespace: snapshot.Namespace}, &secret); err != nil {
+			return nil, err
+		}
+		copied = &corev1.Secret{ObjectMeta: meta, Data: secret.Data, Type: secret.Type}
+		copiedContent.SecretName = meta.Name
+	}
+
+	if err := ctrl.SetControllerReference(snapshot, copied, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Client.Create(ctx, copied); err != nil && !errors.IsAlreadyExists(err) {
+		return nil, err
+	}
+	return copiedContent, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *WebsiteSnapshotReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&devv1.WebsiteSnapshot{}).
+		Complete(r)
+}
@@ -0,0 +1,339 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cosign
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxResponseSize limits the manifests, blobs and tokens read from a registry.
+const maxResponseSize = 4 << 20
+
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+// Reference is an image reference split into the parts the registry API needs.
+type Reference struct {
+	// Registry is the host of the registry
+	Registry string
+	// Repository is the path of the repository in the registry
+	Repository string
+	// Tag or digest of the image
+	Tag, Digest string
+}
+
+// ParseReference splits an image reference such as ghcr.io/org/site:v1 or
+// nginx@sha256:... into its parts. References without a registry host come from Docker Hub.
+func ParseReference(image string) (Reference, error) {
+	var ref Reference
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+
+	host, path, found := strings.Cut(name, "/")
+	if found && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		ref.Registry, ref.Repository = host, path
+	} else {
+		ref.Registry, ref.Repository = "registry-1.docker.io", name
+		if !found {
+			ref.Repository = "library/" + name
+		}
+	}
+	if ref.Repository == "" {
+		return ref, fmt.Errorf("invalid image reference %q", image)
+	}
+	return ref, nil
+}
+
+// registry reads manifests and blobs of a repository through the distribution API,
+// authenticating with bearer tokens when the registry asks for them.
+type registry struct {
+	client             *http.Client
+	ref                Reference
+	username, password string
+	token              string
+}
+
+func newRegistry(ref Reference, username, password string) *registry {
+	return &registry{
+		client:   &http.Client{Timeout: 30 * time.Second},
+		ref:      ref,
+		username: username,
+		password: password,
+	}
+}
+
+// get fetches a path of the repository and returns its body and digest header.
+func (r *registry) get(ctx context.Context, path string, accept []string) ([]byte, string, error) {
+	endpoint := fmt.Sprintf("https://%s/v2/%s/%s", r.ref.Registry, r.ref.Repository, path)
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, mediaType := range accept {
+			req.Header.Add("Accept", mediaType)
+		}
+		if r.token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.token)
+		} else if r.username != "" {
+			req.SetBasicAuth(r.username, r.password)
+		}
+
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+		resp.Body.Close()
+		if err != nil {
+			return nil, "", err
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return body, resp.Header.Get("Docker-Content-Digest"), nil
+		case resp.StatusCode == http.StatusUnauthorized && attempt == 0:
+			if err := r.authenticate(ctx, resp.Header.Get("WWW-Authenticate")); err != nil {
+				return nil, "", err
+			}
+		case resp.StatusCode == http.StatusNotFound:
+			return nil, "", errNotFound
+		default:
+			return nil, "", fmt.Errorf("GET %s: %s", endpoint, resp.Status)
+		}
+	}
+}
+
+// authenticate fetches a bearer token for pulling from the repository.
+func (r *registry) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		if r.username == "" {
+			return fmt.Errorf("registry %s requires credentials", r.ref.Registry)
+		}
+		// Basic authentication is sent on the next attempt.
+		return nil
+	}
+
+	values := map[string]string{}
+	for _, param := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		values[key] = strings.Trim(value, `"`)
+	}
+	realm, err := url.Parse(values["realm"])
+	if err != nil || values["realm"] == "" {
+		return fmt.Errorf("invalid authentication challenge from %s", r.ref.Registry)
+	}
+	query := realm.Query()
+	if service := values["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull", r.ref.Repository))
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("authenticating to %s: %s", r.ref.Registry, resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&token); err != nil {
+		return err
+	}
+	r.token = token.Token
+	if r.token == "" {
+		r.token = token.AccessToken
+	}
+	return nil
+}
+
+// resolve returns the digest of the manifest an image reference points to.
+func (r *registry) resolve(ctx context.Context) (string, error) {
+	if r.ref.Digest != "" {
+		return r.ref.Digest, nil
+	}
+	body, digest, err := r.get(ctx, "manifests/"+r.ref.Tag, manifestMediaTypes)
+	if err != nil {
+		return "", err
+	}
+	if digest == "" {
+		digest = sha256Digest(body)
+	}
+	return digest, nil
+}
+
+// Tags lists the tags of the repository of an image. Username and password authenticate
+// to the registry, anonymous access is used when empty.
+func Tags(ctx context.Context, image, username, password string) ([]string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return nil, err
+	}
+	reg := newRegistry(ref, username, password)
+
+	// Registries return the tags in pages, the first one is asked to be large enough for
+	// any repository of website images.
+	body, _, err := reg.get(ctx, "tags/list?n=10000", nil)
+	if err != nil {
+		return nil, fmt.Errorf("listing the tags of %s: %w", image, err)
+	}
+	var list struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, err
+	}
+	return list.Tags, nil
+}
+
+// Digest returns the digest of the manifest an image reference points to. Username and
+// password authenticate to the registry, anonymous access is used when empty.
+func Digest(ctx context.Context, image, username, password string) (string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	digest, err := newRegistry(ref, username, password).resolve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", image, err)
+	}
+	return digest, nil
+}
+
+// Platforms returns the platforms an image is available for, in the os/architecture form,
+// read from its index or from the configuration of a single-platform image. Username and
+// password authenticate to the registry, anonymous access is used when empty.
+func Platforms(ctx context.Context, image, username, password string) ([]string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return nil, err
+	}
+	reg := newRegistry(ref, username, password)
+
+	reference := ref.Digest
+	if reference == "" {
+		reference = ref.Tag
+	}
+	body, _, err := reg.get(ctx, "manifests/"+reference, manifestMediaTypes)
+	if err != nil {
+		return nil, fmt.Errorf("fetching the manifest of %s: %w", image, err)
+	}
+	platforms, configDigest, err := indexPlatforms(body)
+	if err != nil || configDigest == "" {
+		return platforms, err
+	}
+
+	config, err := reg.blob(ctx, configDigest)
+	if err != nil {
+		return nil, fmt.Errorf("fetching the configuration of %s: %w", image, err)
+	}
+	var platform imagePlatform
+	if err := json.Unmarshal(config, &platform); err != nil {
+		return nil, err
+	}
+	return []string{platform.String()}, nil
+}
+
+type imagePlatform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+}
+
+func (p imagePlatform) String() string {
+	return p.OS + "/" + p.Architecture
+}
+
+// indexPlatforms returns the platforms listed by an image index, or the digest of the
+// configuration of a single-platform manifest. Entries of unknown platforms, such as
+// attestations, are skipped.
+func indexPlatforms(manifest []byte) ([]string, string, error) {
+	var parsed struct {
+		Manifests []struct {
+			Platform *imagePlatform `json:"platform"`
+		} `json:"manifests"`
+		Config *struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(manifest, &parsed); err != nil {
+		return nil, "", err
+	}
+	if parsed.Config != nil {
+		return nil, parsed.Config.Digest, nil
+	}
+
+	var platforms []string
+	for _, entry := range parsed.Manifests {
+		if entry.Platform == nil || entry.Platform.OS == "unknown" {
+			continue
+		}
+		platforms = append(platforms, entry.Platform.String())
+	}
+	return platforms, "", nil
+}
+
+// blob fetches a blob and checks it against its digest.
+func (r *registry) blob(ctx context.Context, digest string) ([]byte, error) {
+	body, _, err := r.get(ctx, "blobs/"+digest, nil)
+	if err != nil {
+		return nil, err
+	}
+	if sha256Digest(body) != digest {
+		return nil, fmt.Errorf("blob %s does not match its digest", digest)
+	}
+	return body, nil
+}
+
+func sha256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cosign verifies the cosign signatures of container images stored next to them
+// in their registry.
+package cosign
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	signatureAnnotation   = "dev.cosignproject.cosign/signature"
+	certificateAnnotation = "dev.sigstore.cosign/certificate"
+	chainAnnotation       = "dev.sigstore.cosign/chain"
+)
+
+var (
+	errNotFound = errors.New("not found")
+
+	// Fulcio certificate extensions holding the OIDC issuer of the signer identity
+	oidcIssuerOID   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidcIssuerV2OID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// Identity is the signer identity keyless signatures must carry.
+type Identity struct {
+	// Issuer is the OIDC issuer the signer authenticated with
+	Issuer string
+	// Subject is the email address or URI of the signer
+	Subject string
+}
+
+// Options configure a verification. Exactly one of PublicKey and Identity must be set.
+type Options struct {
+	// PublicKey is the PEM encoded key images are signed with
+	PublicKey []byte
+	// Identity is the signer of keyless signatures, whose certificates must chain up to Roots
+	Identity *Identity
+	// Roots are the certificate authorities issuing keyless signing certificates
+	Roots *x509.CertPool
+
+	// Username and Password authenticate to the registry. Anonymous access is used when empty.
+	Username, Password string
+}
+
+// Verify checks that an image carries a valid cosign signature and returns the digest
+// it was verified for. Keyless signatures are checked against the signer identity and
+// the certificate authorities only, not against a transparency log.
+func Verify(ctx context.Context, image string, opts Options) (string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	reg := newRegistry(ref, opts.Username, opts.Password)
+
+	digest, err := reg.resolve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", image, err)
+	}
+
+	manifest, _, err := reg.get(ctx, "manifests/"+strings.Replace(digest, ":", "-", 1)+".sig", manifestMediaTypes)
+	if errors.Is(err, errNotFound) {
+		return "", fmt.Errorf("%s is not signed", image)
+	}
+	if err != nil {
+		return "", fmt.Errorf("fetching signatures of %s: %w", image, err)
+	}
+
+	var signatures struct {
+		Layers []struct {
+			Digest      string            `json:"digest"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(manifest, &signatures); err != nil {
+		return "", err
+	}
+
+	var errs []string
+	for _, layer := range signatures.Layers {
+		payload, err := reg.blob(ctx, layer.Digest)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if err := verifySignature(payload, layer.Annotations, digest, opts); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		return digest, nil
+	}
+	if len(errs) == 0 {
+		return "", fmt.Errorf("%s is not signed", image)
+	}
+	return "", fmt.Errorf("no valid signature for %s: %s", image, strings.Join(errs, "; "))
+}
+
+// verifySignature checks a single signature of an image digest.
+func verifySignature(payload []byte, annotations map[string]string, digest string, opts Options) error {
+	var simpleSigning struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(payload, &simpleSigning); err != nil {
+		return fmt.Errorf("invalid signature payload: %w", err)
+	}
+	if simpleSigning.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature is for %s", simpleSigning.Critical.Image.DockerManifestDigest)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(annotations[signatureAnnotation])
+	if err != nil || len(signature) == 0 {
+		return errors.New("missing signature")
+	}
+
+	var key crypto.PublicKey
+	if opts.PublicKey != nil {
+		block, _ := pem.Decode(opts.PublicKey)
+		if block == nil {
+			return errors.New("invalid public key")
+		}
+		if key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return err
+		}
+	} else {
+		cert, err := verifyCertificate([]byte(annotations[certificateAnnotation]), []byte(annotations[chainAnnotation]), opts)
+		if err != nil {
+			return err
+		}
+		key = cert.PublicKey
+	}
+	return checkSignature(key, payload, signature)
+}
+
+// verifyCertificate checks that a keyless signing certificate was issued to the expected
+// identity by a trusted certificate authority, through the intermediates of the PEM
+// encoded chain stored with it. Signing certificates are short-lived, so the chain is
+// verified at the time the certificate was issued.
+func verifyCertificate(data, chain []byte, opts Options) (*x509.Certificate, error) {
+	if opts.Identity == nil || opts.Roots == nil {
+		return nil, errors.New("keyless verification requires an identity and certificate authorities")
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("missing signing certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	intermediates := x509.NewCertPool()
+	for block, rest := pem.Decode(chain); block != nil; block, rest = pem.Decode(rest) {
+		intermediate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate chain: %w", err)
+		}
+		intermediates.AddCert(intermediate)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         opts.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   cert.NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return nil, err
+	}
+
+	subjects := append([]string{}, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		subjects = append(subjects, uri.String())
+	}
+	matched := false
+	for _, subject := range subjects {
+		matched = matched || subject == opts.Identity.Subject
+	}
+	if !matched {
+		return nil, fmt.Errorf("certificate issued to %v", subjects)
+	}
+
+	issuer := ""
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidcIssuerV2OID):
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err != nil {
+				return nil, err
+			}
+		case ext.Id.Equal(oidcIssuerOID) && issuer == "":
+			issuer = string(ext.Value)
+		}
+	}
+	if issuer != opts.Identity.Issuer {
+		return nil, fmt.Errorf("certificate issued by %q", issuer)
+	}
+	return cert, nil
+}
+
+// checkSignature verifies a signature over a payload with the key types cosign signs with.
+func checkSignature(key crypto.PublicKey, payload, signature []byte) error {
+	hash := sha256.Sum256(payload)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, hash[:], signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, payload, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported key type %T", key)
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cosign
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestParseReference(t *testing.T) {
+	for image, want := range map[string]Reference{
+		"nginx":                     {Registry: "registry-1.docker.io", Repository: "library/nginx", Tag: "latest"},
+		"abangser/todo:v1":          {Registry: "registry-1.docker.io", Repository: "abangser/todo", Tag: "v1"},
+		"ghcr.io/org/site@sha256:a": {Registry: "ghcr.io", Repository: "org/site", Digest: "sha256:a"},
+		"localhost:5000/site:v2":    {Registry: "localhost:5000", Repository: "site", Tag: "v2"},
+	} {
+		got, err := ParseReference(image)
+		if err != nil || got != want {
+			t.Errorf("ParseReference(%q) = %+v, %v, want %+v", image, got, err, want)
+		}
+	}
+}
+
+func TestIndexPlatforms(t *testing.T) {
+	index := []byte(`{"manifests":[
+		{"digest":"sha256:a","platform":{"os":"linux","architecture":"amd64"}},
+		{"digest":"sha256:b","platform":{"os":"linux","architecture":"arm64"}},
+		{"digest":"sha256:c","platform":{"os":"unknown","architecture":"unknown"}}]}`)
+	platforms, config, err := indexPlatforms(index)
+	if err != nil || config != "" || fmt.Sprint(platforms) != "[linux/amd64 linux/arm64]" {
+		t.Errorf("indexPlatforms(index) = %v, %q, %v", platforms, config, err)
+	}
+
+	manifest := []byte(`{"config":{"digest":"sha256:d"},"layers":[]}`)
+	platforms, config, err = indexPlatforms(manifest)
+	if err != nil || config != "sha256:d" || platforms != nil {
+		t.Errorf("indexPlatforms(manifest) = %v, %q, %v", platforms, config, err)
+	}
+}
+
+func TestVerifySignatureWithPublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{PublicKey: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})}
+
+	digest := "sha256:0123"
+	payload := []byte(fmt.Sprintf(`{"critical":{"image":{"docker-manifest-digest":%q}}}`, digest))
+	hash := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotations := map[string]string{signatureAnnotation: base64.StdEncoding.EncodeToString(signature)}
+
+	if err := verifySignature(payload, annotations, digest, opts); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+	if err := verifySignature(payload, annotations, "sha256:4567", opts); err == nil {
+		t.Error("expected a signature of another digest to be rejected")
+	}
+	tampered := append(append([]byte{}, payload...), ' ')
+	if err := verifySignature(tampered, annotations, digest, opts); err == nil {
+		t.Error("expected a tampered payload to be rejected")
+	}
+}
+
+func TestVerifyCertificateThroughChain(t *testing.T) {
+	newCertificate := func(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+	notBefore := time.Now().Add(-time.Hour)
+	ca := func(serial int64, name string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             notBefore,
+			NotAfter:              notBefore.Add(24 * time.Hour),
+			KeyUsage:              x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+	}
+	root, rootKey := newCertificate(ca(1, "sigstore"), nil, nil)
+	intermediate, intermediateKey := newCertificate(ca(2, "sigstore-intermediate"), root, rootKey)
+	leaf, _ := newCertificate(&x509.Certificate{
+		SerialNumber:    big.NewInt(3),
+		NotBefore:       notBefore,
+		NotAfter:        notBefore.Add(10 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		EmailAddresses:  []string{"ci@example.com"},
+		ExtraExtensions: []pkix.Extension{{Id: oidcIssuerOID, Value: []byte("https://issuer.example.com")}},
+	}, intermediate, intermediateKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	opts := Options{Roots: roots, Identity: &Identity{Issuer: "https://issuer.example.com", Subject: "ci@example.com"}}
+	encode := func(certs ...*x509.Certificate) []byte {
+		var data []byte
+		for _, cert := range certs {
+			data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+		}
+		return data
+	}
+
+	if _, err := verifyCertificate(encode(leaf), encode(intermediate, root), opts); err != nil {
+		t.Errorf("expected the certificate to chain up to the root, got %v", err)
+	}
+	if _, err := verifyCertificate(encode(leaf), nil, opts); err == nil {
+		t.Error("expected a certificate without its intermediate to be rejected")
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// LogLevelHandler reads and changes the verbosity of the operator logs at runtime. GET
+// returns the current level, PUT replaces it with the level in the request body. The
+// handler does not authenticate callers, it is meant to be served behind the metrics
+// endpoint authorization proxy.
+type LogLevelHandler struct {
+	// Level is the level the logger of the operator was built with.
+	Level zap.AtomicLevel
+	// Parse converts a level in the format of the --log-level flag.
+	Parse func(string) (zapcore.Level, error)
+}
+
+// ServeHTTP implements http.Handler.
+func (h *LogLevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		level, err := h.Parse(strings.TrimSpace(string(body)))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if level != h.Level.Level() {
+			ctrl.Log.WithName("diagnostics").Info("Changing log level", "from", formatLevel(h.Level.Level()), "to", formatLevel(level))
+			h.Level.SetLevel(level)
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, formatLevel(h.Level.Level()))
+}
+
+// formatLevel is the reverse of the --log-level parsing: levels below debug are the
+// logr verbosities they enable.
+func formatLevel(level zapcore.Level) string {
+	if level < zapcore.DebugLevel {
+		return fmt.Sprint(-int(level))
+	}
+	return level.String()
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package diagnostics serves runtime profiling endpoints for the operator.
+package diagnostics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime/metrics"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Server exposes net/http/pprof and runtime/metrics on a dedicated address so
+// that profiling traffic never shares a listener with the metrics or probe endpoints.
+type Server struct {
+	// Addr is the address the diagnostics endpoint binds to.
+	Addr string
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Diagnostics must be
+// available on every replica, not only on the leader.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable and serves until the context is cancelled.
+func (s *Server) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("diagnostics")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/runtime/metrics", serveRuntimeMetrics)
+
+	srv := &http.Server{
+		Addr:              s.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error(err, "Failed to shut down diagnostics server")
+		}
+	}()
+
+	log.Info("Starting diagnostics server", "addr", s.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// serveRuntimeMetrics writes every supported runtime/metrics sample as plain text,
+// one "name value" pair per line. Histograms are summarised by their total count.
+func serveRuntimeMetrics(w http.ResponseWriter, _ *http.Request) {
+	descs := metrics.All()
+	samples := make([]metrics.Sample, len(descs))
+	for i := range descs {
+		samples[i].Name = descs[i].Name
+	}
+	metrics.Read(samples)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, sample := range samples {
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			fmt.Fprintf(w, "%s %d\n", sample.Name, sample.Value.Uint64())
+		case metrics.KindFloat64:
+			fmt.Fprintf(w, "%s %g\n", sample.Name, sample.Value.Float64())
+		case metrics.KindFloat64Histogram:
+			var count uint64
+			for _, c := range sample.Value.Float64Histogram().Counts {
+				count += c
+			}
+			fmt.Fprintf(w, "%s_count %d\n", sample.Name, count)
+		}
+	}
+}
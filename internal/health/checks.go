@@ -0,0 +1,93 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package health implements the readiness checks of the operator, which make a
+// misconfigured install fail readiness instead of silently doing nothing.
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get
+
+// CRDsEstablished returns a check passing once every named CustomResourceDefinition is
+// established. The reader must not be cached, so that no CRD informer is started.
+func CRDsEstablished(reader client.Reader, names ...string) healthz.Checker {
+	return func(req *http.Request) error {
+		for _, name := range names {
+			crd := apiextensionsv1.CustomResourceDefinition{}
+			if err := reader.Get(req.Context(), types.NamespacedName{Name: name}, &crd); err != nil {
+				return fmt.Errorf("get CRD %s: %w", name, err)
+			}
+			established := false
+			for _, condition := range crd.Status.Conditions {
+				if condition.Type == apiextensionsv1.Established && condition.Status == apiextensionsv1.ConditionTrue {
+					established = true
+				}
+			}
+			if !established {
+				return fmt.Errorf("CRD %s is not established", name)
+			}
+		}
+		return nil
+	}
+}
+
+// Permissions returns a check passing once the operator is allowed everything in the
+// attributes, as reported by SelfSubjectAccessReviews.
+func Permissions(c client.Client, attributes ...authorizationv1.ResourceAttributes) healthz.Checker {
+	return func(req *http.Request) error {
+		var denied []string
+		for i := range attributes {
+			review := authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes[i]},
+			}
+			if err := c.Create(req.Context(), &review); err != nil {
+				return fmt.Errorf("review access: %w", err)
+			}
+			if !review.Status.Allowed {
+				denied = append(denied, describe(attributes[i]))
+			}
+		}
+		if len(denied) > 0 {
+			return fmt.Errorf("missing permissions: %s", strings.Join(denied, ", "))
+		}
+		return nil
+	}
+}
+
+func describe(attributes authorizationv1.ResourceAttributes) string {
+	resource := attributes.Resource
+	if attributes.Group != "" {
+		resource += "." + attributes.Group
+	}
+	if attributes.Subresource != "" {
+		resource += "/" + attributes.Subresource
+	}
+	if attributes.Namespace != "" {
+		return fmt.Sprintf("%s %s in %s", attributes.Verb, resource, attributes.Namespace)
+	}
+	return fmt.Sprintf("%s %s", attributes.Verb, resource)
+}
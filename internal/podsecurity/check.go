@@ -0,0 +1,261 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package podsecurity checks pod templates against the Pod Security Standards, so that
+// Websites whose pods would be refused by Pod Security Admission are rejected when they
+// are created rather than leaving their Deployment unable to create pods. It follows the
+// latest version of the standards, whatever version the namespace pins.
+package podsecurity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Level is a level of the Pod Security Standards
+type Level string
+
+const (
+	Privileged Level = "privileged"
+	Baseline   Level = "baseline"
+	Restricted Level = "restricted"
+)
+
+const (
+	// EnforceLabel and WarnLabel hold the levels a namespace enforces and warns about
+	EnforceLabel = "pod-security.kubernetes.io/enforce"
+	WarnLabel    = "pod-security.kubernetes.io/warn"
+
+	appArmorAnnotationPrefix = "container.apparmor.security.beta.kubernetes.io/"
+)
+
+var (
+	// baselineCapabilities may be added to containers at the baseline level
+	baselineCapabilities = set("AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+		"NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT")
+	safeSysctls = set("kernel.shm_rmid_forced", "net.ipv4.ip_local_port_range", "net.ipv4.ip_unprivileged_port_start",
+		"net.ipv4.tcp_syncookies", "net.ipv4.ping_group_range")
+	seLinuxTypes = set("", "container_t", "container_init_t", "container_kvm_t")
+)
+
+func set(values ...string) map[string]bool {
+	result := map[string]bool{}
+	for _, value := range values {
+		result[value] = true
+	}
+	return result
+}
+
+// Check returns the rules of a level a pod template breaks, each with the fields breaking
+// it. Unknown levels are treated as restricted, as Pod Security Admission does.
+func Check(level Level, template *corev1.PodTemplateSpec) []string {
+	if level == Privileged || level == "" {
+		return nil
+	}
+	violations := checkBaseline(template)
+	if level != Baseline {
+		violations = append(violations, checkRestricted(&template.Spec)...)
+	}
+	return violations
+}
+
+// containers returns every container of a pod with its security context, never nil.
+func containers(pod *corev1.PodSpec) []corev1.Container {
+	var result []corev1.Container
+	result = append(result, pod.InitContainers...)
+	result = append(result, pod.Containers...)
+	for _, container := range pod.EphemeralContainers {
+		result = append(result, corev1.Container(container.EphemeralContainerCommon))
+	}
+	for i := range result {
+		if result[i].SecurityContext == nil {
+			result[i].SecurityContext = &corev1.SecurityContext{}
+		}
+	}
+	return result
+}
+
+// violation formats a rule with the offending fields, or returns nil when there are none.
+func violation(rule string, fields []string) []string {
+	if len(fields) == 0 {
+		return nil
+	}
+	sort.Strings(fields)
+	return []string{fmt.Sprintf("%s (%s)", rule, strings.Join(fields, ", "))}
+}
+
+func checkBaseline(template *corev1.PodTemplateSpec) []string {
+	pod := &template.Spec
+	podContext := pod.SecurityContext
+	if podContext == nil {
+		podContext = &corev1.PodSecurityContext{}
+	}
+	var violations []string
+
+	var hostNamespaces []string
+	if pod.HostNetwork {
+		hostNamespaces = append(hostNamespaces, "hostNetwork=true")
+	}
+	if pod.HostPID {
+		hostNamespaces = append(hostNamespaces, "hostPID=true")
+	}
+	if pod.HostIPC {
+		hostNamespaces = append(hostNamespaces, "hostIPC=true")
+	}
+	violations = append(violations, violation("host namespaces", hostNamespaces)...)
+
+	var hostProcess, privileged, capabilities, hostPorts, procMount, seLinux, seccomp []string
+	if options := podContext.WindowsOptions; options != nil && options.HostProcess != nil && *options.HostProcess {
+		hostProcess = append(hostProcess, "pod")
+	}
+	if !seLinuxAllowed(podContext.SELinuxOptions) {
+		seLinux = append(seLinux, "pod")
+	}
+	if podContext.SeccompProfile != nil && podContext.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+		seccomp = append(seccomp, "pod")
+	}
+	for _, container := range containers(pod) {
+		context := container.SecurityContext
+		if options := context.WindowsOptions; options != nil && options.HostProcess != nil && *options.HostProcess {
+			hostProcess = append(hostProcess, container.Name)
+		}
+		if context.Privileged != nil && *context.Privileged {
+			privileged = append(privileged, container.Name)
+		}
+		if context.Capabilities != nil {
+			for _, capability := range context.Capabilities.Add {
+				if !baselineCapabilities[string(capability)] {
+					capabilities = append(capabilities, fmt.Sprintf("%s adds %s", container.Name, capability))
+				}
+			}
+		}
+		for _, port := range container.Ports {
+			if port.HostPort != 0 {
+				hostPorts = append(hostPorts, fmt.Sprintf("%s uses %d", container.Name, port.HostPort))
+			}
+		}
+		if context.ProcMount != nil && *context.ProcMount != corev1.DefaultProcMount {
+			procMount = append(procMount, container.Name)
+		}
+		if !seLinuxAllowed(context.SELinuxOptions) {
+			seLinux = append(seLinux, container.Name)
+		}
+		if context.SeccompProfile != nil && context.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+			seccomp = append(seccomp, container.Name)
+		}
+	}
+	violations = append(violations, violation("hostProcess", hostProcess)...)
+	violations = append(violations, violation("privileged", privileged)...)
+	violations = append(violations, violation("non-default capabilities", capabilities)...)
+
+	var hostPaths []string
+	for _, volume := range pod.Volumes {
+		if volume.HostPath != nil {
+			hostPaths = append(hostPaths, volume.Name)
+		}
+	}
+	violations = append(violations, violation("hostPath volumes", hostPaths)...)
+	violations = append(violations, violation("hostPort", hostPorts)...)
+
+	var appArmor []string
+	for key, value := range template.Annotations {
+		if strings.HasPrefix(key, appArmorAnnotationPrefix) && value != "runtime/default" && !strings.HasPrefix(value, "localhost/") {
+			appArmor = append(appArmor, fmt.Sprintf("%s=%s", strings.TrimPrefix(key, appArmorAnnotationPrefix), value))
+		}
+	}
+	violations = append(violations, violation("forbidden AppArmor profiles", appArmor)...)
+	violations = append(violations, violation("seLinuxOptions", seLinux)...)
+	violations = append(violations, violation("procMount", procMount)...)
+	violations = append(violations, violation("seccompProfile Unconfined", seccomp)...)
+
+	var sysctls []string
+	for _, sysctl := range podContext.Sysctls {
+		if !safeSysctls[sysctl.Name] {
+			sysctls = append(sysctls, sysctl.Name)
+		}
+	}
+	violations = append(violations, violation("forbidden sysctls", sysctls)...)
+	return violations
+}
+
+func seLinuxAllowed(options *corev1.SELinuxOptions) bool {
+	return options == nil || (seLinuxTypes[options.Type] && options.User == "" && options.Role == "")
+}
+
+func checkRestricted(pod *corev1.PodSpec) []string {
+	podContext := pod.SecurityContext
+	if podContext == nil {
+		podContext = &corev1.PodSecurityContext{}
+	}
+	var violations []string
+
+	var volumes []string
+	for _, volume := range pod.Volumes {
+		source := volume.VolumeSource
+		if source.ConfigMap == nil && source.CSI == nil && source.DownwardAPI == nil && source.EmptyDir == nil &&
+			source.Ephemeral == nil && source.PersistentVolumeClaim == nil && source.Projected == nil && source.Secret == nil {
+			volumes = append(volumes, volume.Name)
+		}
+	}
+	violations = append(violations, violation("restricted volume types", volumes)...)
+
+	podNonRoot := podContext.RunAsNonRoot != nil && *podContext.RunAsNonRoot
+	podSeccomp := podContext.SeccompProfile != nil
+	var escalation, nonRoot, rootUser, seccomp, drop, add []string
+	if podContext.RunAsUser != nil && *podContext.RunAsUser == 0 {
+		rootUser = append(rootUser, "pod")
+	}
+	for _, container := range containers(pod) {
+		context := container.SecurityContext
+		if context.AllowPrivilegeEscalation == nil || *context.AllowPrivilegeEscalation {
+			escalation = append(escalation, container.Name)
+		}
+		if context.RunAsNonRoot == nil && !podNonRoot || context.RunAsNonRoot != nil && !*context.RunAsNonRoot {
+			nonRoot = append(nonRoot, container.Name)
+		}
+		if context.RunAsUser != nil && *context.RunAsUser == 0 {
+			rootUser = append(rootUser, container.Name)
+		}
+		if context.SeccompProfile == nil && !podSeccomp {
+			seccomp = append(seccomp, container.Name)
+		}
+
+		dropsAll := false
+		if context.Capabilities != nil {
+			for _, capability := range context.Capabilities.Drop {
+				dropsAll = dropsAll || capability == "ALL"
+			}
+			for _, capability := range context.Capabilities.Add {
+				if capability != "NET_BIND_SERVICE" {
+					add = append(add, fmt.Sprintf("%s adds %s", container.Name, capability))
+				}
+			}
+		}
+		if !dropsAll {
+			drop = append(drop, container.Name)
+		}
+	}
+	violations = append(violations, violation("allowPrivilegeEscalation != false", escalation)...)
+	violations = append(violations, violation("runAsNonRoot != true", nonRoot)...)
+	violations = append(violations, violation("runAsUser=0", rootUser)...)
+	violations = append(violations, violation("seccompProfile not set", seccomp)...)
+	violations = append(violations, violation("capabilities not dropping ALL", drop)...)
+	violations = append(violations, violation("capabilities beyond NET_BIND_SERVICE", add)...)
+	return violations
+}
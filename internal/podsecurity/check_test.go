@@ -0,0 +1,76 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podsecurity
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestCheck(t *testing.T) {
+	nginx := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "nginx"}},
+		},
+	}
+	hardened := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsNonRoot:   pointer.Bool(true),
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			},
+			Containers: []corev1.Container{{
+				Name: "nginx",
+				SecurityContext: &corev1.SecurityContext{
+					AllowPrivilegeEscalation: pointer.Bool(false),
+					Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+				},
+			}},
+		},
+	}
+	hostNetwork := hardened.DeepCopy()
+	hostNetwork.Spec.HostNetwork = true
+	hostNetwork.Spec.Containers[0].Ports = []corev1.ContainerPort{{ContainerPort: 80, HostPort: 80}}
+
+	for _, test := range []struct {
+		name     string
+		level    Level
+		template *corev1.PodTemplateSpec
+		want     []string
+	}{
+		{"privileged allows anything", Privileged, hostNetwork, nil},
+		{"baseline allows root", Baseline, nginx, nil},
+		{"restricted refuses root", Restricted, nginx, []string{
+			"allowPrivilegeEscalation != false (nginx)",
+			"runAsNonRoot != true (nginx)",
+			"seccompProfile not set (nginx)",
+			"capabilities not dropping ALL (nginx)",
+		}},
+		{"restricted allows hardened pods", Restricted, hardened, nil},
+		{"baseline refuses the host network", Baseline, hostNetwork, []string{
+			"host namespaces (hostNetwork=true)",
+			"hostPort (nginx uses 80)",
+		}},
+	} {
+		if got := Check(test.level, test.template); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Check() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route53
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Credentials are AWS access keys, temporary ones having a session token.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// environment caches the credentials the operator gets from its environment.
+var environment struct {
+	mu          sync.Mutex
+	credentials Credentials
+	expiration  time.Time
+}
+
+// EnvironmentCredentials returns the credentials of the operator: the ones of the IAM
+// role of its service account (IRSA) when AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE are
+// set, or else the access keys in AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+func EnvironmentCredentials(ctx context.Context) (Credentials, error) {
+	roleARN, tokenFile := os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	if roleARN == "" || tokenFile == "" {
+		credentials := Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+			return Credentials{}, errors.New("no AWS credentials are configured")
+		}
+		return credentials, nil
+	}
+
+	environment.mu.Lock()
+	defer environment.mu.Unlock()
+	// Credentials are renewed a few minutes before they expire.
+	if time.Until(environment.expiration) > 5*time.Minute {
+		return environment.credentials, nil
+	}
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return Credentials{}, err
+	}
+	credentials, expiration, err := assumeRoleWithWebIdentity(ctx, stsEndpoint(), roleARN, string(token))
+	if err != nil {
+		return Credentials{}, err
+	}
+	environment.credentials, environment.expiration = credentials, expiration
+	return credentials, nil
+}
+
+// stsEndpoint returns the regional STS endpoint when the region is known.
+func stsEndpoint() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return fmt.Sprintf("https://sts.%s.amazonaws.com/", region)
+	}
+	return "https://sts.amazonaws.com/"
+}
+
+// assumeRoleWithWebIdentity exchanges a service account token for temporary credentials
+// of an IAM role. The call is authenticated by the token, so it is not signed.
+func assumeRoleWithWebIdentity(ctx context.Context, endpoint, roleARN, token string) (Credentials, time.Time, error) {
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {"website-operator"},
+		"WebIdentityToken": {token},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return Credentials{}, time.Time{}, err
+	}
+	req.URL.RawQuery = query.Encode()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return Credentials{}, time.Time{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Credentials{}, time.Time{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Credentials{}, time.Time{}, fmt.Errorf("assuming role %s: %s", roleARN, errorMessage(resp.Status, body))
+	}
+
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return Credentials{}, time.Time{}, err
+	}
+	c := result.Credentials
+	return Credentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.SessionToken}, c.Expiration, nil
+}
+
+// errorMessage extracts the code and message of an AWS error response.
+func errorMessage(status string, body []byte) string {
+	var response struct {
+		Code    string `xml:"Error>Code"`
+		Message string `xml:"Error>Message"`
+	}
+	if err := xml.Unmarshal(body, &response); err != nil || response.Code == "" {
+		return status
+	}
+	return fmt.Sprintf("%s: %s: %s", status, response.Code, response.Message)
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package route53 manages the DNS records of a host through the AWS Route 53 API.
+package route53
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultBaseURL is the address of the Route 53 API.
+	DefaultBaseURL = "https://route53.amazonaws.com"
+
+	apiVersion = "2013-04-01"
+	namespace  = "https://route53.amazonaws.com/doc/2013-04-01/"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Client calls the Route 53 API.
+type Client struct {
+	Credentials Credentials
+	// BaseURL of the API. Defaults to DefaultBaseURL.
+	BaseURL string
+}
+
+// RecordSet is a Route 53 record set. Alias record sets have no TTL nor values.
+type RecordSet struct {
+	Name   string       `xml:"Name"`
+	Type   string       `xml:"Type"`
+	TTL    int64        `xml:"TTL,omitempty"`
+	Values []string     `xml:"ResourceRecords>ResourceRecord>Value"`
+	Alias  *AliasTarget `xml:"AliasTarget,omitempty"`
+}
+
+// AliasTarget points an alias record set at an AWS resource, such as a load balancer.
+type AliasTarget struct {
+	HostedZoneID         string `xml:"HostedZoneId"`
+	DNSName              string `xml:"DNSName"`
+	EvaluateTargetHealth bool   `xml:"EvaluateTargetHealth"`
+}
+
+// Change is a change to a record set: an UPSERT or a DELETE.
+type Change struct {
+	Action    string    `xml:"Action"`
+	RecordSet RecordSet `xml:"ResourceRecordSet"`
+}
+
+type changeRequest struct {
+	XMLName xml.Name `xml:"ChangeResourceRecordSetsRequest"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Changes []Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+// do sends a signed request to the API and decodes its response into result.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, result interface{}) error {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	var encoded []byte
+	if body != nil {
+		var err error
+		if encoded, err = xml.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(baseURL, "/")+"/"+apiVersion+path, bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+	req.URL.RawQuery = query.Encode()
+	if body != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+	// Route 53 is a global service signed for us-east-1.
+	sign(req, encoded, c.Credentials, "us-east-1", "route53", time.Now())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", method, path, errorMessage(resp.Status, data))
+	}
+	if result == nil {
+		return nil
+	}
+	return xml.Unmarshal(data, result)
+}
+
+// RecordSets lists the record sets of a name in a hosted zone.
+func (c *Client) RecordSets(ctx context.Context, zoneID, name string) ([]RecordSet, error) {
+	name = fqdn(name)
+	var response struct {
+		RecordSets []RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	}
+	// Record sets are listed in order starting at the name, so the ones of the name come first.
+	query := url.Values{"name": {name}, "maxitems": {"20"}}
+	if err := c.do(ctx, http.MethodGet, "/hostedzone/"+url.PathEscape(zoneID)+"/rrset", query, nil, &response); err != nil {
+		return nil, err
+	}
+	var sets []RecordSet
+	for _, set := range response.RecordSets {
+		if strings.EqualFold(set.Name, name) {
+			sets = append(sets, set)
+		}
+	}
+	return sets, nil
+}
+
+// Sync makes the A, AAAA and CNAME record sets of a name match the desired ones. The name
+// is claimed with a TXT record holding the owner, under a _owner prefix as a CNAME may not
+// share its name. Record sets of a name owned by someone else, or by no one, are not touched.
+func (c *Client) Sync(ctx context.Context, zoneID, name, owner string, desired []RecordSet) error {
+	changes, err := c.Plan(ctx, zoneID, name, owner, desired)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	request := changeRequest{Xmlns: namespace, Changes: changes}
+	return c.do(ctx, http.MethodPost, "/hostedzone/"+url.PathEscape(zoneID)+"/rrset/", nil, request, nil)
+}
+
+// Plan returns the changes Sync sends to make the record sets of a name match the desired
+// ones, reading the record sets but writing nothing.
+func (c *Client) Plan(ctx context.Context, zoneID, name, owner string, desired []RecordSet) ([]Change, error) {
+	name = fqdn(name)
+	ownerName := "_owner." + name
+	ownerValue := fmt.Sprintf("%q", "heritage=website-operator,owner="+owner)
+
+	ownerSets, err := c.RecordSets(ctx, zoneID, ownerName)
+	if err != nil {
+		return nil, err
+	}
+	var ownerSet *RecordSet
+	for i := range ownerSets {
+		if ownerSets[i].Type == "TXT" {
+			ownerSet = &ownerSets[i]
+		}
+	}
+	if ownerSet != nil && !reflect.DeepEqual(ownerSet.Values, []string{ownerValue}) {
+		return nil, fmt.Errorf("%s is owned by %s", name, strings.Join(ownerSet.Values, ", "))
+	}
+
+	existing, err := c.RecordSets(ctx, zoneID, name)
+	if err != nil {
+		return nil, err
+	}
+	current := map[string]RecordSet{}
+	for _, set := range existing {
+		switch set.Type {
+		case "A", "AAAA", "CNAME":
+			current[set.Type] = set
+		}
+	}
+	if ownerSet == nil && len(current) > 0 {
+		if len(desired) == 0 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s already has records not managed by the operator", name)
+	}
+
+	var deletes, upserts []Change
+	wanted := map[string]bool{}
+	for _, set := range desired {
+		set.Name = name
+		wanted[set.Type] = true
+		if existing, ok := current[set.Type]; !ok || !sameRecordSet(existing, set) {
+			upserts = append(upserts, Change{Action: "UPSERT", RecordSet: set})
+		}
+	}
+	for recordType, set := range current {
+		if !wanted[recordType] {
+			deletes = append(deletes, Change{Action: "DELETE", RecordSet: set})
+		}
+	}
+	switch {
+	case len(desired) > 0 && ownerSet == nil:
+		upserts = append(upserts, Change{Action: "UPSERT", RecordSet: RecordSet{Name: ownerName, Type: "TXT", TTL: 300, Values: []string{ownerValue}}})
+	case len(desired) == 0 && ownerSet != nil:
+		deletes = append(deletes, Change{Action: "DELETE", RecordSet: *ownerSet})
+	}
+	// Deletions come first, so that a CNAME can replace A records within the same batch.
+	return append(deletes, upserts...), nil
+}
+
+// sameRecordSet reports whether two record sets of the same name and type are equal,
+// ignoring the order of values and the case and trailing dot of alias targets, which
+// Route 53 normalizes.
+func sameRecordSet(a, b RecordSet) bool {
+	if (a.Alias == nil) != (b.Alias == nil) {
+		return false
+	}
+	if a.Alias != nil {
+		return a.Alias.HostedZoneID == b.Alias.HostedZoneID && a.Alias.EvaluateTargetHealth == b.Alias.EvaluateTargetHealth &&
+			strings.EqualFold(fqdn(a.Alias.DNSName), fqdn(b.Alias.DNSName))
+	}
+	return a.TTL == b.TTL && reflect.DeepEqual(sorted(a.Values), sorted(b.Values))
+}
+
+func sorted(values []string) []string {
+	values = append([]string{}, values...)
+	sort.Strings(values)
+	return values
+}
+
+// fqdn returns a name with its trailing dot.
+func fqdn(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
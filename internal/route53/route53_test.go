@@ -0,0 +1,130 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route53
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeAPI serves record sets from memory and records the changes sent to it.
+type fakeAPI struct {
+	sets    []RecordSet
+	changes []Change
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if r.Method == http.MethodPost {
+		body, _ := io.ReadAll(r.Body)
+		var request changeRequest
+		_ = xml.Unmarshal(body, &request)
+		f.changes = append(f.changes, request.Changes...)
+		_, _ = io.WriteString(w, `<ChangeResourceRecordSetsResponse/>`)
+		return
+	}
+	var sets []RecordSet
+	for _, set := range f.sets {
+		if set.Name >= r.URL.Query().Get("name") {
+			sets = append(sets, set)
+		}
+	}
+	response := struct {
+		XMLName xml.Name    `xml:"ListResourceRecordSetsResponse"`
+		Sets    []RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	}{Sets: sets}
+	_ = xml.NewEncoder(w).Encode(response)
+}
+
+func TestSync(t *testing.T) {
+	owner := `"heritage=website-operator,owner=default/shop"`
+	alias := RecordSet{Type: "A", Alias: &AliasTarget{HostedZoneID: "Z35SXDOTRQ7X7K", DNSName: "lb.elb.amazonaws.com", EvaluateTargetHealth: true}}
+
+	for _, tc := range []struct {
+		name    string
+		sets    []RecordSet
+		desired []RecordSet
+		want    []string
+		wantErr bool
+	}{{
+		name:    "claims a new name",
+		desired: []RecordSet{alias},
+		want:    []string{"UPSERT A shop.example.com.", "UPSERT TXT _owner.shop.example.com."},
+	}, {
+		name: "replaces records it owns",
+		sets: []RecordSet{
+			{Name: "_owner.shop.example.com.", Type: "TXT", TTL: 300, Values: []string{owner}},
+			{Name: "shop.example.com.", Type: "CNAME", TTL: 300, Values: []string{"old.example.com"}},
+		},
+		desired: []RecordSet{alias},
+		want:    []string{"DELETE CNAME shop.example.com.", "UPSERT A shop.example.com."},
+	}, {
+		name: "leaves records in sync alone",
+		sets: []RecordSet{
+			{Name: "_owner.shop.example.com.", Type: "TXT", TTL: 300, Values: []string{owner}},
+			{Name: "shop.example.com.", Type: "A", Alias: &AliasTarget{HostedZoneID: "Z35SXDOTRQ7X7K", DNSName: "LB.elb.amazonaws.com.", EvaluateTargetHealth: true}},
+		},
+		desired: []RecordSet{alias},
+	}, {
+		name: "removes the records and the claim",
+		sets: []RecordSet{
+			{Name: "_owner.shop.example.com.", Type: "TXT", TTL: 300, Values: []string{owner}},
+			{Name: "shop.example.com.", Type: "A", TTL: 60, Values: []string{"192.0.2.1"}},
+		},
+		want: []string{"DELETE A shop.example.com.", "DELETE TXT _owner.shop.example.com."},
+	}, {
+		name:    "refuses records it does not own",
+		sets:    []RecordSet{{Name: "shop.example.com.", Type: "A", TTL: 60, Values: []string{"192.0.2.1"}}},
+		desired: []RecordSet{alias},
+		wantErr: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			api := &fakeAPI{sets: tc.sets}
+			server := httptest.NewServer(api)
+			defer server.Close()
+			c := &Client{Credentials: Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}, BaseURL: server.URL}
+
+			planned, err := c.Plan(context.Background(), "Z1", "shop.example.com", "default/shop", tc.desired)
+			if (err != nil) != tc.wantErr || len(api.changes) > 0 {
+				t.Fatalf("Plan() = %v and sent %d changes, want error %t and none sent", err, len(api.changes), tc.wantErr)
+			}
+			if len(planned) != len(tc.want) {
+				t.Errorf("Plan() = %d changes, want %d", len(planned), len(tc.want))
+			}
+
+			err = c.Sync(context.Background(), "Z1", "shop.example.com", "default/shop", tc.desired)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Sync() = %v, want error %t", err, tc.wantErr)
+			}
+			var got []string
+			for _, change := range api.changes {
+				got = append(got, change.Action+" "+change.RecordSet.Type+" "+change.RecordSet.Name)
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("changes = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
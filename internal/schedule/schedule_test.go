@@ -0,0 +1,67 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 23, 30, 0, 0, time.UTC) // a Wednesday
+	for spec, want := range map[string]time.Time{
+		"*/15 * * * *":    time.Date(2024, time.January, 31, 23, 45, 0, 0, time.UTC),
+		"0 2 * * *":       time.Date(2024, time.February, 1, 2, 0, 0, 0, time.UTC),
+		"0 22 * * 1-5":    time.Date(2024, time.February, 1, 22, 0, 0, 0, time.UTC),
+		"0 6 * * 0":       time.Date(2024, time.February, 4, 6, 0, 0, 0, time.UTC),
+		"0 6 * * 7":       time.Date(2024, time.February, 4, 6, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":      time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"30 1 15 * 6":     time.Date(2024, time.February, 3, 1, 30, 0, 0, time.UTC),
+		"0 0 30 2 *":      {},
+		"5,10 9-10 * * *": time.Date(2024, time.February, 1, 9, 5, 0, 0, time.UTC),
+	} {
+		schedule, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", spec, err)
+		}
+		if got := schedule.Next(from); !got.Equal(want) {
+			t.Errorf("next of %q: expected %v, got %v", spec, want, got)
+		}
+	}
+}
+
+func TestLast(t *testing.T) {
+	schedule, err := Parse("0 22 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, time.February, 1, 1, 15, 0, 0, time.UTC)
+	if got, want := schedule.Last(now, 4*time.Hour), time.Date(2024, time.January, 31, 22, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := schedule.Last(now, time.Hour); !got.IsZero() {
+		t.Errorf("expected no time within an hour, got %v", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package semver parses semantic versions and the constraints image tags are selected with.
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version. Build metadata is ignored.
+type Version struct {
+	Major, Minor, Patch uint64
+	// Prerelease is the part after the hyphen, such as rc.1
+	Prerelease string
+}
+
+// Parse parses a full semantic version such as 1.4.2 or v1.4.2-rc.1.
+func Parse(s string) (Version, error) {
+	v, n, err := parsePartial(s)
+	if err != nil {
+		return Version{}, err
+	}
+	if n != 3 {
+		return Version{}, fmt.Errorf("%q is not a full version", s)
+	}
+	return v, nil
+}
+
+// parsePartial parses a version some trailing parts of which may be missing or wildcards,
+// such as 1.4 or 1.x, and returns how many parts it has.
+func parsePartial(s string) (Version, int, error) {
+	var v Version
+	rest := strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		rest = rest[:i]
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		rest, v.Prerelease = rest[:i], rest[i+1:]
+		if v.Prerelease == "" {
+			return Version{}, 0, fmt.Errorf("invalid version %q", s)
+		}
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) > 3 {
+		return Version{}, 0, fmt.Errorf("invalid version %q", s)
+	}
+	numbers := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			if v.Prerelease != "" || i != len(parts)-1 && parts[i+1] != "x" && parts[i+1] != "X" && parts[i+1] != "*" {
+				return Version{}, 0, fmt.Errorf("invalid version %q", s)
+			}
+			return v, i, nil
+		}
+		number, err := strconv.ParseUint(part, 10, 64)
+		if err != nil || len(part) > 1 && part[0] == '0' {
+			return Version{}, 0, fmt.Errorf("invalid version %q", s)
+		}
+		*numbers[i] = number
+	}
+	if v.Prerelease != "" && len(parts) != 3 {
+		return Version{}, 0, fmt.Errorf("invalid version %q", s)
+	}
+	return v, len(parts), nil
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is older than, the same as or newer
+// than o. Pre-releases come before the release they precede.
+func (v Version) Compare(o Version) int {
+	for _, pair := range [][2]uint64{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case v.Prerelease == o.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case o.Prerelease == "":
+		return -1
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+// comparePrerelease compares dot separated pre-release identifiers: numeric ones
+// numerically and before alphanumeric ones, which are compared lexically.
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// Constraint is a set of version ranges, such as ~1.4, ^2, >=1.2 <1.8 or 1.x || 2.x.
+// Comparisons separated by spaces or commas must all hold, || separates alternatives.
+type Constraint struct {
+	alternatives [][]bound
+}
+
+// bound is a range of versions, from min included to max excluded. A nil end is open.
+type bound struct {
+	min, max *Version
+}
+
+// ParseConstraint parses a version constraint. The operators are =, >, >=, <, <=,
+// ~ (patch updates, or minor ones when only the major version is given) and ^ (updates
+// not changing the leftmost non-zero part). Versions may be partial or end in x.
+func ParseConstraint(s string) (Constraint, error) {
+	var c Constraint
+	for _, alternative := range strings.Split(s, "||") {
+		var bounds []bound
+		for _, comparison := range strings.FieldsFunc(alternative, func(r rune) bool { return r == ' ' || r == ',' }) {
+			b, err := parseComparison(comparison)
+			if err != nil {
+				return Constraint{}, err
+			}
+			bounds = append(bounds, b)
+		}
+		if len(bounds) == 0 {
+			return Constraint{}, fmt.Errorf("empty constraint in %q", s)
+		}
+		c.alternatives = append(c.alternatives, bounds)
+	}
+	return c, nil
+}
+
+func parseComparison(s string) (bound, error) {
+	op := s[:len(s)-len(strings.TrimLeft(s, "=<>~^"))]
+	v, n, err := parsePartial(s[len(op):])
+	if err != nil {
+		return bound{}, err
+	}
+
+	// next returns the first version after the ones matching the first parts of v.
+	next := func(parts int) *Version {
+		switch parts {
+		case 0:
+			return nil
+		case 1:
+			return &Version{Major: v.Major + 1}
+		case 2:
+			return &Version{Major: v.Major, Minor: v.Minor + 1}
+		}
+		return &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+	low := v
+	switch op {
+	case "", "=":
+		if v.Prerelease != "" {
+			// rc.1.0 is the first pre-release after rc.1.
+			high := v
+			high.Prerelease += ".0"
+			return bound{min: &low, max: &high}, nil
+		}
+		return bound{min: &low, max: next(n)}, nil
+	case ">=":
+		return bound{min: &low}, nil
+	case ">":
+		if n == 0 {
+			return bound{}, fmt.Errorf("invalid constraint %q", s)
+		}
+		return bound{min: next(n)}, nil
+	case "<":
+		return bound{max: &low}, nil
+	case "<=":
+		return bound{max: next(n)}, nil
+	case "~":
+		if n < 2 {
+			return bound{min: &low, max: next(n)}, nil
+		}
+		return bound{min: &low, max: next(2)}, nil
+	case "^":
+		switch {
+		case n == 0 || v.Major > 0:
+			return bound{min: &low, max: next(min(n, 1))}, nil
+		case n == 1 || v.Minor > 0:
+			return bound{min: &low, max: next(min(n, 2))}, nil
+		}
+		return bound{min: &low, max: next(3)}, nil
+	}
+	return bound{}, fmt.Errorf("invalid operator %q in %q", op, s)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Check reports whether a version satisfies the constraint. Pre-releases only satisfy it
+// when they are within a range an end of which is a pre-release of the same version, so
+// that ~1.4 never selects 1.5.0-rc.1.
+func (c Constraint) Check(v Version) bool {
+	for _, bounds := range c.alternatives {
+		if satisfies(v, bounds) {
+			return true
+		}
+	}
+	return false
+}
+
+func satisfies(v Version, bounds []bound) bool {
+	prereleaseAllowed := v.Prerelease == ""
+	for _, b := range bounds {
+		if b.min != nil && v.Compare(*b.min) < 0 || b.max != nil && v.Compare(*b.max) >= 0 {
+			return false
+		}
+		for _, end := range []*Version{b.min, b.max} {
+			if end != nil && end.Prerelease != "" && end.Major == v.Major && end.Minor == v.Minor && end.Patch == v.Patch {
+				prereleaseAllowed = true
+			}
+		}
+	}
+	return prereleaseAllowed
+}
+
+// Latest returns the newest of the given tags satisfying the constraint. Tags that are
+// not semantic versions are skipped.
+func (c Constraint) Latest(tags []string) (string, bool) {
+	var latest string
+	var latestVersion Version
+	for _, tag := range tags {
+		v, err := Parse(tag)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if latest == "" || v.Compare(latestVersion) > 0 {
+			latest, latestVersion = tag, v
+		}
+	}
+	return latest, latest != ""
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package semver
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	ordered := []string{"0.9.12", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.2.0", "1.10.0", "2.0.0"}
+	for i := 1; i < len(ordered); i++ {
+		a, err := Parse(ordered[i-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Parse(ordered[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Compare(b) != -1 || b.Compare(a) != 1 || a.Compare(a) != 0 {
+			t.Errorf("expected %s < %s", a, b)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{"1.4", "1.4.2.1", "01.4.2", "1.4.x", "1.4.2-", "latest"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+	v, err := Parse("v1.4.2-rc.1+build.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Version{Major: 1, Minor: 4, Patch: 2, Prerelease: "rc.1"}); v != want {
+		t.Errorf("expected %v, got %v", want, v)
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	for constraint, cases := range map[string]map[string]bool{
+		"~1.4":          {"1.4.0": true, "1.4.9": true, "1.5.0": false, "1.3.9": false, "1.5.0-rc.1": false},
+		"~1.4.2":        {"1.4.1": false, "1.4.2": true, "1.4.7": true, "1.5.0": false},
+		"~1":            {"1.0.0": true, "1.9.0": true, "2.0.0": false},
+		"^1.4":          {"1.4.0": true, "1.9.3": true, "2.0.0": false, "1.3.0": false},
+		"^0.4.1":        {"0.4.1": true, "0.4.8": true, "0.5.0": false},
+		"^0.0.3":        {"0.0.3": true, "0.0.4": false},
+		"1.4.x":         {"1.4.0": true, "1.4.3": true, "1.5.0": false},
+		"1.4.2":         {"1.4.2": true, "1.4.3": false, "1.4.3-rc.1": false},
+		">=1.2 <1.8":    {"1.1.9": false, "1.2.0": true, "1.7.9": true, "1.8.0": false},
+		">1.4":          {"1.4.9": false, "1.5.0": true},
+		"<=1.4":         {"1.4.9": true, "1.5.0": false},
+		"1.x || >=3.1":  {"1.2.0": true, "2.0.0": false, "3.1.0": true},
+		">=2.0.0-rc.1":  {"2.0.0-rc.1": true, "2.0.0-rc.2": true, "2.1.0-rc.1": false, "2.1.0": true},
+		"=1.0.0-beta.2": {"1.0.0-beta.2": true, "1.0.0-beta.3": false, "1.0.0": false},
+		"*":             {"0.0.1": true, "9.0.0": true},
+	} {
+		c, err := ParseConstraint(constraint)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", constraint, err)
+		}
+		for version, want := range cases {
+			v, err := Parse(version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Check(v); got != want {
+				t.Errorf("%q checking %s: expected %v, got %v", constraint, version, want, got)
+			}
+		}
+	}
+
+	for _, constraint := range []string{"", "~", ">x", "=>1.2", "1.x.3", "1.4 ||"} {
+		if _, err := ParseConstraint(constraint); err == nil {
+			t.Errorf("expected %q to be rejected", constraint)
+		}
+	}
+}
+
+func TestLatest(t *testing.T) {
+	c, err := ParseConstraint("~1.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := []string{"latest", "1.3.9", "v1.4.2", "1.4.10", "1.4.11-rc.1", "1.5.0", "1.4"}
+	if got, ok := c.Latest(tags); !ok || got != "1.4.10" {
+		t.Errorf("expected 1.4.10, got %q", got)
+	}
+	if _, ok := c.Latest([]string{"1.5.0"}); ok {
+		t.Error("expected no tag to satisfy the constraint")
+	}
+}
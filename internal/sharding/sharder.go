@@ -0,0 +1,209 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sharding splits Websites between several active operator replicas.
+//
+// Every replica keeps a membership Lease alive in the operator namespace. The live
+// Leases form the member set, and each Website is owned by the member that wins a
+// rendezvous (highest random weight) hash of its namespace/name. Rendezvous hashing is a
+// consistent hash: when a replica joins or leaves, only the Websites it gains or loses
+// change hands.
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// memberLabel marks the Leases that make up the shard member set.
+const memberLabel = "dev.mvasilenko.me/shard-member"
+
+// Sharder maintains this replica's membership Lease and decides which Websites it owns.
+type Sharder struct {
+	// Client is used to write the membership Lease.
+	Client client.Client
+	// Reader is used to list membership Leases. It should read from the API server
+	// directly, so that the manager does not start a cluster-wide Lease informer.
+	Reader client.Reader
+	// Namespace holds the membership Leases, usually the operator namespace.
+	Namespace string
+	// Identity uniquely names this replica, usually its pod name.
+	Identity string
+	// LeaseDuration is how long a member is considered alive after its last renewal.
+	LeaseDuration time.Duration
+	// RenewInterval is how often the Lease is renewed and the member set refreshed.
+	RenewInterval time.Duration
+
+	mu      sync.RWMutex
+	members []string
+	changes chan struct{}
+	once    sync.Once
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable: every replica takes
+// part in sharding, not only a leader.
+func (s *Sharder) NeedLeaderElection() bool {
+	return false
+}
+
+// Changes is signalled whenever the member set changes, and with it the Websites this
+// replica owns.
+func (s *Sharder) Changes() <-chan struct{} {
+	s.once.Do(func() { s.changes = make(chan struct{}, 1) })
+	return s.changes
+}
+
+// Owns reports whether this replica is responsible for the given Website. Until the
+// first member set has been observed no Website is owned.
+func (s *Sharder) Owns(key types.NamespacedName) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return owner(s.members, key.String()) == s.Identity
+}
+
+// Start implements manager.Runnable. It renews the membership Lease and refreshes the
+// member set until the context is cancelled, then releases the Lease so the remaining
+// replicas take over immediately.
+func (s *Sharder) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("sharding").WithValues("identity", s.Identity)
+	s.Changes()
+
+	ticker := time.NewTicker(s.RenewInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.renew(ctx); err != nil {
+			log.Error(err, "Failed to renew shard membership")
+		} else if members, err := s.liveMembers(ctx); err != nil {
+			log.Error(err, "Failed to list shard members")
+		} else {
+			s.setMembers(members, func() { log.Info("Shard members changed", "members", members) })
+		}
+
+		select {
+		case <-ctx.Done():
+			lease := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: s.leaseName(), Namespace: s.Namespace}}
+			if err := s.Client.Delete(context.Background(), lease); err != nil && !errors.IsNotFound(err) {
+				log.Error(err, "Failed to release shard membership")
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Sharder) leaseName() string {
+	return fmt.Sprintf("website-operator-shard-%s", s.Identity)
+}
+
+// renew creates this replica's membership Lease, or bumps its renew time.
+func (s *Sharder) renew(ctx context.Context) error {
+	now := metav1.NewMicroTime(time.Now())
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      s.leaseName(),
+			Namespace: s.Namespace,
+			Labels:    map[string]string{memberLabel: "true"},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       pointer.String(s.Identity),
+			LeaseDurationSeconds: pointer.Int32(int32(s.LeaseDuration.Seconds())),
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		},
+	}
+	err := s.Client.Create(ctx, lease)
+	if !errors.IsAlreadyExists(err) {
+		return err
+	}
+
+	current := coordinationv1.Lease{}
+	if err := s.Reader.Get(ctx, client.ObjectKeyFromObject(lease), &current); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(current.DeepCopy())
+	current.Spec.RenewTime = &now
+	current.Spec.LeaseDurationSeconds = lease.Spec.LeaseDurationSeconds
+	return s.Client.Patch(ctx, &current, patch)
+}
+
+// liveMembers returns the identities of every replica whose Lease has not expired.
+func (s *Sharder) liveMembers(ctx context.Context) ([]string, error) {
+	leases := coordinationv1.LeaseList{}
+	if err := s.Reader.List(ctx, &leases, client.InNamespace(s.Namespace), client.MatchingLabels{memberLabel: "true"}); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	members := []string{}
+	for _, lease := range leases.Items {
+		spec := lease.Spec
+		if spec.HolderIdentity == nil || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+			continue
+		}
+		expiry := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+		if expiry.After(now) {
+			members = append(members, *spec.HolderIdentity)
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+func (s *Sharder) setMembers(members []string, onChange func()) {
+	s.mu.Lock()
+	changed := !reflect.DeepEqual(s.members, members)
+	s.members = members
+	s.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	onChange()
+	select {
+	case s.changes <- struct{}{}:
+	default:
+		// A change notification is already pending.
+	}
+}
+
+// owner picks the member with the highest hash for the given key.
+func owner(members []string, key string) string {
+	var winner string
+	var best uint64
+	for _, member := range members {
+		h := fnv.New64a()
+		h.Write([]byte(member))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		if score := h.Sum64(); winner == "" || score > best {
+			winner, best = member, score
+		}
+	}
+	return winner
+}
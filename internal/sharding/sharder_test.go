@@ -0,0 +1,41 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharding
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestOwnerOnlyMovesKeysOfDepartedMember(t *testing.T) {
+	before := []string{"a", "b", "c"}
+	after := []string{"a", "c"}
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("default/website-%d", i)
+		previous, current := owner(before, key), owner(after, key)
+		if previous != "b" && previous != current {
+			t.Errorf("key %q moved from %q to %q although its owner is still a member", key, previous, current)
+		}
+	}
+}
+
+func TestOwnerWithoutMembers(t *testing.T) {
+	if got := owner(nil, "default/website"); got != "" {
+		t.Errorf("expected no owner, got %q", got)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package trigger serves the endpoint CI systems and GitHub webhooks call to redeploy
+// websites.
+package trigger
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	devv1 "github.com/mvasilenko/helloworld-operator/api/v1"
+)
+
+const (
+	pathPrefix = "/trigger/"
+
+	// maxBodySize limits the request bodies read, GitHub push payloads included.
+	maxBodySize = 1 << 20
+)
+
+// Request is the body of a trigger request. Without an image tag, the website pods are
+// restarted to resync their content.
+type Request struct {
+	// ImageTag the website is moved to
+	ImageTag string `json:"imageTag,omitempty"`
+	// By identifies who triggers the redeploy, e.g. a CI job URL
+	By string `json:"by,omitempty"`
+}
+
+// githubEvent holds the fields of a GitHub webhook payload the server uses.
+type githubEvent struct {
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// Server accepts POST requests on /trigger/<namespace>/<name>. Callers authenticate
+// either with the token as a bearer token, or, for GitHub webhooks, by signing the
+// payload with the token as webhook secret. GitHub deliveries restart the website pods.
+type Server struct {
+	// Addr is the address the trigger endpoint binds to.
+	Addr string
+	// Client updates the triggered Websites.
+	Client client.Client
+	// Token authenticates callers.
+	Token string
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Triggers only write to
+// the API server, so every replica may serve them.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable and serves until the context is cancelled.
+func (s *Server) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("trigger")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(pathPrefix, s.serveTrigger)
+
+	srv := &http.Server{
+		Addr:              s.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error(err, "Failed to shut down trigger server")
+		}
+	}()
+
+	log.Info("Starting trigger server", "addr", s.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) serveTrigger(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, pathPrefix), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, "expected /trigger/<namespace>/<name>", http.StatusNotFound)
+		return
+	}
+	name := types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	var req Request
+	if signature := r.Header.Get("X-Hub-Signature-256"); signature != "" {
+		if !s.validSignature(body, signature) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		var event githubEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			http.Error(w, "invalid GitHub payload", http.StatusBadRequest)
+			return
+		}
+		req.By = "github:" + event.Sender.Login
+	} else {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &req); err != nil {
+				http.Error(w, "invalid request", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	if err := s.trigger(r.Context(), name, req); err != nil {
+		ctrl.Log.WithName("trigger").Error(err, "Failed to trigger website", "website", name.Name, "namespace", name.Namespace)
+		status := http.StatusInternalServerError
+		if apiStatus, ok := err.(apierrors.APIStatus); ok {
+			status = int(apiStatus.Status().Code)
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// validSignature checks the GitHub HMAC signature of a payload.
+func (s *Server) validSignature(body []byte, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(s.Token))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// trigger moves a website to a new image tag, or restarts its pods, and records the
+// trigger in its status.
+func (s *Server) trigger(ctx context.Context, name types.NamespacedName, req Request) error {
+	website := devv1.Website{}
+	if err := s.Client.Get(ctx, name, &website); err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	patch := client.MergeFrom(website.DeepCopy())
+	if req.ImageTag != "" {
+		website.Spec.ImageTag = req.ImageTag
+	} else {
+		if website.Annotations == nil {
+			website.Annotations = map[string]string{}
+		}
+		website.Annotations[devv1.RedeployAnnotation] = now.UTC().Format(time.RFC3339)
+	}
+	if err := s.Client.Patch(ctx, &website, patch); err != nil {
+		return err
+	}
+
+	ctrl.Log.WithName("trigger").Info("Website triggered", "website", name.Name, "namespace", name.Namespace,
+		"by", req.By, "imageTag", req.ImageTag)
+	patch = client.MergeFrom(website.DeepCopy())
+	website.Status.LastTrigger = &devv1.TriggerStatus{By: req.By, At: now, ImageTag: req.ImageTag}
+	return s.Client.Status().Patch(ctx, &website, patch)
+}